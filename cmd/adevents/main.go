@@ -0,0 +1,142 @@
+// adevents работает с журналом событий объявлений, который сервер пишет при AD_EVENT_STORE:
+//
+//	adevents -store ads.jsonl check         проверяет журнал и печатает размер проекций, построенных из него
+//	adevents -store ads.jsonl history <id>  печатает события объявления
+//	adevents -server <url> -requester <id> [-cacert cert.pem] rebuild
+//	                                        перестраивает проекции работающего сервера от имени администратора
+//
+// Проекции живут в памяти сервера, поэтому check и history их не меняют, а rebuild просит об этом сервер
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"homework10/internal/adapters/repository/eventrepo"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	path := flag.String("store", os.Getenv("AD_EVENT_STORE"), "path to the ad event store")
+	server := flag.String("server", "https://localhost:3333", "base URL of the running server for rebuild")
+	requester := flag.Int64("requester", -1, "id of the admin on whose behalf rebuild is requested")
+	caCert := flag.String("cacert", "", "PEM certificate to trust for the server, e.g. its self-signed pCertFile")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	if flag.Arg(0) == "rebuild" {
+		report, err := rebuild(*server, *requester, *caCert)
+		if err != nil {
+			fail(err)
+		}
+		if err = encoder.Encode(report); err != nil {
+			fail(err)
+		}
+		return
+	}
+
+	if *path == "" {
+		usage()
+	}
+	store, err := eventrepo.OpenFileStore(*path)
+	if err != nil {
+		fail(err)
+	}
+	defer store.Close()
+	// New сразу строит проекции из журнала, поэтому битый журнал обнаружится здесь
+	repo, err := eventrepo.New(store)
+	if err != nil {
+		fail(err)
+	}
+
+	switch flag.Arg(0) {
+	case "check":
+		report, err := repo.Rebuild()
+		if err != nil {
+			fail(err)
+		}
+		err = encoder.Encode(report)
+		if err != nil {
+			fail(err)
+		}
+	case "history":
+		adID, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil {
+			usage()
+		}
+		history, err := repo.History(adID)
+		if err != nil {
+			fail(err)
+		}
+		for _, e := range history {
+			if err = encoder.Encode(e); err != nil {
+				fail(err)
+			}
+		}
+	default:
+		usage()
+	}
+}
+
+// rebuild вызывает POST /api/v1/admin/projections/rebuild и возвращает отчет сервера
+func rebuild(server string, requester int64, caCert string) (json.RawMessage, error) {
+	body, err := json.Marshal(map[string]int64{"requester_id": requester})
+	if err != nil {
+		return nil, err
+	}
+	client := http.Client{Timeout: time.Minute}
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates", caCert)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots}}
+	}
+	resp, err := client.Post(strings.TrimSuffix(server, "/")+"/api/v1/admin/projections/rebuild",
+		"application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Data  json.RawMessage `json:"data"`
+		Error *string         `json:"error"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, fmt.Errorf("rebuild: %s: %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message := resp.Status
+		if envelope.Error != nil {
+			message += ": " + *envelope.Error
+		}
+		return nil, fmt.Errorf("rebuild: %s", message)
+	}
+	return envelope.Data, nil
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: adevents -store <file> check | history <ad_id>")
+	fmt.Fprintln(os.Stderr, "       adevents -server <url> -requester <admin_id> [-cacert <file>] rebuild")
+	os.Exit(2)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
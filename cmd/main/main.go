@@ -5,16 +5,26 @@ import (
 	"flag"
 	"fmt"
 	"golang.org/x/sync/errgroup"
+	"homework10/internal/adapters/mailer"
 	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/eventrepo"
 	"homework10/internal/adapters/repository/userrepo"
 	"homework10/internal/app"
+	"homework10/internal/events"
+	"homework10/internal/idempotency"
+	"homework10/internal/outbox"
+	"homework10/internal/ports/graphql"
 	"homework10/internal/ports/grpc"
 	"homework10/internal/ports/httpgin"
+	"homework10/internal/quota"
+	"homework10/internal/ratelimit"
 	"homework10/internal/util"
+	"homework10/internal/webhook"
 	"log"
 	"os"
 	"os/signal"
 	"strconv"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -35,22 +45,96 @@ func main() {
 
 	flag.Parse()
 	fmt.Println(PORT_REST)
-	repo := adrepo.New()
-	uRep := userrepo.New()
+	// репозитории пишут события в outbox под той же блокировкой, что и изменения
+	store, pingOutbox, closeOutbox, err := newOutboxStore()
+	if err != nil {
+		log.Fatalf("can't open outbox: %v", err)
+	}
+	defer closeOutbox()
+	repo, pingRepo, closeRepo, err := newAdRepository(store)
+	if err != nil {
+		log.Fatalf("can't open ad repository: %v", err)
+	}
+	defer closeRepo()
+	uRep := userrepo.New(userrepo.WithRecorder(store))
 	formatter := util.NewDateTimeFormatter(time.RFC3339)
-	newApp := app.NewApp(repo, uRep, formatter)
 	signals := append([]os.Signal{}, os.Interrupt, os.Kill, syscall.SIGINT, syscall.SIGTERM)
 
 	httpLogger := log.New(os.Stdout, "[HTTP] ", 0)
 	rpcLogger := log.New(os.Stdout, "[gRPC] ", 0)
 	sysLogger := log.New(os.Stdout, "[SYSTEM] ", log.Ldate|log.Ltime)
+	mailLogger := log.New(os.Stdout, "[MAIL] ", log.Ldate|log.Ltime)
+
+	eventLogger := log.New(os.Stdout, "[EVENTS] ", log.Ldate|log.Ltime)
+	bus := events.NewBus(events.WithFailureHandler(func(f events.Failure) {
+		eventLogger.Printf("%s: subscriber %q failed after %d attempts: %v\n", f.Event.Name(), f.Subscriber, f.Attempts, f.Err)
+	}))
+
+	// релей рассылает записи outbox партнерам, подписанным на webhooks
+	hooks := webhook.NewStore()
+	dispatcher := webhook.NewDispatcher(hooks)
+	outboxLogger := log.New(os.Stdout, "[OUTBOX] ", log.Ldate|log.Ltime)
+	relay := outbox.NewRelay(store, outbox.Deduplicate(10000, func(ctx context.Context, entry outbox.Entry) error {
+		if err := dispatcher.Handle(ctx, entry); err != nil {
+			return err
+		}
+		outboxLogger.Printf("delivered %s %s\n", entry.Event, entry.ID)
+		return nil
+	}))
+
+	opts := []app.Option{
+		app.WithVerificationSender(mailer.NewLogSender(mailLogger)),
+		app.WithEventBus(bus),
+		app.WithOutbox(store),
+		app.WithWebhooks(hooks),
+		// бесплатный тариф ограничивает число объявлений, администраторы без лимитов
+		app.WithQuotas(quota.DefaultPolicy),
+	}
+	// без VERIFY_SECRET токены подписываются случайным ключом и не переживают перезапуск
+	if secret, ok := os.LookupEnv("VERIFY_SECRET"); ok {
+		opts = append(opts, app.WithTokenSigner(util.NewHMACTokenSigner([]byte(secret))))
+	}
+	// ADMIN_IDS - id администраторов через запятую, им доступны персональные данные всех пользователей
+	if admins, ok := os.LookupEnv("ADMIN_IDS"); ok {
+		opts = append(opts, app.WithAdmins(parseIDs(admins, sysLogger)...))
+	}
+	newApp := app.NewApp(repo, uRep, formatter, opts...)
 
 	g, ctx := errgroup.WithContext(context.Background())
 	sigQuit := make(chan os.Signal, 1)
 	signal.Notify(sigQuit, signals...)
 
-	hServer := httpgin.NewHTTPServer(PORT_REST, newApp, httpLogger, *cert, *key)
-	gServer := grpc.NewServer(rpcLogger, newApp)
+	g.Go(func() error {
+		return relay.Run(ctx)
+	})
+
+	// Idempotency-Key действует в пределах маршрута, адрес клиента не учитывается: gRPC и /api/v2 вызывают одни методы AdService
+	// и повторяют ответы друг друга, у /api/v1 и мутаций /graphql ключи свои
+	idempotent := newIdempotencyStore(sysLogger)
+	// одно хранилище ведер на все серверы: общие лимиты создания считаются вместе по всем транспортам
+	limiter := ratelimit.NewLimiter()
+	// /api/v2 - тот же AdService по HTTP/JSON, маршруты берутся из service.proto
+	gateway, err := grpc.NewGateway(newApp, grpc.WithIdempotencyStore(idempotent),
+		grpc.WithRateLimits(limiter, grpc.DefaultRateLimits))
+	if err != nil {
+		log.Fatalf("can't create HTTP gateway: %v", err)
+	}
+	graphQL := graphql.NewHandler(newApp, graphql.WithIdempotencyStore(idempotent),
+		graphql.WithRateLimits(limiter, graphql.DefaultRateLimits))
+	httpOpts := []httpgin.Option{
+		httpgin.WithIdempotencyStore(idempotent), httpgin.WithRateLimits(limiter, httpgin.DefaultRateLimits),
+		httpgin.WithGateway(gateway), httpgin.WithGraphQL(graphQL),
+	}
+	// TRUSTED_PROXIES - адреса или подсети прокси через запятую, только им можно верить в X-Forwarded-For
+	if proxies, ok := os.LookupEnv("TRUSTED_PROXIES"); ok {
+		httpOpts = append(httpOpts, httpgin.WithTrustedProxies(parseList(proxies)...))
+	}
+	hServer := httpgin.NewHTTPServer(PORT_REST, newApp, httpLogger, *cert, *key, httpOpts...)
+	// grpc.health.v1 в NOT_SERVING, пока журнал объявлений или outbox недоступны или релей outbox не работает
+	gServer := grpc.NewServer(rpcLogger, newApp, grpc.WithIdempotencyStore(idempotent),
+		grpc.WithRateLimits(limiter, grpc.DefaultRateLimits),
+		grpc.WithHealthCheck("repository", pingRepo), grpc.WithHealthCheck("outbox", pingOutbox),
+		grpc.WithHealthCheck("outbox_relay", relay.Check))
 
 	g.Go(func() error {
 		select {
@@ -111,6 +195,56 @@ func main() {
 	if err2 := g.Wait(); err2 != nil {
 		sysLogger.Printf("gracefully shutting down the servers: %v\n", err2)
 	}
+	// серверы остановлены, новых событий не будет - дожидаемся асинхронных подписчиков
+	bus.Close()
+	dispatcher.Close()
+}
+
+// newOutboxStore с AD_EVENT_STORE outbox хранится рядом с журналом объявлений в файле с суффиксом .outbox:
+// события, которые не успели доставить до падения, доставляются после перезапуска. Без него - в памяти
+func newOutboxStore() (outbox.Store, grpc.HealthCheck, func(), error) {
+	path, ok := os.LookupEnv("AD_EVENT_STORE")
+	if !ok {
+		return outbox.NewStore(), func(context.Context) error { return nil }, func() {}, nil
+	}
+	store, err := outbox.OpenFileStore(path + ".outbox")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return store, func(context.Context) error { return store.Ping() }, func() { _ = store.Close() }, nil
+}
+
+// newAdRepository с AD_EVENT_STORE объявления хранятся журналом событий в этом файле
+// и переживают перезапуск, без него - в памяти. Вторым значением возвращается проверка доступности хранилища
+func newAdRepository(store outbox.Store) (adrepo.AdRepository, grpc.HealthCheck, func(), error) {
+	path, ok := os.LookupEnv("AD_EVENT_STORE")
+	if !ok {
+		return adrepo.New(adrepo.WithRecorder(store)), func(context.Context) error { return nil }, func() {}, nil
+	}
+	journal, err := eventrepo.OpenFileStore(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	repo, err := eventrepo.New(journal, eventrepo.WithRecorder(store))
+	if err != nil {
+		_ = journal.Close()
+		return nil, nil, nil, err
+	}
+	return repo, func(context.Context) error { return journal.Ping() }, func() { _ = journal.Close() }, nil
+}
+
+// newIdempotencyStore IDEMPOTENCY_TTL - сколько хранить ответы, например 1h, по умолчанию сутки
+func newIdempotencyStore(logger *log.Logger) idempotency.Store {
+	value, ok := os.LookupEnv("IDEMPOTENCY_TTL")
+	if !ok {
+		return idempotency.NewStore()
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil || ttl <= 0 {
+		logger.Printf("bad IDEMPOTENCY_TTL %q, using %s\n", value, idempotency.DefaultTTL)
+		return idempotency.NewStore()
+	}
+	return idempotency.NewStore(idempotency.WithTTL(ttl))
 }
 
 func setPortEnv(dPort int, name, sep string) (port string) {
@@ -122,3 +256,27 @@ func setPortEnv(dPort int, name, sep string) (port string) {
 	}
 	return port
 }
+
+// parseList непустые значения списка через запятую
+func parseList(list string) []string {
+	values := make([]string, 0)
+	for _, value := range strings.Split(list, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func parseIDs(list string, logger *log.Logger) []int64 {
+	ids := make([]int64, 0)
+	for _, str := range strings.Split(list, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(str), 10, 64)
+		if err != nil {
+			logger.Printf("skip bad id %q: %v\n", str, err)
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
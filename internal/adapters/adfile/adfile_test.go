@@ -0,0 +1,98 @@
+package adfile
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/entities"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readAll(t *testing.T, r RowReader) []Row {
+	rows := make([]Row, 0)
+	for {
+		row, err := r.Next()
+		if err == io.EOF {
+			return rows
+		}
+		assert.NoError(t, err)
+		rows = append(rows, row)
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+	file := "user_id,title,text,extra\n1,hello,world,x\nnot int,hello,world,x\n2,\"a,b\",text,x\n"
+	r, err := NewReader(strings.NewReader(file), CSV)
+	assert.NoError(t, err)
+
+	rows := readAll(t, r)
+	assert.Len(t, rows, 3)
+	assert.Equal(t, 2, rows[0].Line)
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, int64(1), rows[0].Ad.AuthorID)
+	assert.Equal(t, "hello", rows[0].Ad.Title)
+	assert.ErrorIs(t, rows[1].Err, ErrBadUserID)
+	assert.Equal(t, "a,b", rows[2].Ad.Title)
+}
+
+func TestCSVReader_BadHeader(t *testing.T) {
+	_, err := NewReader(strings.NewReader("title,text\nhello,world\n"), CSV)
+	assert.ErrorIs(t, err, ErrBadHeader)
+
+	_, err = NewReader(strings.NewReader(""), CSV)
+	assert.ErrorIs(t, err, ErrBadHeader)
+}
+
+func TestJSONLReader(t *testing.T) {
+	file := `{"title":"hello","text":"world","user_id":1}
+
+{"title":"hello"}
+not json
+`
+	r, err := NewReader(strings.NewReader(file), JSONL)
+	assert.NoError(t, err)
+
+	rows := readAll(t, r)
+	assert.Len(t, rows, 3)
+	assert.NoError(t, rows[0].Err)
+	assert.Equal(t, int64(1), rows[0].Ad.AuthorID)
+	assert.Equal(t, 3, rows[1].Line)
+	assert.ErrorIs(t, rows[1].Err, ErrBadUserID)
+	assert.Error(t, rows[2].Err)
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	ads := []entities.Ad{
+		{ID: 1, Title: "hello", Text: "multi\nline, text", AuthorID: 0, CreateDate: time.Now().UTC()},
+		{ID: 2, Title: "second", Text: "\"quoted\"", AuthorID: 3, Published: true},
+	}
+	for _, format := range []Format{CSV, JSONL} {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, format)
+		assert.NoError(t, err)
+		for _, ad := range ads {
+			assert.NoError(t, w.Write(ad))
+		}
+		assert.NoError(t, w.Flush())
+
+		r, err := NewReader(&buf, format)
+		assert.NoError(t, err)
+		rows := readAll(t, r)
+		assert.Len(t, rows, len(ads), format)
+		for i, row := range rows {
+			assert.NoError(t, row.Err)
+			assert.Equal(t, ads[i].Title, row.Ad.Title)
+			assert.Equal(t, ads[i].Text, row.Ad.Text)
+			assert.Equal(t, ads[i].AuthorID, row.Ad.AuthorID)
+		}
+	}
+}
+
+func TestBadFormat(t *testing.T) {
+	_, err := NewReader(strings.NewReader(""), "xml")
+	assert.ErrorIs(t, err, ErrBadFormat)
+	_, err = NewWriter(io.Discard, "xml")
+	assert.ErrorIs(t, err, ErrBadFormat)
+}
@@ -0,0 +1,102 @@
+package adfile
+
+import (
+	"encoding/csv"
+	"errors"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var csvHeader = []string{"id", "title", "text", "user_id", "published", "create_date", "update_date"}
+
+// csvReader находит колонки по заголовку, лишние колонки (например, из экспорта) пропускаются
+type csvReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVReader(r io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, ErrBadHeader
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"title", "text", "user_id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, ErrBadHeader
+		}
+	}
+	return &csvReader{r: reader, columns: columns}, nil
+}
+
+func (c *csvReader) Next() (Row, error) {
+	record, err := c.r.Read()
+	if err == io.EOF {
+		return Row{}, io.EOF
+	}
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return Row{Line: parseErr.StartLine, Err: err}, nil
+	}
+	if err != nil {
+		return Row{}, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	row := Row{Line: line}
+	userID, err := strconv.ParseInt(strings.TrimSpace(c.field(record, "user_id")), 10, 64)
+	if err != nil {
+		row.Err = ErrBadUserID
+	}
+	row.Ad = service.NewAd{
+		Title:    c.field(record, "title"),
+		Text:     c.field(record, "text"),
+		AuthorID: userID,
+	}
+	return row, nil
+}
+
+func (c *csvReader) field(record []string, name string) string {
+	if i := c.columns[name]; i < len(record) {
+		return record[i]
+	}
+	return ""
+}
+
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (*csvWriter, error) {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return &csvWriter{w: writer}, nil
+}
+
+func (c *csvWriter) Write(ad entities.Ad) error {
+	return c.w.Write([]string{
+		strconv.FormatInt(ad.ID, 10),
+		ad.Title,
+		ad.Text,
+		strconv.FormatInt(ad.AuthorID, 10),
+		strconv.FormatBool(ad.Published),
+		ad.CreateDate.Format(time.RFC3339),
+		ad.UpdateDate.Format(time.RFC3339),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
@@ -0,0 +1,69 @@
+package adfile
+
+import (
+	"homework10/internal/apperr"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"io"
+)
+
+var (
+	ErrBadFormat = apperr.New(apperr.InvalidArgument, "bad_file_format", "bad file format")
+	// ErrBadHeader в CSV нет одной из обязательных колонок title, text, user_id
+	ErrBadHeader = apperr.New(apperr.InvalidArgument, "bad_csv_header", "csv header must contain title, text and user_id columns")
+	ErrBadUserID = apperr.New(apperr.InvalidArgument, "bad_user_id", "user_id is missing or not int")
+)
+
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+func (f Format) ContentType() string {
+	if f == CSV {
+		return "text/csv"
+	}
+	return "application/x-ndjson"
+}
+
+// Row строка файла импорта. Err - ошибка разбора строки, остальные строки при этом читаются дальше
+type Row struct {
+	Line int
+	Ad   service.NewAd
+	Err  error
+}
+
+type RowReader interface {
+	// Next возвращает io.EOF, когда строки закончились
+	Next() (Row, error)
+}
+
+type RowWriter interface {
+	Write(ad entities.Ad) error
+	Flush() error
+}
+
+func NewReader(r io.Reader, format Format) (RowReader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r)
+	case JSONL:
+		return newJSONLReader(r), nil
+	default:
+		return nil, ErrBadFormat
+	}
+}
+
+// NewWriter пишет объявления в формате, который принимает NewReader
+func NewWriter(w io.Writer, format Format) (RowWriter, error) {
+	switch format {
+	case CSV:
+		return newCSVWriter(w)
+	case JSONL:
+		return newJSONLWriter(w), nil
+	default:
+		return nil, ErrBadFormat
+	}
+}
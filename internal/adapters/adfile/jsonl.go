@@ -0,0 +1,89 @@
+package adfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"io"
+	"strings"
+	"time"
+)
+
+// maxLineSize ограничивает длину одной строки JSONL
+const maxLineSize = 1 << 20
+
+type jsonRecord struct {
+	ID         int64     `json:"id"`
+	Title      string    `json:"title"`
+	Text       string    `json:"text"`
+	UserID     *int64    `json:"user_id"`
+	Published  bool      `json:"published"`
+	CreateDate time.Time `json:"create_date"`
+	UpdateDate time.Time `json:"update_date"`
+}
+
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLReader(r io.Reader) *jsonlReader {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return &jsonlReader{scanner: scanner}
+}
+
+// Next пустые строки пропускаются
+func (j *jsonlReader) Next() (Row, error) {
+	for j.scanner.Scan() {
+		j.line++
+		text := j.scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		row := Row{Line: j.line}
+		var record jsonRecord
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			row.Err = err
+			return row, nil
+		}
+		if record.UserID == nil {
+			row.Err = ErrBadUserID
+			return row, nil
+		}
+		row.Ad = service.NewAd{Title: record.Title, Text: record.Text, AuthorID: *record.UserID}
+		return row, nil
+	}
+	if err := j.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+	return Row{}, io.EOF
+}
+
+type jsonlWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) *jsonlWriter {
+	buffered := bufio.NewWriter(w)
+	return &jsonlWriter{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+func (j *jsonlWriter) Write(ad entities.Ad) error {
+	userID := ad.AuthorID
+	return j.enc.Encode(jsonRecord{
+		ID:         ad.ID,
+		Title:      ad.Title,
+		Text:       ad.Text,
+		UserID:     &userID,
+		Published:  ad.Published,
+		CreateDate: ad.CreateDate,
+		UpdateDate: ad.UpdateDate,
+	})
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.w.Flush()
+}
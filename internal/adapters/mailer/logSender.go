@@ -0,0 +1,22 @@
+package mailer
+
+import (
+	"context"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"log"
+)
+
+type logSender struct {
+	log *log.Logger
+}
+
+// NewLogSender пишет токены подтверждения в лог вместо отправки письма
+func NewLogSender(logger *log.Logger) service.VerificationSender {
+	return &logSender{log: logger}
+}
+
+func (s *logSender) SendVerification(ctx context.Context, user entities.User, token string) error {
+	s.log.Printf("verification token for user %d <%s>: %s\n", user.ID, user.Email, token)
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	"homework10/internal/util"
+	"sync"
 	"testing"
 	"time"
 )
@@ -39,6 +41,7 @@ func (s *repoSuite) TearDownTest() {
 	for key := range s.repo.(*mapRepository).rep {
 		delete(s.repo.(*mapRepository).rep, key)
 	}
+	clear(s.repo.(*mapRepository).active)
 }
 
 func (s *repoSuite) Test_Repo_GetAdByID_NotFound() {
@@ -82,6 +85,32 @@ func (s *repoSuite) Test_AdRepo_EditAdStatus() {
 	assert.Equal(s.T(), *updatedAd, *adFromRepo)
 }
 
+func Test_AdRepo_EditAdStatus_Stale(t *testing.T) {
+	repo := New()
+	id, err := repo.AddAd(dAd)
+	assert.NoError(t, err)
+	stale, err := repo.GetAdByID(id)
+	assert.NoError(t, err)
+
+	// текст изменили после чтения, смена статуса его не затирает
+	_, err = repo.ChangeAdText(id, "NewTitle", "NewText", time.Now().UTC())
+	assert.NoError(t, err)
+	changed, err := repo.EditAdStatus(stale, true, time.Now().UTC())
+	assert.NoError(t, err)
+	assert.True(t, changed.Published)
+	assert.Equal(t, "NewTitle", changed.Title)
+	assert.False(t, stale.Published)
+
+	// удаленное объявление не возвращается
+	assert.NoError(t, repo.DeleteAd(id))
+	_, err = repo.EditAdStatus(stale, false, time.Now().UTC())
+	assert.ErrorIs(t, err, util.ErrNotFound)
+	_, err = repo.GetAdByID(id)
+	assert.ErrorIs(t, err, util.ErrNotFound)
+	active, _ := repo.ActiveAds(dAd.AuthorID)
+	assert.Equal(t, 0, active)
+}
+
 func (s *repoSuite) Test_AdRepo_ChangeAdText() {
 	id, _ := s.repo.AddAd(dAd)
 	text := "NewTextUpdate"
@@ -146,3 +175,172 @@ func (s *repoSuite) Test_AdRepo_GetByFilter_WithAuthorID() {
 	assert.NoError(s.T(), err)
 	assert.Equal(s.T(), 1, len(ads))
 }
+
+func (s *repoSuite) Test_AdRepo_DeleteAdsByAuthor() {
+	other := dAd
+	other.AuthorID = dAd.AuthorID + 1
+	id, _ := s.repo.AddAd(dAd)
+	otherID, _ := s.repo.AddAd(other)
+
+	removed, err := s.repo.DeleteAdsByAuthor(dAd.AuthorID)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), removed, 1)
+	assert.Equal(s.T(), id, removed[0].ID)
+
+	_, err = s.repo.GetAdByID(id)
+	assert.ErrorIs(s.T(), err, util.ErrNotFound)
+	_, err = s.repo.GetAdByID(otherID)
+	assert.NoError(s.T(), err)
+}
+
+func (s *repoSuite) Test_AdRepo_ChangeAdsAuthor() {
+	id, _ := s.repo.AddAd(dAd)
+	newAuthorID := dAd.AuthorID + 1
+	updateTime := time.Now().UTC().Add(time.Hour)
+
+	changed, err := s.repo.ChangeAdsAuthor(dAd.AuthorID, newAuthorID, updateTime)
+	assert.NoError(s.T(), err)
+	assert.Len(s.T(), changed, 1)
+	assert.Equal(s.T(), dAd.AuthorID, changed[0].AuthorID)
+
+	ad, err := s.repo.GetAdByID(id)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), newAuthorID, ad.AuthorID)
+	assert.Equal(s.T(), updateTime, ad.UpdateDate)
+}
+
+func (s *repoSuite) Test_AdRepo_RestoreAds() {
+	id, _ := s.repo.AddAd(dAd)
+	removed, _ := s.repo.DeleteAdsByAuthor(dAd.AuthorID)
+
+	err := s.repo.RestoreAds(removed)
+	assert.NoError(s.T(), err)
+	ad, err := s.repo.GetAdByID(id)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), dAd.Title, ad.Title)
+}
+
+// eventLog запоминает записанные репозиторием события
+type eventLog struct {
+	names []string
+}
+
+func (l *eventLog) Record(batch []events.Event) {
+	for _, event := range batch {
+		l.names = append(l.names, event.Name())
+	}
+}
+
+func Test_AdRepo_Recorder(t *testing.T) {
+	log := &eventLog{}
+	repo := New(WithRecorder(log))
+
+	id, _ := repo.AddAd(dAd)
+	ad, _ := repo.GetAdByID(id)
+	_, _ = repo.EditAdStatus(ad, true, time.Now().UTC())
+	_, _ = repo.ChangeAdText(id, "title", "text", time.Now().UTC())
+	_, _ = repo.ChangeAdText(id+100, "title", "text", time.Now().UTC())
+	removed, _ := repo.DeleteAdsByAuthor(dAd.AuthorID)
+	_ = repo.RestoreAds(removed)
+	_ = repo.DeleteAd(id)
+	_ = repo.DeleteAd(id)
+
+	assert.Equal(t, []string{
+		events.NameAdCreated,
+		events.NameAdStatusChanged,
+		events.NameAdUpdated,
+		events.NameAdDeleted,
+		events.NameAdCreated,
+		events.NameAdDeleted,
+	}, log.names)
+}
+
+func (s *repoSuite) Test_AdRepo_ActiveAds() {
+	// свой репозиторий, чтобы не сдвигать ID в остальных тестах набора
+	repo := New()
+	published := dAd
+	published.Published = true
+	first, err := repo.AddAd(published)
+	s.NoError(err)
+	second, err := repo.AddAd(dAd)
+	s.NoError(err)
+	ad, err := repo.GetAdByID(second)
+	s.NoError(err)
+	_, err = repo.EditAdStatus(ad, true, time.Now().UTC())
+	s.NoError(err)
+	active, err := repo.ActiveAds(dAd.AuthorID)
+	s.NoError(err)
+	s.Equal(2, active)
+
+	// счетчик переходит к новому автору и возвращается при откате
+	changed, err := repo.ChangeAdsAuthor(dAd.AuthorID, 2, time.Now().UTC())
+	s.NoError(err)
+	active, _ = repo.ActiveAds(dAd.AuthorID)
+	s.Equal(0, active)
+	active, _ = repo.ActiveAds(2)
+	s.Equal(2, active)
+	s.NoError(repo.RestoreAds(changed))
+	active, _ = repo.ActiveAds(2)
+	s.Equal(0, active)
+
+	s.NoError(repo.DeleteAd(first))
+	active, _ = repo.ActiveAds(dAd.AuthorID)
+	s.Equal(1, active)
+	_, err = repo.DeleteAdsByAuthor(dAd.AuthorID)
+	s.NoError(err)
+	active, _ = repo.ActiveAds(dAd.AuthorID)
+	s.Equal(0, active)
+}
+
+func Test_AdRepo_ConcurrentReadWrite(t *testing.T) {
+	// чтения и записи под одной блокировкой, go test -race не находит гонок по rep и active
+	repo := New()
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				id, err := repo.AddAd(dAd)
+				assert.NoError(t, err)
+				assert.NoError(t, repo.DeleteAd(id))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				_, _ = repo.GetAdByID(0)
+				_, err := repo.GetAdsByFilters(nil)
+				assert.NoError(t, err)
+			}
+		}()
+	}
+	wg.Wait()
+	ads, err := repo.GetAdsByFilters(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, ads)
+}
+
+func Test_AdRepo_GetAdsPage(t *testing.T) {
+	repo := New()
+	for i := range 5 {
+		ad := dAd
+		ad.Published = i != 3
+		_, err := repo.AddAd(ad)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, repo.DeleteAd(1))
+	published := []func(ad entities.Ad) bool{func(ad entities.Ad) bool { return ad.Published }}
+
+	page, err := repo.GetAdsPage(published, -1, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 2) {
+		assert.Equal(t, int64(0), page[0].ID)
+		assert.Equal(t, int64(2), page[1].ID)
+	}
+	page, err = repo.GetAdsPage(published, 2, 2)
+	assert.NoError(t, err)
+	if assert.Len(t, page, 1) {
+		assert.Equal(t, int64(4), page[0].ID)
+	}
+}
@@ -2,6 +2,7 @@ package adrepo
 
 import (
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	"homework10/internal/util"
 	"sync"
 	"time"
@@ -14,14 +15,34 @@ type AdRepository interface {
 	ChangeAdText(adID int64, title, text string, updateTime time.Time) (*entities.Ad, error)
 	GetAdByID(adID int64) (*entities.Ad, error)
 	GetAdsByFilters(filters []func(ad entities.Ad) bool) ([]entities.Ad, error)
+	// GetAdsPage до limit объявлений с ID больше afterID в порядке ID, для которых все filters вернули true.
+	// Для чтения по страницам, без выборки всех объявлений сразу
+	GetAdsPage(filters []func(ad entities.Ad) bool, afterID int64, limit int) ([]entities.Ad, error)
 	DeleteAd(adID int64) error
+	DeleteAdsByAuthor(authorID int64) ([]entities.Ad, error)
+	ChangeAdsAuthor(authorID, newAuthorID int64, updateTime time.Time) ([]entities.Ad, error)
+	RestoreAds(ads []entities.Ad) error
+	// ActiveAds число опубликованных объявлений автора
+	ActiveAds(authorID int64) (int, error)
 }
 
 type mapRepository struct {
-	rep    map[int64]entities.Ad
-	mutex  sync.Mutex
-	rMutex sync.RWMutex
+	rep map[int64]entities.Ad
+	// active опубликованные объявления каждого автора, меняется вместе с rep через put и remove
+	active map[int64]int
+	mutex  sync.RWMutex
 	util.UID
+	// recorder пишет события изменений под той же блокировкой, что и само изменение
+	recorder events.Recorder
+}
+
+type Option func(*mapRepository)
+
+// WithRecorder записывает событие о каждом изменении, например в outbox
+func WithRecorder(recorder events.Recorder) Option {
+	return func(m *mapRepository) {
+		m.recorder = recorder
+	}
 }
 
 func (m *mapRepository) AddAd(ad entities.Ad) (int64, error) {
@@ -35,7 +56,8 @@ func (m *mapRepository) AddAd(ad entities.Ad) (int64, error) {
 	}
 
 	ad.ID = id
-	m.rep[id] = ad
+	m.put(ad)
+	m.record(events.AdCreated{Ad: ad})
 	return ad.ID, nil
 }
 
@@ -43,34 +65,48 @@ func (m *mapRepository) EditAdStatus(ad *entities.Ad, published bool, updateTime
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	ad.Published = published
-	ad.UpdateDate = updateTime
+	// ad прочитано вызывающим раньше: объявление могли удалить, изменить или передать другому автору,
+	// поэтому меняется только статус сохраненного
+	changed, err := m.getAdByID(ad.ID)
+	if err != nil {
+		return changed, err
+	}
+	prev := *changed
+	changed.Published = published
+	changed.UpdateDate = updateTime
 
-	m.rep[ad.ID] = *ad
+	m.put(*changed)
+	m.record(events.AdStatusChanged{Ad: *changed, Prev: prev})
 
-	return ad, nil
+	return changed, nil
 }
 
 func (m *mapRepository) ChangeAdText(adID int64, title, text string, updateTime time.Time) (*entities.Ad, error) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	ad, err := m.GetAdByID(adID)
+	ad, err := m.getAdByID(adID)
 	if err != nil {
 		return ad, err
 	}
+	prev := *ad
 	ad.Title = title
 	ad.Text = text
 	ad.UpdateDate = updateTime
 
-	m.rep[adID] = *ad
+	m.put(*ad)
+	m.record(events.AdUpdated{Ad: *ad, Prev: prev})
 	return ad, nil
 }
 
 func (m *mapRepository) GetAdByID(adID int64) (*entities.Ad, error) {
-	m.rMutex.RLock()
-	defer m.rMutex.RUnlock()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
+	return m.getAdByID(adID)
+}
+
+func (m *mapRepository) getAdByID(adID int64) (*entities.Ad, error) {
 	empty := &entities.Ad{}
 	ad := m.rep[adID]
 	if ad == (*empty) {
@@ -80,8 +116,8 @@ func (m *mapRepository) GetAdByID(adID int64) (*entities.Ad, error) {
 }
 
 func (m *mapRepository) GetAdsByFilters(filters []func(ad entities.Ad) bool) ([]entities.Ad, error) {
-	m.rMutex.RLock()
-	defer m.rMutex.RUnlock()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
 	adsResult := make([]entities.Ad, 0)
 adLoop:
@@ -101,16 +137,147 @@ adLoop:
 	return adsResult, nil
 }
 
+// GetAdsPage обходит ID по порядку: ID выдает UID, поэтому все объявления лежат в пределах последнего выданного
+func (m *mapRepository) GetAdsPage(filters []func(ad entities.Ad) bool, afterID int64, limit int) ([]entities.Ad, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	page := make([]entities.Ad, 0, limit)
+adLoop:
+	for id := afterID + 1; id <= m.UID.Id && len(page) < limit; id++ {
+		ad, ok := m.rep[id]
+		if !ok {
+			continue
+		}
+		for _, f := range filters {
+			if !f(ad) {
+				continue adLoop
+			}
+		}
+		page = append(page, ad)
+	}
+	return page, nil
+}
+
 func (m *mapRepository) DeleteAd(adID int64) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	delete(m.rep, adID)
+	if ad, ok := m.rep[adID]; ok {
+		m.remove(adID)
+		m.record(events.AdDeleted{Ad: ad})
+	}
 	return nil
 }
 
-func New() AdRepository {
-	return &mapRepository{
-		rep: make(map[int64]entities.Ad),
-		UID: util.UID{Id: -1}}
+// DeleteAdsByAuthor снимает с публикации и удаляет все объявления автора, возвращает их прежнее состояние
+func (m *mapRepository) DeleteAdsByAuthor(authorID int64) ([]entities.Ad, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := make([]entities.Ad, 0)
+	batch := make([]events.Event, 0)
+	for id, ad := range m.rep {
+		if ad.AuthorID != authorID {
+			continue
+		}
+		removed = append(removed, ad)
+		m.remove(id)
+		batch = append(batch, events.AdDeleted{Ad: ad})
+	}
+	m.record(batch...)
+	return removed, nil
+}
+
+// ChangeAdsAuthor передает все объявления автора другому пользователю, возвращает их прежнее состояние
+func (m *mapRepository) ChangeAdsAuthor(authorID, newAuthorID int64, updateTime time.Time) ([]entities.Ad, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	changed := make([]entities.Ad, 0)
+	batch := make([]events.Event, 0)
+	for _, ad := range m.rep {
+		if ad.AuthorID == authorID {
+			changed = append(changed, ad)
+		}
+	}
+	// put удаляет и снова добавляет ключ, поэтому не во время обхода rep
+	for _, prev := range changed {
+		ad := prev
+		ad.AuthorID = newAuthorID
+		ad.UpdateDate = updateTime
+		m.put(ad)
+		batch = append(batch, events.AdUpdated{Ad: ad, Prev: prev})
+	}
+	m.record(batch...)
+	return changed, nil
+}
+
+// RestoreAds возвращает объявления в переданное состояние, используется для отката
+func (m *mapRepository) RestoreAds(ads []entities.Ad) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	batch := make([]events.Event, 0, len(ads))
+	for _, ad := range ads {
+		prev, existed := m.rep[ad.ID]
+		m.put(ad)
+		switch {
+		case !existed:
+			batch = append(batch, events.AdCreated{Ad: ad})
+		case prev.Published != ad.Published:
+			batch = append(batch, events.AdStatusChanged{Ad: ad, Prev: prev})
+		default:
+			batch = append(batch, events.AdUpdated{Ad: ad, Prev: prev})
+		}
+	}
+	m.record(batch...)
+	return nil
+}
+
+func (m *mapRepository) ActiveAds(authorID int64) (int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.active[authorID], nil
+}
+
+// put сохраняет ad и пересчитывает active, вызывается под блокировкой на запись
+func (m *mapRepository) put(ad entities.Ad) {
+	m.remove(ad.ID)
+	m.rep[ad.ID] = ad
+	if ad.Published {
+		m.active[ad.AuthorID]++
+	}
+}
+
+// remove вызывается под блокировкой на запись
+func (m *mapRepository) remove(adID int64) {
+	ad, ok := m.rep[adID]
+	if !ok {
+		return
+	}
+	delete(m.rep, adID)
+	if ad.Published {
+		if m.active[ad.AuthorID]--; m.active[ad.AuthorID] == 0 {
+			delete(m.active, ad.AuthorID)
+		}
+	}
+}
+
+// record вызывается под блокировкой на запись
+func (m *mapRepository) record(batch ...events.Event) {
+	if m.recorder != nil && len(batch) > 0 {
+		m.recorder.Record(batch)
+	}
+}
+
+func New(opts ...Option) AdRepository {
+	m := &mapRepository{
+		rep:    make(map[int64]entities.Ad),
+		active: make(map[int64]int),
+		UID:    util.UID{Id: -1}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
@@ -0,0 +1,398 @@
+package eventrepo
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/util"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var (
+	tDate = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tAd   = entities.Ad{Title: "Test", Text: "TestText", AuthorID: 1, CreateDate: tDate, UpdateDate: tDate}
+)
+
+type eventLog struct {
+	events []events.Event
+}
+
+func (l *eventLog) Record(batch []events.Event) {
+	l.events = append(l.events, batch...)
+}
+
+type repoSuite struct {
+	suite.Suite
+	store Store
+	repo  Repository
+	log   *eventLog
+}
+
+func TestSuiteEventRepo(t *testing.T) {
+	suite.Run(t, new(repoSuite))
+}
+
+func (s *repoSuite) SetupTest() {
+	s.store = NewMemoryStore()
+	s.log = &eventLog{}
+	var err error
+	s.repo, err = New(s.store, WithRecorder(s.log))
+	s.Require().NoError(err)
+}
+
+func (s *repoSuite) addAd(ad entities.Ad) int64 {
+	id, err := s.repo.AddAd(ad)
+	s.Require().NoError(err)
+	return id
+}
+
+func (s *repoSuite) Test_AddAd_GetAdByID() {
+	id := s.addAd(tAd)
+	s.Equal(int64(0), id)
+	s.Equal(int64(1), s.addAd(tAd))
+
+	ad, err := s.repo.GetAdByID(id)
+	s.NoError(err)
+	expected := tAd
+	expected.ID = id
+	s.Equal(expected, *ad)
+	s.Equal([]events.Event{events.AdCreated{Ad: expected}}, s.log.events[:1])
+
+	_, err = s.repo.GetAdByID(100)
+	s.ErrorIs(err, util.ErrNotFound)
+}
+
+func (s *repoSuite) Test_Fold() {
+	id := s.addAd(tAd)
+	updated := tDate.Add(time.Hour)
+	ad, err := s.repo.ChangeAdText(id, "new title", "new text", updated)
+	s.NoError(err)
+	s.Equal("new title", ad.Title)
+	ad, err = s.repo.EditAdStatus(ad, true, updated.Add(time.Hour))
+	s.NoError(err)
+	s.True(ad.Published)
+
+	history, err := s.repo.History(id)
+	s.NoError(err)
+	s.Len(history, 3)
+	s.Equal([]EventType{Created, TextChanged, StatusChanged}, []EventType{history[0].Type, history[1].Type, history[2].Type})
+	s.Equal([]int{1, 2, 3}, []int{history[0].Version, history[1].Version, history[2].Version})
+
+	folded, exists := Fold(history)
+	s.True(exists)
+	s.Equal(*ad, folded)
+	stored, err := s.repo.GetAdByID(id)
+	s.NoError(err)
+	s.Equal(*ad, *stored)
+
+	// состояние на любой момент - свертка префикса потока
+	first, _ := Fold(history[:2])
+	s.Equal("new title", first.Title)
+	s.False(first.Published)
+}
+
+func (s *repoSuite) Test_EditAdStatus_Stale() {
+	id := s.addAd(tAd)
+	stale, err := s.repo.GetAdByID(id)
+	s.NoError(err)
+	_, err = s.repo.ChangeAdText(id, "title", "text", tDate)
+	s.NoError(err)
+
+	// результат и событие собраны из журнала, а не из прочитанной раньше копии
+	changed, err := s.repo.EditAdStatus(stale, true, tDate)
+	s.NoError(err)
+	s.Equal("title", changed.Title)
+	s.True(changed.Published)
+	s.False(stale.Published)
+	last := s.log.events[len(s.log.events)-1].(events.AdStatusChanged)
+	s.Equal(*changed, last.Ad)
+	s.Equal("title", last.Prev.Title)
+}
+
+func (s *repoSuite) Test_GetAdsPage() {
+	for i := range 5 {
+		ad := tAd
+		ad.Published = i != 3
+		s.addAd(ad)
+	}
+	s.NoError(s.repo.DeleteAd(1))
+	published := []func(ad entities.Ad) bool{func(ad entities.Ad) bool { return ad.Published }}
+
+	page, err := s.repo.GetAdsPage(published, -1, 2)
+	s.NoError(err)
+	s.Equal([]int64{0, 2}, adIDs(page))
+	page, err = s.repo.GetAdsPage(published, 2, 2)
+	s.NoError(err)
+	s.Equal([]int64{4}, adIDs(page))
+}
+
+func adIDs(ads []entities.Ad) []int64 {
+	ids := make([]int64, 0, len(ads))
+	for _, ad := range ads {
+		ids = append(ids, ad.ID)
+	}
+	return ids
+}
+
+func (s *repoSuite) Test_DeleteAd() {
+	id := s.addAd(tAd)
+	s.NoError(s.repo.DeleteAd(id))
+	s.NoError(s.repo.DeleteAd(id))
+
+	_, err := s.repo.GetAdByID(id)
+	s.ErrorIs(err, util.ErrNotFound)
+	_, err = s.repo.ChangeAdText(id, "title", "text", tDate)
+	s.ErrorIs(err, util.ErrNotFound)
+	ads, err := s.repo.GetAdsByFilters(nil)
+	s.NoError(err)
+	s.Empty(ads)
+
+	// удаленное объявление остается в журнале
+	history, err := s.repo.History(id)
+	s.NoError(err)
+	s.Equal(Deleted, history[len(history)-1].Type)
+	s.Len(history, 2)
+}
+
+func (s *repoSuite) Test_GetAdsByFilters() {
+	first := s.addAd(tAd)
+	other := tAd
+	other.AuthorID = 2
+	second := s.addAd(other)
+
+	ads, err := s.repo.GetAdsByFilters([]func(ad entities.Ad) bool{
+		func(ad entities.Ad) bool { return ad.AuthorID == 2 },
+	})
+	s.NoError(err)
+	s.Len(ads, 1)
+	s.Equal(second, ads[0].ID)
+
+	ads, err = s.repo.GetAdsByFilters(nil)
+	s.NoError(err)
+	s.Equal([]int64{first, second}, []int64{ads[0].ID, ads[1].ID})
+}
+
+func (s *repoSuite) Test_DeleteAdsByAuthor_Restore() {
+	first := s.addAd(tAd)
+	second := s.addAd(tAd)
+	other := tAd
+	other.AuthorID = 2
+	kept := s.addAd(other)
+
+	removed, err := s.repo.DeleteAdsByAuthor(tAd.AuthorID)
+	s.NoError(err)
+	s.Len(removed, 2)
+	ads, _ := s.repo.GetAdsByFilters(nil)
+	s.Len(ads, 1)
+	s.Equal(kept, ads[0].ID)
+
+	s.NoError(s.repo.RestoreAds(removed))
+	for _, id := range []int64{first, second} {
+		ad, err := s.repo.GetAdByID(id)
+		s.NoError(err)
+		s.Equal(tAd.Title, ad.Title)
+	}
+	removed, err = s.repo.DeleteAdsByAuthor(tAd.AuthorID)
+	s.NoError(err)
+	s.Len(removed, 2)
+}
+
+func (s *repoSuite) Test_ChangeAdsAuthor() {
+	id := s.addAd(tAd)
+	changed, err := s.repo.ChangeAdsAuthor(tAd.AuthorID, 5, tDate.Add(time.Hour))
+	s.NoError(err)
+	s.Len(changed, 1)
+	s.Equal(tAd.AuthorID, changed[0].AuthorID)
+
+	ad, err := s.repo.GetAdByID(id)
+	s.NoError(err)
+	s.Equal(int64(5), ad.AuthorID)
+	removed, err := s.repo.DeleteAdsByAuthor(tAd.AuthorID)
+	s.NoError(err)
+	s.Empty(removed)
+	removed, err = s.repo.DeleteAdsByAuthor(5)
+	s.NoError(err)
+	s.Len(removed, 1)
+
+	last := s.log.events[len(s.log.events)-1]
+	s.Equal(events.NameAdDeleted, last.Name())
+}
+
+func (s *repoSuite) Test_ActiveAds() {
+	published := tAd
+	published.Published = true
+	first := s.addAd(published)
+	second := s.addAd(tAd)
+	ad, err := s.repo.GetAdByID(second)
+	s.Require().NoError(err)
+	_, err = s.repo.EditAdStatus(ad, true, tDate)
+	s.NoError(err)
+	active, err := s.repo.ActiveAds(tAd.AuthorID)
+	s.NoError(err)
+	s.Equal(2, active)
+
+	_, err = s.repo.ChangeAdsAuthor(tAd.AuthorID, 2, tDate)
+	s.NoError(err)
+	s.NoError(s.repo.DeleteAd(first))
+	active, _ = s.repo.ActiveAds(2)
+	s.Equal(1, active)
+
+	// счетчик строится и из журнала
+	_, err = s.repo.Rebuild()
+	s.NoError(err)
+	active, _ = s.repo.ActiveAds(2)
+	s.Equal(1, active)
+	active, _ = s.repo.ActiveAds(tAd.AuthorID)
+	s.Equal(0, active)
+}
+
+func (s *repoSuite) Test_Rebuild() {
+	first := s.addAd(tAd)
+	s.addAd(tAd)
+	s.NoError(s.repo.DeleteAd(first))
+
+	// проекции новой копии репозитория строятся только из журнала
+	rebuilt, err := New(s.store)
+	s.Require().NoError(err)
+	ads, err := rebuilt.GetAdsByFilters(nil)
+	s.NoError(err)
+	s.Len(ads, 1)
+
+	report, err := rebuilt.Rebuild()
+	s.NoError(err)
+	s.Equal(3, report.Events)
+	s.Equal(map[string]int{"ads": 1, "ads_by_author": 1}, report.Projections)
+
+	id, err := rebuilt.AddAd(tAd)
+	s.NoError(err)
+	s.Equal(int64(2), id)
+}
+
+func (s *repoSuite) Test_VersionConflict() {
+	id := s.addAd(tAd)
+	_, err := s.store.Append([]Event{{AdID: id, Version: 1, Type: Deleted}})
+	s.ErrorIs(err, ErrVersionConflict)
+	_, err = s.store.Append([]Event{
+		{AdID: 7, Version: 1, Type: Created},
+		{AdID: id, Version: 5, Type: Deleted},
+	})
+	s.ErrorIs(err, ErrVersionConflict)
+
+	// batch с конфликтом не записывается целиком
+	stream, err := s.store.Load(7)
+	s.NoError(err)
+	s.Empty(stream)
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	repo, err := New(store)
+	assert.NoError(t, err)
+	id, err := repo.AddAd(tAd)
+	assert.NoError(t, err)
+	_, err = repo.ChangeAdText(id, "title", "text", tDate)
+	assert.NoError(t, err)
+	assert.NoError(t, store.Ping())
+	assert.NoError(t, store.Close())
+	assert.Error(t, store.Ping())
+
+	store, err = OpenFileStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	repo, err = New(store)
+	assert.NoError(t, err)
+	ad, err := repo.GetAdByID(id)
+	assert.NoError(t, err)
+	assert.Equal(t, "title", ad.Title)
+	assert.Equal(t, tAd.CreateDate, ad.CreateDate)
+	history, err := repo.History(id)
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+}
+
+func TestFileStore_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	assert.NoError(t, os.WriteFile(path, []byte(`{"seq":1,"ad_id":0,"version":1,"type":"created"}`+"\n"+`{"seq":5}`+"\n"), 0o644))
+	_, err := OpenFileStore(path)
+	assert.ErrorContains(t, err, "expected seq 2")
+}
+
+func TestFileStore_TornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	first := `{"seq":1,"ad_id":0,"version":1,"type":"created"}` + "\n"
+	assert.NoError(t, os.WriteFile(path, []byte(first+`{"seq":2,"ad_id":0,"ver`), 0o644))
+
+	// недописанная последняя строка отбрасывается, следующая запись идет после первой
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	all, err := store.All()
+	assert.NoError(t, err)
+	assert.Len(t, all, 1)
+	_, err = store.Append([]Event{{AdID: 0, Version: 2, Type: TextChanged}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Close())
+
+	store, err = OpenFileStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	all, err = store.All()
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func TestFileStore_FailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	assert.NoError(t, store.file.Close())
+	// файл только для чтения: не пишется и не обрезается
+	store.file, err = os.Open(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Append([]Event{{AdID: 0, Version: 1, Type: Created}})
+	assert.Error(t, err)
+	all, err := store.All()
+	assert.NoError(t, err)
+	assert.Empty(t, all)
+	// после необрезанной записи журнал больше не дописывается
+	_, err = store.Append([]Event{{AdID: 0, Version: 1, Type: Created}})
+	assert.ErrorContains(t, err, "event store is broken")
+	assert.ErrorContains(t, store.Ping(), "event store is broken")
+}
+
+func TestFileStore_PingFailedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+
+	// запись не удалась, но файл обрезан: журнал цел, а Ping сообщает об ошибке до следующей удачной записи
+	store.failed = errors.New("no space left on device")
+	assert.ErrorContains(t, store.Ping(), "no space left on device")
+	_, err = store.Append([]Event{{AdID: 0, Version: 1, Type: Created}})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Ping())
+}
+
+func TestFileStore_PingRemoved(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	assert.NoError(t, store.Ping())
+
+	assert.NoError(t, os.Remove(path))
+	assert.Error(t, store.Ping())
+
+	// на месте удаленного создан другой файл
+	assert.NoError(t, os.WriteFile(path, nil, 0o644))
+	assert.ErrorContains(t, store.Ping(), "was replaced")
+}
@@ -0,0 +1,99 @@
+package eventrepo
+
+import (
+	"homework10/internal/entities"
+	"time"
+)
+
+type EventType string
+
+const (
+	Created       EventType = "created"
+	TextChanged   EventType = "text_changed"
+	StatusChanged EventType = "status_changed"
+	AuthorChanged EventType = "author_changed"
+	Deleted       EventType = "deleted"
+	// Restored возвращает объявление в сохраненное состояние целиком, так откатываются операции App
+	Restored EventType = "restored"
+)
+
+// Event событие потока объявления AdID. Version - номер события в потоке начиная с 1,
+// Seq - номер в общем журнале. Какие поля заполнены, зависит от Type
+type Event struct {
+	Seq        int64     `json:"seq"`
+	AdID       int64     `json:"ad_id"`
+	Version    int       `json:"version"`
+	Type       EventType `json:"type"`
+	RecordedAt time.Time `json:"recorded_at"`
+	Title      string    `json:"title,omitempty"`
+	Text       string    `json:"text,omitempty"`
+	AuthorID   int64     `json:"author_id,omitempty"`
+	Published  bool      `json:"published,omitempty"`
+	CreateDate time.Time `json:"create_date"`
+	UpdateDate time.Time `json:"update_date"`
+}
+
+// aggregate состояние объявления, свернутое из событий его потока
+type aggregate struct {
+	ad      entities.Ad
+	version int
+	exists  bool
+}
+
+func (g *aggregate) apply(e Event) {
+	g.version = e.Version
+	switch e.Type {
+	case Created, Restored:
+		g.ad = entities.Ad{
+			ID:         e.AdID,
+			Title:      e.Title,
+			Text:       e.Text,
+			AuthorID:   e.AuthorID,
+			Published:  e.Published,
+			CreateDate: e.CreateDate,
+			UpdateDate: e.UpdateDate,
+		}
+		g.exists = true
+	case TextChanged:
+		g.ad.Title = e.Title
+		g.ad.Text = e.Text
+		g.ad.UpdateDate = e.UpdateDate
+	case StatusChanged:
+		g.ad.Published = e.Published
+		g.ad.UpdateDate = e.UpdateDate
+	case AuthorChanged:
+		g.ad.AuthorID = e.AuthorID
+		g.ad.UpdateDate = e.UpdateDate
+	case Deleted:
+		g.ad = entities.Ad{}
+		g.exists = false
+	}
+}
+
+// Fold восстанавливает объявление по событиям его потока. false - объявления нет или оно удалено
+func Fold(stream []Event) (entities.Ad, bool) {
+	g := fold(stream)
+	return g.ad, g.exists
+}
+
+func fold(stream []Event) aggregate {
+	var g aggregate
+	for _, e := range stream {
+		g.apply(e)
+	}
+	return g
+}
+
+// snapshot событие, которое целиком задает состояние ad
+func snapshot(eventType EventType, ad entities.Ad) Event {
+	return Event{
+		AdID:       ad.ID,
+		Type:       eventType,
+		Title:      ad.Title,
+		Text:       ad.Text,
+		AuthorID:   ad.AuthorID,
+		Published:  ad.Published,
+		CreateDate: ad.CreateDate,
+		UpdateDate: ad.UpdateDate,
+	}
+}
@@ -0,0 +1,185 @@
+package eventrepo
+
+import (
+	"homework10/internal/entities"
+	"sort"
+)
+
+// Projection модель для чтения, которая строится из журнала событий и может быть перестроена с нуля
+type Projection interface {
+	Name() string
+	Reset()
+	Apply(e Event)
+	// Size число записей в проекции
+	Size() int
+}
+
+// adsProjection текущее состояние всех объявлений для выборок по фильтрам
+type adsProjection struct {
+	ads map[int64]*aggregate
+}
+
+func newAdsProjection() *adsProjection {
+	return &adsProjection{ads: make(map[int64]*aggregate)}
+}
+
+func (p *adsProjection) Name() string {
+	return "ads"
+}
+
+func (p *adsProjection) Reset() {
+	p.ads = make(map[int64]*aggregate)
+}
+
+func (p *adsProjection) Apply(e Event) {
+	g, ok := p.ads[e.AdID]
+	if !ok {
+		g = &aggregate{}
+		p.ads[e.AdID] = g
+	}
+	g.apply(e)
+	if !g.exists {
+		delete(p.ads, e.AdID)
+	}
+}
+
+func (p *adsProjection) get(adID int64) (aggregate, bool) {
+	g, ok := p.ads[adID]
+	if !ok {
+		return aggregate{}, false
+	}
+	return *g, true
+}
+
+// list объявления в порядке ID, для которых все filters вернули true
+func (p *adsProjection) list(filters []func(ad entities.Ad) bool) []entities.Ad {
+	ads := make([]entities.Ad, 0)
+adLoop:
+	for _, g := range p.ads {
+		for _, f := range filters {
+			if !f(g.ad) {
+				continue adLoop
+			}
+		}
+		ads = append(ads, g.ad)
+	}
+	sort.Slice(ads, func(i, j int) bool { return ads[i].ID < ads[j].ID })
+	return ads
+}
+
+// page до limit объявлений с ID от afterID+1 до lastID в порядке ID, для которых все filters вернули true
+func (p *adsProjection) page(filters []func(ad entities.Ad) bool, afterID, lastID int64, limit int) []entities.Ad {
+	ads := make([]entities.Ad, 0, limit)
+adLoop:
+	for id := afterID + 1; id <= lastID && len(ads) < limit; id++ {
+		g, ok := p.ads[id]
+		if !ok {
+			continue
+		}
+		for _, f := range filters {
+			if !f(g.ad) {
+				continue adLoop
+			}
+		}
+		ads = append(ads, g.ad)
+	}
+	return ads
+}
+
+func (p *adsProjection) Size() int {
+	return len(p.ads)
+}
+
+// authorProjection объявления каждого автора, для удаления и передачи объявлений пользователя,
+// и число опубликованных из них для лимита тарифа
+type authorProjection struct {
+	authors map[int64]map[int64]struct{}
+	// owners текущий автор и статус объявления, чтобы убрать его из старого набора
+	owners map[int64]owner
+	// active число опубликованных объявлений автора
+	active map[int64]int
+}
+
+type owner struct {
+	authorID  int64
+	published bool
+}
+
+func newAuthorProjection() *authorProjection {
+	p := &authorProjection{}
+	p.Reset()
+	return p
+}
+
+func (p *authorProjection) Name() string {
+	return "ads_by_author"
+}
+
+func (p *authorProjection) Reset() {
+	p.authors = make(map[int64]map[int64]struct{})
+	p.owners = make(map[int64]owner)
+	p.active = make(map[int64]int)
+}
+
+func (p *authorProjection) Apply(e Event) {
+	o, ok := p.owners[e.AdID]
+	switch e.Type {
+	case Created, Restored:
+		p.put(e.AdID, owner{authorID: e.AuthorID, published: e.Published})
+	case AuthorChanged:
+		o.authorID = e.AuthorID
+		p.put(e.AdID, o)
+	case StatusChanged:
+		if ok {
+			o.published = e.Published
+			p.put(e.AdID, o)
+		}
+	case Deleted:
+		p.remove(e.AdID)
+	}
+}
+
+func (p *authorProjection) put(adID int64, o owner) {
+	p.remove(adID)
+	ads, ok := p.authors[o.authorID]
+	if !ok {
+		ads = make(map[int64]struct{})
+		p.authors[o.authorID] = ads
+	}
+	ads[adID] = struct{}{}
+	p.owners[adID] = o
+	if o.published {
+		p.active[o.authorID]++
+	}
+}
+
+func (p *authorProjection) remove(adID int64) {
+	o, ok := p.owners[adID]
+	if !ok {
+		return
+	}
+	delete(p.owners, adID)
+	delete(p.authors[o.authorID], adID)
+	if len(p.authors[o.authorID]) == 0 {
+		delete(p.authors, o.authorID)
+	}
+	if o.published {
+		if p.active[o.authorID]--; p.active[o.authorID] == 0 {
+			delete(p.active, o.authorID)
+		}
+	}
+}
+
+// ads ID объявлений автора по возрастанию
+func (p *authorProjection) ads(authorID int64) []int64 {
+	ids := make([]int64, 0, len(p.authors[authorID]))
+	for id := range p.authors[authorID] {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func (p *authorProjection) Size() int {
+	return len(p.authors)
+}
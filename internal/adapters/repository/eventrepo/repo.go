@@ -0,0 +1,324 @@
+package eventrepo
+
+import (
+	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/util"
+	"sync"
+	"time"
+)
+
+// Repository AdRepository на журнале событий: объявление восстанавливается сверткой событий его потока,
+// выборки читают проекции
+type Repository interface {
+	adrepo.AdRepository
+	// History события объявления, в том числе удаленного
+	History(adID int64) ([]Event, error)
+	// Rebuild перестраивает проекции из журнала
+	Rebuild() (RebuildReport, error)
+}
+
+type RebuildReport struct {
+	Events int `json:"events"`
+	// Projections число записей в каждой проекции после перестройки
+	Projections map[string]int `json:"projections"`
+}
+
+type eventRepository struct {
+	store    Store
+	ads      *adsProjection
+	byAuthor *authorProjection
+	// mutex запись в журнал и обновление проекций происходят под одной блокировкой
+	mutex sync.RWMutex
+	util.UID
+	recorder events.Recorder
+}
+
+type Option func(*eventRepository)
+
+// WithRecorder записывает доменное событие о каждом изменении, например в outbox
+func WithRecorder(recorder events.Recorder) Option {
+	return func(r *eventRepository) {
+		r.recorder = recorder
+	}
+}
+
+// New строит проекции из событий, уже сохраненных в store
+func New(store Store, opts ...Option) (Repository, error) {
+	r := &eventRepository{
+		store:    store,
+		ads:      newAdsProjection(),
+		byAuthor: newAuthorProjection(),
+		UID:      util.UID{Id: -1},
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if _, err := r.Rebuild(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *eventRepository) projections() []Projection {
+	return []Projection{r.ads, r.byAuthor}
+}
+
+func (r *eventRepository) AddAd(ad entities.Ad) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	const notValidID = -1
+	id, err := r.UID.GenerateID()
+	if err != nil {
+		return notValidID, err
+	}
+	ad.ID = id
+	e := snapshot(Created, ad)
+	e.Version = 1
+	if err = r.append(e); err != nil {
+		return notValidID, err
+	}
+	r.record(events.AdCreated{Ad: ad})
+	return id, nil
+}
+
+func (r *eventRepository) EditAdStatus(ad *entities.Ad, published bool, updateTime time.Time) (*entities.Ad, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	g, err := r.load(ad.ID)
+	if err != nil {
+		return &g.ad, err
+	}
+	err = r.append(Event{AdID: ad.ID, Version: g.version + 1, Type: StatusChanged, Published: published, UpdateDate: updateTime})
+	if err != nil {
+		return &g.ad, err
+	}
+	prev := g.ad
+	changed := g.ad
+	changed.Published = published
+	changed.UpdateDate = updateTime
+	r.record(events.AdStatusChanged{Ad: changed, Prev: prev})
+	return &changed, nil
+}
+
+func (r *eventRepository) ChangeAdText(adID int64, title, text string, updateTime time.Time) (*entities.Ad, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	g, err := r.load(adID)
+	if err != nil {
+		return &g.ad, err
+	}
+	err = r.append(Event{AdID: adID, Version: g.version + 1, Type: TextChanged, Title: title, Text: text, UpdateDate: updateTime})
+	if err != nil {
+		return &g.ad, err
+	}
+	prev := g.ad
+	ad := g.ad
+	ad.Title = title
+	ad.Text = text
+	ad.UpdateDate = updateTime
+	r.record(events.AdUpdated{Ad: ad, Prev: prev})
+	return &ad, nil
+}
+
+func (r *eventRepository) GetAdByID(adID int64) (*entities.Ad, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	g, err := r.load(adID)
+	return &g.ad, err
+}
+
+func (r *eventRepository) GetAdsByFilters(filters []func(ad entities.Ad) bool) ([]entities.Ad, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.ads.list(filters), nil
+}
+
+func (r *eventRepository) GetAdsPage(filters []func(ad entities.Ad) bool, afterID int64, limit int) ([]entities.Ad, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.ads.page(filters, afterID, r.UID.Id, limit), nil
+}
+
+func (r *eventRepository) ActiveAds(authorID int64) (int, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.byAuthor.active[authorID], nil
+}
+
+func (r *eventRepository) DeleteAd(adID int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	g, ok := r.ads.get(adID)
+	if !ok {
+		return nil
+	}
+	if err := r.append(Event{AdID: adID, Version: g.version + 1, Type: Deleted}); err != nil {
+		return err
+	}
+	r.record(events.AdDeleted{Ad: g.ad})
+	return nil
+}
+
+// DeleteAdsByAuthor снимает с публикации и удаляет все объявления автора, возвращает их прежнее состояние
+func (r *eventRepository) DeleteAdsByAuthor(authorID int64) ([]entities.Ad, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	removed := make([]entities.Ad, 0)
+	batch := make([]Event, 0)
+	for _, id := range r.byAuthor.ads(authorID) {
+		g, _ := r.ads.get(id)
+		removed = append(removed, g.ad)
+		batch = append(batch, Event{AdID: id, Version: g.version + 1, Type: Deleted})
+	}
+	if err := r.append(batch...); err != nil {
+		return nil, err
+	}
+	domain := make([]events.Event, 0, len(removed))
+	for _, ad := range removed {
+		domain = append(domain, events.AdDeleted{Ad: ad})
+	}
+	r.record(domain...)
+	return removed, nil
+}
+
+// ChangeAdsAuthor передает все объявления автора другому пользователю, возвращает их прежнее состояние
+func (r *eventRepository) ChangeAdsAuthor(authorID, newAuthorID int64, updateTime time.Time) ([]entities.Ad, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	changed := make([]entities.Ad, 0)
+	batch := make([]Event, 0)
+	for _, id := range r.byAuthor.ads(authorID) {
+		g, _ := r.ads.get(id)
+		changed = append(changed, g.ad)
+		batch = append(batch, Event{AdID: id, Version: g.version + 1, Type: AuthorChanged, AuthorID: newAuthorID, UpdateDate: updateTime})
+	}
+	if err := r.append(batch...); err != nil {
+		return nil, err
+	}
+	domain := make([]events.Event, 0, len(changed))
+	for _, prev := range changed {
+		ad := prev
+		ad.AuthorID = newAuthorID
+		ad.UpdateDate = updateTime
+		domain = append(domain, events.AdUpdated{Ad: ad, Prev: prev})
+	}
+	r.record(domain...)
+	return changed, nil
+}
+
+// RestoreAds возвращает объявления в переданное состояние событием Restored, используется для отката
+func (r *eventRepository) RestoreAds(ads []entities.Ad) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	batch := make([]Event, 0, len(ads))
+	domain := make([]events.Event, 0, len(ads))
+	for _, ad := range ads {
+		stream, err := r.store.Load(ad.ID)
+		if err != nil {
+			return err
+		}
+		g := fold(stream)
+		e := snapshot(Restored, ad)
+		e.Version = g.version + 1
+		batch = append(batch, e)
+		switch {
+		case !g.exists:
+			domain = append(domain, events.AdCreated{Ad: ad})
+		case g.ad.Published != ad.Published:
+			domain = append(domain, events.AdStatusChanged{Ad: ad, Prev: g.ad})
+		default:
+			domain = append(domain, events.AdUpdated{Ad: ad, Prev: g.ad})
+		}
+	}
+	if err := r.append(batch...); err != nil {
+		return err
+	}
+	r.record(domain...)
+	return nil
+}
+
+func (r *eventRepository) History(adID int64) ([]Event, error) {
+	stream, err := r.store.Load(adID)
+	if err != nil {
+		return nil, err
+	}
+	if len(stream) == 0 {
+		return nil, util.ErrNotFound
+	}
+	return stream, nil
+}
+
+func (r *eventRepository) Rebuild() (RebuildReport, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	all, err := r.store.All()
+	if err != nil {
+		return RebuildReport{}, err
+	}
+	for _, p := range r.projections() {
+		p.Reset()
+	}
+	maxID := int64(-1)
+	for _, e := range all {
+		for _, p := range r.projections() {
+			p.Apply(e)
+		}
+		maxID = max(maxID, e.AdID)
+	}
+	// новые объявления получают ID после всех, что есть в журнале
+	r.UID = util.UID{Id: maxID}
+
+	report := RebuildReport{Events: len(all), Projections: make(map[string]int)}
+	for _, p := range r.projections() {
+		report.Projections[p.Name()] = p.Size()
+	}
+	return report, nil
+}
+
+// load сворачивает поток объявления, вызывается под блокировкой
+func (r *eventRepository) load(adID int64) (aggregate, error) {
+	stream, err := r.store.Load(adID)
+	if err != nil {
+		return aggregate{}, err
+	}
+	g := fold(stream)
+	if !g.exists {
+		return g, util.ErrNotFound
+	}
+	return g, nil
+}
+
+// append пишет события в журнал и применяет их к проекциям, вызывается под блокировкой на запись
+func (r *eventRepository) append(batch ...Event) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	stored, err := r.store.Append(batch)
+	if err != nil {
+		return err
+	}
+	for _, e := range stored {
+		for _, p := range r.projections() {
+			p.Apply(e)
+		}
+	}
+	return nil
+}
+
+// record вызывается под блокировкой на запись
+func (r *eventRepository) record(batch ...events.Event) {
+	if r.recorder != nil && len(batch) > 0 {
+		r.recorder.Record(batch)
+	}
+}
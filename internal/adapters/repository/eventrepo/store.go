@@ -0,0 +1,232 @@
+package eventrepo
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"homework10/internal/apperr"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var ErrVersionConflict = apperr.New(apperr.Aborted, "version_conflict", "ad stream version conflict")
+
+// Store журнал событий, только дописывается
+type Store interface {
+	// Append дописывает batch атомарно. Version каждого события должна быть следующей в своем потоке,
+	// иначе ErrVersionConflict и ничего не записывается. Возвращает события с Seq и RecordedAt
+	Append(batch []Event) ([]Event, error)
+	// Load события потока adID в порядке Version
+	Load(adID int64) ([]Event, error)
+	// All все события в порядке Seq
+	All() ([]Event, error)
+}
+
+type memStore struct {
+	mutex   sync.RWMutex
+	log     []Event
+	streams map[int64][]int
+}
+
+func NewMemoryStore() Store {
+	return &memStore{streams: make(map[int64][]int)}
+}
+
+func (s *memStore) Append(batch []Event) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.append(batch)
+}
+
+// append проверяет версии всего batch до записи, вызывается под блокировкой
+func (s *memStore) append(batch []Event) ([]Event, error) {
+	next := make(map[int64]int)
+	for _, e := range batch {
+		version, ok := next[e.AdID]
+		if !ok {
+			version = len(s.streams[e.AdID])
+		}
+		if e.Version != version+1 {
+			return nil, fmt.Errorf("%w: ad %d expected version %d, got %d", ErrVersionConflict, e.AdID, version+1, e.Version)
+		}
+		next[e.AdID] = e.Version
+	}
+
+	now := time.Now().UTC()
+	stored := make([]Event, 0, len(batch))
+	for _, e := range batch {
+		e.Seq = int64(len(s.log)) + 1
+		e.RecordedAt = now
+		s.streams[e.AdID] = append(s.streams[e.AdID], len(s.log))
+		s.log = append(s.log, e)
+		stored = append(stored, e)
+	}
+	return stored, nil
+}
+
+func (s *memStore) Load(adID int64) ([]Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	stream := make([]Event, 0, len(s.streams[adID]))
+	for _, i := range s.streams[adID] {
+		stream = append(stream, s.log[i])
+	}
+	return stream, nil
+}
+
+func (s *memStore) All() ([]Event, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return append([]Event(nil), s.log...), nil
+}
+
+// FileStore хранит журнал в файле JSON Lines и держит его копию в памяти
+type FileStore struct {
+	memStore
+	path   string
+	file   *os.File
+	closed bool
+	// size длина файла без недописанных строк, до нее файл обрезается после неудачной записи
+	size int64
+	// broken файл не удалось обрезать, дописывать после недописанной строки нельзя
+	broken error
+	// failed ошибка последней записи, сбрасывается следующей удачной
+	failed error
+}
+
+// OpenFileStore открывает журнал path, создавая его при необходимости.
+// Последняя строка без перевода строки - запись, прерванная падением процесса, она отбрасывается
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	s := &FileStore{memStore: memStore{streams: make(map[int64][]int)}, path: path, file: file}
+	if err = s.load(path); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) load(path string) error {
+	reader := bufio.NewReader(s.file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				return s.file.Truncate(s.size)
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var e Event
+		if err = json.Unmarshal(data, &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if e.Seq != int64(len(s.log))+1 {
+			return fmt.Errorf("%s:%d: expected seq %d, got %d", path, line, len(s.log)+1, e.Seq)
+		}
+		s.streams[e.AdID] = append(s.streams[e.AdID], len(s.log))
+		s.log = append(s.log, e)
+		s.size += int64(len(data))
+	}
+}
+
+// Append сначала пишет batch в файл одним вызовом Write, потом в память
+func (s *FileStore) Append(batch []Event) ([]Event, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.broken != nil {
+		return nil, s.broken
+	}
+	stored, err := s.append(batch)
+	if err != nil {
+		return nil, err
+	}
+	var buf []byte
+	for _, e := range stored {
+		line, err := json.Marshal(e)
+		if err != nil {
+			s.rollback(len(stored))
+			return nil, err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	if err = s.write(buf); err != nil {
+		s.rollback(len(stored))
+		return nil, err
+	}
+	return stored, nil
+}
+
+// write дописывает buf и сбрасывает его на диск. Если записать не удалось, файл обрезается
+// до прежней длины, чтобы в журнале не осталось недописанной строки
+func (s *FileStore) write(buf []byte) error {
+	_, err := s.file.Write(buf)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err == nil {
+		s.size += int64(len(buf))
+		s.failed = nil
+		return nil
+	}
+	s.failed = err
+	if truncErr := s.file.Truncate(s.size); truncErr != nil {
+		s.broken = fmt.Errorf("event store is broken after a failed write: %w", truncErr)
+		return errors.Join(err, s.broken)
+	}
+	return err
+}
+
+// rollback убирает из памяти n последних событий, которые не удалось записать в файл
+func (s *memStore) rollback(n int) {
+	for _, e := range s.log[len(s.log)-n:] {
+		stream := s.streams[e.AdID]
+		s.streams[e.AdID] = stream[:len(stream)-1]
+	}
+	s.log = s.log[:len(s.log)-n]
+}
+
+// Ping проверяет, что журнал не закрыт, последняя запись удалась
+// и по пути path лежит тот же файл, что открыт, а не удаленный или подмененный
+func (s *FileStore) Ping() error {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	switch {
+	case s.closed:
+		return os.ErrClosed
+	case s.broken != nil:
+		return s.broken
+	case s.failed != nil:
+		return fmt.Errorf("last event store write failed: %w", s.failed)
+	}
+	opened, err := s.file.Stat()
+	if err != nil {
+		return err
+	}
+	current, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	if !os.SameFile(opened, current) {
+		return fmt.Errorf("event store file %s was replaced", s.path)
+	}
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return s.file.Close()
+}
@@ -1,27 +1,46 @@
 package userrepo
 
 import (
-	"errors"
+	"homework10/internal/apperr"
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	"homework10/internal/util"
+	"strings"
 	"sync"
 )
 
-var ErrEmptyUser = errors.New("user is empty")
+var ErrEmptyUser = apperr.New(apperr.NotFound, "user_not_found", "user is empty")
+
+// ErrConflict возвращается, если nickname или email уже заняты другим пользователем (без учета регистра)
+var ErrConflict = apperr.New(apperr.AlreadyExists, "user_conflict", "nickname or email already exists")
 
 //go:generate go run github.com/vektra/mockery/v2@v2.25.0 --name=UserRepository --filename=mockUserRepo.go --output ../../../mocks/repomocks
 type UserRepository interface {
 	AddUser(user entities.User) (int64, error)
 	EditUser(setUser entities.User) (*entities.User, error)
 	GetUserByID(id int64) (*entities.User, error)
+	GetUsersByIDs(ids []int64) ([]entities.User, error)
+	GetUserByEmail(email string) (*entities.User, error)
+	GetUserByNickname(nickname string) (*entities.User, error)
 	DeleteUser(id int64) error
 }
 
+// mapRepository проверяет уникальность и записывает пользователя под одной блокировкой
 type mapRepository struct {
-	rep    map[int64]entities.User
-	mutex  sync.Mutex
-	rMutex sync.RWMutex
+	rep   map[int64]entities.User
+	mutex sync.RWMutex
 	util.UID
+	// recorder пишет события изменений под той же блокировкой, что и само изменение
+	recorder events.Recorder
+}
+
+type Option func(*mapRepository)
+
+// WithRecorder записывает событие о каждом изменении, например в outbox
+func WithRecorder(recorder events.Recorder) Option {
+	return func(m *mapRepository) {
+		m.recorder = recorder
+	}
 }
 
 func (m *mapRepository) AddUser(user entities.User) (int64, error) {
@@ -29,6 +48,9 @@ func (m *mapRepository) AddUser(user entities.User) (int64, error) {
 	defer m.mutex.Unlock()
 
 	const notValidID = -1
+	if m.hasConflict(user, notValidID) {
+		return notValidID, ErrConflict
+	}
 	id, err := m.UID.GenerateID()
 	if err != nil {
 		return notValidID, err
@@ -36,6 +58,7 @@ func (m *mapRepository) AddUser(user entities.User) (int64, error) {
 
 	user.ID = id
 	m.rep[id] = user
+	m.record(events.UserCreated{User: user})
 	return user.ID, nil
 }
 
@@ -43,39 +66,121 @@ func (m *mapRepository) EditUser(setUser entities.User) (*entities.User, error)
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if _, err := m.GetUserByID(setUser.ID); err != nil {
+	prev, err := m.getUserByID(setUser.ID)
+	if err != nil {
 		return &setUser, err
 	}
+	if m.hasConflict(setUser, setUser.ID) {
+		return &setUser, ErrConflict
+	}
 
 	m.rep[setUser.ID] = setUser
+	// подтверждение email меняет только флаг, любое другое изменение - обновление профиля
+	verifiedOnly := *prev
+	verifiedOnly.Verified = true
+	if !prev.Verified && setUser == verifiedOnly {
+		m.record(events.UserVerified{User: setUser})
+	} else {
+		m.record(events.UserUpdated{User: setUser, Prev: *prev})
+	}
 	return &setUser, nil
 }
 
 // GetUserByID получает пользователя по ID. Реализована проверка на существование пользователя
 func (m *mapRepository) GetUserByID(id int64) (*entities.User, error) {
-	m.rMutex.RLock()
-	defer m.rMutex.RUnlock()
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
 
-	empty := &entities.User{}
-	user := m.rep[id]
-	if user == (*empty) {
-		return &user, ErrEmptyUser
+	return m.getUserByID(id)
+}
+
+// GetUsersByIDs пользователи с ids в том же порядке, отсутствующих пропускает
+func (m *mapRepository) GetUsersByIDs(ids []int64) ([]entities.User, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	users := make([]entities.User, 0, len(ids))
+	for _, id := range ids {
+		if user, ok := m.rep[id]; ok {
+			users = append(users, user)
+		}
 	}
-	return &user, nil
+	return users, nil
+}
+
+func (m *mapRepository) GetUserByEmail(email string) (*entities.User, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findUser(func(user entities.User) bool {
+		return strings.EqualFold(user.Email, email)
+	})
+}
+
+func (m *mapRepository) GetUserByNickname(nickname string) (*entities.User, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.findUser(func(user entities.User) bool {
+		return strings.EqualFold(user.Nickname, nickname)
+	})
 }
 
 func (m *mapRepository) DeleteUser(id int64) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if _, err := m.GetUserByID(id); err != nil {
+	user, err := m.getUserByID(id)
+	if err != nil {
 		return err
 	}
 	delete(m.rep, id)
+	m.record(events.UserDeleted{User: *user})
 	return nil
 }
-func New() UserRepository {
-	return &mapRepository{
+
+func (m *mapRepository) getUserByID(id int64) (*entities.User, error) {
+	empty := &entities.User{}
+	user := m.rep[id]
+	if user == (*empty) {
+		return &user, ErrEmptyUser
+	}
+	return &user, nil
+}
+
+func (m *mapRepository) findUser(match func(user entities.User) bool) (*entities.User, error) {
+	for _, user := range m.rep {
+		if match(user) {
+			return &user, nil
+		}
+	}
+	return &entities.User{}, ErrEmptyUser
+}
+
+// hasConflict вызывается под блокировкой на запись, selfID исключается из проверки
+func (m *mapRepository) hasConflict(user entities.User, selfID int64) bool {
+	_, err := m.findUser(func(other entities.User) bool {
+		if other.ID == selfID {
+			return false
+		}
+		return strings.EqualFold(other.Nickname, user.Nickname) || strings.EqualFold(other.Email, user.Email)
+	})
+	return err == nil
+}
+
+// record вызывается под блокировкой на запись
+func (m *mapRepository) record(batch ...events.Event) {
+	if m.recorder != nil && len(batch) > 0 {
+		m.recorder.Record(batch)
+	}
+}
+
+func New(opts ...Option) UserRepository {
+	m := &mapRepository{
 		rep: make(map[int64]entities.User),
 		UID: util.UID{Id: -1}}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
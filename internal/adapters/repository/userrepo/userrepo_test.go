@@ -4,7 +4,10 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	"homework10/internal/util"
+	"sync"
+	"sync/atomic"
 	"testing"
 )
 
@@ -73,6 +76,22 @@ func (s *repoSuite) Test_Repo_GetUserByID() {
 	assert.Equal(s.T(), *userFromRepo, newUser)
 }
 
+func (s *repoSuite) Test_Repo_GetUsersByIDs() {
+	first, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+	secondUser := testUser
+	secondUser.Nickname, secondUser.Email = "Second", "second@example.com"
+	second, err := s.repo.AddUser(secondUser)
+	assert.NoError(s.T(), err)
+
+	users, err := s.repo.GetUsersByIDs([]int64{second, -1, first})
+	assert.NoError(s.T(), err)
+	if assert.Len(s.T(), users, 2) {
+		assert.Equal(s.T(), second, users[0].ID)
+		assert.Equal(s.T(), first, users[1].ID)
+	}
+}
+
 func (s *repoSuite) Test_Repo_DeleteUser() {
 	id, err := s.repo.AddUser(testUser)
 	assert.NoError(s.T(), err)
@@ -110,3 +129,111 @@ func (s *repoSuite) Test_Repo_UpdateUser_NotFound() {
 	_, err := s.repo.EditUser(newUser)
 	assert.ErrorIs(s.T(), err, ErrEmptyUser)
 }
+
+func (s *repoSuite) Test_Repo_GetUserByEmail() {
+	id, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+
+	user, err := s.repo.GetUserByEmail(testUser.Email)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), id, user.ID)
+}
+
+func (s *repoSuite) Test_Repo_GetUserByEmail_NotFound() {
+	_, err := s.repo.GetUserByEmail(testUser.Email)
+	assert.ErrorIs(s.T(), err, ErrEmptyUser)
+}
+
+func (s *repoSuite) Test_Repo_GetUserByEmail_IgnoreCase() {
+	id, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+
+	user, err := s.repo.GetUserByEmail("TestUser@Example.com")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), id, user.ID)
+}
+
+func (s *repoSuite) Test_Repo_GetUserByNickname() {
+	id, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+
+	user, err := s.repo.GetUserByNickname("TEST")
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), id, user.ID)
+
+	_, err = s.repo.GetUserByNickname("unknown")
+	assert.ErrorIs(s.T(), err, ErrEmptyUser)
+}
+
+func (s *repoSuite) Test_Repo_AddUser_Conflict() {
+	_, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+
+	sameNickname := entities.User{Nickname: "TEST", Email: "other@example.com"}
+	_, err = s.repo.AddUser(sameNickname)
+	assert.ErrorIs(s.T(), err, ErrConflict)
+
+	sameEmail := entities.User{Nickname: "other", Email: "TESTUSER@example.com"}
+	_, err = s.repo.AddUser(sameEmail)
+	assert.ErrorIs(s.T(), err, ErrConflict)
+}
+
+func (s *repoSuite) Test_Repo_UpdateUser_Conflict() {
+	_, err := s.repo.AddUser(testUser)
+	assert.NoError(s.T(), err)
+	id, err := s.repo.AddUser(entities.User{Nickname: "other", Email: "other@example.com"})
+	assert.NoError(s.T(), err)
+
+	_, err = s.repo.EditUser(entities.User{ID: id, Nickname: "other", Email: testUser.Email})
+	assert.ErrorIs(s.T(), err, ErrConflict)
+
+	user, err := s.repo.EditUser(entities.User{ID: id, Nickname: "Other", Email: "other@example.com"})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), "Other", user.Nickname)
+}
+
+func Test_Repo_AddUser_ConcurrentConflict(t *testing.T) {
+	repo := New()
+	const attempts = 50
+
+	var wg sync.WaitGroup
+	var created atomic.Int64
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := repo.AddUser(testUser); err == nil {
+				created.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int64(1), created.Load())
+}
+
+// eventLog запоминает записанные репозиторием события
+type eventLog struct {
+	names []string
+}
+
+func (l *eventLog) Record(batch []events.Event) {
+	for _, event := range batch {
+		l.names = append(l.names, event.Name())
+	}
+}
+
+func Test_Repo_Recorder(t *testing.T) {
+	log := &eventLog{}
+	repo := New(WithRecorder(log))
+
+	id, _ := repo.AddUser(testUser)
+	_, _ = repo.AddUser(testUser)
+	user, _ := repo.GetUserByID(id)
+	user.Verified = true
+	_, _ = repo.EditUser(*user)
+	user.Nickname = "renamed"
+	_, _ = repo.EditUser(*user)
+	_ = repo.DeleteUser(id)
+
+	assert.Equal(t, []string{events.NameUserCreated, events.NameUserVerified, events.NameUserUpdated, events.NameUserDeleted}, log.names)
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+)
+
+// Изменения объявлений выполняются под removeMutex на чтение: между проверкой автора и записью
+// объявления пользователь не может быть удален, а его объявления - удалены или переданы
+
+// CreateAd проверяет автора под той же блокировкой, проверка в обработчике могла устареть
+func (a *AdsApp) CreateAd(ctx context.Context, title string, text string, authorID int64) (*entities.Ad, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	if _, err := a.userRepo.GetUserByID(authorID); err != nil {
+		return nil, err
+	}
+	return a.AdService.CreateAd(ctx, title, text, authorID)
+}
+
+func (a *AdsApp) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*entities.Ad, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.ChangeAdStatus(ctx, adID, authorID, published)
+}
+
+func (a *AdsApp) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*entities.Ad, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.UpdateAd(ctx, adID, authorID, title, text)
+}
+
+func (a *AdsApp) PatchAd(ctx context.Context, adID int64, authorID int64, patch service.AdPatch) (*entities.Ad, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.PatchAd(ctx, adID, authorID, patch)
+}
+
+func (a *AdsApp) RemoveAd(ctx context.Context, adID int64, authorID int64) error {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.RemoveAd(ctx, adID, authorID)
+}
+
+func (a *AdsApp) CreateAds(ctx context.Context, ads []service.NewAd, allOrNothing bool) ([]service.BatchResult, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.CreateAds(ctx, ads, allOrNothing)
+}
+
+func (a *AdsApp) ChangeAdsStatus(ctx context.Context, changes []service.AdStatusChange, allOrNothing bool) ([]service.BatchResult, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.ChangeAdsStatus(ctx, changes, allOrNothing)
+}
+
+func (a *AdsApp) RemoveAds(ctx context.Context, refs []service.AdRef, allOrNothing bool) ([]service.BatchResult, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.RemoveAds(ctx, refs, allOrNothing)
+}
+
+func (a *AdsApp) ImportAd(ctx context.Context, ad service.NewAd, dryRun bool) (*entities.Ad, error) {
+	a.removeMutex.RLock()
+	defer a.removeMutex.RUnlock()
+	return a.AdService.ImportAd(ctx, ad, dryRun)
+}
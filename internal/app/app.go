@@ -1,25 +1,292 @@
 package app
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"homework10/internal/adapters/adfile"
 	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/eventrepo"
 	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/outbox"
+	"homework10/internal/quota"
 	"homework10/internal/service"
 	"homework10/internal/util"
+	"homework10/internal/webhook"
+	"io"
+	"sync"
+	"time"
+)
+
+var (
+	ErrBadNewOwner  = apperr.New(apperr.InvalidArgument, "bad_new_owner", "bad new owner of ads")
+	ErrBadAdsPolicy = apperr.New(apperr.InvalidArgument, "bad_ads_policy", "bad ads policy")
+)
+
+// AdsPolicy определяет, что происходит с объявлениями удаляемого пользователя
+type AdsPolicy int
+
+const (
+	// CascadeAds снимает с публикации и удаляет объявления вместе с пользователем
+	CascadeAds AdsPolicy = iota
+	// TransferAds передает объявления другому пользователю
+	TransferAds
 )
 
 //go:generate go run github.com/vektra/mockery/v2@v2.25.0 --name=App --filename=mockApp.go --output ../mocks/appemocks
 type App interface {
 	service.UserService
 	service.AdService
+	RemoveUserWithAds(ctx context.Context, userID int64, policy AdsPolicy, newOwnerID int64) error
+	ExportUserData(ctx context.Context, requesterID int64, userID int64) (*UserExport, error)
+	EraseUser(ctx context.Context, requesterID int64, userID int64) (*entities.User, error)
+	ImportAds(ctx context.Context, r io.Reader, format adfile.Format, dryRun bool) (*ImportReport, error)
+	ExportAds(ctx context.Context, filters service.AdFilters, format adfile.Format, w io.Writer) error
+	OutboxEntries(ctx context.Context, requesterID int64, status outbox.Status) ([]outbox.Entry, error)
+	ReplayOutboxEntry(ctx context.Context, requesterID int64, entryID string) (*outbox.Entry, error)
+	CreateWebhook(ctx context.Context, requesterID int64, url string, secret string, eventTypes []string) (*webhook.Subscription, error)
+	Webhooks(ctx context.Context, requesterID int64) ([]webhook.Subscription, error)
+	DeleteWebhook(ctx context.Context, requesterID int64, webhookID int64) error
+	WebhookDeliveries(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.Delivery, error)
+	WebhookDeadLetters(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.DeadLetter, error)
+	RebuildProjections(ctx context.Context, requesterID int64) (*eventrepo.RebuildReport, error)
+	AdHistory(ctx context.Context, requesterID int64, adID int64) ([]eventrepo.Event, error)
+	AuditLog(ctx context.Context, requesterID int64, filter audit.Filter) ([]audit.Entry, error)
+	UserQuota(ctx context.Context, requesterID int64, userID int64) (*quota.Usage, error)
 }
 
+// AdsApp согласует операции, затрагивающие оба репозитория
 type AdsApp struct {
 	service.UserService
 	service.AdService
+	adRepo   adrepo.AdRepository
+	userRepo userrepo.UserRepository
+	bus      events.Bus
+	outbox   outbox.Store
+	webhooks webhook.Store
+	audit    audit.Store
+	admins   map[int64]struct{}
+	// removeMutex изменения объявлений берут его на чтение, удаление пользователя - на запись.
+	// Пока объявления удаляются или переходят к новому владельцу, объявления не создаются и не меняются,
+	// поэтому не остается объявлений без автора, а откат не перезаписывает чужих изменений
+	removeMutex sync.RWMutex
+}
+
+// RemoveUser удаляет пользователя вместе с его объявлениями
+func (a *AdsApp) RemoveUser(ctx context.Context, userID int64) error {
+	return a.RemoveUserWithAds(ctx, userID, CascadeAds, 0)
+}
+
+// RemoveUserWithAds удаляет пользователя атомарно: если удаление не удалось, объявления возвращаются в прежнее состояние.
+// Остальные изменения объявлений ждут конца удаления, см. ads.go
+func (a *AdsApp) RemoveUserWithAds(ctx context.Context, userID int64, policy AdsPolicy, newOwnerID int64) error {
+	a.removeMutex.Lock()
+	defer a.removeMutex.Unlock()
+	// пользователь удаляет себя сам, он же автор изменений его объявлений
+	ctx = audit.WithActor(ctx, userID)
+
+	user, err := a.userRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	var changed []entities.Ad
+	switch policy {
+	case CascadeAds:
+		changed, err = a.adRepo.DeleteAdsByAuthor(userID)
+	case TransferAds:
+		if err = a.checkNewOwner(ctx, userID, newOwnerID); err != nil {
+			return err
+		}
+		var updateTime time.Time
+		updateTime, err = a.GetDateTimeFormat().ToTime(time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		changed, err = a.adRepo.ChangeAdsAuthor(userID, newOwnerID, updateTime)
+	default:
+		return ErrBadAdsPolicy
+	}
+	if err != nil {
+		return errors.Join(err, a.adRepo.RestoreAds(changed))
+	}
+
+	if err = a.userRepo.DeleteUser(userID); err != nil {
+		return errors.Join(err, a.adRepo.RestoreAds(changed))
+	}
+	a.publishRemoved(ctx, policy, changed)
+	a.bus.Publish(ctx, events.UserDeleted{User: *user})
+	return nil
+}
+
+// publishRemoved сообщает подписчикам об объявлениях, удаленных или переданных вместе с пользователем
+func (a *AdsApp) publishRemoved(ctx context.Context, policy AdsPolicy, changed []entities.Ad) {
+	for _, prev := range changed {
+		if policy == CascadeAds {
+			a.bus.Publish(ctx, events.AdDeleted{Ad: prev})
+			continue
+		}
+		ad, err := a.adRepo.GetAdByID(prev.ID)
+		if err != nil {
+			continue
+		}
+		a.bus.Publish(ctx, events.AdUpdated{Ad: *ad, Prev: prev})
+	}
+}
+
+// checkNewOwner новый владелец должен существовать, отличаться от удаляемого, иметь подтвержденный email
+// и место в тарифе для опубликованных объявлений удаляемого. Вызывается под removeMutex, поэтому
+// число опубликованных объявлений не меняется до передачи
+func (a *AdsApp) checkNewOwner(ctx context.Context, userID, newOwnerID int64) error {
+	if newOwnerID == userID {
+		return ErrBadNewOwner
+	}
+	owner, err := a.userRepo.GetUserByID(newOwnerID)
+	if err != nil {
+		return ErrBadNewOwner
+	}
+	if !owner.Verified {
+		return service.ErrNotVerified
+	}
+	usage, err := a.QuotaUsage(ctx, newOwnerID)
+	if err != nil {
+		return err
+	}
+	moving, err := a.adRepo.ActiveAds(userID)
+	if err != nil {
+		return err
+	}
+	if limit := usage.Plan.MaxActiveAds; limit > 0 && usage.ActiveAds+moving > limit {
+		return &quota.ExceededError{Kind: quota.ActiveAds, Plan: usage.Plan.Name, Limit: limit}
+	}
+	return nil
+}
+
+type Option func(*options)
+
+type options struct {
+	signer   util.TokenSigner
+	sender   service.VerificationSender
+	admins   map[int64]struct{}
+	bus      events.Bus
+	outbox   outbox.Store
+	webhooks webhook.Store
+	audit    audit.Store
+	quotas   *quota.Policy
+}
+
+// WithAdmins задает пользователей, которым доступны персональные данные всех пользователей
+func WithAdmins(ids ...int64) Option {
+	return func(o *options) {
+		for _, id := range ids {
+			o.admins[id] = struct{}{}
+		}
+	}
+}
+
+// WithEventBus задает шину доменных событий, по умолчанию создается своя без подписчиков
+func WithEventBus(bus events.Bus) Option {
+	return func(o *options) {
+		o.bus = bus
+	}
+}
+
+// WithOutbox задает outbox, в который пишут репозитории, чтобы администратор мог его просматривать
+func WithOutbox(store outbox.Store) Option {
+	return func(o *options) {
+		o.outbox = store
+	}
+}
+
+// WithWebhooks задает хранилище подписок, из которого читает webhook.Dispatcher
+func WithWebhooks(store webhook.Store) Option {
+	return func(o *options) {
+		o.webhooks = store
+	}
+}
+
+// WithAuditLog задает журнал аудита, в который пишутся все изменения объявлений и пользователей
+func WithAuditLog(store audit.Store) Option {
+	return func(o *options) {
+		o.audit = store
+	}
+}
+
+// WithQuotas включает лимиты объявлений по тарифам, например quota.DefaultPolicy. По умолчанию лимитов нет
+func WithQuotas(policy quota.Policy) Option {
+	return func(o *options) {
+		o.quotas = &policy
+	}
+}
+
+// WithTokenSigner задает ключ подписи токенов подтверждения email
+func WithTokenSigner(signer util.TokenSigner) Option {
+	return func(o *options) {
+		o.signer = signer
+	}
+}
+
+// WithVerificationSender задает способ доставки токенов подтверждения email
+func WithVerificationSender(sender service.VerificationSender) Option {
+	return func(o *options) {
+		o.sender = sender
+	}
 }
 
-func NewApp(adRepo adrepo.AdRepository, userRepo userrepo.UserRepository, formatter util.DateTimeFormatter) App {
-	userService := service.NewUserService(userRepo)
-	adService := service.NewAdsService(adRepo, formatter)
-	return &AdsApp{userService, adService}
+func NewApp(adRepo adrepo.AdRepository, userRepo userrepo.UserRepository, formatter util.DateTimeFormatter, opts ...Option) App {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.bus == nil {
+		o.bus = events.NewBus()
+	}
+	if o.outbox == nil {
+		o.outbox = outbox.NewStore()
+	}
+	if o.webhooks == nil {
+		o.webhooks = webhook.NewStore()
+	}
+	if o.audit == nil {
+		o.audit = audit.NewStore()
+	}
+	o.bus.Subscribe(audit.Subscriber(o.audit))
+	userService := service.NewUserService(userRepo, o.signer, o.sender, o.bus)
+	var adOpts []service.AdServiceOption
+	if o.quotas != nil {
+		adOpts = append(adOpts, service.WithQuotas(*o.quotas, roleOf(o.admins)))
+	}
+	adService := service.NewAdsService(adRepo, userRepo, formatter, o.bus, adOpts...)
+	return &AdsApp{
+		UserService: userService,
+		AdService:   adService,
+		adRepo:      adRepo,
+		userRepo:    userRepo,
+		bus:         o.bus,
+		outbox:      o.outbox,
+		webhooks:    o.webhooks,
+		audit:       o.audit,
+		admins:      o.admins,
+	}
+}
+
+// defaultOptions подписывает токены случайным ключом и никуда их не отправляет
+func defaultOptions() options {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return options{
+		signer: util.NewHMACTokenSigner(secret),
+		sender: discardSender{},
+		admins: make(map[int64]struct{}),
+	}
+}
+
+type discardSender struct{}
+
+func (discardSender) SendVerification(ctx context.Context, user entities.User, token string) error {
+	return nil
 }
@@ -0,0 +1,488 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"homework10/internal/adapters/adfile"
+	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/eventrepo"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/audit"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	mocks "homework10/internal/mocks/repomocks"
+	"homework10/internal/outbox"
+	"homework10/internal/quota"
+	"homework10/internal/service"
+	"homework10/internal/util"
+	"homework10/internal/webhook"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type appSuite struct {
+	suite.Suite
+	adRepo   adrepo.AdRepository
+	userRepo userrepo.UserRepository
+	app      App
+	owner    int64
+	ads      []int64
+}
+
+func TestSuiteApp(t *testing.T) {
+	suite.Run(t, new(appSuite))
+}
+
+func (s *appSuite) SetupTest() {
+	s.adRepo = adrepo.New()
+	s.userRepo = userrepo.New()
+	s.app = NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime))
+
+	var err error
+	s.owner, err = s.userRepo.AddUser(entities.User{Nickname: "owner", Email: "owner@mail.ru", Verified: true})
+	s.Require().NoError(err)
+	s.ads = nil
+	for i := 0; i < 2; i++ {
+		ad, err := s.app.CreateAd(context.Background(), "title", "text", s.owner)
+		s.Require().NoError(err)
+		s.ads = append(s.ads, ad.ID)
+	}
+}
+
+func (s *appSuite) Test_RemoveUser_Cascade() {
+	err := s.app.RemoveUserWithAds(context.Background(), s.owner, CascadeAds, 0)
+	s.NoError(err)
+
+	_, err = s.app.GetUserByID(context.Background(), s.owner)
+	s.ErrorIs(err, userrepo.ErrEmptyUser)
+	for _, id := range s.ads {
+		_, err = s.app.GetAdByID(context.Background(), id)
+		s.ErrorIs(err, util.ErrNotFound)
+	}
+}
+
+func (s *appSuite) Test_RemoveUser_ConcurrentCreate() {
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// удаление может пройти раньше, тогда создание вернет ошибку
+			_, _ = s.app.CreateAd(context.Background(), "title", "text", s.owner)
+		}()
+	}
+	s.NoError(s.app.RemoveUser(context.Background(), s.owner))
+	wg.Wait()
+
+	// объявлений без автора не осталось
+	ads, err := s.adRepo.GetAdsByFilters([]func(ad entities.Ad) bool{func(ad entities.Ad) bool {
+		return ad.AuthorID == s.owner
+	}})
+	s.NoError(err)
+	s.Empty(ads)
+}
+
+func (s *appSuite) Test_RemoveUser_Events() {
+	bus := events.NewBus()
+	var got []events.Event
+	bus.Subscribe(events.Subscriber{
+		Events: []string{events.NameAdDeleted, events.NameUserDeleted},
+		Handler: func(ctx context.Context, event events.Event) error {
+			got = append(got, event)
+			return nil
+		},
+	})
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithEventBus(bus))
+
+	err := a.RemoveUserWithAds(context.Background(), s.owner, CascadeAds, 0)
+	s.NoError(err)
+	s.Len(got, len(s.ads)+1)
+	deleted := make([]int64, 0)
+	for _, event := range got[:len(s.ads)] {
+		deleted = append(deleted, event.(events.AdDeleted).Ad.ID)
+	}
+	s.ElementsMatch(s.ads, deleted)
+	s.Equal(s.owner, got[len(s.ads)].(events.UserDeleted).User.ID)
+}
+
+func (s *appSuite) Test_RemoveUser_DefaultCascade() {
+	err := s.app.RemoveUser(context.Background(), s.owner)
+	s.NoError(err)
+
+	_, err = s.app.GetAdByID(context.Background(), s.ads[0])
+	s.ErrorIs(err, util.ErrNotFound)
+}
+
+func (s *appSuite) Test_RemoveUser_Transfer() {
+	newOwner, _ := s.userRepo.AddUser(entities.User{Nickname: "new", Email: "new@mail.ru", Verified: true})
+
+	err := s.app.RemoveUserWithAds(context.Background(), s.owner, TransferAds, newOwner)
+	s.NoError(err)
+	for _, id := range s.ads {
+		ad, err := s.app.GetAdByID(context.Background(), id)
+		s.NoError(err)
+		s.Equal(newOwner, ad.AuthorID)
+	}
+}
+
+func (s *appSuite) Test_RemoveUser_TransferBadOwner() {
+	err := s.app.RemoveUserWithAds(context.Background(), s.owner, TransferAds, s.owner)
+	s.ErrorIs(err, ErrBadNewOwner)
+
+	err = s.app.RemoveUserWithAds(context.Background(), s.owner, TransferAds, 100)
+	s.ErrorIs(err, ErrBadNewOwner)
+
+	_, err = s.app.GetUserByID(context.Background(), s.owner)
+	s.NoError(err)
+}
+
+func (s *appSuite) Test_RemoveUser_TransferQuota() {
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime),
+		WithQuotas(quota.Policy{Default: quota.Plan{Name: "small", MaxActiveAds: 2}}))
+	newOwner, _ := s.userRepo.AddUser(entities.User{Nickname: "new", Email: "new@mail.ru", Verified: true})
+	for _, id := range s.ads {
+		_, err := a.ChangeAdStatus(context.Background(), id, s.owner, true)
+		s.Require().NoError(err)
+	}
+	ad, err := a.CreateAd(context.Background(), "own", "text", newOwner)
+	s.Require().NoError(err)
+	_, err = a.ChangeAdStatus(context.Background(), ad.ID, newOwner, true)
+	s.Require().NoError(err)
+
+	// у нового владельца уже 1 из 2, два опубликованных объявления не помещаются
+	err = a.RemoveUserWithAds(context.Background(), s.owner, TransferAds, newOwner)
+	s.ErrorIs(err, quota.ErrExceeded)
+	_, err = a.GetUserByID(context.Background(), s.owner)
+	s.NoError(err)
+	moved, err := a.GetAdByID(context.Background(), s.ads[0])
+	s.NoError(err)
+	s.Equal(s.owner, moved.AuthorID)
+
+	_, err = a.ChangeAdStatus(context.Background(), ad.ID, newOwner, false)
+	s.Require().NoError(err)
+	s.NoError(a.RemoveUserWithAds(context.Background(), s.owner, TransferAds, newOwner))
+}
+
+func (s *appSuite) Test_RemoveUser_TransferNotVerified() {
+	newOwner, _ := s.userRepo.AddUser(entities.User{Nickname: "new", Email: "new@mail.ru"})
+
+	err := s.app.RemoveUserWithAds(context.Background(), s.owner, TransferAds, newOwner)
+	s.ErrorIs(err, service.ErrNotVerified)
+
+	ad, err := s.app.GetAdByID(context.Background(), s.ads[0])
+	s.NoError(err)
+	s.Equal(s.owner, ad.AuthorID)
+}
+
+func (s *appSuite) Test_RemoveUser_BadPolicy() {
+	err := s.app.RemoveUserWithAds(context.Background(), s.owner, AdsPolicy(100), 0)
+	s.ErrorIs(err, ErrBadAdsPolicy)
+}
+
+func (s *appSuite) Test_RemoveUser_NotFound() {
+	err := s.app.RemoveUserWithAds(context.Background(), 100, CascadeAds, 0)
+	s.ErrorIs(err, userrepo.ErrEmptyUser)
+}
+
+// Test_RemoveUser_Rollback при ошибке удаления пользователя объявления возвращаются
+func (s *appSuite) Test_RemoveUser_Rollback() {
+	errDelete := errors.New("delete failed")
+	owner, _ := s.userRepo.GetUserByID(s.owner)
+	userRepo := new(mocks.UserRepository)
+	userRepo.On("GetUserByID", s.owner).Return(owner, nil)
+	userRepo.On("DeleteUser", mock.Anything).Return(errDelete)
+	a := NewApp(s.adRepo, userRepo, util.NewDateTimeFormatter(time.DateTime))
+
+	err := a.RemoveUserWithAds(context.Background(), s.owner, CascadeAds, 0)
+	s.ErrorIs(err, errDelete)
+	for _, id := range s.ads {
+		ad, err := s.app.GetAdByID(context.Background(), id)
+		s.NoError(err)
+		s.Equal(s.owner, ad.AuthorID)
+	}
+}
+
+func (s *appSuite) Test_ExportUserData() {
+	export, err := s.app.ExportUserData(context.Background(), s.owner, s.owner)
+	s.NoError(err)
+	s.Equal(s.owner, export.Profile.ID)
+	s.Len(export.Ads, len(s.ads))
+
+	data, err := export.Encode(ExportJSON)
+	s.NoError(err)
+	var decoded UserExport
+	s.NoError(json.Unmarshal(data, &decoded))
+	s.Equal(export.Profile, decoded.Profile)
+}
+
+func (s *appSuite) Test_ExportUserData_Zip() {
+	export, err := s.app.ExportUserData(context.Background(), s.owner, s.owner)
+	s.NoError(err)
+
+	data, err := export.Encode(ExportZIP)
+	s.NoError(err)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	s.NoError(err)
+	names := make([]string, 0)
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	s.ElementsMatch([]string{"profile.json", "ads.json"}, names)
+
+	_, err = export.Encode("xml")
+	s.ErrorIs(err, ErrBadExportFormat)
+}
+
+func (s *appSuite) Test_ExportUserData_Forbidden() {
+	other, _ := s.userRepo.AddUser(entities.User{Nickname: "other", Email: "other@mail.ru"})
+
+	_, err := s.app.ExportUserData(context.Background(), other, s.owner)
+	s.ErrorIs(err, ErrForbidden)
+	_, err = s.app.EraseUser(context.Background(), other, s.owner)
+	s.ErrorIs(err, ErrForbidden)
+}
+
+func (s *appSuite) Test_ExportUserData_Admin() {
+	admin, _ := s.userRepo.AddUser(entities.User{Nickname: "admin", Email: "admin@mail.ru"})
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithAdmins(admin))
+
+	export, err := a.ExportUserData(context.Background(), admin, s.owner)
+	s.NoError(err)
+	s.Equal(s.owner, export.Profile.ID)
+}
+
+func (s *appSuite) Test_EraseUser() {
+	user, err := s.app.EraseUser(context.Background(), s.owner, s.owner)
+	s.NoError(err)
+	s.Equal(s.owner, user.ID)
+	s.False(user.Verified)
+	s.NotContains(user.Email, "owner")
+	s.NotContains(user.Nickname, "owner")
+
+	// объявления остаются и учитываются в статистике
+	ad, err := s.app.GetAdByID(context.Background(), s.ads[0])
+	s.NoError(err)
+	s.Equal(s.owner, ad.AuthorID)
+	_, err = s.userRepo.GetUserByEmail("owner@mail.ru")
+	s.ErrorIs(err, userrepo.ErrEmptyUser)
+}
+
+func (s *appSuite) Test_ImportAds() {
+	file := fmt.Sprintf("title,text,user_id\nhello,world,%d\n,world,%d\nhello,world,100\n", s.owner, s.owner)
+
+	report, err := s.app.ImportAds(context.Background(), strings.NewReader(file), adfile.CSV, false)
+	s.NoError(err)
+	s.Equal(3, report.Total)
+	s.Equal(1, report.Valid)
+	s.Equal(2, report.Failed)
+	s.NotNil(report.Rows[0].AdID)
+	s.NotNil(report.Rows[1].Error)
+	s.NotNil(report.Rows[2].Error)
+
+	_, err = s.app.GetAdByID(context.Background(), *report.Rows[0].AdID)
+	s.NoError(err)
+}
+
+func (s *appSuite) Test_ImportAds_DryRun() {
+	file := fmt.Sprintf(`{"title":"hello","text":"world","user_id":%d}`, s.owner)
+
+	report, err := s.app.ImportAds(context.Background(), strings.NewReader(file), adfile.JSONL, true)
+	s.NoError(err)
+	s.True(report.DryRun)
+	s.Equal(1, report.Valid)
+	s.Nil(report.Rows[0].AdID)
+
+	ads, err := s.adRepo.GetAdsByFilters(nil)
+	s.NoError(err)
+	s.Len(ads, len(s.ads))
+}
+
+func (s *appSuite) Test_ExportAds() {
+	var buf bytes.Buffer
+	filters := service.AdFilters{AuthorID: s.owner, Published: false}
+
+	err := s.app.ExportAds(context.Background(), filters, adfile.JSONL, &buf)
+	s.NoError(err)
+	s.Equal(len(s.ads), strings.Count(buf.String(), "\n"))
+
+	// экспорт можно загрузить обратно
+	report, err := s.app.ImportAds(context.Background(), &buf, adfile.JSONL, true)
+	s.NoError(err)
+	s.Equal(len(s.ads), report.Valid)
+}
+
+func (s *appSuite) Test_OutboxEntries() {
+	store := outbox.NewStore()
+	adRepo := adrepo.New(adrepo.WithRecorder(store))
+	userRepo := userrepo.New(userrepo.WithRecorder(store))
+	a := NewApp(adRepo, userRepo, util.NewDateTimeFormatter(time.DateTime), WithOutbox(store), WithAdmins(100))
+	owner, err := a.CreateUser(context.Background(), "owner", "owner@mail.ru")
+	s.Require().NoError(err)
+	_, err = a.CreateAd(context.Background(), "title", "text", owner.ID)
+	s.Require().NoError(err)
+
+	_, err = a.OutboxEntries(context.Background(), owner.ID, "")
+	s.ErrorIs(err, ErrForbidden)
+	entries, err := a.OutboxEntries(context.Background(), 100, outbox.Pending)
+	s.NoError(err)
+	s.Len(entries, 2)
+	s.Equal(events.NameUserCreated, entries[0].Event)
+	s.Equal(events.NameAdCreated, entries[1].Event)
+
+	relay := outbox.NewRelay(store, func(ctx context.Context, entry outbox.Entry) error {
+		return errors.New("down")
+	}, outbox.WithRetry(events.NoRetry))
+	relay.Flush(context.Background())
+	failed, err := a.OutboxEntries(context.Background(), 100, outbox.Failed)
+	s.NoError(err)
+	s.Len(failed, 2)
+
+	_, err = a.ReplayOutboxEntry(context.Background(), owner.ID, failed[0].ID)
+	s.ErrorIs(err, ErrForbidden)
+	entry, err := a.ReplayOutboxEntry(context.Background(), 100, failed[0].ID)
+	s.NoError(err)
+	s.Equal(outbox.Pending, entry.Status)
+}
+
+func (s *appSuite) Test_Webhooks() {
+	received := make(chan webhook.Payload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		s.NoError(json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer receiver.Close()
+
+	store := outbox.NewStore()
+	hooks := webhook.NewStore()
+	adRepo := adrepo.New(adrepo.WithRecorder(store))
+	userRepo := userrepo.New(userrepo.WithRecorder(store))
+	a := NewApp(adRepo, userRepo, util.NewDateTimeFormatter(time.DateTime), WithOutbox(store), WithWebhooks(hooks), WithAdmins(100))
+
+	_, err := a.CreateWebhook(context.Background(), 1, receiver.URL, "", []string{events.NameAdCreated})
+	s.ErrorIs(err, ErrForbidden)
+	_, err = a.CreateWebhook(context.Background(), 100, receiver.URL, "", []string{events.NameUserCreated})
+	s.ErrorIs(err, webhook.ErrBadEvents)
+	sub, err := a.CreateWebhook(context.Background(), 100, receiver.URL, "", []string{events.NameAdCreated})
+	s.Require().NoError(err)
+	s.NotEmpty(sub.Secret)
+
+	owner, err := a.CreateUser(context.Background(), "owner", "owner@mail.ru")
+	s.Require().NoError(err)
+	ad, err := a.CreateAd(context.Background(), "title", "text", owner.ID)
+	s.Require().NoError(err)
+
+	dispatcher := webhook.NewDispatcher(hooks)
+	relay := outbox.NewRelay(store, dispatcher.Handle)
+	s.Equal(2, relay.Flush(context.Background()))
+	dispatcher.Close()
+
+	s.Require().Len(received, 1)
+	payload := <-received
+	s.Equal(events.NameAdCreated, payload.Event)
+	var created events.AdCreated
+	s.NoError(json.Unmarshal(payload.Data, &created))
+	s.Equal(ad.ID, created.Ad.ID)
+
+	deliveries, err := a.WebhookDeliveries(context.Background(), 100, sub.ID)
+	s.NoError(err)
+	s.Len(deliveries, 1)
+	letters, err := a.WebhookDeadLetters(context.Background(), 100, sub.ID)
+	s.NoError(err)
+	s.Empty(letters)
+	subs, err := a.Webhooks(context.Background(), 100)
+	s.NoError(err)
+	s.Len(subs, 1)
+
+	s.NoError(a.DeleteWebhook(context.Background(), 100, sub.ID))
+	s.ErrorIs(a.DeleteWebhook(context.Background(), 100, sub.ID), webhook.ErrNotFound)
+	_, err = a.WebhookDeliveries(context.Background(), 100, sub.ID)
+	s.ErrorIs(err, webhook.ErrNotFound)
+}
+
+func (s *appSuite) Test_RebuildProjections() {
+	_, err := s.app.RebuildProjections(context.Background(), 100)
+	s.ErrorIs(err, ErrForbidden)
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithAdmins(100))
+	_, err = a.RebuildProjections(context.Background(), 100)
+	s.ErrorIs(err, ErrNotEventSourced)
+
+	adRepo, err := eventrepo.New(eventrepo.NewMemoryStore())
+	s.Require().NoError(err)
+	a = NewApp(adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithAdmins(100))
+	ad, err := a.CreateAd(context.Background(), "title", "text", s.owner)
+	s.Require().NoError(err)
+	_, err = a.ChangeAdStatus(context.Background(), ad.ID, s.owner, true)
+	s.Require().NoError(err)
+	s.Require().NoError(a.RemoveUser(context.Background(), s.owner))
+
+	report, err := a.RebuildProjections(context.Background(), 100)
+	s.NoError(err)
+	s.Equal(3, report.Events)
+	s.Equal(0, report.Projections["ads"])
+
+	history, err := a.AdHistory(context.Background(), 100, ad.ID)
+	s.NoError(err)
+	s.Equal([]eventrepo.EventType{eventrepo.Created, eventrepo.StatusChanged, eventrepo.Deleted},
+		[]eventrepo.EventType{history[0].Type, history[1].Type, history[2].Type})
+}
+
+func (s *appSuite) Test_AuditLog() {
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithAdmins(100))
+	ctx := audit.WithOrigin(context.Background(), audit.Origin{Transport: audit.GRPC, IP: "10.0.0.2"})
+	ad, err := a.UpdateAd(ctx, s.ads[0], s.owner, "new title", "new text")
+	s.Require().NoError(err)
+	_, err = a.EraseUser(ctx, 100, s.owner)
+	s.Require().NoError(err)
+
+	_, err = a.AuditLog(context.Background(), s.owner, audit.Filter{})
+	s.ErrorIs(err, ErrForbidden)
+
+	entries, err := a.AuditLog(context.Background(), 100, audit.Filter{Target: audit.TargetAd, TargetID: &ad.ID})
+	s.NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(events.NameAdUpdated, entries[0].Action)
+	s.Equal(s.owner, entries[0].Actor)
+	s.Equal(audit.GRPC, entries[0].Transport)
+	s.Equal("10.0.0.2", entries[0].IP)
+
+	admin := int64(100)
+	entries, err = a.AuditLog(context.Background(), 100, audit.Filter{Actor: &admin})
+	s.NoError(err)
+	s.Require().Len(entries, 1)
+	s.Equal(events.NameUserErased, entries[0].Action)
+	s.Equal(s.owner, entries[0].TargetID)
+}
+
+func (s *appSuite) Test_UserQuota() {
+	admin, err := s.userRepo.AddUser(entities.User{Nickname: "admin", Email: "admin@mail.ru", Verified: true})
+	s.Require().NoError(err)
+	a := NewApp(s.adRepo, s.userRepo, util.NewDateTimeFormatter(time.DateTime), WithAdmins(admin), WithQuotas(quota.DefaultPolicy))
+	_, err = a.ChangeAdStatus(context.Background(), s.ads[0], s.owner, true)
+	s.Require().NoError(err)
+
+	usage, err := a.UserQuota(context.Background(), s.owner, s.owner)
+	s.NoError(err)
+	s.Equal(quota.Free, usage.Plan)
+	s.Equal(1, usage.ActiveAds)
+	// объявления из SetupTest созданы другим экземпляром App без лимитов
+	s.Equal(0, usage.DailyAds)
+
+	usage, err = a.UserQuota(context.Background(), admin, admin)
+	s.NoError(err)
+	s.Equal(quota.Unlimited, usage.Plan)
+	_, err = a.UserQuota(context.Background(), admin, s.owner)
+	s.NoError(err)
+	_, err = a.UserQuota(context.Background(), s.owner, admin)
+	s.ErrorIs(err, ErrForbidden)
+}
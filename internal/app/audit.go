@@ -0,0 +1,20 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/audit"
+)
+
+// auditListLimit столько записей аудита возвращается за один запрос
+const auditListLimit = 500
+
+// AuditLog записи журнала аудита для администратора, новые первыми
+func (a *AdsApp) AuditLog(ctx context.Context, requesterID int64, filter audit.Filter) ([]audit.Entry, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	if filter.Limit <= 0 || filter.Limit > auditListLimit {
+		filter.Limit = auditListLimit
+	}
+	return a.audit.Query(filter)
+}
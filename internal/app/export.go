@@ -0,0 +1,126 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"time"
+)
+
+var (
+	ErrForbidden       = apperr.New(apperr.PermissionDenied, "forbidden", "only the user or an admin can access personal data")
+	ErrBadExportFormat = apperr.New(apperr.InvalidArgument, "bad_export_format", "bad export format")
+)
+
+type ExportFormat string
+
+const (
+	ExportJSON ExportFormat = "json"
+	ExportZIP  ExportFormat = "zip"
+)
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportZIP {
+		return "application/zip"
+	}
+	return "application/json"
+}
+
+// UserExport персональные данные пользователя для ответа на запрос субъекта данных
+type UserExport struct {
+	Profile    entities.User `json:"profile"`
+	Ads        []entities.Ad `json:"ads"`
+	ExportedAt time.Time     `json:"exported_at"`
+}
+
+// Encode json - один документ, zip - архив с profile.json и ads.json
+func (e *UserExport) Encode(format ExportFormat) ([]byte, error) {
+	switch format {
+	case ExportJSON:
+		return json.Marshal(e)
+	case ExportZIP:
+		return e.zip()
+	default:
+		return nil, ErrBadExportFormat
+	}
+}
+
+func (e *UserExport) zip() ([]byte, error) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := map[string]any{
+		"profile.json": e.Profile,
+		"ads.json":     e.Ads,
+	}
+	for _, name := range []string{"profile.json", "ads.json"} {
+		w, err := archive.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: e.ExportedAt})
+		if err != nil {
+			return nil, err
+		}
+		if err = json.NewEncoder(w).Encode(files[name]); err != nil {
+			return nil, err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExportUserData выгружает профиль и все объявления пользователя, включая неопубликованные
+func (a *AdsApp) ExportUserData(ctx context.Context, requesterID int64, userID int64) (*UserExport, error) {
+	if err := a.authorizePersonalData(requesterID, userID); err != nil {
+		return &UserExport{}, err
+	}
+	user, err := a.userRepo.GetUserByID(userID)
+	if err != nil {
+		return &UserExport{}, err
+	}
+	ads, err := a.adRepo.GetAdsByFilters([]func(ad entities.Ad) bool{
+		func(ad entities.Ad) bool { return ad.AuthorID == userID },
+	})
+	if err != nil {
+		return &UserExport{}, err
+	}
+	exportedAt, err := a.GetDateTimeFormat().ToTime(time.Now().UTC())
+	if err != nil {
+		return &UserExport{}, err
+	}
+	return &UserExport{Profile: *user, Ads: ads, ExportedAt: exportedAt}, nil
+}
+
+// EraseUser обезличивает персональные поля пользователя. Пользователь и его объявления сохраняются,
+// поэтому статистика по объявлениям и авторам не меняется
+func (a *AdsApp) EraseUser(ctx context.Context, requesterID int64, userID int64) (*entities.User, error) {
+	if err := a.authorizePersonalData(requesterID, userID); err != nil {
+		return &entities.User{}, err
+	}
+	user, err := a.userRepo.GetUserByID(userID)
+	if err != nil {
+		return user, err
+	}
+	erased, err := a.userRepo.EditUser(entities.User{
+		ID:       user.ID,
+		Nickname: fmt.Sprintf("erased_%d", user.ID),
+		Email:    fmt.Sprintf("erased_%d@erased.invalid", user.ID),
+		Verified: false,
+	})
+	if err != nil {
+		return erased, err
+	}
+	a.bus.Publish(audit.WithActor(ctx, requesterID), events.UserErased{User: *erased})
+	return erased, nil
+}
+
+func (a *AdsApp) authorizePersonalData(requesterID int64, userID int64) error {
+	if _, isAdmin := a.admins[requesterID]; requesterID == userID || isAdmin {
+		return nil
+	}
+	return ErrForbidden
+}
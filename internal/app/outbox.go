@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/outbox"
+	"time"
+)
+
+// outboxListLimit столько записей outbox возвращается за один запрос
+const outboxListLimit = 500
+
+// OutboxEntries записи outbox для администратора, status "failed" - застрявшие записи
+func (a *AdsApp) OutboxEntries(ctx context.Context, requesterID int64, status outbox.Status) ([]outbox.Entry, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	return a.outbox.List(status, outboxListLimit)
+}
+
+// ReplayOutboxEntry возвращает застрявшую запись в очередь релея
+func (a *AdsApp) ReplayOutboxEntry(ctx context.Context, requesterID int64, entryID string) (*outbox.Entry, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return &outbox.Entry{}, err
+	}
+	entry, err := a.outbox.Replay(entryID, time.Now().UTC())
+	return &entry, err
+}
+
+func (a *AdsApp) authorizeAdmin(requesterID int64) error {
+	if _, isAdmin := a.admins[requesterID]; !isAdmin {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/adapters/repository/eventrepo"
+	"homework10/internal/apperr"
+)
+
+var ErrNotEventSourced = apperr.New(apperr.Unimplemented, "not_event_sourced", "ad repository is not event sourced")
+
+// RebuildProjections перестраивает проекции объявлений из журнала событий
+func (a *AdsApp) RebuildProjections(ctx context.Context, requesterID int64) (*eventrepo.RebuildReport, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return &eventrepo.RebuildReport{}, err
+	}
+	repo, ok := a.adRepo.(eventrepo.Repository)
+	if !ok {
+		return &eventrepo.RebuildReport{}, ErrNotEventSourced
+	}
+	report, err := repo.Rebuild()
+	return &report, err
+}
+
+// AdHistory события объявления из журнала, в том числе после удаления
+func (a *AdsApp) AdHistory(ctx context.Context, requesterID int64, adID int64) ([]eventrepo.Event, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	repo, ok := a.adRepo.(eventrepo.Repository)
+	if !ok {
+		return nil, ErrNotEventSourced
+	}
+	return repo.History(adID)
+}
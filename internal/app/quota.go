@@ -0,0 +1,24 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/quota"
+)
+
+// UserQuota использование лимитов тарифа, доступно самому пользователю и администратору
+func (a *AdsApp) UserQuota(ctx context.Context, requesterID int64, userID int64) (*quota.Usage, error) {
+	if err := a.authorizePersonalData(requesterID, userID); err != nil {
+		return nil, err
+	}
+	return a.QuotaUsage(ctx, userID)
+}
+
+// roleOf роль пользователя для выбора тарифа
+func roleOf(admins map[int64]struct{}) func(userID int64) quota.Role {
+	return func(userID int64) quota.Role {
+		if _, isAdmin := admins[userID]; isAdmin {
+			return quota.RoleAdmin
+		}
+		return quota.RoleUser
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"homework10/internal/adapters/adfile"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"io"
+)
+
+// ImportRowReport результат одной строки файла. AdID не заполняется для ошибок и в режиме dry run
+type ImportRowReport struct {
+	Line  int     `json:"line"`
+	AdID  *int64  `json:"ad_id"`
+	Error *string `json:"error"`
+}
+
+type ImportReport struct {
+	DryRun bool              `json:"dry_run"`
+	Total  int               `json:"total"`
+	Valid  int               `json:"valid"`
+	Failed int               `json:"failed"`
+	Rows   []ImportRowReport `json:"rows"`
+}
+
+// ImportAds читает файл построчно и создает объявления через ImportAd. Ошибка в строке попадает в отчет
+// и не прерывает импорт, ошибка возвращается только если файл нельзя читать дальше
+func (a *AdsApp) ImportAds(ctx context.Context, r io.Reader, format adfile.Format, dryRun bool) (*ImportReport, error) {
+	report := &ImportReport{DryRun: dryRun, Rows: make([]ImportRowReport, 0)}
+	reader, err := adfile.NewReader(r, format)
+	if err != nil {
+		return report, err
+	}
+	for {
+		if err = ctx.Err(); err != nil {
+			return report, err
+		}
+		var row adfile.Row
+		row, err = reader.Next()
+		if errors.Is(err, io.EOF) {
+			return report, nil
+		}
+		if err != nil {
+			return report, err
+		}
+
+		report.Total++
+		rowReport := ImportRowReport{Line: row.Line}
+		rowErr := row.Err
+		if rowErr == nil {
+			var ad *entities.Ad
+			ad, rowErr = a.ImportAd(ctx, row.Ad, dryRun)
+			if rowErr == nil && !dryRun {
+				rowReport.AdID = &ad.ID
+			}
+		}
+		if rowErr != nil {
+			msg := rowErr.Error()
+			rowReport.Error = &msg
+			report.Failed++
+		} else {
+			report.Valid++
+		}
+		report.Rows = append(report.Rows, rowReport)
+	}
+}
+
+// ExportAds пишет объявления, подходящие под фильтры GetAdsByFilter, в формате, который принимает ImportAds
+func (a *AdsApp) ExportAds(ctx context.Context, filters service.AdFilters, format adfile.Format, w io.Writer) error {
+	writer, err := adfile.NewWriter(w, format)
+	if err != nil {
+		return err
+	}
+	ads, err := a.GetAdsByFilter(ctx, filters)
+	if err != nil {
+		return err
+	}
+	for _, ad := range ads {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = writer.Write(ad); err != nil {
+			return err
+		}
+	}
+	return writer.Flush()
+}
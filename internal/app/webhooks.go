@@ -0,0 +1,49 @@
+package app
+
+import (
+	"context"
+	"homework10/internal/webhook"
+)
+
+// CreateWebhook подписывает партнера на события объявлений, пустой secret генерируется
+func (a *AdsApp) CreateWebhook(ctx context.Context, requesterID int64, url string, secret string, eventTypes []string) (*webhook.Subscription, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return &webhook.Subscription{}, err
+	}
+	sub, err := webhook.NewSubscription(url, secret, eventTypes)
+	if err != nil {
+		return &webhook.Subscription{}, err
+	}
+	sub, err = a.webhooks.Add(sub)
+	return &sub, err
+}
+
+func (a *AdsApp) Webhooks(ctx context.Context, requesterID int64) ([]webhook.Subscription, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	return a.webhooks.List(), nil
+}
+
+func (a *AdsApp) DeleteWebhook(ctx context.Context, requesterID int64, webhookID int64) error {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return err
+	}
+	return a.webhooks.Delete(webhookID)
+}
+
+// WebhookDeliveries журнал попыток доставки, новые первыми
+func (a *AdsApp) WebhookDeliveries(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.Delivery, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	return a.webhooks.Deliveries(webhookID)
+}
+
+// WebhookDeadLetters события, которые не удалось доставить за все попытки
+func (a *AdsApp) WebhookDeadLetters(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.DeadLetter, error) {
+	if err := a.authorizeAdmin(requesterID); err != nil {
+		return nil, err
+	}
+	return a.webhooks.DeadLetters(webhookID)
+}
@@ -0,0 +1,160 @@
+package apperr
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Kind класс ошибки, по нему транспорт выбирает HTTP статус и gRPC код
+type Kind string
+
+const (
+	Internal           Kind = "internal"
+	InvalidArgument    Kind = "invalid_argument"
+	NotFound           Kind = "not_found"
+	AlreadyExists      Kind = "already_exists"
+	PermissionDenied   Kind = "permission_denied"
+	FailedPrecondition Kind = "failed_precondition"
+	Aborted            Kind = "aborted"
+	// ResourceExhausted лимит восстановится со временем, см. Error.RetryAfter
+	ResourceExhausted Kind = "resource_exhausted"
+	// QuotaExceeded лимит не восстановится сам, пользователю нужно освободить ресурс
+	QuotaExceeded Kind = "quota_exceeded"
+	Unimplemented Kind = "unimplemented"
+	Unavailable   Kind = "unavailable"
+)
+
+// Error ошибка предметной области. Сервисы объявляют ошибки-образцы через New,
+// поэтому errors.Is продолжает работать, а транспорт получает класс и код через From
+type Error struct {
+	Kind Kind
+	// Code машиночитаемый код, стабилен между версиями в отличие от Message
+	Code    string
+	Message string
+	Details map[string]any
+	// Fields ошибки в отдельных полях запроса
+	Fields []FieldViolation
+	// RetryAfter через сколько можно повторить запрос, 0 - неизвестно
+	RetryAfter time.Duration
+	// Err причина, по ней работают errors.Is и errors.As
+	Err error
+}
+
+// FieldViolation ошибка в поле запроса field
+type FieldViolation struct {
+	Field   string
+	Code    string
+	Message string
+}
+
+func New(kind Kind, code, message string) *Error {
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// Field ошибка проверки одного поля запроса
+func Field(field, code, message string) *Error {
+	return &Error{
+		Kind:    InvalidArgument,
+		Code:    code,
+		Message: message,
+		Fields:  []FieldViolation{{Field: field, Code: code, Message: message}},
+	}
+}
+
+// Wrap присваивает класс и код чужой ошибке err
+func Wrap(kind Kind, code string, err error) *Error {
+	return &Error{Kind: kind, Code: code, Message: err.Error(), Err: err}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails копия e с дополнительными подробностями, errors.Is(copy, e) == true
+func (e *Error) WithDetails(details map[string]any) *Error {
+	c := *e
+	c.Err = e
+	c.Details = make(map[string]any, len(e.Details)+len(details))
+	for k, v := range e.Details {
+		c.Details[k] = v
+	}
+	for k, v := range details {
+		c.Details[k] = v
+	}
+	return &c
+}
+
+// Coder ошибка, которая сама знает свое представление, например, с подробностями из полей
+type Coder interface {
+	AppError() *Error
+}
+
+type registered struct {
+	err   error
+	kind  Kind
+	code  string
+	field string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []registered
+)
+
+// Register задает класс и код ошибкам из сторонних пакетов, которые нельзя объявить через New
+func Register(kind Kind, code string, errs ...error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, err := range errs {
+		registry = append(registry, registered{err: err, kind: kind, code: code})
+	}
+}
+
+// RegisterField как Register, но ошибки относятся к полю запроса field
+func RegisterField(field, code string, errs ...error) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	for _, err := range errs {
+		registry = append(registry, registered{err: err, kind: InvalidArgument, code: code, field: field})
+	}
+}
+
+// From приводит err к *Error. Ошибки без класса считаются Internal
+func From(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var c Coder
+	if errors.As(err, &c) {
+		return c.AppError()
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for _, r := range registry {
+		if errors.Is(err, r.err) {
+			e = Wrap(r.kind, r.code, err)
+			if r.field != "" {
+				e.Fields = []FieldViolation{{Field: r.field, Code: r.code, Message: r.err.Error()}}
+			}
+			return e
+		}
+	}
+	return Wrap(Internal, "internal", err)
+}
+
+// KindOf класс ошибки err, для nil - пустая строка
+func KindOf(err error) Kind {
+	if err == nil {
+		return ""
+	}
+	return From(err).Kind
+}
@@ -0,0 +1,74 @@
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+var errNotFound = New(NotFound, "thing_not_found", "thing not found")
+
+type limitError struct{}
+
+func (limitError) Error() string { return "limit" }
+
+func (limitError) AppError() *Error {
+	return &Error{Kind: ResourceExhausted, Code: "limit", Message: "limit", RetryAfter: time.Minute}
+}
+
+func TestFrom(t *testing.T) {
+	assert.Nil(t, From(nil))
+	assert.Equal(t, Kind(""), KindOf(nil))
+
+	wrapped := fmt.Errorf("get thing: %w", errNotFound)
+	assert.Same(t, errNotFound, From(wrapped))
+	assert.Equal(t, NotFound, KindOf(wrapped))
+
+	e := From(fmt.Errorf("call: %w", limitError{}))
+	assert.Equal(t, ResourceExhausted, e.Kind)
+	assert.Equal(t, time.Minute, e.RetryAfter)
+
+	plain := errors.New("boom")
+	e = From(plain)
+	assert.Equal(t, Internal, e.Kind)
+	assert.Equal(t, "boom", e.Message)
+	assert.ErrorIs(t, e, plain)
+}
+
+func TestRegister(t *testing.T) {
+	foreign := errors.New("foreign bad input")
+	assert.Equal(t, Internal, KindOf(foreign))
+
+	Register(InvalidArgument, "foreign_bad_input", foreign)
+	e := From(fmt.Errorf("validate: %w", foreign))
+	assert.Equal(t, InvalidArgument, e.Kind)
+	assert.Equal(t, "foreign_bad_input", e.Code)
+	assert.ErrorIs(t, e, foreign)
+}
+
+func TestRegisterField(t *testing.T) {
+	foreign := errors.New("foreign bad name")
+	RegisterField("name", "bad_name", foreign)
+
+	e := From(foreign)
+	assert.Equal(t, InvalidArgument, e.Kind)
+	assert.Equal(t, []FieldViolation{{Field: "name", Code: "bad_name", Message: "foreign bad name"}}, e.Fields)
+
+	e = Field("email", "bad_email", "bad email")
+	assert.Equal(t, InvalidArgument, e.Kind)
+	assert.Equal(t, []FieldViolation{{Field: "email", Code: "bad_email", Message: "bad email"}}, e.Fields)
+}
+
+func TestError_WithDetails(t *testing.T) {
+	base := errNotFound.WithDetails(map[string]any{"id": 1})
+	e := base.WithDetails(map[string]any{"owner": 2})
+
+	assert.ErrorIs(t, e, errNotFound)
+	assert.Equal(t, map[string]any{"id": 1, "owner": 2}, e.Details)
+	assert.Equal(t, map[string]any{"id": 1}, base.Details)
+	assert.Nil(t, errNotFound.Details)
+	assert.Equal(t, "thing not found", e.Error())
+	assert.Same(t, e, From(e))
+}
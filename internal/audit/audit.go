@@ -0,0 +1,139 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"time"
+)
+
+type Transport string
+
+const (
+	HTTP Transport = "http"
+	GRPC Transport = "grpc"
+	// Internal изменение не пришло из запроса, например откат или фоновая задача
+	Internal Transport = "internal"
+)
+
+const (
+	TargetAd   = "ad"
+	TargetUser = "user"
+)
+
+// Entry запись журнала аудита. Before и After - состояние цели до и после изменения
+type Entry struct {
+	ID        int64           `json:"id"`
+	At        time.Time       `json:"at"`
+	Actor     int64           `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	TargetID  int64           `json:"target_id"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	IP        string          `json:"ip,omitempty"`
+	Transport Transport       `json:"transport"`
+}
+
+// Origin откуда пришел запрос, кладется в контекст транспортом
+type Origin struct {
+	Transport Transport
+	IP        string
+}
+
+type originKey struct{}
+
+type actorKey struct{}
+
+func WithOrigin(ctx context.Context, origin Origin) context.Context {
+	return context.WithValue(ctx, originKey{}, origin)
+}
+
+func OriginFrom(ctx context.Context) Origin {
+	if origin, ok := ctx.Value(originKey{}).(Origin); ok {
+		return origin
+	}
+	return Origin{Transport: Internal}
+}
+
+// WithActor задает автора изменений - того, от чьего имени выполняется запрос. Его кладут методы,
+// которые получают id инициатора: authorID у объявлений, requesterID у действий администратора
+func WithActor(ctx context.Context, actorID int64) context.Context {
+	return context.WithValue(ctx, actorKey{}, actorID)
+}
+
+// Subscriber пишет в журнал каждое доменное событие. Подписчик синхронный, поэтому видит контекст запроса
+func Subscriber(store Store) events.Subscriber {
+	return events.Subscriber{
+		Name: "audit",
+		Handler: func(ctx context.Context, e events.Event) error {
+			entry, ok := newEntry(ctx, e)
+			if !ok {
+				return nil
+			}
+			return store.Append(entry)
+		},
+	}
+}
+
+// newEntry автор берется из контекста. Без него - регистрация, подтверждение email, изменение пользователем
+// своего профиля, откат пакета - в запросе нет другого инициатора, и действие приписывается владельцу цели
+func newEntry(ctx context.Context, e events.Event) (Entry, bool) {
+	origin := OriginFrom(ctx)
+	entry := Entry{Action: e.Name(), IP: origin.IP, Transport: origin.Transport}
+	switch e := e.(type) {
+	case events.AdCreated:
+		entry.setAd(e.Ad, nil, &e.Ad)
+	case events.AdUpdated:
+		entry.setAd(e.Prev, &e.Prev, &e.Ad)
+	case events.AdStatusChanged:
+		entry.setAd(e.Prev, &e.Prev, &e.Ad)
+	case events.AdDeleted:
+		entry.setAd(e.Ad, &e.Ad, nil)
+	case events.UserCreated:
+		entry.setUser(e.User, nil, &e.User)
+	case events.UserUpdated:
+		entry.setUser(e.Prev, &e.Prev, &e.User)
+	case events.UserVerified:
+		entry.setUser(e.User, nil, &e.User)
+	case events.UserDeleted:
+		entry.setUser(e.User, &e.User, nil)
+	case events.UserErased:
+		entry.setUser(e.User, nil, &e.User)
+	default:
+		return Entry{}, false
+	}
+	if actor, ok := ctx.Value(actorKey{}).(int64); ok {
+		entry.Actor = actor
+	}
+	return entry, true
+}
+
+func (e *Entry) setAd(owner entities.Ad, before, after *entities.Ad) {
+	e.Target, e.TargetID, e.Actor = TargetAd, owner.ID, owner.AuthorID
+	if before != nil {
+		e.Before = marshal(before)
+	}
+	if after != nil {
+		e.After = marshal(after)
+	}
+}
+
+func (e *Entry) setUser(owner entities.User, before, after *entities.User) {
+	e.Target, e.TargetID, e.Actor = TargetUser, owner.ID, owner.ID
+	if before != nil {
+		e.Before = marshal(before)
+	}
+	if after != nil {
+		e.After = marshal(after)
+	}
+}
+
+func marshal(v any) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
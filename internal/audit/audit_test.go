@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"testing"
+	"time"
+)
+
+func newBus(store Store) events.Bus {
+	bus := events.NewBus()
+	bus.Subscribe(Subscriber(store))
+	return bus
+}
+
+func TestSubscriber_Ad(t *testing.T) {
+	store := NewStore()
+	bus := newBus(store)
+	defer bus.Close()
+
+	prev := entities.Ad{ID: 3, Title: "old", AuthorID: 7}
+	ad := prev
+	ad.Title = "new"
+	ctx := WithOrigin(context.Background(), Origin{Transport: HTTP, IP: "10.0.0.1"})
+	bus.Publish(ctx, events.AdUpdated{Ad: ad, Prev: prev})
+
+	entries, err := store.Query(Filter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, int64(7), entry.Actor)
+	assert.Equal(t, events.NameAdUpdated, entry.Action)
+	assert.Equal(t, TargetAd, entry.Target)
+	assert.Equal(t, int64(3), entry.TargetID)
+	assert.Equal(t, HTTP, entry.Transport)
+	assert.Equal(t, "10.0.0.1", entry.IP)
+	assert.False(t, entry.At.IsZero())
+
+	var before, after entities.Ad
+	assert.NoError(t, json.Unmarshal(entry.Before, &before))
+	assert.NoError(t, json.Unmarshal(entry.After, &after))
+	assert.Equal(t, prev, before)
+	assert.Equal(t, ad, after)
+}
+
+func TestSubscriber_User(t *testing.T) {
+	store := NewStore()
+	bus := newBus(store)
+	defer bus.Close()
+
+	user := entities.User{ID: 5, Nickname: "nick"}
+	bus.Publish(context.Background(), events.UserCreated{User: user})
+	bus.Publish(WithActor(context.Background(), 100), events.UserDeleted{User: user})
+
+	entries, err := store.Query(Filter{Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 2)
+	deleted, created := entries[0], entries[1]
+
+	assert.Equal(t, Internal, created.Transport)
+	assert.Equal(t, int64(5), created.Actor)
+	assert.Nil(t, created.Before)
+	assert.NotNil(t, created.After)
+
+	// администратор указан явно
+	assert.Equal(t, int64(100), deleted.Actor)
+	assert.Equal(t, int64(5), deleted.TargetID)
+	assert.NotNil(t, deleted.Before)
+	assert.Nil(t, deleted.After)
+}
+
+func TestStore_Query(t *testing.T) {
+	store := NewStore()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := int64(0); i < 4; i++ {
+		target := TargetAd
+		if i%2 == 1 {
+			target = TargetUser
+		}
+		assert.NoError(t, store.Append(Entry{At: start.Add(time.Duration(i) * time.Hour), Actor: i % 2, Target: target, TargetID: i}))
+	}
+	actor := int64(1)
+	targetID := int64(2)
+
+	entries, err := store.Query(Filter{Actor: &actor, Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{3, 1}, []int64{entries[0].TargetID, entries[1].TargetID})
+
+	entries, err = store.Query(Filter{Target: TargetAd, TargetID: &targetID, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	entries, err = store.Query(Filter{From: start.Add(time.Hour), To: start.Add(3 * time.Hour), Limit: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{2, 1}, []int64{entries[0].TargetID, entries[1].TargetID})
+
+	entries, err = store.Query(Filter{Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), entries[0].TargetID)
+
+	_, err = store.Query(Filter{Target: "order", Limit: 10})
+	assert.ErrorIs(t, err, ErrBadFilter)
+	_, err = store.Query(Filter{From: start, To: start, Limit: 10})
+	assert.ErrorIs(t, err, ErrBadFilter)
+	_, err = store.Query(Filter{})
+	assert.ErrorIs(t, err, ErrBadFilter)
+}
@@ -0,0 +1,88 @@
+package audit
+
+import (
+	"homework10/internal/apperr"
+	"sync"
+	"time"
+)
+
+var ErrBadFilter = apperr.New(apperr.InvalidArgument, "bad_audit_filter", "bad audit filter")
+
+// keepEntries столько последних записей хранится в памяти
+const keepEntries = 100000
+
+// Filter пустые поля не ограничивают выборку. From включительно, To - нет
+type Filter struct {
+	Actor    *int64
+	Target   string
+	TargetID *int64
+	From     time.Time
+	To       time.Time
+	// Limit сколько записей вернуть, новые первыми
+	Limit int
+}
+
+func (f Filter) match(e Entry) bool {
+	switch {
+	case f.Actor != nil && e.Actor != *f.Actor:
+		return false
+	case f.Target != "" && e.Target != f.Target:
+		return false
+	case f.TargetID != nil && e.TargetID != *f.TargetID:
+		return false
+	case !f.From.IsZero() && e.At.Before(f.From):
+		return false
+	case !f.To.IsZero() && !e.At.Before(f.To):
+		return false
+	}
+	return true
+}
+
+type Store interface {
+	Append(entry Entry) error
+	Query(filter Filter) ([]Entry, error)
+}
+
+type memStore struct {
+	mutex   sync.RWMutex
+	entries []Entry
+	nextID  int64
+}
+
+func NewStore() Store {
+	return &memStore{}
+}
+
+func (s *memStore) Append(entry Entry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry.ID = s.nextID
+	s.nextID++
+	if entry.At.IsZero() {
+		entry.At = time.Now().UTC()
+	}
+	s.entries = append(s.entries, entry)
+	// старые записи удаляются пачкой, чтобы не копировать журнал на каждой записи
+	if len(s.entries) > keepEntries+keepEntries/10 {
+		s.entries = append([]Entry(nil), s.entries[len(s.entries)-keepEntries:]...)
+	}
+	return nil
+}
+
+func (s *memStore) Query(filter Filter) ([]Entry, error) {
+	if filter.Limit <= 0 || (filter.Target != "" && filter.Target != TargetAd && filter.Target != TargetUser) ||
+		(!filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To)) {
+		return nil, ErrBadFilter
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	found := make([]Entry, 0)
+	for i := len(s.entries) - 1; i >= 0 && len(found) < filter.Limit; i-- {
+		if filter.match(s.entries[i]) {
+			found = append(found, s.entries[i])
+		}
+	}
+	return found, nil
+}
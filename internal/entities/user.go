@@ -4,4 +4,5 @@ type User struct {
 	ID       int64
 	Nickname string
 	Email    string
+	Verified bool
 }
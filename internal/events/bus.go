@@ -0,0 +1,274 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	ErrSubscriberPanic = errors.New("subscriber panicked")
+	ErrQueueFull       = errors.New("subscriber queue is full")
+	ErrBusClosed       = errors.New("event bus is closed")
+)
+
+// defaultQueueSize столько событий может ждать асинхронного подписчика, дальше события отбрасываются
+const defaultQueueSize = 256
+
+type Handler func(ctx context.Context, event Event) error
+
+// RetryPolicy повторяет обработку с экспоненциально растущей паузой
+type RetryPolicy struct {
+	// Attempts общее число попыток, 0 и 1 - без повторов
+	Attempts   int
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+var (
+	NoRetry      = RetryPolicy{Attempts: 1}
+	DefaultRetry = RetryPolicy{Attempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: 2 * time.Second}
+)
+
+// Delay пауза перед попыткой attempt+1
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	d := p.Backoff
+	for i := 1; i < attempt; i++ {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	return d
+}
+
+type Subscriber struct {
+	// Name попадает в Failure, чтобы было видно, какой подписчик не справился
+	Name    string
+	Handler Handler
+	// Events имена событий подписки, пустой список - все события
+	Events []string
+	// Async подписчик получает события по очереди в своей горутине, Publish его не ждет
+	Async bool
+	Retry RetryPolicy
+}
+
+// Failure событие, которое подписчик не обработал за все попытки
+type Failure struct {
+	Subscriber string
+	Event      Event
+	Attempts   int
+	Err        error
+}
+
+// Bus шина доменных событий внутри процесса
+type Bus interface {
+	// Publish доставляет событие синхронным подписчикам до возврата и ставит его в очереди асинхронных.
+	// Ошибки и паники подписчиков не доходят до издателя и передаются обработчику отказов
+	Publish(ctx context.Context, event Event)
+	Subscribe(subscriber Subscriber) (unsubscribe func())
+	// Close дожидается, пока асинхронные подписчики обработают уже поставленные в очередь события
+	Close()
+}
+
+type Option func(*bus)
+
+// WithFailureHandler по умолчанию отказы пишутся в стандартный логгер
+func WithFailureHandler(handler func(Failure)) Option {
+	return func(b *bus) {
+		b.onFailure = handler
+	}
+}
+
+func WithQueueSize(size int) Option {
+	return func(b *bus) {
+		b.queueSize = size
+	}
+}
+
+type bus struct {
+	mutex         sync.RWMutex
+	subscriptions []*subscription
+	closed        bool
+	onFailure     func(Failure)
+	queueSize     int
+	workers       sync.WaitGroup
+}
+
+type subscription struct {
+	Subscriber
+	names map[string]struct{}
+	// mutex защищает queue от закрытия во время записи
+	mutex  sync.Mutex
+	queue  chan queued
+	closed bool
+}
+
+type queued struct {
+	ctx   context.Context
+	event Event
+}
+
+func NewBus(opts ...Option) Bus {
+	b := &bus{onFailure: logFailure, queueSize: defaultQueueSize}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func logFailure(f Failure) {
+	log.Printf("event %s: subscriber %q failed after %d attempts: %v", f.Event.Name(), f.Subscriber, f.Attempts, f.Err)
+}
+
+func (b *bus) Subscribe(subscriber Subscriber) func() {
+	s := &subscription{Subscriber: subscriber, names: make(map[string]struct{}, len(subscriber.Events))}
+	for _, name := range subscriber.Events {
+		s.names[name] = struct{}{}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if s.Async {
+		s.queue = make(chan queued, b.queueSize)
+		if b.closed {
+			s.closed = true
+			close(s.queue)
+		}
+		b.workers.Add(1)
+		go func() {
+			defer b.workers.Done()
+			for q := range s.queue {
+				b.deliver(q.ctx, s, q.event)
+			}
+		}()
+	}
+	b.subscriptions = append(b.subscriptions, s)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mutex.Lock()
+			for i, other := range b.subscriptions {
+				if other == s {
+					b.subscriptions = append(b.subscriptions[:i:i], b.subscriptions[i+1:]...)
+					break
+				}
+			}
+			b.mutex.Unlock()
+			s.close()
+		})
+	}
+}
+
+func (b *bus) Publish(ctx context.Context, event Event) {
+	b.mutex.RLock()
+	subscriptions := b.subscriptions
+	closed := b.closed
+	b.mutex.RUnlock()
+
+	for _, s := range subscriptions {
+		if !s.accepts(event) {
+			continue
+		}
+		if !s.Async {
+			b.deliver(ctx, s, event)
+			continue
+		}
+		if closed {
+			b.onFailure(Failure{Subscriber: s.Name, Event: event, Err: ErrBusClosed})
+			continue
+		}
+		// асинхронный подписчик переживает запрос, поэтому отмена ctx до него не доходит
+		if err := s.enqueue(queued{ctx: context.WithoutCancel(ctx), event: event}); err != nil {
+			b.onFailure(Failure{Subscriber: s.Name, Event: event, Err: err})
+		}
+	}
+}
+
+func (b *bus) Close() {
+	b.mutex.Lock()
+	b.closed = true
+	subscriptions := b.subscriptions
+	b.mutex.Unlock()
+
+	for _, s := range subscriptions {
+		s.close()
+	}
+	b.workers.Wait()
+}
+
+// deliver вызывает обработчик с повторами по политике подписчика
+func (b *bus) deliver(ctx context.Context, s *subscription, event Event) {
+	attempts := max(s.Retry.Attempts, 1)
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = call(ctx, s.Handler, event); err == nil {
+			return
+		}
+		if attempt == attempts {
+			break
+		}
+		timer := time.NewTimer(s.Retry.Delay(attempt))
+		select {
+		case <-timer.C:
+			continue
+		case <-ctx.Done():
+			timer.Stop()
+			err = errors.Join(err, ctx.Err())
+		}
+		break
+	}
+	b.onFailure(Failure{Subscriber: s.Name, Event: event, Attempts: attempt, Err: err})
+}
+
+func call(ctx context.Context, handler Handler, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", ErrSubscriberPanic, r)
+		}
+	}()
+	return handler(ctx, event)
+}
+
+func (s *subscription) accepts(event Event) bool {
+	if len(s.names) == 0 {
+		return true
+	}
+	_, ok := s.names[event.Name()]
+	return ok
+}
+
+func (s *subscription) enqueue(q queued) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		// подписчик отписался после того, как Publish взял список подписок
+		return nil
+	}
+	select {
+	case s.queue <- q:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (s *subscription) close() {
+	if !s.Async {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+}
@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/entities"
+	"sync"
+	"testing"
+	"time"
+)
+
+var errHandler = errors.New("handler failed")
+
+// failures собирает отказы шины
+type failures struct {
+	mutex sync.Mutex
+	list  []Failure
+}
+
+func (f *failures) add(failure Failure) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.list = append(f.list, failure)
+}
+
+func (f *failures) get() []Failure {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]Failure(nil), f.list...)
+}
+
+func newTestBus(opts ...Option) (Bus, *failures) {
+	f := &failures{}
+	return NewBus(append([]Option{WithFailureHandler(f.add)}, opts...)...), f
+}
+
+func TestBus_Sync(t *testing.T) {
+	bus, failed := newTestBus()
+	var got []Event
+	bus.Subscribe(Subscriber{
+		Name:   "ads",
+		Events: []string{NameAdCreated},
+		Handler: func(ctx context.Context, event Event) error {
+			got = append(got, event)
+			return nil
+		},
+	})
+
+	bus.Publish(context.Background(), AdCreated{Ad: entities.Ad{ID: 1}})
+	bus.Publish(context.Background(), UserCreated{})
+	assert.Equal(t, []Event{AdCreated{Ad: entities.Ad{ID: 1}}}, got)
+	assert.Empty(t, failed.get())
+}
+
+func TestBus_Async(t *testing.T) {
+	bus, failed := newTestBus()
+	var mutex sync.Mutex
+	var got []int64
+	bus.Subscribe(Subscriber{
+		Name:  "async",
+		Async: true,
+		Handler: func(ctx context.Context, event Event) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+			got = append(got, event.(AdCreated).Ad.ID)
+			return nil
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := int64(0); i < 3; i++ {
+		bus.Publish(ctx, AdCreated{Ad: entities.Ad{ID: i}})
+	}
+	// отмена контекста запроса не мешает асинхронному подписчику
+	cancel()
+	bus.Close()
+	assert.Equal(t, []int64{0, 1, 2}, got)
+	assert.Empty(t, failed.get())
+
+	bus.Publish(context.Background(), AdCreated{})
+	assert.ErrorIs(t, failed.get()[0].Err, ErrBusClosed)
+}
+
+func TestBus_Retry(t *testing.T) {
+	bus, failed := newTestBus()
+	calls := 0
+	bus.Subscribe(Subscriber{
+		Name:  "flaky",
+		Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond},
+		Handler: func(ctx context.Context, event Event) error {
+			calls++
+			if calls < 3 {
+				return errHandler
+			}
+			return nil
+		},
+	})
+
+	bus.Publish(context.Background(), AdCreated{})
+	assert.Equal(t, 3, calls)
+	assert.Empty(t, failed.get())
+
+	calls = -10
+	bus.Publish(context.Background(), AdDeleted{})
+	assert.Equal(t, -7, calls)
+	f := failed.get()
+	assert.Len(t, f, 1)
+	assert.Equal(t, "flaky", f[0].Subscriber)
+	assert.Equal(t, 3, f[0].Attempts)
+	assert.Equal(t, AdDeleted{}, f[0].Event)
+	assert.ErrorIs(t, f[0].Err, errHandler)
+}
+
+func TestBus_Retry_Canceled(t *testing.T) {
+	bus, failed := newTestBus()
+	bus.Subscribe(Subscriber{
+		Retry: RetryPolicy{Attempts: 5, Backoff: time.Hour},
+		Handler: func(ctx context.Context, event Event) error {
+			return errHandler
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	bus.Publish(ctx, AdCreated{})
+	f := failed.get()
+	assert.Len(t, f, 1)
+	assert.Equal(t, 1, f[0].Attempts)
+	assert.ErrorIs(t, f[0].Err, context.Canceled)
+}
+
+func TestBus_Isolation(t *testing.T) {
+	bus, failed := newTestBus()
+	delivered := false
+	bus.Subscribe(Subscriber{
+		Name: "panics",
+		Handler: func(ctx context.Context, event Event) error {
+			panic("boom")
+		},
+	})
+	bus.Subscribe(Subscriber{
+		Name: "works",
+		Handler: func(ctx context.Context, event Event) error {
+			delivered = true
+			return nil
+		},
+	})
+
+	bus.Publish(context.Background(), UserDeleted{})
+	assert.True(t, delivered)
+	f := failed.get()
+	assert.Len(t, f, 1)
+	assert.Equal(t, "panics", f[0].Subscriber)
+	assert.ErrorIs(t, f[0].Err, ErrSubscriberPanic)
+}
+
+func TestBus_QueueFull(t *testing.T) {
+	bus, failed := newTestBus(WithQueueSize(1))
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	bus.Subscribe(Subscriber{
+		Name:  "slow",
+		Async: true,
+		Handler: func(ctx context.Context, event Event) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		},
+	})
+
+	bus.Publish(context.Background(), AdCreated{})
+	<-started
+	bus.Publish(context.Background(), AdCreated{})
+	bus.Publish(context.Background(), AdCreated{})
+	close(release)
+	go func() {
+		for range started {
+		}
+	}()
+	bus.Close()
+	close(started)
+
+	f := failed.get()
+	assert.Len(t, f, 1)
+	assert.ErrorIs(t, f[0].Err, ErrQueueFull)
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	bus, _ := newTestBus()
+	calls := 0
+	unsubscribe := bus.Subscribe(Subscriber{
+		Handler: func(ctx context.Context, event Event) error {
+			calls++
+			return nil
+		},
+	})
+
+	bus.Publish(context.Background(), AdCreated{})
+	unsubscribe()
+	unsubscribe()
+	bus.Publish(context.Background(), AdCreated{})
+	assert.Equal(t, 1, calls)
+}
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	p := RetryPolicy{Attempts: 10, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Delay(1))
+	assert.Equal(t, 4*time.Second, p.Delay(3))
+	assert.Equal(t, 5*time.Second, p.Delay(4))
+	assert.Equal(t, 5*time.Second, p.Delay(70))
+}
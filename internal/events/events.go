@@ -0,0 +1,79 @@
+package events
+
+import "homework10/internal/entities"
+
+// Event доменное событие. Name одинаково для всех событий одного типа, по нему оформляется подписка
+type Event interface {
+	Name() string
+}
+
+// Recorder сохраняет события в той же транзакции, что и изменение, которое их вызвало
+type Recorder interface {
+	Record(batch []Event)
+}
+
+const (
+	NameAdCreated       = "ad.created"
+	NameAdUpdated       = "ad.updated"
+	NameAdStatusChanged = "ad.status_changed"
+	NameAdDeleted       = "ad.deleted"
+	NameUserCreated     = "user.created"
+	NameUserUpdated     = "user.updated"
+	NameUserVerified    = "user.verified"
+	NameUserDeleted     = "user.deleted"
+	NameUserErased      = "user.erased"
+)
+
+// AdCreated объявление создано или восстановлено при откате удаления
+type AdCreated struct {
+	Ad entities.Ad `json:"ad"`
+}
+
+// AdUpdated изменились текст, заголовок или автор объявления
+type AdUpdated struct {
+	Ad   entities.Ad `json:"ad"`
+	Prev entities.Ad `json:"prev"`
+}
+
+type AdStatusChanged struct {
+	Ad   entities.Ad `json:"ad"`
+	Prev entities.Ad `json:"prev"`
+}
+
+// AdDeleted Ad - последнее состояние удаленного объявления
+type AdDeleted struct {
+	Ad entities.Ad `json:"ad"`
+}
+
+type UserCreated struct {
+	User entities.User `json:"user"`
+}
+
+type UserUpdated struct {
+	User entities.User `json:"user"`
+	Prev entities.User `json:"prev"`
+}
+
+type UserVerified struct {
+	User entities.User `json:"user"`
+}
+
+type UserDeleted struct {
+	User entities.User `json:"user"`
+}
+
+// UserErased персональные данные пользователя обезличены. Прежнее состояние не передается,
+// чтобы стертые данные не попали к подписчикам
+type UserErased struct {
+	User entities.User `json:"user"`
+}
+
+func (AdCreated) Name() string       { return NameAdCreated }
+func (AdUpdated) Name() string       { return NameAdUpdated }
+func (AdStatusChanged) Name() string { return NameAdStatusChanged }
+func (AdDeleted) Name() string       { return NameAdDeleted }
+func (UserCreated) Name() string     { return NameUserCreated }
+func (UserUpdated) Name() string     { return NameUserUpdated }
+func (UserVerified) Name() string    { return NameUserVerified }
+func (UserDeleted) Name() string     { return NameUserDeleted }
+func (UserErased) Name() string      { return NameUserErased }
@@ -0,0 +1,39 @@
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Fingerprint отпечаток запроса: одинаковые запросы дают одинаковый отпечаток
+func Fingerprint(parts ...[]byte) string {
+	h := sha256.New()
+	for _, part := range parts {
+		// длина перед частью, чтобы ("ab", "c") и ("a", "bc") различались
+		var size [8]byte
+		for i, n := 0, len(part); i < len(size); i, n = i+1, n>>8 {
+			size[i] = byte(n)
+		}
+		h.Write(size[:])
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CanonicalJSON приводит JSON к виду без пробелов и с отсортированными ключами,
+// чтобы повтор с другим форматированием не считался другим запросом. Не JSON возвращается как есть
+func CanonicalJSON(body []byte) []byte {
+	var v any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil || decoder.More() {
+		return body
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+	return canonical
+}
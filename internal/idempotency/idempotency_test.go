@@ -0,0 +1,104 @@
+package idempotency
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+const scope = "POST /ads"
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func TestStore_Replay(t *testing.T) {
+	store := NewStore()
+	_, replay, err := store.Begin(scope, "key", "a")
+	assert.NoError(t, err)
+	assert.False(t, replay)
+
+	_, _, err = store.Begin(scope, "key", "a")
+	assert.ErrorIs(t, err, ErrInProgress)
+	store.Complete(scope, "key", "response")
+
+	response, replay, err := store.Begin(scope, "key", "a")
+	assert.NoError(t, err)
+	assert.True(t, replay)
+	assert.Equal(t, "response", response)
+
+	_, _, err = store.Begin(scope, "key", "b")
+	assert.ErrorIs(t, err, ErrMismatch)
+}
+
+func TestStore_Release(t *testing.T) {
+	store := NewStore()
+	_, _, err := store.Begin(scope, "key", "a")
+	assert.NoError(t, err)
+	store.Release(scope, "key")
+
+	// после неудачи ключ свободен, в том числе для другого запроса
+	_, replay, err := store.Begin(scope, "key", "b")
+	assert.NoError(t, err)
+	assert.False(t, replay)
+
+	store.Complete(scope, "key", "response")
+	store.Release(scope, "key")
+	_, replay, err = store.Begin(scope, "key", "b")
+	assert.NoError(t, err)
+	assert.True(t, replay)
+}
+
+func TestStore_TTL(t *testing.T) {
+	c := &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewStore(WithTTL(time.Hour), withClock(c.Now))
+	_, _, _ = store.Begin(scope, "key", "a")
+	store.Complete(scope, "key", "response")
+	_, _, _ = store.Begin(scope, "other", "a")
+	store.Complete(scope, "other", "response")
+
+	c.now = c.now.Add(59 * time.Minute)
+	_, replay, err := store.Begin(scope, "key", "a")
+	assert.NoError(t, err)
+	assert.True(t, replay)
+
+	c.now = c.now.Add(2 * time.Minute)
+	_, replay, err = store.Begin(scope, "key", "b")
+	assert.NoError(t, err)
+	assert.False(t, replay)
+	// истекшие ключи удаляются и без обращения к ним
+	assert.NotContains(t, store.(*memStore).records, scoped(scope, "other"))
+}
+
+func TestStore_Scope(t *testing.T) {
+	store := NewStore()
+	_, _, _ = store.Begin(scope, "key", "a")
+	store.Complete(scope, "key", "response")
+
+	// тот же ключ в другом методе - другой запрос
+	_, replay, err := store.Begin("POST /users", "key", "b")
+	assert.NoError(t, err)
+	assert.False(t, replay)
+}
+
+func TestValidKey(t *testing.T) {
+	assert.True(t, ValidKey("2f1c-4a7b"))
+	assert.False(t, ValidKey(""))
+	assert.False(t, ValidKey("with space"))
+	assert.False(t, ValidKey(strings.Repeat("k", maxKeyLength+1)))
+
+	_, _, err := NewStore().Begin(scope, "", "a")
+	assert.ErrorIs(t, err, ErrBadKey)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint([]byte("a"), []byte("b")), Fingerprint([]byte("a"), []byte("b")))
+	assert.NotEqual(t, Fingerprint([]byte("ab"), []byte("c")), Fingerprint([]byte("a"), []byte("bc")))
+	assert.Equal(t, CanonicalJSON([]byte(`{"b":1,"a":"x"}`)), CanonicalJSON([]byte("{ \"a\": \"x\",\n \"b\": 1 }")))
+	assert.Equal(t, []byte("not json"), CanonicalJSON([]byte("not json")))
+}
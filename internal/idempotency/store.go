@@ -0,0 +1,157 @@
+package idempotency
+
+import (
+	"homework10/internal/apperr"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrMismatch ключ уже использован с другим запросом
+	ErrMismatch = apperr.New(apperr.FailedPrecondition, "idempotency_key_mismatch", "idempotency key was used with a different request")
+	// ErrInProgress первый запрос с этим ключом еще выполняется
+	ErrInProgress = apperr.New(apperr.Aborted, "idempotency_key_in_progress", "request with this idempotency key is in progress")
+	ErrBadKey     = apperr.New(apperr.InvalidArgument, "bad_idempotency_key", "bad idempotency key")
+)
+
+const (
+	DefaultTTL   = 24 * time.Hour
+	maxKeyLength = 255
+	// sweepInterval как часто удалять истекшие ключи
+	sweepInterval = time.Minute
+)
+
+// Response сохраненный ответ, транспорт сам решает, что в нем хранить
+type Response any
+
+type record struct {
+	fingerprint string
+	done        bool
+	response    Response
+	expiresAt   time.Time
+}
+
+// Store запоминает первый успешный ответ на запрос с ключом на TTL.
+// Ключи разных scope, например разных методов, не пересекаются
+type Store interface {
+	// Begin резервирует ключ. Если ответ уже сохранен, возвращает его и replay=true.
+	// ErrMismatch - ключ занят другим запросом, ErrInProgress - тот же запрос еще выполняется
+	Begin(scope, key string, fingerprint string) (response Response, replay bool, err error)
+	// Complete сохраняет ответ на TTL
+	Complete(scope, key string, response Response)
+	// Release освобождает ключ после неудачного запроса, повтор выполнится заново
+	Release(scope, key string)
+}
+
+type memStore struct {
+	mutex     sync.Mutex
+	ttl       time.Duration
+	records   map[string]*record
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type Option func(*memStore)
+
+func WithTTL(ttl time.Duration) Option {
+	return func(s *memStore) {
+		s.ttl = ttl
+	}
+}
+
+// withClock подменяет время в тестах
+func withClock(now func() time.Time) Option {
+	return func(s *memStore) {
+		s.now = now
+	}
+}
+
+func NewStore(opts ...Option) Store {
+	s := &memStore{ttl: DefaultTTL, records: make(map[string]*record), now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	s.lastSweep = s.now()
+	return s
+}
+
+// ValidKey ключ непустой, печатный ASCII и не длиннее 255 символов
+func ValidKey(key string) bool {
+	if key == "" || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x21 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *memStore) Begin(scope, key string, fingerprint string) (Response, bool, error) {
+	if !ValidKey(key) {
+		return nil, false, ErrBadKey
+	}
+	key = scoped(scope, key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+	r, ok := s.records[key]
+	if ok && now.After(r.expiresAt) {
+		delete(s.records, key)
+		ok = false
+	}
+	if !ok {
+		s.records[key] = &record{fingerprint: fingerprint, expiresAt: now.Add(s.ttl)}
+		return nil, false, nil
+	}
+	if r.fingerprint != fingerprint {
+		return nil, false, ErrMismatch
+	}
+	if !r.done {
+		return nil, false, ErrInProgress
+	}
+	return r.response, true, nil
+}
+
+func (s *memStore) Complete(scope, key string, response Response) {
+	key = scoped(scope, key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r, ok := s.records[key]; ok {
+		r.done = true
+		r.response = response
+		r.expiresAt = s.now().Add(s.ttl)
+	}
+}
+
+func (s *memStore) Release(scope, key string) {
+	key = scoped(scope, key)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if r, ok := s.records[key]; ok && !r.done {
+		delete(s.records, key)
+	}
+}
+
+// scoped ключ валиден без пробелов, поэтому пробел однозначно отделяет его от scope
+func scoped(scope, key string) string {
+	return scope + " " + key
+}
+
+// sweep удаляет истекшие ключи не чаще раза в sweepInterval, вызывается под блокировкой
+func (s *memStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, r := range s.records {
+		if now.After(r.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
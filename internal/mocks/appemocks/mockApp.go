@@ -3,14 +3,30 @@
 package mocks
 
 import (
+	adfile "homework10/internal/adapters/adfile"
+	app "homework10/internal/app"
+
+	audit "homework10/internal/audit"
+
 	context "context"
+
 	entities "homework10/internal/entities"
 
+	eventrepo "homework10/internal/adapters/repository/eventrepo"
+
+	io "io"
+
 	mock "github.com/stretchr/testify/mock"
 
+	outbox "homework10/internal/outbox"
+
+	quota "homework10/internal/quota"
+
 	service "homework10/internal/service"
 
 	util "homework10/internal/util"
+
+	webhook "homework10/internal/webhook"
 )
 
 // App is an autogenerated mock type for the App type
@@ -18,6 +34,58 @@ type App struct {
 	mock.Mock
 }
 
+// AdHistory provides a mock function with given fields: ctx, requesterID, adID
+func (_m *App) AdHistory(ctx context.Context, requesterID int64, adID int64) ([]eventrepo.Event, error) {
+	ret := _m.Called(ctx, requesterID, adID)
+
+	var r0 []eventrepo.Event
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) ([]eventrepo.Event, error)); ok {
+		return rf(ctx, requesterID, adID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) []eventrepo.Event); ok {
+		r0 = rf(ctx, requesterID, adID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]eventrepo.Event)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, adID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// AuditLog provides a mock function with given fields: ctx, requesterID, filter
+func (_m *App) AuditLog(ctx context.Context, requesterID int64, filter audit.Filter) ([]audit.Entry, error) {
+	ret := _m.Called(ctx, requesterID, filter)
+
+	var r0 []audit.Entry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, audit.Filter) ([]audit.Entry, error)); ok {
+		return rf(ctx, requesterID, filter)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, audit.Filter) []audit.Entry); ok {
+		r0 = rf(ctx, requesterID, filter)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]audit.Entry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, audit.Filter) error); ok {
+		r1 = rf(ctx, requesterID, filter)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // ChangeAdStatus provides a mock function with given fields: ctx, adID, authorID, published
 func (_m *App) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*entities.Ad, error) {
 	ret := _m.Called(ctx, adID, authorID, published)
@@ -44,6 +112,32 @@ func (_m *App) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, p
 	return r0, r1
 }
 
+// ChangeAdsStatus provides a mock function with given fields: ctx, changes, allOrNothing
+func (_m *App) ChangeAdsStatus(ctx context.Context, changes []service.AdStatusChange, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, changes, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdStatusChange, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, changes, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdStatusChange, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, changes, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.AdStatusChange, bool) error); ok {
+		r1 = rf(ctx, changes, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateAd provides a mock function with given fields: ctx, title, text, authorID
 func (_m *App) CreateAd(ctx context.Context, title string, text string, authorID int64) (*entities.Ad, error) {
 	ret := _m.Called(ctx, title, text, authorID)
@@ -70,6 +164,32 @@ func (_m *App) CreateAd(ctx context.Context, title string, text string, authorID
 	return r0, r1
 }
 
+// CreateAds provides a mock function with given fields: ctx, ads, allOrNothing
+func (_m *App) CreateAds(ctx context.Context, ads []service.NewAd, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, ads, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.NewAd, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, ads, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.NewAd, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, ads, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.NewAd, bool) error); ok {
+		r1 = rf(ctx, ads, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateUser provides a mock function with given fields: ctx, nickname, email
 func (_m *App) CreateUser(ctx context.Context, nickname string, email string) (*entities.User, error) {
 	ret := _m.Called(ctx, nickname, email)
@@ -96,6 +216,126 @@ func (_m *App) CreateUser(ctx context.Context, nickname string, email string) (*
 	return r0, r1
 }
 
+// CreateWebhook provides a mock function with given fields: ctx, requesterID, url, secret, eventTypes
+func (_m *App) CreateWebhook(ctx context.Context, requesterID int64, url string, secret string, eventTypes []string) (*webhook.Subscription, error) {
+	ret := _m.Called(ctx, requesterID, url, secret, eventTypes)
+
+	var r0 *webhook.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string, []string) (*webhook.Subscription, error)); ok {
+		return rf(ctx, requesterID, url, secret, eventTypes)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string, string, []string) *webhook.Subscription); ok {
+		r0 = rf(ctx, requesterID, url, secret, eventTypes)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*webhook.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string, string, []string) error); ok {
+		r1 = rf(ctx, requesterID, url, secret, eventTypes)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteWebhook provides a mock function with given fields: ctx, requesterID, webhookID
+func (_m *App) DeleteWebhook(ctx context.Context, requesterID int64, webhookID int64) error {
+	ret := _m.Called(ctx, requesterID, webhookID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) error); ok {
+		r0 = rf(ctx, requesterID, webhookID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EachAd provides a mock function with given fields: ctx, filters, fn
+func (_m *App) EachAd(ctx context.Context, filters service.AdFilters, fn func(entities.Ad) error) error {
+	ret := _m.Called(ctx, filters, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, func(entities.Ad) error) error); ok {
+		r0 = rf(ctx, filters, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// EraseUser provides a mock function with given fields: ctx, requesterID, userID
+func (_m *App) EraseUser(ctx context.Context, requesterID int64, userID int64) (*entities.User, error) {
+	ret := _m.Called(ctx, requesterID, userID)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) (*entities.User, error)); ok {
+		return rf(ctx, requesterID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) *entities.User); ok {
+		r0 = rf(ctx, requesterID, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ExportAds provides a mock function with given fields: ctx, filters, format, w
+func (_m *App) ExportAds(ctx context.Context, filters service.AdFilters, format adfile.Format, w io.Writer) error {
+	ret := _m.Called(ctx, filters, format, w)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, adfile.Format, io.Writer) error); ok {
+		r0 = rf(ctx, filters, format, w)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ExportUserData provides a mock function with given fields: ctx, requesterID, userID
+func (_m *App) ExportUserData(ctx context.Context, requesterID int64, userID int64) (*app.UserExport, error) {
+	ret := _m.Called(ctx, requesterID, userID)
+
+	var r0 *app.UserExport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) (*app.UserExport, error)); ok {
+		return rf(ctx, requesterID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) *app.UserExport); ok {
+		r0 = rf(ctx, requesterID, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*app.UserExport)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetAdByID provides a mock function with given fields: ctx, adID
 func (_m *App) GetAdByID(ctx context.Context, adID int64) (*entities.Ad, error) {
 	ret := _m.Called(ctx, adID)
@@ -190,6 +430,240 @@ func (_m *App) GetUserByID(ctx context.Context, userID int64) (*entities.User, e
 	return r0, r1
 }
 
+// GetUserByNickname provides a mock function with given fields: ctx, nickname
+func (_m *App) GetUserByNickname(ctx context.Context, nickname string) (*entities.User, error) {
+	ret := _m.Called(ctx, nickname)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entities.User, error)); ok {
+		return rf(ctx, nickname)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.User); ok {
+		r0 = rf(ctx, nickname)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, nickname)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsersByIDs provides a mock function with given fields: ctx, userIDs
+func (_m *App) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]entities.User, error) {
+	ret := _m.Called(ctx, userIDs)
+
+	var r0 []entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) ([]entities.User, error)); ok {
+		return rf(ctx, userIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) []entities.User); ok {
+		r0 = rf(ctx, userIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, userIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ImportAd provides a mock function with given fields: ctx, ad, dryRun
+func (_m *App) ImportAd(ctx context.Context, ad service.NewAd, dryRun bool) (*entities.Ad, error) {
+	ret := _m.Called(ctx, ad, dryRun)
+
+	var r0 *entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.NewAd, bool) (*entities.Ad, error)); ok {
+		return rf(ctx, ad, dryRun)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.NewAd, bool) *entities.Ad); ok {
+		r0 = rf(ctx, ad, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.NewAd, bool) error); ok {
+		r1 = rf(ctx, ad, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ImportAds provides a mock function with given fields: ctx, r, format, dryRun
+func (_m *App) ImportAds(ctx context.Context, r io.Reader, format adfile.Format, dryRun bool) (*app.ImportReport, error) {
+	ret := _m.Called(ctx, r, format, dryRun)
+
+	var r0 *app.ImportReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, io.Reader, adfile.Format, bool) (*app.ImportReport, error)); ok {
+		return rf(ctx, r, format, dryRun)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, io.Reader, adfile.Format, bool) *app.ImportReport); ok {
+		r0 = rf(ctx, r, format, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*app.ImportReport)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, io.Reader, adfile.Format, bool) error); ok {
+		r1 = rf(ctx, r, format, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// OutboxEntries provides a mock function with given fields: ctx, requesterID, status
+func (_m *App) OutboxEntries(ctx context.Context, requesterID int64, status outbox.Status) ([]outbox.Entry, error) {
+	ret := _m.Called(ctx, requesterID, status)
+
+	var r0 []outbox.Entry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, outbox.Status) ([]outbox.Entry, error)); ok {
+		return rf(ctx, requesterID, status)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, outbox.Status) []outbox.Entry); ok {
+		r0 = rf(ctx, requesterID, status)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]outbox.Entry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, outbox.Status) error); ok {
+		r1 = rf(ctx, requesterID, status)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PatchAd provides a mock function with given fields: ctx, adID, authorID, patch
+func (_m *App) PatchAd(ctx context.Context, adID int64, authorID int64, patch service.AdPatch) (*entities.Ad, error) {
+	ret := _m.Called(ctx, adID, authorID, patch)
+
+	var r0 *entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64, service.AdPatch) (*entities.Ad, error)); ok {
+		return rf(ctx, adID, authorID, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64, service.AdPatch) *entities.Ad); ok {
+		r0 = rf(ctx, adID, authorID, patch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64, service.AdPatch) error); ok {
+		r1 = rf(ctx, adID, authorID, patch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PatchUser provides a mock function with given fields: ctx, userID, patch
+func (_m *App) PatchUser(ctx context.Context, userID int64, patch service.UserPatch) (*entities.User, error) {
+	ret := _m.Called(ctx, userID, patch)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, service.UserPatch) (*entities.User, error)); ok {
+		return rf(ctx, userID, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, service.UserPatch) *entities.User); ok {
+		r0 = rf(ctx, userID, patch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, service.UserPatch) error); ok {
+		r1 = rf(ctx, userID, patch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QuotaUsage provides a mock function with given fields: ctx, userID
+func (_m *App) QuotaUsage(ctx context.Context, userID int64) (*quota.Usage, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *quota.Usage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*quota.Usage, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *quota.Usage); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*quota.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RebuildProjections provides a mock function with given fields: ctx, requesterID
+func (_m *App) RebuildProjections(ctx context.Context, requesterID int64) (*eventrepo.RebuildReport, error) {
+	ret := _m.Called(ctx, requesterID)
+
+	var r0 *eventrepo.RebuildReport
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*eventrepo.RebuildReport, error)); ok {
+		return rf(ctx, requesterID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *eventrepo.RebuildReport); ok {
+		r0 = rf(ctx, requesterID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*eventrepo.RebuildReport)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, requesterID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveAd provides a mock function with given fields: ctx, adID, authorID
 func (_m *App) RemoveAd(ctx context.Context, adID int64, authorID int64) error {
 	ret := _m.Called(ctx, adID, authorID)
@@ -204,6 +678,32 @@ func (_m *App) RemoveAd(ctx context.Context, adID int64, authorID int64) error {
 	return r0
 }
 
+// RemoveAds provides a mock function with given fields: ctx, refs, allOrNothing
+func (_m *App) RemoveAds(ctx context.Context, refs []service.AdRef, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, refs, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdRef, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, refs, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdRef, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, refs, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.AdRef, bool) error); ok {
+		r1 = rf(ctx, refs, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveUser provides a mock function with given fields: ctx, userID
 func (_m *App) RemoveUser(ctx context.Context, userID int64) error {
 	ret := _m.Called(ctx, userID)
@@ -218,6 +718,98 @@ func (_m *App) RemoveUser(ctx context.Context, userID int64) error {
 	return r0
 }
 
+// RemoveUserWithAds provides a mock function with given fields: ctx, userID, policy, newOwnerID
+func (_m *App) RemoveUserWithAds(ctx context.Context, userID int64, policy app.AdsPolicy, newOwnerID int64) error {
+	ret := _m.Called(ctx, userID, policy, newOwnerID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, app.AdsPolicy, int64) error); ok {
+		r0 = rf(ctx, userID, policy, newOwnerID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ResendVerification provides a mock function with given fields: ctx, userID
+func (_m *App) ResendVerification(ctx context.Context, userID int64) (*entities.User, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*entities.User, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *entities.User); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ReplayOutboxEntry provides a mock function with given fields: ctx, requesterID, entryID
+func (_m *App) ReplayOutboxEntry(ctx context.Context, requesterID int64, entryID string) (*outbox.Entry, error) {
+	ret := _m.Called(ctx, requesterID, entryID)
+
+	var r0 *outbox.Entry
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) (*outbox.Entry, error)); ok {
+		return rf(ctx, requesterID, entryID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) *outbox.Entry); ok {
+		r0 = rf(ctx, requesterID, entryID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*outbox.Entry)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, string) error); ok {
+		r1 = rf(ctx, requesterID, entryID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResumeAds provides a mock function with given fields: ctx, filters, lastEventID
+func (_m *App) ResumeAds(ctx context.Context, filters service.AdFilters, lastEventID int64) (*service.AdWatch, error) {
+	ret := _m.Called(ctx, filters, lastEventID)
+
+	var r0 *service.AdWatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, int64) (*service.AdWatch, error)); ok {
+		return rf(ctx, filters, lastEventID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, int64) *service.AdWatch); ok {
+		r0 = rf(ctx, filters, lastEventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.AdWatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.AdFilters, int64) error); ok {
+		r1 = rf(ctx, filters, lastEventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateAd provides a mock function with given fields: ctx, adID, authorID, title, text
 func (_m *App) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*entities.Ad, error) {
 	ret := _m.Called(ctx, adID, authorID, title, text)
@@ -270,6 +862,171 @@ func (_m *App) UpdateUser(ctx context.Context, UserID int64, Nickname string, Em
 	return r0, r1
 }
 
+// UserQuota provides a mock function with given fields: ctx, requesterID, userID
+func (_m *App) UserQuota(ctx context.Context, requesterID int64, userID int64) (*quota.Usage, error) {
+	ret := _m.Called(ctx, requesterID, userID)
+
+	var r0 *quota.Usage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) (*quota.Usage, error)); ok {
+		return rf(ctx, requesterID, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) *quota.Usage); ok {
+		r0 = rf(ctx, requesterID, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*quota.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// VerifyUser provides a mock function with given fields: ctx, token
+func (_m *App) VerifyUser(ctx context.Context, token string) (*entities.User, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entities.User, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.User); ok {
+		r0 = rf(ctx, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WatchAds provides a mock function with given fields: ctx, filters
+func (_m *App) WatchAds(ctx context.Context, filters service.AdFilters) ([]entities.Ad, <-chan service.AdEvent, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 []entities.Ad
+	var r1 <-chan service.AdEvent
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters) ([]entities.Ad, <-chan service.AdEvent, error)); ok {
+		return rf(ctx, filters)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters) []entities.Ad); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.AdFilters) <-chan service.AdEvent); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(<-chan service.AdEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, service.AdFilters) error); ok {
+		r2 = rf(ctx, filters)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
+// WebhookDeadLetters provides a mock function with given fields: ctx, requesterID, webhookID
+func (_m *App) WebhookDeadLetters(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.DeadLetter, error) {
+	ret := _m.Called(ctx, requesterID, webhookID)
+
+	var r0 []webhook.DeadLetter
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) ([]webhook.DeadLetter, error)); ok {
+		return rf(ctx, requesterID, webhookID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) []webhook.DeadLetter); ok {
+		r0 = rf(ctx, requesterID, webhookID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhook.DeadLetter)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, webhookID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// WebhookDeliveries provides a mock function with given fields: ctx, requesterID, webhookID
+func (_m *App) WebhookDeliveries(ctx context.Context, requesterID int64, webhookID int64) ([]webhook.Delivery, error) {
+	ret := _m.Called(ctx, requesterID, webhookID)
+
+	var r0 []webhook.Delivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) ([]webhook.Delivery, error)); ok {
+		return rf(ctx, requesterID, webhookID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64) []webhook.Delivery); ok {
+		r0 = rf(ctx, requesterID, webhookID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhook.Delivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64) error); ok {
+		r1 = rf(ctx, requesterID, webhookID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Webhooks provides a mock function with given fields: ctx, requesterID
+func (_m *App) Webhooks(ctx context.Context, requesterID int64) ([]webhook.Subscription, error) {
+	ret := _m.Called(ctx, requesterID)
+
+	var r0 []webhook.Subscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) ([]webhook.Subscription, error)); ok {
+		return rf(ctx, requesterID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []webhook.Subscription); ok {
+		r0 = rf(ctx, requesterID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]webhook.Subscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, requesterID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewApp interface {
 	mock.TestingT
 	Cleanup(func())
@@ -15,6 +15,30 @@ type AdRepository struct {
 	mock.Mock
 }
 
+// ActiveAds provides a mock function with given fields: authorID
+func (_m *AdRepository) ActiveAds(authorID int64) (int, error) {
+	ret := _m.Called(authorID)
+
+	var r0 int
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) (int, error)); ok {
+		return rf(authorID)
+	}
+	if rf, ok := ret.Get(0).(func(int64) int); ok {
+		r0 = rf(authorID)
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(authorID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // AddAd provides a mock function with given fields: ad
 func (_m *AdRepository) AddAd(ad entities.Ad) (int64, error) {
 	ret := _m.Called(ad)
@@ -65,6 +89,32 @@ func (_m *AdRepository) ChangeAdText(adID int64, title string, text string, upda
 	return r0, r1
 }
 
+// ChangeAdsAuthor provides a mock function with given fields: authorID, newAuthorID, updateTime
+func (_m *AdRepository) ChangeAdsAuthor(authorID int64, newAuthorID int64, updateTime time.Time) ([]entities.Ad, error) {
+	ret := _m.Called(authorID, newAuthorID, updateTime)
+
+	var r0 []entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64, int64, time.Time) ([]entities.Ad, error)); ok {
+		return rf(authorID, newAuthorID, updateTime)
+	}
+	if rf, ok := ret.Get(0).(func(int64, int64, time.Time) []entities.Ad); ok {
+		r0 = rf(authorID, newAuthorID, updateTime)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64, int64, time.Time) error); ok {
+		r1 = rf(authorID, newAuthorID, updateTime)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // DeleteAd provides a mock function with given fields: adID
 func (_m *AdRepository) DeleteAd(adID int64) error {
 	ret := _m.Called(adID)
@@ -79,6 +129,32 @@ func (_m *AdRepository) DeleteAd(adID int64) error {
 	return r0
 }
 
+// DeleteAdsByAuthor provides a mock function with given fields: authorID
+func (_m *AdRepository) DeleteAdsByAuthor(authorID int64) ([]entities.Ad, error) {
+	ret := _m.Called(authorID)
+
+	var r0 []entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(int64) ([]entities.Ad, error)); ok {
+		return rf(authorID)
+	}
+	if rf, ok := ret.Get(0).(func(int64) []entities.Ad); ok {
+		r0 = rf(authorID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(int64) error); ok {
+		r1 = rf(authorID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // EditAdStatus provides a mock function with given fields: ad, published, updateTime
 func (_m *AdRepository) EditAdStatus(ad *entities.Ad, published bool, updateTime time.Time) (*entities.Ad, error) {
 	ret := _m.Called(ad, published, updateTime)
@@ -157,6 +233,46 @@ func (_m *AdRepository) GetAdsByFilters(filters []func(entities.Ad) bool) ([]ent
 	return r0, r1
 }
 
+// GetAdsPage provides a mock function with given fields: filters, afterID, limit
+func (_m *AdRepository) GetAdsPage(filters []func(entities.Ad) bool, afterID int64, limit int) ([]entities.Ad, error) {
+	ret := _m.Called(filters, afterID, limit)
+
+	var r0 []entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]func(entities.Ad) bool, int64, int) ([]entities.Ad, error)); ok {
+		return rf(filters, afterID, limit)
+	}
+	if rf, ok := ret.Get(0).(func([]func(entities.Ad) bool, int64, int) []entities.Ad); ok {
+		r0 = rf(filters, afterID, limit)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]func(entities.Ad) bool, int64, int) error); ok {
+		r1 = rf(filters, afterID, limit)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// RestoreAds provides a mock function with given fields: ads
+func (_m *AdRepository) RestoreAds(ads []entities.Ad) error {
+	ret := _m.Called(ads)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func([]entities.Ad) error); ok {
+		r0 = rf(ads)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 type mockConstructorTestingTNewAdRepository interface {
 	mock.TestingT
 	Cleanup(func())
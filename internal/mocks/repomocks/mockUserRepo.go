@@ -77,6 +77,32 @@ func (_m *UserRepository) EditUser(setUser entities.User) (*entities.User, error
 	return r0, r1
 }
 
+// GetUserByEmail provides a mock function with given fields: email
+func (_m *UserRepository) GetUserByEmail(email string) (*entities.User, error) {
+	ret := _m.Called(email)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*entities.User, error)); ok {
+		return rf(email)
+	}
+	if rf, ok := ret.Get(0).(func(string) *entities.User); ok {
+		r0 = rf(email)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(email)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserByID provides a mock function with given fields: id
 func (_m *UserRepository) GetUserByID(id int64) (*entities.User, error) {
 	ret := _m.Called(id)
@@ -103,6 +129,58 @@ func (_m *UserRepository) GetUserByID(id int64) (*entities.User, error) {
 	return r0, r1
 }
 
+// GetUserByNickname provides a mock function with given fields: nickname
+func (_m *UserRepository) GetUserByNickname(nickname string) (*entities.User, error) {
+	ret := _m.Called(nickname)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(string) (*entities.User, error)); ok {
+		return rf(nickname)
+	}
+	if rf, ok := ret.Get(0).(func(string) *entities.User); ok {
+		r0 = rf(nickname)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(nickname)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsersByIDs provides a mock function with given fields: ids
+func (_m *UserRepository) GetUsersByIDs(ids []int64) ([]entities.User, error) {
+	ret := _m.Called(ids)
+
+	var r0 []entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func([]int64) ([]entities.User, error)); ok {
+		return rf(ids)
+	}
+	if rf, ok := ret.Get(0).(func([]int64) []entities.User); ok {
+		r0 = rf(ids)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func([]int64) error); ok {
+		r1 = rf(ids)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewUserRepository interface {
 	mock.TestingT
 	Cleanup(func())
@@ -8,6 +8,8 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 
+	quota "homework10/internal/quota"
+
 	service "homework10/internal/service"
 
 	util "homework10/internal/util"
@@ -44,6 +46,32 @@ func (_m *AdService) ChangeAdStatus(ctx context.Context, adID int64, authorID in
 	return r0, r1
 }
 
+// ChangeAdsStatus provides a mock function with given fields: ctx, changes, allOrNothing
+func (_m *AdService) ChangeAdsStatus(ctx context.Context, changes []service.AdStatusChange, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, changes, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdStatusChange, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, changes, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdStatusChange, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, changes, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.AdStatusChange, bool) error); ok {
+		r1 = rf(ctx, changes, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // CreateAd provides a mock function with given fields: ctx, title, text, authorID
 func (_m *AdService) CreateAd(ctx context.Context, title string, text string, authorID int64) (*entities.Ad, error) {
 	ret := _m.Called(ctx, title, text, authorID)
@@ -70,6 +98,46 @@ func (_m *AdService) CreateAd(ctx context.Context, title string, text string, au
 	return r0, r1
 }
 
+// CreateAds provides a mock function with given fields: ctx, ads, allOrNothing
+func (_m *AdService) CreateAds(ctx context.Context, ads []service.NewAd, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, ads, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.NewAd, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, ads, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.NewAd, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, ads, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.NewAd, bool) error); ok {
+		r1 = rf(ctx, ads, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// EachAd provides a mock function with given fields: ctx, filters, fn
+func (_m *AdService) EachAd(ctx context.Context, filters service.AdFilters, fn func(entities.Ad) error) error {
+	ret := _m.Called(ctx, filters, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, func(entities.Ad) error) error); ok {
+		r0 = rf(ctx, filters, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // GetAdByID provides a mock function with given fields: ctx, adID
 func (_m *AdService) GetAdByID(ctx context.Context, adID int64) (*entities.Ad, error) {
 	ret := _m.Called(ctx, adID)
@@ -138,6 +206,84 @@ func (_m *AdService) GetDateTimeFormat() util.DateTimeFormatter {
 	return r0
 }
 
+// ImportAd provides a mock function with given fields: ctx, ad, dryRun
+func (_m *AdService) ImportAd(ctx context.Context, ad service.NewAd, dryRun bool) (*entities.Ad, error) {
+	ret := _m.Called(ctx, ad, dryRun)
+
+	var r0 *entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.NewAd, bool) (*entities.Ad, error)); ok {
+		return rf(ctx, ad, dryRun)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.NewAd, bool) *entities.Ad); ok {
+		r0 = rf(ctx, ad, dryRun)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.NewAd, bool) error); ok {
+		r1 = rf(ctx, ad, dryRun)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PatchAd provides a mock function with given fields: ctx, adID, authorID, patch
+func (_m *AdService) PatchAd(ctx context.Context, adID int64, authorID int64, patch service.AdPatch) (*entities.Ad, error) {
+	ret := _m.Called(ctx, adID, authorID, patch)
+
+	var r0 *entities.Ad
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64, service.AdPatch) (*entities.Ad, error)); ok {
+		return rf(ctx, adID, authorID, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, int64, service.AdPatch) *entities.Ad); ok {
+		r0 = rf(ctx, adID, authorID, patch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, int64, service.AdPatch) error); ok {
+		r1 = rf(ctx, adID, authorID, patch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// QuotaUsage provides a mock function with given fields: ctx, userID
+func (_m *AdService) QuotaUsage(ctx context.Context, userID int64) (*quota.Usage, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *quota.Usage
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*quota.Usage, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *quota.Usage); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*quota.Usage)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveAd provides a mock function with given fields: ctx, adID, authorID
 func (_m *AdService) RemoveAd(ctx context.Context, adID int64, authorID int64) error {
 	ret := _m.Called(ctx, adID, authorID)
@@ -152,6 +298,58 @@ func (_m *AdService) RemoveAd(ctx context.Context, adID int64, authorID int64) e
 	return r0
 }
 
+// RemoveAds provides a mock function with given fields: ctx, refs, allOrNothing
+func (_m *AdService) RemoveAds(ctx context.Context, refs []service.AdRef, allOrNothing bool) ([]service.BatchResult, error) {
+	ret := _m.Called(ctx, refs, allOrNothing)
+
+	var r0 []service.BatchResult
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdRef, bool) ([]service.BatchResult, error)); ok {
+		return rf(ctx, refs, allOrNothing)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []service.AdRef, bool) []service.BatchResult); ok {
+		r0 = rf(ctx, refs, allOrNothing)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]service.BatchResult)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []service.AdRef, bool) error); ok {
+		r1 = rf(ctx, refs, allOrNothing)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ResumeAds provides a mock function with given fields: ctx, filters, lastEventID
+func (_m *AdService) ResumeAds(ctx context.Context, filters service.AdFilters, lastEventID int64) (*service.AdWatch, error) {
+	ret := _m.Called(ctx, filters, lastEventID)
+
+	var r0 *service.AdWatch
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, int64) (*service.AdWatch, error)); ok {
+		return rf(ctx, filters, lastEventID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters, int64) *service.AdWatch); ok {
+		r0 = rf(ctx, filters, lastEventID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*service.AdWatch)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.AdFilters, int64) error); ok {
+		r1 = rf(ctx, filters, lastEventID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateAd provides a mock function with given fields: ctx, adID, authorID, title, text
 func (_m *AdService) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*entities.Ad, error) {
 	ret := _m.Called(ctx, adID, authorID, title, text)
@@ -178,6 +376,41 @@ func (_m *AdService) UpdateAd(ctx context.Context, adID int64, authorID int64, t
 	return r0, r1
 }
 
+// WatchAds provides a mock function with given fields: ctx, filters
+func (_m *AdService) WatchAds(ctx context.Context, filters service.AdFilters) ([]entities.Ad, <-chan service.AdEvent, error) {
+	ret := _m.Called(ctx, filters)
+
+	var r0 []entities.Ad
+	var r1 <-chan service.AdEvent
+	var r2 error
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters) ([]entities.Ad, <-chan service.AdEvent, error)); ok {
+		return rf(ctx, filters)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, service.AdFilters) []entities.Ad); ok {
+		r0 = rf(ctx, filters)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.Ad)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, service.AdFilters) <-chan service.AdEvent); ok {
+		r1 = rf(ctx, filters)
+	} else {
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(<-chan service.AdEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(2).(func(context.Context, service.AdFilters) error); ok {
+		r2 = rf(ctx, filters)
+	} else {
+		r2 = ret.Error(2)
+	}
+
+	return r0, r1, r2
+}
+
 type mockConstructorTestingTNewAdService interface {
 	mock.TestingT
 	Cleanup(func())
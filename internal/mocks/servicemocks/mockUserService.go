@@ -7,6 +7,8 @@ import (
 	entities "homework10/internal/entities"
 
 	mock "github.com/stretchr/testify/mock"
+
+	service "homework10/internal/service"
 )
 
 // UserService is an autogenerated mock type for the UserService type
@@ -66,6 +68,84 @@ func (_m *UserService) GetUserByID(ctx context.Context, userID int64) (*entities
 	return r0, r1
 }
 
+// GetUserByNickname provides a mock function with given fields: ctx, nickname
+func (_m *UserService) GetUserByNickname(ctx context.Context, nickname string) (*entities.User, error) {
+	ret := _m.Called(ctx, nickname)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entities.User, error)); ok {
+		return rf(ctx, nickname)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.User); ok {
+		r0 = rf(ctx, nickname)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, nickname)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetUsersByIDs provides a mock function with given fields: ctx, userIDs
+func (_m *UserService) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]entities.User, error) {
+	ret := _m.Called(ctx, userIDs)
+
+	var r0 []entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) ([]entities.User, error)); ok {
+		return rf(ctx, userIDs)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, []int64) []entities.User); ok {
+		r0 = rf(ctx, userIDs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, []int64) error); ok {
+		r1 = rf(ctx, userIDs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// PatchUser provides a mock function with given fields: ctx, userID, patch
+func (_m *UserService) PatchUser(ctx context.Context, userID int64, patch service.UserPatch) (*entities.User, error) {
+	ret := _m.Called(ctx, userID, patch)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, service.UserPatch) (*entities.User, error)); ok {
+		return rf(ctx, userID, patch)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64, service.UserPatch) *entities.User); ok {
+		r0 = rf(ctx, userID, patch)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64, service.UserPatch) error); ok {
+		r1 = rf(ctx, userID, patch)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // RemoveUser provides a mock function with given fields: ctx, userID
 func (_m *UserService) RemoveUser(ctx context.Context, userID int64) error {
 	ret := _m.Called(ctx, userID)
@@ -80,6 +160,32 @@ func (_m *UserService) RemoveUser(ctx context.Context, userID int64) error {
 	return r0
 }
 
+// ResendVerification provides a mock function with given fields: ctx, userID
+func (_m *UserService) ResendVerification(ctx context.Context, userID int64) (*entities.User, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) (*entities.User, error)); ok {
+		return rf(ctx, userID)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, int64) *entities.User); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // UpdateUser provides a mock function with given fields: ctx, UserID, Nickname, Email
 func (_m *UserService) UpdateUser(ctx context.Context, UserID int64, Nickname string, Email string) (*entities.User, error) {
 	ret := _m.Called(ctx, UserID, Nickname, Email)
@@ -106,6 +212,32 @@ func (_m *UserService) UpdateUser(ctx context.Context, UserID int64, Nickname st
 	return r0, r1
 }
 
+// VerifyUser provides a mock function with given fields: ctx, token
+func (_m *UserService) VerifyUser(ctx context.Context, token string) (*entities.User, error) {
+	ret := _m.Called(ctx, token)
+
+	var r0 *entities.User
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) (*entities.User, error)); ok {
+		return rf(ctx, token)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, string) *entities.User); ok {
+		r0 = rf(ctx, token)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*entities.User)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, token)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 type mockConstructorTestingTNewUserService interface {
 	mock.TestingT
 	Cleanup(func())
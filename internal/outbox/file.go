@@ -0,0 +1,181 @@
+package outbox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// FileStore outbox в файле JSON Lines. Каждое изменение записи дописывается строкой с ее новым состоянием
+// и сбрасывается на диск до возврата из метода. Record вызывают репозитории под своей блокировкой на запись,
+// поэтому к ответу клиенту запись outbox уже на диске, а после перезапуска недоставленные записи снова уходят релею
+type FileStore struct {
+	memStore
+	path   string
+	file   *os.File
+	closed bool
+	// size длина файла без недописанных строк, до нее файл обрезается после неудачной записи
+	size int64
+	// broken файл не удалось обрезать, дописывать после недописанной строки нельзя
+	broken error
+	// failed ошибка последней записи, сбрасывается следующей удачной
+	failed error
+}
+
+// OpenFileStore открывает outbox path, создавая его при необходимости. Файл сжимается до последнего
+// состояния каждой записи, недописанная последняя строка - запись, прерванная падением процесса, отбрасывается
+func OpenFileStore(path string) (*FileStore, error) {
+	s := &FileStore{memStore: memStore{entries: make(map[string]*Entry), notify: make(chan struct{}, 1)}, path: path}
+	s.memStore.save = s.saveToFile
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileStore) load() error {
+	file, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var entry Entry
+		if err = json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("%s:%d: %w", s.path, line, err)
+		}
+		s.restore(entry)
+	}
+}
+
+// restore применяет сохраненное состояние записи при загрузке
+func (s *memStore) restore(entry Entry) {
+	stored, ok := s.entries[entry.ID]
+	if !ok {
+		stored = &Entry{}
+		s.entries[entry.ID] = stored
+		s.order = append(s.order, entry.ID)
+		s.seq = max(s.seq, entry.Seq)
+	}
+	delivered := stored.Status == Delivered
+	*stored = entry
+	if entry.Status == Delivered && !delivered {
+		s.markDelivered(entry.ID)
+	}
+}
+
+// compact переписывает файл текущим состоянием записей через временный файл и открывает его на дозапись
+func (s *FileStore) compact() error {
+	var buf []byte
+	for _, id := range s.order {
+		line, err := json.Marshal(s.entries[id])
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := writeFileSync(tmp, buf); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file, s.size = file, int64(len(buf))
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(data); err == nil {
+		err = file.Sync()
+	}
+	return errors.Join(err, file.Close())
+}
+
+// saveToFile дописывает entries одним вызовом Write и сбрасывает на диск, вызывается под блокировкой memStore.
+// Recorder не возвращает ошибку, поэтому она пишется в лог и отдается через Ping, а запись остается в памяти
+func (s *FileStore) saveToFile(entries ...*Entry) {
+	if err := s.write(entries); err != nil {
+		s.failed = err
+		log.Printf("outbox: can't save %d entries to %s: %v", len(entries), s.path, err)
+		return
+	}
+	s.failed = nil
+}
+
+func (s *FileStore) write(entries []*Entry) error {
+	if s.broken != nil {
+		return s.broken
+	}
+	var buf []byte
+	for _, entry := range entries {
+		line, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	_, err := s.file.Write(buf)
+	if err == nil {
+		err = s.file.Sync()
+	}
+	if err == nil {
+		s.size += int64(len(buf))
+		return nil
+	}
+	if truncErr := s.file.Truncate(s.size); truncErr != nil {
+		s.broken = fmt.Errorf("outbox store is broken after a failed write: %w", truncErr)
+		return errors.Join(err, s.broken)
+	}
+	return err
+}
+
+// Ping проверяет, что outbox не закрыт и последняя запись в файл удалась
+func (s *FileStore) Ping() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch {
+	case s.closed:
+		return os.ErrClosed
+	case s.broken != nil:
+		return s.broken
+	case s.failed != nil:
+		return fmt.Errorf("last outbox write failed: %w", s.failed)
+	}
+	return nil
+}
+
+func (s *FileStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return s.file.Close()
+}
@@ -0,0 +1,240 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var errDeliver = errors.New("receiver is down")
+
+func TestStore_Record(t *testing.T) {
+	store := NewStore()
+	store.Record([]events.Event{
+		events.AdCreated{Ad: entities.Ad{ID: 1}},
+		events.AdDeleted{Ad: entities.Ad{ID: 1}},
+	})
+
+	due := store.Due(time.Now().UTC(), 10)
+	assert.Len(t, due, 2)
+	assert.Equal(t, events.NameAdCreated, due[0].Event)
+	assert.Equal(t, events.NameAdDeleted, due[1].Event)
+	assert.Less(t, due[0].Seq, due[1].Seq)
+	assert.NotEqual(t, due[0].ID, due[1].ID)
+	assert.JSONEq(t, `{"ad":{"ID":1,"Title":"","Text":"","AuthorID":0,"Published":false,
+		"CreateDate":"0001-01-01T00:00:00Z","UpdateDate":"0001-01-01T00:00:00Z"}}`, string(due[0].Payload))
+
+	select {
+	case <-store.Notify():
+	default:
+		t.Fatal("store did not notify about new entries")
+	}
+
+	_, err := store.List("unknown", 10)
+	assert.ErrorIs(t, err, ErrBadStatus)
+}
+
+func TestRelay_Deliver(t *testing.T) {
+	store := NewStore()
+	var got []string
+	relay := NewRelay(store, func(ctx context.Context, entry Entry) error {
+		got = append(got, entry.Event)
+		return nil
+	})
+	store.Record([]events.Event{events.UserCreated{}, events.UserDeleted{}})
+
+	assert.Equal(t, 2, relay.Flush(context.Background()))
+	assert.Equal(t, []string{events.NameUserCreated, events.NameUserDeleted}, got)
+	delivered, err := store.List(Delivered, 10)
+	assert.NoError(t, err)
+	assert.Len(t, delivered, 2)
+	assert.Equal(t, 1, delivered[0].Attempts)
+	assert.NotNil(t, delivered[0].DeliveredAt)
+	assert.Equal(t, 0, relay.Flush(context.Background()))
+}
+
+func TestRelay_Stuck(t *testing.T) {
+	store := NewStore()
+	fail := true
+	relay := NewRelay(store, func(ctx context.Context, entry Entry) error {
+		if fail {
+			return errDeliver
+		}
+		return nil
+	}, WithRetry(events.RetryPolicy{Attempts: 3}))
+	store.Record([]events.Event{events.AdCreated{}})
+
+	assert.Equal(t, 0, relay.Flush(context.Background()))
+	failed, err := store.List(Failed, 10)
+	assert.NoError(t, err)
+	assert.Len(t, failed, 1)
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.Equal(t, errDeliver.Error(), failed[0].LastError)
+
+	fail = false
+	entry, err := store.Replay(failed[0].ID, time.Now().UTC())
+	assert.NoError(t, err)
+	assert.Equal(t, Pending, entry.Status)
+	assert.Equal(t, 0, entry.Attempts)
+	assert.Equal(t, 1, relay.Flush(context.Background()))
+
+	_, err = store.Replay(failed[0].ID, time.Now().UTC())
+	assert.ErrorIs(t, err, ErrDelivered)
+	_, err = store.Replay("missing", time.Now().UTC())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRelay_Backoff(t *testing.T) {
+	store := NewStore()
+	relay := NewRelay(store, func(ctx context.Context, entry Entry) error {
+		panic("boom")
+	}, WithRetry(events.RetryPolicy{Attempts: 3, Backoff: time.Hour}))
+	store.Record([]events.Event{events.AdCreated{}})
+
+	// после ошибки запись отложена и в этом проходе больше не доставляется
+	assert.Equal(t, 0, relay.Flush(context.Background()))
+	pending, err := store.List(Pending, 10)
+	assert.NoError(t, err)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Contains(t, pending[0].LastError, events.ErrSubscriberPanic.Error())
+	assert.True(t, pending[0].NextAttemptAt.After(time.Now().Add(time.Minute)))
+}
+
+func TestRelay_Run(t *testing.T) {
+	store := NewStore()
+	delivered := make(chan Entry)
+	relay := NewRelay(store, func(ctx context.Context, entry Entry) error {
+		delivered <- entry
+		return nil
+	}, WithPollInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- relay.Run(ctx)
+	}()
+
+	// новая запись доставляется сразу, не дожидаясь опроса
+	store.Record([]events.Event{events.AdCreated{}})
+	entry := <-delivered
+	assert.Equal(t, events.NameAdCreated, entry.Event)
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestRelay_Check(t *testing.T) {
+	store := NewStore()
+	release := make(chan struct{})
+	relay := NewRelay(store, func(ctx context.Context, entry Entry) error {
+		<-release
+		return nil
+	}, WithPollInterval(time.Millisecond))
+	assert.ErrorIs(t, relay.Check(context.Background()), ErrRelayStopped)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- relay.Run(ctx)
+	}()
+	assert.Eventually(t, func() bool {
+		return relay.Check(context.Background()) == nil
+	}, time.Second, time.Millisecond)
+
+	// обработчик завис, цикл Run не проходит
+	store.Record([]events.Event{events.AdCreated{}})
+	assert.Eventually(t, func() bool {
+		return errors.Is(relay.Check(context.Background()), ErrRelayStalled)
+	}, time.Second, time.Millisecond)
+
+	close(release)
+	cancel()
+	<-done
+	assert.ErrorIs(t, relay.Check(context.Background()), ErrRelayStopped)
+}
+
+func TestDeduplicate(t *testing.T) {
+	calls := 0
+	fail := true
+	handler := Deduplicate(1, func(ctx context.Context, entry Entry) error {
+		calls++
+		if fail {
+			return errDeliver
+		}
+		return nil
+	})
+
+	// неудачная обработка не запоминается
+	assert.ErrorIs(t, handler(context.Background(), Entry{ID: "a"}), errDeliver)
+	fail = false
+	assert.NoError(t, handler(context.Background(), Entry{ID: "a"}))
+	assert.NoError(t, handler(context.Background(), Entry{ID: "a"}))
+	assert.Equal(t, 2, calls)
+
+	// "a" вытеснена из памяти
+	assert.NoError(t, handler(context.Background(), Entry{ID: "b"}))
+	assert.NoError(t, handler(context.Background(), Entry{ID: "a"}))
+	assert.Equal(t, 4, calls)
+}
+
+func TestFileStore_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl.outbox")
+	store, err := OpenFileStore(path)
+	assert.NoError(t, err)
+	store.Record([]events.Event{events.AdCreated{}, events.AdUpdated{}, events.AdDeleted{}})
+	due := store.Due(time.Now().UTC(), 10)
+	assert.NoError(t, store.MarkDelivered(due[0].ID, time.Now().UTC()))
+	assert.NoError(t, store.MarkFailed(due[1].ID, errDeliver, time.Now().UTC(), false))
+	assert.NoError(t, store.Ping())
+	assert.NoError(t, store.Close())
+	assert.Error(t, store.Ping())
+
+	// процесс упал посреди записи: недописанная строка отбрасывается
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	assert.NoError(t, err)
+	_, err = file.WriteString(`{"id":"torn","seq":4,`)
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	// недоставленные записи снова уходят релею, доставленные остаются доставленными
+	store, err = OpenFileStore(path)
+	assert.NoError(t, err)
+	defer store.Close()
+	pending, err := store.List(Pending, 10)
+	assert.NoError(t, err)
+	if assert.Len(t, pending, 2) {
+		assert.Equal(t, due[1].ID, pending[0].ID)
+		assert.Equal(t, 1, pending[0].Attempts)
+		assert.Equal(t, errDeliver.Error(), pending[0].LastError)
+		assert.Equal(t, due[2].ID, pending[1].ID)
+		assert.JSONEq(t, string(due[2].Payload), string(pending[1].Payload))
+	}
+	delivered, err := store.List(Delivered, 10)
+	assert.NoError(t, err)
+	assert.Len(t, delivered, 1)
+
+	store.Record([]events.Event{events.UserCreated{}})
+	all, err := store.List("", 10)
+	assert.NoError(t, err)
+	if assert.Len(t, all, 4) {
+		assert.Equal(t, int64(4), all[3].Seq)
+	}
+	select {
+	case <-store.Notify():
+	default:
+		t.Fatal("store did not notify about new entries")
+	}
+}
+
+func TestFileStore_Corrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ads.jsonl.outbox")
+	assert.NoError(t, os.WriteFile(path, []byte("{}\nnot json\n{}\n"), 0o644))
+	_, err := OpenFileStore(path)
+	assert.ErrorContains(t, err, ":2:")
+}
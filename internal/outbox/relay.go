@@ -0,0 +1,174 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"homework10/internal/events"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultBatch        = 100
+	defaultPollInterval = time.Second
+	// stalledAfter сколько интервалов опроса цикл Run может не проходить, прежде чем Check сочтет релей зависшим
+	stalledAfter = 10
+)
+
+var (
+	ErrRelayStopped = errors.New("outbox relay is not running")
+	ErrRelayStalled = errors.New("outbox relay is stalled")
+)
+
+// DefaultRetry после 8 неудачных попыток запись считается застрявшей
+var DefaultRetry = events.RetryPolicy{Attempts: 8, Backoff: time.Second, MaxBackoff: time.Minute}
+
+// Handler доставляет запись получателю. Запись может прийти повторно, если релей остановился
+// между вызовом Handler и отметкой о доставке, поэтому получатель должен учитывать Entry.ID
+type Handler func(ctx context.Context, entry Entry) error
+
+// Relay доставляет записи outbox хотя бы один раз
+type Relay struct {
+	store    Store
+	handler  Handler
+	retry    events.RetryPolicy
+	interval time.Duration
+	// mutex не дает Run и Flush доставлять одну запись одновременно
+	mutex sync.Mutex
+	// heartbeat время последнего прохода цикла Run в наносекундах, 0 - Run не запущен
+	heartbeat atomic.Int64
+}
+
+type RelayOption func(*Relay)
+
+func WithRetry(policy events.RetryPolicy) RelayOption {
+	return func(r *Relay) {
+		r.retry = policy
+	}
+}
+
+// WithPollInterval как часто проверять отложенные записи, новые записи доставляются сразу
+func WithPollInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+func NewRelay(store Store, handler Handler, opts ...RelayOption) *Relay {
+	r := &Relay{store: store, handler: handler, retry: DefaultRetry, interval: defaultPollInterval}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run доставляет записи, пока не отменен ctx
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	r.beat()
+	defer r.heartbeat.Store(0)
+	for {
+		r.Flush(ctx)
+		r.beat()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-r.store.Notify():
+		}
+	}
+}
+
+func (r *Relay) beat() {
+	r.heartbeat.Store(time.Now().UnixNano())
+}
+
+// Check проверка для health: ошибка, если Run не запущен или его цикл давно не проходил
+func (r *Relay) Check(ctx context.Context) error {
+	beat := r.heartbeat.Load()
+	switch {
+	case beat == 0:
+		return ErrRelayStopped
+	case time.Since(time.Unix(0, beat)) > stalledAfter*r.interval:
+		return ErrRelayStalled
+	}
+	return nil
+}
+
+// Flush доставляет все записи, срок которых наступил, и возвращает число доставленных
+func (r *Relay) Flush(ctx context.Context) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delivered := 0
+	for {
+		due := r.store.Due(time.Now().UTC(), defaultBatch)
+		if len(due) == 0 {
+			return delivered
+		}
+		for _, entry := range due {
+			if ctx.Err() != nil {
+				return delivered
+			}
+			if r.deliver(ctx, entry) {
+				delivered++
+			}
+		}
+	}
+}
+
+func (r *Relay) deliver(ctx context.Context, entry Entry) bool {
+	err := call(ctx, r.handler, entry)
+	now := time.Now().UTC()
+	if err == nil {
+		_ = r.store.MarkDelivered(entry.ID, now)
+		return true
+	}
+	attempt := entry.Attempts + 1
+	dead := attempt >= max(r.retry.Attempts, 1)
+	_ = r.store.MarkFailed(entry.ID, err, now.Add(r.retry.Delay(attempt)), dead)
+	return false
+}
+
+func call(ctx context.Context, handler Handler, entry Entry) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %v", events.ErrSubscriberPanic, r)
+		}
+	}()
+	return handler(ctx, entry)
+}
+
+// Deduplicate пропускает записи, которые handler уже успешно обработал. Помнит последние size ID
+func Deduplicate(size int, handler Handler) Handler {
+	if size <= 0 {
+		return handler
+	}
+	var mutex sync.Mutex
+	seen := make(map[string]struct{}, size)
+	order := make([]string, 0, size)
+	return func(ctx context.Context, entry Entry) error {
+		mutex.Lock()
+		_, ok := seen[entry.ID]
+		mutex.Unlock()
+		if ok {
+			return nil
+		}
+		if err := handler(ctx, entry); err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		if len(order) == size {
+			delete(seen, order[0])
+			order = order[1:]
+		}
+		seen[entry.ID] = struct{}{}
+		order = append(order, entry.ID)
+		return nil
+	}
+}
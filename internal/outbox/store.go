@@ -0,0 +1,254 @@
+package outbox
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"homework10/internal/apperr"
+	"homework10/internal/events"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNotFound  = apperr.New(apperr.NotFound, "outbox_entry_not_found", "outbox entry not found")
+	ErrDelivered = apperr.New(apperr.Aborted, "outbox_entry_delivered", "outbox entry is already delivered")
+	ErrBadStatus = apperr.New(apperr.InvalidArgument, "bad_outbox_status", "bad outbox entry status")
+)
+
+type Status string
+
+const (
+	// Pending ждет доставки, в том числе повторной после ошибки
+	Pending   Status = "pending"
+	Delivered Status = "delivered"
+	// Failed застрявшая запись: попытки исчерпаны, доставить ее можно только через Replay
+	Failed Status = "failed"
+)
+
+// keepDelivered столько доставленных записей хранится для просмотра, старые удаляются
+const keepDelivered = 1000
+
+// Entry запись outbox. ID - ключ дедупликации: при повторной доставке получатель видит тот же ID
+type Entry struct {
+	ID            string          `json:"id"`
+	Seq           int64           `json:"seq"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        Status          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	// Domain событие, из которого сделан Payload. FileStore его не хранит, после перезапуска оно пустое
+	Domain events.Event `json:"-"`
+}
+
+// Store хранилище outbox. Record вызывают репозитории под своей блокировкой на запись,
+// поэтому запись в outbox и изменение данных происходят атомарно
+type Store interface {
+	events.Recorder
+	// Due возвращает до limit записей в порядке Seq, которые пора доставить
+	Due(now time.Time, limit int) []Entry
+	MarkDelivered(id string, at time.Time) error
+	// MarkFailed откладывает запись до next, а при dead переводит ее в Failed
+	MarkFailed(id string, err error, next time.Time, dead bool) error
+	Get(id string) (Entry, error)
+	// List возвращает записи со статусом status в порядке Seq, пустой статус - все записи
+	List(status Status, limit int) ([]Entry, error)
+	// Replay возвращает запись в очередь доставки со сброшенным счетчиком попыток
+	Replay(id string, now time.Time) (Entry, error)
+	// Notify получает сигнал, когда появляются записи для доставки
+	Notify() <-chan struct{}
+}
+
+type memStore struct {
+	mutex   sync.Mutex
+	entries map[string]*Entry
+	order   []string
+	seq     int64
+	// delivered ID доставленных записей в порядке доставки
+	delivered []string
+	notify    chan struct{}
+	// save сохраняет измененные записи, вызывается под блокировкой. nil - только в памяти
+	save func(entries ...*Entry)
+}
+
+func NewStore() Store {
+	return &memStore{entries: make(map[string]*Entry), notify: make(chan struct{}, 1)}
+}
+
+func (s *memStore) Record(batch []events.Event) {
+	now := time.Now().UTC()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	recorded := make([]*Entry, 0, len(batch))
+	for _, event := range batch {
+		payload, _ := json.Marshal(event)
+		s.seq++
+		entry := &Entry{
+			ID:            newID(),
+			Seq:           s.seq,
+			Event:         event.Name(),
+			Payload:       payload,
+			Status:        Pending,
+			CreatedAt:     now,
+			NextAttemptAt: now,
+			Domain:        event,
+		}
+		s.entries[entry.ID] = entry
+		s.order = append(s.order, entry.ID)
+		recorded = append(recorded, entry)
+	}
+	s.persist(recorded...)
+	s.signal()
+}
+
+func (s *memStore) Due(now time.Time, limit int) []Entry {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	due := make([]Entry, 0)
+	for _, id := range s.order {
+		if len(due) == limit {
+			break
+		}
+		entry := s.entries[id]
+		if entry.Status == Pending && !entry.NextAttemptAt.After(now) {
+			due = append(due, *entry)
+		}
+	}
+	return due
+}
+
+func (s *memStore) MarkDelivered(id string, at time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	entry.Status = Delivered
+	entry.Attempts++
+	entry.LastError = ""
+	entry.DeliveredAt = &at
+	s.persist(entry)
+	s.markDelivered(id)
+	return nil
+}
+
+// markDelivered запоминает порядок доставки и удаляет самые старые доставленные записи сверх keepDelivered
+func (s *memStore) markDelivered(id string) {
+	s.delivered = append(s.delivered, id)
+	if len(s.delivered) > keepDelivered {
+		s.evict(s.delivered[0])
+		s.delivered = s.delivered[1:]
+	}
+}
+
+func (s *memStore) MarkFailed(id string, err error, next time.Time, dead bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return ErrNotFound
+	}
+	entry.Attempts++
+	entry.LastError = err.Error()
+	entry.NextAttemptAt = next
+	if dead {
+		entry.Status = Failed
+	}
+	s.persist(entry)
+	return nil
+}
+
+func (s *memStore) Get(id string) (Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return *entry, nil
+}
+
+func (s *memStore) List(status Status, limit int) ([]Entry, error) {
+	if status != "" && status != Pending && status != Delivered && status != Failed {
+		return nil, ErrBadStatus
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	list := make([]Entry, 0)
+	for _, id := range s.order {
+		if len(list) == limit {
+			break
+		}
+		entry := s.entries[id]
+		if status == "" || entry.Status == status {
+			list = append(list, *entry)
+		}
+	}
+	return list, nil
+}
+
+func (s *memStore) Replay(id string, now time.Time) (Entry, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	entry, ok := s.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	if entry.Status == Delivered {
+		return *entry, ErrDelivered
+	}
+	entry.Status = Pending
+	entry.Attempts = 0
+	entry.LastError = ""
+	entry.NextAttemptAt = now
+	s.persist(entry)
+	s.signal()
+	return *entry, nil
+}
+
+func (s *memStore) Notify() <-chan struct{} {
+	return s.notify
+}
+
+// persist вызывается под блокировкой
+func (s *memStore) persist(entries ...*Entry) {
+	if s.save != nil && len(entries) > 0 {
+		s.save(entries...)
+	}
+}
+
+// signal не блокируется: одного ожидающего сигнала достаточно, чтобы релей забрал все записи
+func (s *memStore) signal() {
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *memStore) evict(id string) {
+	delete(s.entries, id)
+	for i, other := range s.order {
+		if other == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			return
+		}
+	}
+}
+
+func newID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,144 @@
+package graphql
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	gql "github.com/graph-gophers/graphql-go"
+	"homework10/internal/app"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+//go:embed schema.graphql
+var schema string
+
+const (
+	// maxDepth ограничивает вложенность вроде ads { author { ads { author ... } } }
+	maxDepth = 10
+	// maxNodes сколько объявлений все списки одного запроса могут вернуть вместе,
+	// глубина сама по себе не мешает запросить 100 авторов по 100 объявлений у каждого
+	maxNodes = 10000
+	// maxBodySize запросы к схеме намного меньше, больше - 413
+	maxBodySize = 1 << 20
+
+	idempotencyKeyHeader = "Idempotency-Key"
+)
+
+// DefaultRateLimits те же лимиты, что у POST /ads и /users, ключ - имя мутации
+var DefaultRateLimits = ratelimit.Rules{
+	"createAd":   ratelimit.CreateAd,
+	"createUser": ratelimit.CreateUser,
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type options struct {
+	idempotency idempotency.Store
+	limiter     ratelimit.Limiter
+	rateLimits  ratelimit.Rules
+}
+
+type Option func(*options)
+
+// WithIdempotencyStore хранилище ответов createAd и createUser на запросы с Idempotency-Key
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(o *options) {
+		o.idempotency = store
+	}
+}
+
+// WithRateLimits задает лимиты по именам мутаций, по умолчанию DefaultRateLimits
+func WithRateLimits(limiter ratelimit.Limiter, rules ratelimit.Rules) Option {
+	return func(o *options) {
+		o.limiter = limiter
+		o.rateLimits = rules
+	}
+}
+
+type handler struct {
+	app    app.App
+	schema *gql.Schema
+}
+
+// NewHandler обрабатывает POST с JSON телом {query, operationName, variables}, ответ - {data, errors}.
+// Все данные берутся через app.App, загрузчики объединяют обращения за авторами и их объявлениями.
+// Клиент определяется по RemoteAddr, перед обработчиком его уточняет httpgin.ClientAddrMiddleware
+func NewHandler(a app.App, opts ...Option) http.Handler {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.idempotency == nil {
+		o.idempotency = idempotency.NewStore()
+	}
+	if o.limiter == nil {
+		o.limiter, o.rateLimits = ratelimit.NewLimiter(), DefaultRateLimits
+	}
+	r := &resolver{app: a, idempotency: o.idempotency, limiter: o.limiter, rateLimits: o.rateLimits}
+	return &handler{
+		app:    a,
+		schema: gql.MustParseSchema(schema, r, gql.MaxDepth(maxDepth)),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(&req); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(map[string]any{"errors": []map[string]any{{"message": err.Error()}}})
+		return
+	}
+	ctx := withLoaders(r.Context(), newLoaders(h.app))
+	ctx = withCall(ctx, &call{client: clientKey(r), idempotencyKey: r.Header.Get(idempotencyKeyHeader)})
+	response := h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	_ = json.NewEncoder(w).Encode(response)
+}
+
+// clientKey адрес клиента, тот же ключ, что у лимитов /api/v1 и gRPC
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// call состояние одного запроса: кто его прислал и сколько объявлений уже отдано
+type call struct {
+	client         string
+	idempotencyKey string
+	nodes          atomic.Int64
+}
+
+type callKey struct{}
+
+func withCall(ctx context.Context, c *call) context.Context {
+	return context.WithValue(ctx, callKey{}, c)
+}
+
+func callFrom(ctx context.Context) *call {
+	return ctx.Value(callKey{}).(*call)
+}
+
+// reserve учитывает n объявлений, ошибка - если запрос вышел за maxNodes
+func (c *call) reserve(n int) error {
+	if c.nodes.Add(int64(n)) > maxNodes {
+		return toError(errTooComplex)
+	}
+	return nil
+}
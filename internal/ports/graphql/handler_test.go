@@ -0,0 +1,256 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"homework10/internal/apperr"
+	"homework10/internal/entities"
+	mocks "homework10/internal/mocks/appemocks"
+	"homework10/internal/ratelimit"
+	"homework10/internal/service"
+	"homework10/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type response struct {
+	Data   map[string]any `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func execute(t *testing.T, a *mocks.App, query string, variables map[string]any) response {
+	t.Helper()
+	return serve(t, NewHandler(a), query, variables, "")
+}
+
+// serve выполняет запрос на h, idempotencyKey - заголовок Idempotency-Key, если не пустой
+func serve(t *testing.T, h http.Handler, query string, variables map[string]any, idempotencyKey string) response {
+	t.Helper()
+	body, _ := json.Marshal(request{Query: query, Variables: variables})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp response
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp
+}
+
+var (
+	created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	ads     = []entities.Ad{
+		{ID: 3, Title: "c", AuthorID: 2, Published: true, CreateDate: created},
+		{ID: 1, Title: "a", AuthorID: 1, Published: true, CreateDate: created},
+		{ID: 2, Title: "b", AuthorID: 1, Published: true, CreateDate: created},
+	}
+	users = []entities.User{{ID: 1, Nickname: "first"}, {ID: 2, Nickname: "second"}}
+)
+
+func TestAds_BatchesAuthors(t *testing.T) {
+	a := new(mocks.App)
+	a.On("GetAdsByFilter", mock.Anything, service.AdFilters{AuthorID: -1, Published: true}).Return(ads, nil).Once()
+	// авторы всех объявлений и их объявления - по одному вызову на запрос, а не на каждое объявление
+	a.On("GetUsersByIDs", mock.Anything, []int64{1, 2}).Return(users, nil).Once()
+	a.On("GetAdsByFilter", mock.Anything, service.AdFilters{AuthorID: -1, AuthorIDs: []int64{1, 2}, Published: true}).
+		Return(ads, nil).Once()
+
+	resp := execute(t, a, `{ ads { totalCount nodes { id title createDate author { nickname ads { totalCount } } } } }`, nil)
+	assert.Empty(t, resp.Errors)
+	nodes := resp.Data["ads"].(map[string]any)["nodes"].([]any)
+	assert.Len(t, nodes, 3)
+	first := nodes[0].(map[string]any)
+	assert.Equal(t, "1", first["id"])
+	assert.Equal(t, "2024-01-02T03:04:05Z", first["createDate"])
+	assert.Equal(t, map[string]any{"nickname": "first", "ads": map[string]any{"totalCount": float64(2)}}, first["author"])
+	assert.Equal(t, "second", nodes[2].(map[string]any)["author"].(map[string]any)["nickname"])
+	a.AssertExpectations(t)
+}
+
+func TestAds_Pagination(t *testing.T) {
+	a := new(mocks.App)
+	a.On("GetAdsByFilter", mock.Anything, service.AdFilters{AuthorID: 1, Published: false, Title: "a"}).Return(ads, nil)
+	query := `query($after: String) {
+		ads(filter: {authorId: "1", published: false, title: "a"}, first: 2, after: $after) {
+			totalCount nodes { id } pageInfo { endCursor hasNextPage }
+		}
+	}`
+
+	resp := execute(t, a, query, nil)
+	assert.Empty(t, resp.Errors)
+	page := resp.Data["ads"].(map[string]any)
+	assert.Equal(t, float64(3), page["totalCount"])
+	assert.Equal(t, []any{map[string]any{"id": "1"}, map[string]any{"id": "2"}}, page["nodes"])
+	info := page["pageInfo"].(map[string]any)
+	assert.Equal(t, true, info["hasNextPage"])
+
+	resp = execute(t, a, query, map[string]any{"after": info["endCursor"]})
+	page = resp.Data["ads"].(map[string]any)
+	assert.Equal(t, []any{map[string]any{"id": "3"}}, page["nodes"])
+	assert.Equal(t, false, page["pageInfo"].(map[string]any)["hasNextPage"])
+
+	resp = execute(t, a, query, map[string]any{"after": "garbage"})
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "BAD_CURSOR", resp.Errors[0].Extensions["code"])
+	}
+}
+
+func TestAd_NotFound(t *testing.T) {
+	a := new(mocks.App)
+	a.On("GetAdByID", mock.Anything, int64(100)).Return(nil, util.ErrNotFound)
+
+	resp := execute(t, a, `{ ad(id: "100") { id } }`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"ad": nil}, resp.Data)
+
+	resp = execute(t, a, `{ ad(id: "abc") { id } }`, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "BAD_ID", resp.Errors[0].Extensions["code"])
+	}
+}
+
+func TestMutations(t *testing.T) {
+	a := new(mocks.App)
+	ad := entities.Ad{ID: 5, Title: "title", Text: "text", AuthorID: 1}
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&users[0], nil)
+	a.On("CreateAd", mock.Anything, "title", "text", int64(1)).Return(&ad, nil)
+	text := "patched"
+	patched := ad
+	patched.Text = text
+	a.On("PatchAd", mock.Anything, int64(5), int64(1), service.AdPatch{Text: &text}).Return(&patched, nil)
+	a.On("ChangeAdStatus", mock.Anything, int64(5), int64(1), true).Return(&ad, nil)
+	a.On("RemoveAd", mock.Anything, int64(5), int64(1)).Return(nil)
+
+	resp := execute(t, a, `mutation {
+		create: createAd(input: {userId: "1", title: "title", text: "text"}) { id }
+		update: updateAd(input: {id: "5", userId: "1", text: "patched"}) { title text }
+		status: changeAdStatus(input: {id: "5", userId: "1", published: true}) { id }
+		deleted: deleteAd(input: {id: "5", userId: "1"})
+	}`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]any{"id": "5"}, resp.Data["create"])
+	assert.Equal(t, map[string]any{"title": "title", "text": "patched"}, resp.Data["update"])
+	assert.Equal(t, "5", resp.Data["deleted"])
+	a.AssertExpectations(t)
+}
+
+func TestMutations_Errors(t *testing.T) {
+	a := new(mocks.App)
+	a.On("CreateUser", mock.Anything, "bad", "not an email").
+		Return(nil, apperr.Field("email", "bad_email", "invalid email"))
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&users[0], nil)
+	a.On("CreateAd", mock.Anything, "title", "text", int64(1)).
+		Return(nil, apperr.New(apperr.Internal, "internal", "disk is on fire"))
+
+	resp := execute(t, a, `mutation { createUser(input: {nickname: "bad", email: "not an email"}) { id } }`, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "invalid email", resp.Errors[0].Message)
+		assert.Equal(t, "BAD_EMAIL", resp.Errors[0].Extensions["code"])
+		assert.Equal(t, []any{map[string]any{"field": "email", "code": "bad_email", "message": "invalid email"}},
+			resp.Errors[0].Extensions["fields"])
+	}
+
+	// подробности внутренних ошибок клиенту не показываются
+	resp = execute(t, a, `mutation { createAd(input: {userId: "1", title: "title", text: "text"}) { id } }`, nil)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "internal error", resp.Errors[0].Message)
+	}
+}
+
+func TestHandler_BadRequest(t *testing.T) {
+	w := httptest.NewRecorder()
+	NewHandler(new(mocks.App)).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader([]byte("{"))))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestHandler_BodyTooLarge(t *testing.T) {
+	body, _ := json.Marshal(request{Query: "{ ads { totalCount } }" + strings.Repeat(" ", maxBodySize)})
+	w := httptest.NewRecorder()
+	NewHandler(new(mocks.App)).ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestAds_TooComplex(t *testing.T) {
+	// 100 авторов по 100 объявлений: 100 в корне и еще 100 * 100 у авторов - больше maxNodes
+	var authors []entities.User
+	var first, all []entities.Ad
+	for author := int64(1); author <= 100; author++ {
+		authors = append(authors, entities.User{ID: author})
+		for i := int64(0); i < 100; i++ {
+			all = append(all, entities.Ad{ID: author*1000 + i, AuthorID: author, Published: true})
+		}
+		first = append(first, all[len(all)-100])
+	}
+	a := new(mocks.App)
+	a.On("GetAdsByFilter", mock.Anything, service.AdFilters{AuthorID: -1, Published: true}).Return(first, nil)
+	a.On("GetUsersByIDs", mock.Anything, mock.Anything).Return(authors, nil)
+	a.On("GetAdsByFilter", mock.Anything, mock.MatchedBy(func(f service.AdFilters) bool {
+		return len(f.AuthorIDs) > 0
+	})).Return(all, nil)
+
+	resp := execute(t, a, `{ ads(first: 100) { nodes { author { ads(first: 100) { nodes { id } } } } } }`, nil)
+	if assert.NotEmpty(t, resp.Errors) {
+		assert.Equal(t, "QUERY_TOO_COMPLEX", resp.Errors[0].Extensions["code"])
+	}
+
+	// тот же запрос с меньшими страницами укладывается
+	resp = execute(t, a, `{ ads(first: 100) { nodes { author { ads(first: 10) { nodes { id } } } } } }`, nil)
+	assert.Empty(t, resp.Errors)
+}
+
+func TestMutations_RateLimit(t *testing.T) {
+	a := new(mocks.App)
+	a.On("CreateUser", mock.Anything, "nick", "nick@example.com").Return(&users[0], nil).Once()
+	h := NewHandler(a, WithRateLimits(ratelimit.NewLimiter(),
+		ratelimit.Rules{"createUser": ratelimit.PerMinute(1)}))
+	query := `mutation { createUser(input: {nickname: "nick", email: "nick@example.com"}) { id } }`
+
+	resp := serve(t, h, query, nil, "")
+	assert.Empty(t, resp.Errors)
+	resp = serve(t, h, query, nil, "")
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "RATE_LIMITED", resp.Errors[0].Extensions["code"])
+		assert.Equal(t, float64(60), resp.Errors[0].Extensions["retryAfter"])
+	}
+	a.AssertExpectations(t)
+}
+
+func TestMutations_Idempotency(t *testing.T) {
+	a := new(mocks.App)
+	ad := entities.Ad{ID: 5, Title: "title", Text: "text", AuthorID: 1}
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&users[0], nil).Once()
+	a.On("CreateAd", mock.Anything, "title", "text", int64(1)).Return(&ad, nil).Once()
+	h := NewHandler(a)
+	query := `mutation($title: String!) { createAd(input: {userId: "1", title: $title, text: "text"}) { id } }`
+
+	for range 2 {
+		resp := serve(t, h, query, map[string]any{"title": "title"}, "key")
+		assert.Empty(t, resp.Errors)
+		assert.Equal(t, map[string]any{"id": "5"}, resp.Data["createAd"])
+	}
+	// повтор с другого адреса, например после смены сети, получает сохраненный ответ
+	body, _ := json.Marshal(request{Query: query, Variables: map[string]any{"title": "title"}})
+	req := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body))
+	req.RemoteAddr = "10.0.0.9:1234"
+	req.Header.Set(idempotencyKeyHeader, "key")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"data":{"createAd":{"id":"5"}}}`, w.Body.String())
+
+	resp := serve(t, h, query, map[string]any{"title": "other"}, "key")
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "IDEMPOTENCY_KEY_MISMATCH", resp.Errors[0].Extensions["code"])
+	}
+	a.AssertExpectations(t)
+}
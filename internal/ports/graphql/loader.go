@@ -0,0 +1,123 @@
+package graphql
+
+import (
+	"cmp"
+	"context"
+	"homework10/internal/app"
+	"homework10/internal/entities"
+	"homework10/internal/service"
+	"slices"
+	"sync"
+)
+
+// batch один вызов fetch, его результат ждут все ключи, попавшие в вызов
+type batch[K cmp.Ordered, V any] struct {
+	done   chan struct{}
+	values map[K]V
+	err    error
+}
+
+// loader собирает ключи в один вызов fetch и запоминает результат до конца запроса.
+// Ключи, отложенные через queue, загружаются вместе с первым же load, поэтому
+// список объявлений заранее откладывает своих авторов, и они приходят одним вызовом, а не N
+type loader[K cmp.Ordered, V any] struct {
+	fetch   func(ctx context.Context, keys []K) (map[K]V, error)
+	mutex   sync.Mutex
+	pending map[K]struct{}
+	batches map[K]*batch[K, V]
+}
+
+func newLoader[K cmp.Ordered, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		pending: make(map[K]struct{}),
+		batches: make(map[K]*batch[K, V]),
+	}
+}
+
+// queue откладывает keys до ближайшего load
+func (l *loader[K, V]) queue(keys ...K) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, key := range keys {
+		if _, ok := l.batches[key]; !ok {
+			l.pending[key] = struct{}{}
+		}
+	}
+}
+
+// load значение для key, ok == false, если fetch его не вернул
+func (l *loader[K, V]) load(ctx context.Context, key K) (value V, ok bool, err error) {
+	l.mutex.Lock()
+	b, started := l.batches[key]
+	var keys []K
+	if !started {
+		b = &batch[K, V]{done: make(chan struct{})}
+		l.pending[key] = struct{}{}
+		keys = make([]K, 0, len(l.pending))
+		for k := range l.pending {
+			keys = append(keys, k)
+			l.batches[k] = b
+		}
+		clear(l.pending)
+		slices.Sort(keys)
+	}
+	l.mutex.Unlock()
+
+	if !started {
+		b.values, b.err = l.fetch(ctx, keys)
+		close(b.done)
+	}
+	select {
+	case <-b.done:
+	case <-ctx.Done():
+		return value, false, ctx.Err()
+	}
+	value, ok = b.values[key]
+	return value, ok, b.err
+}
+
+// loaders кэш одного запроса, новые для каждого запроса, чтобы мутации не видели устаревших данных
+type loaders struct {
+	users *loader[int64, entities.User]
+	// adsByAuthor все объявления автора
+	adsByAuthor *loader[int64, []entities.Ad]
+}
+
+func newLoaders(a app.App) *loaders {
+	return &loaders{
+		users: newLoader(func(ctx context.Context, ids []int64) (map[int64]entities.User, error) {
+			users, err := a.GetUsersByIDs(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			byID := make(map[int64]entities.User, len(users))
+			for _, user := range users {
+				byID[user.ID] = user
+			}
+			return byID, nil
+		}),
+		adsByAuthor: newLoader(func(ctx context.Context, authorIDs []int64) (map[int64][]entities.Ad, error) {
+			// Published: true вместе с фильтром по авторам возвращает и неопубликованные объявления
+			ads, err := a.GetAdsByFilter(ctx, service.AdFilters{AuthorID: -1, AuthorIDs: authorIDs, Published: true})
+			if err != nil {
+				return nil, err
+			}
+			byAuthor := make(map[int64][]entities.Ad, len(authorIDs))
+			for _, ad := range ads {
+				byAuthor[ad.AuthorID] = append(byAuthor[ad.AuthorID], ad)
+			}
+			return byAuthor, nil
+		}),
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,61 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestLoader_QueuedKeysShareFetch(t *testing.T) {
+	var calls atomic.Int32
+	var fetched [][]int64
+	l := newLoader(func(ctx context.Context, keys []int64) (map[int64]string, error) {
+		calls.Add(1)
+		fetched = append(fetched, keys)
+		values := make(map[int64]string, len(keys))
+		for _, key := range keys {
+			if key != 4 {
+				values[key] = string(rune('a' + key))
+			}
+		}
+		return values, nil
+	})
+	l.queue(3, 1, 2, 4)
+
+	var wg sync.WaitGroup
+	for _, key := range []int64{1, 2, 3, 4} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			value, ok, err := l.load(context.Background(), key)
+			assert.NoError(t, err)
+			assert.Equal(t, key != 4, ok)
+			if ok {
+				assert.Equal(t, string(rune('a'+key)), value)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, [][]int64{{1, 2, 3, 4}}, fetched)
+
+	// загруженные ключи берутся из кэша, новый ключ - отдельным вызовом
+	_, _, _ = l.load(context.Background(), 1)
+	_, _, _ = l.load(context.Background(), 5)
+	assert.Equal(t, int32(2), calls.Load())
+	assert.Equal(t, []int64{5}, fetched[1])
+}
+
+func TestLoader_Error(t *testing.T) {
+	errFetch := errors.New("fetch failed")
+	l := newLoader(func(ctx context.Context, keys []int64) (map[int64]string, error) {
+		return nil, errFetch
+	})
+
+	_, ok, err := l.load(context.Background(), 1)
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, errFetch)
+}
@@ -0,0 +1,522 @@
+package graphql
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	gql "github.com/graph-gophers/graphql-go"
+	"homework10/internal/app"
+	"homework10/internal/apperr"
+	"homework10/internal/entities"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
+	"homework10/internal/service"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const maxPageSize = 100
+
+var (
+	errPageSize   = apperr.Field("first", "bad_page_size", "first must be between 0 and 100")
+	errCursor     = apperr.Field("after", "bad_cursor", "unknown cursor")
+	errTooComplex = apperr.New(apperr.InvalidArgument, "query_too_complex",
+		fmt.Sprintf("query returns more than %d ads, request smaller pages", maxNodes))
+)
+
+// resolverError ошибка с кодом и полями в extensions, как в problem+json /api/v1
+type resolverError struct {
+	err *apperr.Error
+}
+
+func toError(err error) error {
+	return resolverError{err: apperr.From(err)}
+}
+
+func (e resolverError) Error() string {
+	if e.err.Kind == apperr.Internal {
+		return "internal error"
+	}
+	return e.err.Message
+}
+
+func (e resolverError) Extensions() map[string]any {
+	extensions := map[string]any{"code": strings.ToUpper(e.err.Code), "kind": e.err.Kind}
+	if len(e.err.Fields) > 0 {
+		fields := make([]map[string]any, 0, len(e.err.Fields))
+		for _, f := range e.err.Fields {
+			fields = append(fields, map[string]any{"field": f.Field, "code": f.Code, "message": f.Message})
+		}
+		extensions["fields"] = fields
+	}
+	if e.err.RetryAfter > 0 {
+		extensions["retryAfter"] = int64(math.Ceil(e.err.RetryAfter.Seconds()))
+	}
+	return extensions
+}
+
+// parseID id из GraphQL ID, field - имя аргумента для ошибки
+func parseID(id gql.ID, field string) (int64, error) {
+	value, err := strconv.ParseInt(string(id), 10, 64)
+	if err != nil {
+		return 0, toError(apperr.Field(field, "bad_id", field+" must be an integer"))
+	}
+	return value, nil
+}
+
+func toID(id int64) gql.ID {
+	return gql.ID(strconv.FormatInt(id, 10))
+}
+
+// notFound nil ошибка для отсутствующей сущности, в схеме такие поля nullable
+func notFound(err error) error {
+	if apperr.KindOf(err) == apperr.NotFound {
+		return nil
+	}
+	return toError(err)
+}
+
+type resolver struct {
+	app         app.App
+	idempotency idempotency.Store
+	limiter     ratelimit.Limiter
+	rateLimits  ratelimit.Rules
+}
+
+// allow проверяет лимит мутации для клиента запроса
+func (r *resolver) allow(ctx context.Context, mutation string) error {
+	limit, ok := r.rateLimits.Lookup(mutation)
+	if !ok {
+		return nil
+	}
+	allowed, retryAfter := r.limiter.Allow(limit.Key(mutation, callFrom(ctx).client), limit)
+	if allowed {
+		return nil
+	}
+	return toError(&apperr.Error{
+		Kind:       apperr.ResourceExhausted,
+		Code:       "rate_limited",
+		Message:    fmt.Sprintf("rate limit exceeded, retry after %ds", int(math.Ceil(retryAfter.Seconds()))),
+		RetryAfter: retryAfter,
+	})
+}
+
+// idempotent выполняет мутацию один раз на Idempotency-Key запроса, повтор с теми же аргументами
+// получает сохраненный результат, с другими - ошибку. Ключи у каждой мутации свои, адрес клиента не учитывается:
+// мобильный клиент повторяет запрос уже из другой сети
+func idempotent[T any](ctx context.Context, store idempotency.Store, mutation string, input any,
+	run func() (T, error)) (value T, err error) {
+	c := callFrom(ctx)
+	if c.idempotencyKey == "" {
+		return run()
+	}
+	body, err := json.Marshal(input)
+	if err != nil {
+		return value, toError(err)
+	}
+	scope, key := mutation, c.idempotencyKey
+	stored, replay, err := store.Begin(scope, key, idempotency.Fingerprint([]byte(scope), body))
+	if err != nil {
+		return value, toError(err)
+	}
+	if replay {
+		return stored.(T), nil
+	}
+
+	completed := false
+	defer func() {
+		if !completed {
+			store.Release(scope, key)
+		}
+	}()
+	value, err = run()
+	if err == nil {
+		store.Complete(scope, key, value)
+		completed = true
+	}
+	return value, err
+}
+
+func (r *resolver) Ad(ctx context.Context, args struct{ ID gql.ID }) (*adResolver, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	ad, err := r.app.GetAdByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &adResolver{ad: *ad}, nil
+}
+
+type adFilter struct {
+	AuthorID   *gql.ID
+	Published  bool
+	Title      *string
+	CreateDate *gql.Time
+}
+
+func (r *resolver) Ads(ctx context.Context, args struct {
+	Filter *adFilter
+	First  int32
+	After  *string
+}) (*adConnection, error) {
+	filters := service.AdFilters{AuthorID: -1, Published: true}
+	if f := args.Filter; f != nil {
+		filters.Published = f.Published
+		if f.AuthorID != nil {
+			id, err := parseID(*f.AuthorID, "authorId")
+			if err != nil {
+				return nil, err
+			}
+			filters.AuthorID = id
+		}
+		if f.Title != nil {
+			filters.Title = *f.Title
+		}
+		if f.CreateDate != nil {
+			filters.CreateDate = f.CreateDate.Time
+		}
+	}
+	ads, err := r.app.GetAdsByFilter(ctx, filters)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return paginate(ctx, ads, args.First, args.After)
+}
+
+func (r *resolver) User(ctx context.Context, args struct{ ID gql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.app.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &userResolver{user: *user}, nil
+}
+
+func (r *resolver) UserByNickname(ctx context.Context, args struct{ Nickname string }) (*userResolver, error) {
+	user, err := r.app.GetUserByNickname(ctx, args.Nickname)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &userResolver{user: *user}, nil
+}
+
+type createAdInput struct {
+	UserID gql.ID
+	Title  string
+	Text   string
+}
+
+func (r *resolver) CreateAd(ctx context.Context, args struct{ Input createAdInput }) (*adResolver, error) {
+	userID, err := parseID(args.Input.UserID, "userId")
+	if err != nil {
+		return nil, err
+	}
+	if err = r.allow(ctx, "createAd"); err != nil {
+		return nil, err
+	}
+	ad, err := idempotent(ctx, r.idempotency, "createAd", args.Input, func() (entities.Ad, error) {
+		// автор проверяется до создания, как в /api/v1 и gRPC
+		if _, err := r.app.GetUserByID(ctx, userID); err != nil {
+			return entities.Ad{}, toError(err)
+		}
+		ad, err := r.app.CreateAd(ctx, args.Input.Title, args.Input.Text, userID)
+		if err != nil {
+			return entities.Ad{}, toError(err)
+		}
+		return *ad, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &adResolver{ad: ad}, nil
+}
+
+type updateAdInput struct {
+	ID     gql.ID
+	UserID gql.ID
+	Title  *string
+	Text   *string
+}
+
+func (r *resolver) UpdateAd(ctx context.Context, args struct{ Input updateAdInput }) (*adResolver, error) {
+	id, err := parseID(args.Input.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID(args.Input.UserID, "userId")
+	if err != nil {
+		return nil, err
+	}
+	ad, err := r.app.PatchAd(ctx, id, userID, service.AdPatch{Title: args.Input.Title, Text: args.Input.Text})
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &adResolver{ad: *ad}, nil
+}
+
+type changeAdStatusInput struct {
+	ID        gql.ID
+	UserID    gql.ID
+	Published bool
+}
+
+func (r *resolver) ChangeAdStatus(ctx context.Context, args struct{ Input changeAdStatusInput }) (*adResolver, error) {
+	id, err := parseID(args.Input.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseID(args.Input.UserID, "userId")
+	if err != nil {
+		return nil, err
+	}
+	ad, err := r.app.ChangeAdStatus(ctx, id, userID, args.Input.Published)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &adResolver{ad: *ad}, nil
+}
+
+type deleteAdInput struct {
+	ID     gql.ID
+	UserID gql.ID
+}
+
+func (r *resolver) DeleteAd(ctx context.Context, args struct{ Input deleteAdInput }) (gql.ID, error) {
+	id, err := parseID(args.Input.ID, "id")
+	if err != nil {
+		return "", err
+	}
+	userID, err := parseID(args.Input.UserID, "userId")
+	if err != nil {
+		return "", err
+	}
+	if err = r.app.RemoveAd(ctx, id, userID); err != nil {
+		return "", toError(err)
+	}
+	return args.Input.ID, nil
+}
+
+type createUserInput struct {
+	Nickname string
+	Email    string
+}
+
+func (r *resolver) CreateUser(ctx context.Context, args struct{ Input createUserInput }) (*userResolver, error) {
+	if err := r.allow(ctx, "createUser"); err != nil {
+		return nil, err
+	}
+	user, err := idempotent(ctx, r.idempotency, "createUser", args.Input, func() (entities.User, error) {
+		user, err := r.app.CreateUser(ctx, args.Input.Nickname, args.Input.Email)
+		if err != nil {
+			return entities.User{}, toError(err)
+		}
+		return *user, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &userResolver{user: user}, nil
+}
+
+type updateUserInput struct {
+	ID       gql.ID
+	Nickname *string
+	Email    *string
+}
+
+func (r *resolver) UpdateUser(ctx context.Context, args struct{ Input updateUserInput }) (*userResolver, error) {
+	id, err := parseID(args.Input.ID, "id")
+	if err != nil {
+		return nil, err
+	}
+	user, err := r.app.PatchUser(ctx, id, service.UserPatch{Nickname: args.Input.Nickname, Email: args.Input.Email})
+	if err != nil {
+		return nil, toError(err)
+	}
+	return &userResolver{user: *user}, nil
+}
+
+type adResolver struct {
+	ad entities.Ad
+}
+
+func (r *adResolver) ID() gql.ID {
+	return toID(r.ad.ID)
+}
+
+func (r *adResolver) Title() string {
+	return r.ad.Title
+}
+
+func (r *adResolver) Text() string {
+	return r.ad.Text
+}
+
+func (r *adResolver) Published() bool {
+	return r.ad.Published
+}
+
+func (r *adResolver) CreateDate() gql.Time {
+	return gql.Time{Time: r.ad.CreateDate}
+}
+
+func (r *adResolver) UpdateDate() gql.Time {
+	return gql.Time{Time: r.ad.UpdateDate}
+}
+
+func (r *adResolver) AuthorID() gql.ID {
+	return toID(r.ad.AuthorID)
+}
+
+func (r *adResolver) Author(ctx context.Context) (*userResolver, error) {
+	user, ok, err := loadersFrom(ctx).users.load(ctx, r.ad.AuthorID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &userResolver{user: user}, nil
+}
+
+type userResolver struct {
+	user entities.User
+}
+
+func (r *userResolver) ID() gql.ID {
+	return toID(r.user.ID)
+}
+
+func (r *userResolver) Nickname() string {
+	return r.user.Nickname
+}
+
+func (r *userResolver) Email() string {
+	return r.user.Email
+}
+
+func (r *userResolver) Verified() bool {
+	return r.user.Verified
+}
+
+func (r *userResolver) Ads(ctx context.Context, args struct {
+	First int32
+	After *string
+}) (*adConnection, error) {
+	ads, _, err := loadersFrom(ctx).adsByAuthor.load(ctx, r.user.ID)
+	if err != nil {
+		return nil, toError(err)
+	}
+	return paginate(ctx, ads, args.First, args.After)
+}
+
+// cursor непрозрачный для клиента курсор после объявления id
+func cursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("ad:" + strconv.FormatInt(id, 10)))
+}
+
+func cursorID(c string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, toError(errCursor)
+	}
+	value, ok := strings.CutPrefix(string(raw), "ad:")
+	if !ok {
+		return 0, toError(errCursor)
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, toError(errCursor)
+	}
+	return id, nil
+}
+
+// paginate страница ads по возрастанию id после курсора after.
+// Авторы страницы откладываются в загрузчики, чтобы author и author.ads пришли одним вызовом на всю страницу
+func paginate(ctx context.Context, ads []entities.Ad, first int32, after *string) (*adConnection, error) {
+	if first < 0 || first > maxPageSize {
+		return nil, toError(errPageSize)
+	}
+	sorted := slices.Clone(ads)
+	slices.SortFunc(sorted, func(a, b entities.Ad) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	conn := &adConnection{total: len(sorted)}
+	page := sorted
+	if after != nil {
+		afterID, err := cursorID(*after)
+		if err != nil {
+			return nil, err
+		}
+		start, found := slices.BinarySearchFunc(sorted, afterID, func(ad entities.Ad, id int64) int {
+			return cmp.Compare(ad.ID, id)
+		})
+		if found {
+			start++
+		}
+		page = sorted[start:]
+	}
+	if len(page) > int(first) {
+		page, conn.hasNextPage = page[:first], true
+	}
+	if err := callFrom(ctx).reserve(len(page)); err != nil {
+		return nil, err
+	}
+	conn.ads = page
+
+	l := loadersFrom(ctx)
+	for _, ad := range page {
+		l.users.queue(ad.AuthorID)
+		l.adsByAuthor.queue(ad.AuthorID)
+	}
+	return conn, nil
+}
+
+type adConnection struct {
+	ads         []entities.Ad
+	total       int
+	hasNextPage bool
+}
+
+func (c *adConnection) Nodes() []*adResolver {
+	nodes := make([]*adResolver, 0, len(c.ads))
+	for _, ad := range c.ads {
+		nodes = append(nodes, &adResolver{ad: ad})
+	}
+	return nodes
+}
+
+func (c *adConnection) TotalCount() int32 {
+	return int32(c.total)
+}
+
+func (c *adConnection) PageInfo() *pageInfo {
+	info := &pageInfo{hasNextPage: c.hasNextPage}
+	if len(c.ads) > 0 {
+		end := cursor(c.ads[len(c.ads)-1].ID)
+		info.endCursor = &end
+	}
+	return info
+}
+
+type pageInfo struct {
+	endCursor   *string
+	hasNextPage bool
+}
+
+func (p *pageInfo) EndCursor() *string {
+	return p.endCursor
+}
+
+func (p *pageInfo) HasNextPage() bool {
+	return p.hasNextPage
+}
@@ -2,11 +2,22 @@ package grpc
 
 import (
 	"context"
+	"fmt"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
 	"homework10/internal/util"
 	"log"
+	"math"
+	"net"
+	"strconv"
 	"time"
 )
 
@@ -45,3 +56,153 @@ func RecoveryInterceptor(logger *log.Logger) grpc.UnaryServerInterceptor {
 		return handler(ctx, req)
 	}
 }
+
+// AuditInterceptor кладет в контекст адрес клиента для журнала аудита
+func AuditInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(withAuditOrigin(ctx), req)
+	}
+}
+
+// AuditStreamInterceptor то же для потоковых методов, ImportAds тоже изменяет объявления
+func AuditStreamInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &auditStream{ServerStream: ss, ctx: withAuditOrigin(ss.Context())})
+	}
+}
+
+type auditStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *auditStream) Context() context.Context {
+	return s.ctx
+}
+
+func withAuditOrigin(ctx context.Context) context.Context {
+	return audit.WithOrigin(ctx, audit.Origin{Transport: audit.GRPC, IP: peerIP(ctx)})
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	ip := p.Addr.String()
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	return ip
+}
+
+// clientKey адрес клиента, по нему считаются лимиты
+func clientKey(ctx context.Context) string {
+	return "ip:" + peerIP(ctx)
+}
+
+const (
+	idempotencyKeyMetadata = "idempotency-key"
+	// idempotentReplayedMetadata заголовок ответа, сохраненного при первом вызове
+	idempotentReplayedMetadata = "idempotent-replayed"
+)
+
+// IdempotencyInterceptor запоминает успешный ответ на вызов methods с метаданными idempotency-key и отдает его на повторы.
+// Тот же ключ с другим запросом - FailedPrecondition, пока первый вызов выполняется - Aborted
+func IdempotencyInterceptor(store idempotency.Store, methods ...string) grpc.UnaryServerInterceptor {
+	idempotent := make(map[string]struct{}, len(methods))
+	for _, method := range methods {
+		idempotent[method] = struct{}{}
+	}
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp any, err error) {
+		if _, ok := idempotent[info.FullMethod]; !ok {
+			return handler(ctx, req)
+		}
+		values := metadata.ValueFromIncomingContext(ctx, idempotencyKeyMetadata)
+		message, ok := req.(proto.Message)
+		if len(values) == 0 || !ok {
+			return handler(ctx, req)
+		}
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(message)
+		if err != nil {
+			return nil, statusError(apperr.Wrap(apperr.InvalidArgument, "bad_request", err))
+		}
+
+		// адрес клиента в scope не входит: мобильный клиент повторяет вызов уже из другой сети.
+		// Чужой ответ по тому же ключу не вернется, отпечаток связывает ключ с запросом вместе с user_id
+		scope, key := info.FullMethod, values[0]
+		stored, replay, err := store.Begin(scope, key, idempotency.Fingerprint([]byte(scope), body))
+		if err != nil {
+			return nil, statusError(err)
+		}
+		if replay {
+			_ = grpc.SetHeader(ctx, metadata.Pairs(idempotentReplayedMetadata, "true"))
+			return proto.Clone(stored.(proto.Message)), nil
+		}
+
+		completed := false
+		// Release и при панике обработчика, иначе ключ останется занятым до истечения TTL
+		defer func() {
+			if !completed {
+				store.Release(scope, key)
+			}
+		}()
+		resp, err = handler(ctx, req)
+		if response, ok := resp.(proto.Message); ok && err == nil {
+			store.Complete(scope, key, proto.Clone(response))
+			completed = true
+		}
+		return resp, err
+	}
+}
+
+const retryAfterMetadata = "retry-after"
+
+// DefaultRateLimits ограничивает только создание объявлений и пользователей, остальные методы без лимита
+var DefaultRateLimits = ratelimit.Rules{
+	"/ad.AdService/AddAd":          ratelimit.CreateAd,
+	"/ad.AdService/BatchCreateAds": ratelimit.BatchAds,
+	"/ad.AdService/ImportAds":      ratelimit.ImportAds,
+	"/ad.AdService/AddUser":        ratelimit.CreateUser,
+}
+
+// RateLimitInterceptor ограничивает частоту вызовов методов из rules отдельно для каждого адреса клиента.
+// requester_id и user_id из запроса не учитываются: их присылает сам клиент.
+// Сверх лимита - ResourceExhausted и retry-after в секундах
+func RateLimitInterceptor(limiter ratelimit.Limiter, rules ratelimit.Rules) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := allow(ctx, limiter, rules, info.FullMethod, clientKey(ctx)); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// RateLimitStreamInterceptor то же для потоковых методов
+func RateLimitStreamInterceptor(limiter ratelimit.Limiter, rules ratelimit.Rules) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := allow(ss.Context(), limiter, rules, info.FullMethod, clientKey(ss.Context())); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func allow(ctx context.Context, limiter ratelimit.Limiter, rules ratelimit.Rules, method, requester string) error {
+	limit, ok := rules.Lookup(method)
+	if !ok {
+		return nil
+	}
+	allowed, retryAfter := limiter.Allow(limit.Key(method, requester), limit)
+	if allowed {
+		return nil
+	}
+	seconds := strconv.Itoa(int(math.Ceil(retryAfter.Seconds())))
+	_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadata, seconds))
+	return statusError(&apperr.Error{
+		Kind:       apperr.ResourceExhausted,
+		Code:       "rate_limited",
+		Message:    fmt.Sprintf("rate limit exceeded, retry after %ss", seconds),
+		RetryAfter: retryAfter,
+	})
+}
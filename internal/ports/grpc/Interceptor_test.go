@@ -4,11 +4,20 @@ import (
 	"bytes"
 	"context"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"homework10/internal/audit"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
 	"log"
+	"net"
 	"testing"
+	"time"
 )
 
 func TestLoggerInterceptor(t *testing.T) {
@@ -65,3 +74,105 @@ func TestRecoveryInterceptor(t *testing.T) {
 	assert.NotEqual(t, logOutput.String(), 0, "log output should be empty")
 	assert.Equal(t, codes.Internal, status.Code(err))
 }
+
+func TestAuditInterceptor(t *testing.T) {
+	interceptor := AuditInterceptor()
+	info := &grpc.UnaryServerInfo{
+		FullMethod: "/service.Service/Method",
+	}
+	var origin audit.Origin
+	handlerFunc := func(ctx context.Context, req any) (any, error) {
+		origin = audit.OriginFrom(ctx)
+		return nil, nil
+	}
+
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 4), Port: 5051}})
+	_, _ = interceptor(ctx, nil, info, handlerFunc)
+
+	assert.Equal(t, audit.Origin{Transport: audit.GRPC, IP: "10.0.0.4"}, origin)
+}
+
+func TestIdempotencyInterceptor(t *testing.T) {
+	interceptor := IdempotencyInterceptor(idempotency.NewStore(), "/ad.AdService/AddAd")
+	info := &grpc.UnaryServerInfo{FullMethod: "/ad.AdService/AddAd"}
+	calls := int64(0)
+	handlerFunc := func(ctx context.Context, req any) (any, error) {
+		calls++
+		if req.(*CreateAdRequest).Title == "" {
+			return nil, status.Error(codes.InvalidArgument, "invalid argument")
+		}
+		return &AdResponse{Id: calls, Title: req.(*CreateAdRequest).Title}, nil
+	}
+	call := func(key string, req *CreateAdRequest) (any, error) {
+		ctx := context.Background()
+		if key != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(idempotencyKeyMetadata, key))
+		}
+		return interceptor(ctx, req, info, handlerFunc)
+	}
+
+	first, err := call("key", &CreateAdRequest{UserId: 1, Title: "a", Text: "b"})
+	assert.NoError(t, err)
+	retry, err := call("key", &CreateAdRequest{UserId: 1, Title: "a", Text: "b"})
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(first.(proto.Message), retry.(proto.Message)))
+	assert.NotSame(t, first, retry)
+
+	_, err = call("key", &CreateAdRequest{UserId: 1, Title: "a", Text: "c"})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	_, err = call("bad key", &CreateAdRequest{UserId: 1, Title: "a"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, int64(1), calls)
+
+	// ошибка не сохраняется, повтор с тем же ключом выполняется заново
+	_, err = call("other", &CreateAdRequest{UserId: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = call("other", &CreateAdRequest{UserId: 1})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, int64(3), calls)
+
+	// без ключа и для других методов вызов не запоминается
+	_, _ = call("", &CreateAdRequest{UserId: 1, Title: "a", Text: "b"})
+	_, _ = interceptor(metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyMetadata, "key")),
+		&CreateAdRequest{UserId: 1, Title: "a", Text: "b"}, &grpc.UnaryServerInfo{FullMethod: "/ad.AdService/ModifyAd"}, handlerFunc)
+	assert.Equal(t, int64(5), calls)
+
+	// повтор с другого адреса, например после смены сети, получает сохраненный ответ
+	ctx := peer.NewContext(metadata.NewIncomingContext(context.Background(), metadata.Pairs(idempotencyKeyMetadata, "key")),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 9), Port: 5051}})
+	other, err := interceptor(ctx, &CreateAdRequest{UserId: 1, Title: "a", Text: "b"}, info, handlerFunc)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), other.(*AdResponse).Id)
+	// тот же ключ с запросом другого пользователя - другой запрос
+	_, err = interceptor(ctx, &CreateAdRequest{UserId: 2, Title: "a", Text: "b"}, info, handlerFunc)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, int64(5), calls)
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	interceptor := RateLimitInterceptor(ratelimit.NewLimiter(), ratelimit.Rules{"/ad.AdService/AddAd": {Rate: 0.5, Burst: 1}})
+	info := &grpc.UnaryServerInfo{FullMethod: "/ad.AdService/AddAd"}
+	handlerFunc := func(ctx context.Context, req any) (any, error) {
+		return &AdResponse{}, nil
+	}
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 5), Port: 5051}})
+
+	_, err := interceptor(ctx, &CreateAdRequest{UserId: 1}, info, handlerFunc)
+	assert.NoError(t, err)
+	_, err = interceptor(ctx, &CreateAdRequest{UserId: 1}, info, handlerFunc)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	assert.Contains(t, err.Error(), "retry after 2s")
+	retry, ok := errorDetail[*errdetails.RetryInfo](err)
+	assert.True(t, ok)
+	assert.InDelta(t, 2*time.Second, retry.RetryDelay.AsDuration(), float64(10*time.Millisecond))
+
+	// лимит считается по адресу: другой user_id его не обходит, другой адрес не задевает
+	_, err = interceptor(ctx, &CreateAdRequest{UserId: 2}, info, handlerFunc)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	other := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 6), Port: 5051}})
+	_, err = interceptor(other, &CreateAdRequest{UserId: 1}, info, handlerFunc)
+	assert.NoError(t, err)
+	// другие методы не ограничены
+	_, err = interceptor(ctx, &GetUserRequest{}, &grpc.UnaryServerInfo{FullMethod: "/ad.AdService/GetUser"}, handlerFunc)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,109 @@
+package grpc
+
+import (
+	"fmt"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"homework10/internal/apperr"
+	"strconv"
+	"strings"
+)
+
+// errorDomain домен причин в ErrorInfo
+const errorDomain = "ads.homework10"
+
+// grpcCodes коды ответа по классу ошибки, HTTP статусы задаются такой же таблицей в httpgin
+var grpcCodes = map[apperr.Kind]codes.Code{
+	apperr.InvalidArgument:    codes.InvalidArgument,
+	apperr.NotFound:           codes.NotFound,
+	apperr.AlreadyExists:      codes.AlreadyExists,
+	apperr.PermissionDenied:   codes.PermissionDenied,
+	apperr.FailedPrecondition: codes.FailedPrecondition,
+	apperr.Aborted:            codes.Aborted,
+	apperr.ResourceExhausted:  codes.ResourceExhausted,
+	apperr.QuotaExceeded:      codes.ResourceExhausted,
+	apperr.Unimplemented:      codes.Unimplemented,
+	apperr.Unavailable:        codes.Unavailable,
+	apperr.Internal:           codes.Internal,
+}
+
+// resourceTypes тип ресурса для ResourceInfo по коду ошибки "не найдено"
+var resourceTypes = map[string]string{
+	"ad_not_found":           "ad",
+	"user_not_found":         "user",
+	"webhook_not_found":      "webhook",
+	"outbox_entry_not_found": "outbox_entry",
+}
+
+// resources идентификаторы ресурсов вызова по типу, из них берется имя в ResourceInfo
+type resources map[string]int64
+
+// grpcCode код ответа для ошибки err, для nil - OK
+func grpcCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if code, ok := grpcCodes[apperr.KindOf(err)]; ok {
+		return code
+	}
+	return codes.Internal
+}
+
+// statusError ошибка err с кодом по ее классу и подробностями: ErrorInfo всегда, BadRequest для ошибок
+// в полях, ResourceInfo для ненайденных ресурсов, RetryInfo, если известно, когда повторить.
+// Текст внутренних ошибок клиенту не показывается
+func statusError(err error) error {
+	return resourceError(err, nil)
+}
+
+// ErrorStatus google.rpc.Status с теми же подробностями, что у statusError, для ответов REST в protobuf
+func ErrorStatus(err error) *spb.Status {
+	return status.Convert(statusError(err)).Proto()
+}
+
+// resourceError как statusError, но в ResourceInfo попадает идентификатор ненайденного ресурса из ids
+func resourceError(err error, ids resources) error {
+	e := apperr.From(err)
+	code := grpcCode(e)
+	message := e.Message
+	if code == codes.Internal {
+		message = "internal error"
+	}
+	st := status.New(code, message)
+
+	info := &errdetails.ErrorInfo{Reason: strings.ToUpper(e.Code), Domain: errorDomain}
+	if len(e.Details) > 0 {
+		info.Metadata = make(map[string]string, len(e.Details))
+		for k, v := range e.Details {
+			info.Metadata[k] = fmt.Sprint(v)
+		}
+	}
+	details := []protoadapt.MessageV1{info}
+	if len(e.Fields) > 0 {
+		violations := make([]*errdetails.BadRequest_FieldViolation, 0, len(e.Fields))
+		for _, f := range e.Fields {
+			violations = append(violations, &errdetails.BadRequest_FieldViolation{Field: f.Field, Description: f.Message})
+		}
+		details = append(details, &errdetails.BadRequest{FieldViolations: violations})
+	}
+	if resourceType, ok := resourceTypes[e.Code]; ok && e.Kind == apperr.NotFound {
+		resource := &errdetails.ResourceInfo{ResourceType: resourceType, Description: e.Message}
+		if id, ok := ids[resourceType]; ok {
+			resource.ResourceName = strconv.FormatInt(id, 10)
+		}
+		details = append(details, resource)
+	}
+	if e.RetryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(e.RetryAfter)})
+	}
+
+	withDetails, detailsErr := st.WithDetails(details...)
+	if detailsErr != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
@@ -0,0 +1,199 @@
+package grpc
+
+import (
+	"context"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/encoding/protojson"
+	"homework10/internal/app"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// NewGateway HTTP/JSON шлюз по аннотациям google.api.http из service.proto.
+// Запросы вызывают GServer в том же процессе через те же лимиты и idempotency-key, что и gRPC сервер,
+// а журнал, восстановление после паник и аудит остаются на HTTP сервере
+func NewGateway(a app.App, opts ...Option) (http.Handler, error) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.idempotency == nil {
+		o.idempotency = idempotency.NewStore()
+	}
+	if o.limiter == nil {
+		o.limiter, o.rateLimits = ratelimit.NewLimiter(), DefaultRateLimits
+	}
+
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(runtime.MIMEWildcard, &runtime.JSONPb{
+			MarshalOptions:   protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true},
+			UnmarshalOptions: protojson.UnmarshalOptions{DiscardUnknown: true},
+		}),
+		runtime.WithIncomingHeaderMatcher(incomingHeader),
+		runtime.WithOutgoingHeaderMatcher(outgoingHeader),
+	)
+	server := gatewayServer{
+		AdServiceServer: GServer{App: a},
+		interceptor: chainUnary(RateLimitInterceptor(o.limiter, o.rateLimits),
+			IdempotencyInterceptor(o.idempotency, idempotentMethods...)),
+	}
+	if err := RegisterAdServiceHandlerServer(context.Background(), mux, server); err != nil {
+		return nil, err
+	}
+	return withPeer(mux), nil
+}
+
+// incomingHeader Idempotency-Key передается как метаданные idempotency-key, остальное - как в grpc-gateway по умолчанию
+func incomingHeader(key string) (string, bool) {
+	if strings.EqualFold(key, "Idempotency-Key") {
+		return idempotencyKeyMetadata, true
+	}
+	return runtime.DefaultHeaderMatcher(key)
+}
+
+// outgoingHeader метаданные ответа с тем же смыслом, что и заголовки /api/v1, отдаются без префикса Grpc-Metadata-
+func outgoingHeader(key string) (string, bool) {
+	switch key {
+	case retryAfterMetadata:
+		return "Retry-After", true
+	case idempotentReplayedMetadata:
+		return "Idempotent-Replayed", true
+	}
+	return runtime.MetadataHeaderPrefix + key, true
+}
+
+// withPeer адрес клиента для лимитов без requester_id, как у вызова по gRPC
+func withPeer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if addr, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			r = r.WithContext(peer.NewContext(r.Context(), &peer.Peer{Addr: net.TCPAddrFromAddrPort(addr)}))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// chainUnary один interceptor из нескольких, первый в списке вызывается первым, как у grpc.ChainUnaryInterceptor
+func chainUnary(interceptors ...grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req any) (any, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// gatewayServer пропускает вызовы шлюза через interceptor.
+// Шлюз вызывает только методы с google.api.http, потоковые методы уходят во встроенный AdServiceServer как есть
+type gatewayServer struct {
+	AdServiceServer
+	interceptor grpc.UnaryServerInterceptor
+}
+
+func intercept[Req, Resp any](s gatewayServer, ctx context.Context, method string, req Req, handler func(context.Context, Req) (Resp, error)) (Resp, error) {
+	info := &grpc.UnaryServerInfo{Server: s.AdServiceServer, FullMethod: "/ad.AdService/" + method}
+	resp, err := s.interceptor(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+		return handler(ctx, req.(Req))
+	})
+	if err != nil {
+		var empty Resp
+		return empty, err
+	}
+	return resp.(Resp), nil
+}
+
+func (s gatewayServer) AddAd(ctx context.Context, req *CreateAdRequest) (*AdResponse, error) {
+	return intercept(s, ctx, "AddAd", req, s.AdServiceServer.AddAd)
+}
+
+func (s gatewayServer) UpdateAdStatus(ctx context.Context, req *ChangeAdStatusRequest) (*AdResponse, error) {
+	return intercept(s, ctx, "UpdateAdStatus", req, s.AdServiceServer.UpdateAdStatus)
+}
+
+func (s gatewayServer) ModifyAd(ctx context.Context, req *UpdateAdRequest) (*AdResponse, error) {
+	return intercept(s, ctx, "ModifyAd", req, s.AdServiceServer.ModifyAd)
+}
+
+func (s gatewayServer) GetAd(ctx context.Context, req *GetADByIDRequest) (*AdResponse, error) {
+	return intercept(s, ctx, "GetAd", req, s.AdServiceServer.GetAd)
+}
+
+func (s gatewayServer) GetAds(ctx context.Context, req *AdFilters) (*ListAdResponse, error) {
+	return intercept(s, ctx, "GetAds", req, s.AdServiceServer.GetAds)
+}
+
+func (s gatewayServer) RemoveAd(ctx context.Context, req *DeleteAdRequest) (*DeleteAdResponse, error) {
+	return intercept(s, ctx, "RemoveAd", req, s.AdServiceServer.RemoveAd)
+}
+
+func (s gatewayServer) ModifyUser(ctx context.Context, req *UserUpdateRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "ModifyUser", req, s.AdServiceServer.ModifyUser)
+}
+
+func (s gatewayServer) AddUser(ctx context.Context, req *UserRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "AddUser", req, s.AdServiceServer.AddUser)
+}
+
+func (s gatewayServer) GetUser(ctx context.Context, req *GetUserRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "GetUser", req, s.AdServiceServer.GetUser)
+}
+
+func (s gatewayServer) RemoveUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
+	return intercept(s, ctx, "RemoveUser", req, s.AdServiceServer.RemoveUser)
+}
+
+func (s gatewayServer) VerifyUser(ctx context.Context, req *VerifyUserRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "VerifyUser", req, s.AdServiceServer.VerifyUser)
+}
+
+func (s gatewayServer) GetUserByNickname(ctx context.Context, req *GetUserByNicknameRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "GetUserByNickname", req, s.AdServiceServer.GetUserByNickname)
+}
+
+func (s gatewayServer) ExportUserData(ctx context.Context, req *ExportUserDataRequest) (*ExportUserDataResponse, error) {
+	return intercept(s, ctx, "ExportUserData", req, s.AdServiceServer.ExportUserData)
+}
+
+func (s gatewayServer) EraseUser(ctx context.Context, req *EraseUserRequest) (*UserResponse, error) {
+	return intercept(s, ctx, "EraseUser", req, s.AdServiceServer.EraseUser)
+}
+
+func (s gatewayServer) BatchCreateAds(ctx context.Context, req *BatchCreateAdsRequest) (*BatchAdsResponse, error) {
+	return intercept(s, ctx, "BatchCreateAds", req, s.AdServiceServer.BatchCreateAds)
+}
+
+func (s gatewayServer) BatchUpdateAdStatus(ctx context.Context, req *BatchUpdateAdStatusRequest) (*BatchAdsResponse, error) {
+	return intercept(s, ctx, "BatchUpdateAdStatus", req, s.AdServiceServer.BatchUpdateAdStatus)
+}
+
+func (s gatewayServer) BatchDeleteAds(ctx context.Context, req *BatchDeleteAdsRequest) (*BatchAdsResponse, error) {
+	return intercept(s, ctx, "BatchDeleteAds", req, s.AdServiceServer.BatchDeleteAds)
+}
+
+func (s gatewayServer) AddWebhook(ctx context.Context, req *CreateWebhookRequest) (*WebhookResponse, error) {
+	return intercept(s, ctx, "AddWebhook", req, s.AdServiceServer.AddWebhook)
+}
+
+func (s gatewayServer) ListWebhooks(ctx context.Context, req *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	return intercept(s, ctx, "ListWebhooks", req, s.AdServiceServer.ListWebhooks)
+}
+
+func (s gatewayServer) RemoveWebhook(ctx context.Context, req *WebhookRequest) (*DeleteWebhookResponse, error) {
+	return intercept(s, ctx, "RemoveWebhook", req, s.AdServiceServer.RemoveWebhook)
+}
+
+func (s gatewayServer) ListWebhookDeliveries(ctx context.Context, req *WebhookRequest) (*ListWebhookDeliveriesResponse, error) {
+	return intercept(s, ctx, "ListWebhookDeliveries", req, s.AdServiceServer.ListWebhookDeliveries)
+}
+
+func (s gatewayServer) ListWebhookDeadLetters(ctx context.Context, req *WebhookRequest) (*ListWebhookDeadLettersResponse, error) {
+	return intercept(s, ctx, "ListWebhookDeadLetters", req, s.AdServiceServer.ListWebhookDeadLetters)
+}
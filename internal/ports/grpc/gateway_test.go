@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"homework10/internal/entities"
+	mocks "homework10/internal/mocks/appemocks"
+	"homework10/internal/ratelimit"
+	"homework10/internal/service"
+	"homework10/internal/util"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serveGateway(t *testing.T, gateway http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	gateway.ServeHTTP(w, req)
+	return w
+}
+
+func decodeGateway(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body
+}
+
+func TestGateway_GetAd(t *testing.T) {
+	a := new(mocks.App)
+	ad := tAd
+	ad.ID, ad.AuthorID = 7, 3
+	a.On("GetAdByID", mock.Anything, int64(7)).Return(&ad, nil)
+	gateway, err := NewGateway(a)
+	assert.NoError(t, err)
+
+	w := serveGateway(t, gateway, http.MethodGet, "/api/v2/ads/7", "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	body := decodeGateway(t, w)
+	// int64 в protojson - строки, имена полей как в service.proto
+	assert.Equal(t, "7", body["id"])
+	assert.Equal(t, "3", body["author_id"])
+	assert.Equal(t, ad.Title, body["title"])
+	assert.Equal(t, false, body["published"])
+}
+
+func TestGateway_NotFound(t *testing.T) {
+	a := new(mocks.App)
+	a.On("GetAdByID", mock.Anything, int64(100)).Return(emptyAd, util.ErrNotFound)
+	gateway, err := NewGateway(a)
+	assert.NoError(t, err)
+
+	w := serveGateway(t, gateway, http.MethodGet, "/api/v2/ads/100", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	body := decodeGateway(t, w)
+	assert.EqualValues(t, 5, body["code"])
+	details, _ := body["details"].([]any)
+	assert.NotEmpty(t, details)
+	assert.Contains(t, w.Body.String(), `"AD_NOT_FOUND"`)
+	assert.Contains(t, w.Body.String(), `"resource_type":"ad"`)
+
+	w = serveGateway(t, gateway, http.MethodGet, "/api/v2/ads/abc", "", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveGateway(t, gateway, http.MethodGet, "/api/v2/unknown", "", nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestGateway_PatchAd_UpdateMask(t *testing.T) {
+	a := new(mocks.App)
+	title := "patched"
+	ad := tAd
+	ad.ID, ad.Title = 4, title
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&tUser, nil)
+	a.On("PatchAd", mock.Anything, int64(4), int64(1), service.AdPatch{Title: &title}).Return(&ad, nil).Once()
+	gateway, err := NewGateway(a)
+	assert.NoError(t, err)
+
+	// text без маски не меняется, хотя и пришел в теле
+	w := serveGateway(t, gateway, http.MethodPatch, "/api/v2/ads/4",
+		`{"user_id":"1","title":"patched","text":"ignored","update_mask":"title"}`, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, title, decodeGateway(t, w)["title"])
+	a.AssertExpectations(t)
+}
+
+func TestGateway_Idempotency(t *testing.T) {
+	a := new(mocks.App)
+	user := tUser
+	user.ID = 1
+	ad := tAd
+	ad.ID, ad.AuthorID = 9, 1
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&user, nil).Once()
+	a.On("CreateAd", mock.Anything, "title", "text", int64(1)).Return(&ad, nil).Once()
+	gateway, err := NewGateway(a)
+	assert.NoError(t, err)
+
+	header := http.Header{"Idempotency-Key": {"key-1"}}
+	body := `{"title":"title","text":"text","user_id":"1"}`
+	first := serveGateway(t, gateway, http.MethodPost, "/api/v2/ads", body, header)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	second := serveGateway(t, gateway, http.MethodPost, "/api/v2/ads", body, header)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+	assert.JSONEq(t, first.Body.String(), second.Body.String())
+	a.AssertExpectations(t)
+}
+
+func TestGateway_RateLimit(t *testing.T) {
+	a := new(mocks.App)
+	user := tUser
+	user.ID = 1
+	a.On("GetUserByID", mock.Anything, int64(1)).Return(&user, nil)
+	a.On("CreateAd", mock.Anything, "title", "text", int64(1)).Return(&entities.Ad{ID: 1, AuthorID: 1}, nil)
+	gateway, err := NewGateway(a, WithRateLimits(ratelimit.NewLimiter(), ratelimit.Rules{"/ad.AdService/AddAd": {Rate: 0.5, Burst: 1}}))
+	assert.NoError(t, err)
+
+	body := `{"title":"title","text":"text","user_id":"1"}`
+	w := serveGateway(t, gateway, http.MethodPost, "/api/v2/ads", body, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveGateway(t, gateway, http.MethodPost, "/api/v2/ads", body, nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"RATE_LIMITED"`)
+}
@@ -3,26 +3,75 @@ package grpc
 import (
 	"context"
 	"errors"
-	"github.com/AirstaNs/ValidationAds"
-	"google.golang.org/grpc/codes"
-	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
-	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/adapters/adfile"
 	"homework10/internal/app"
+	"homework10/internal/apperr"
 	"homework10/internal/entities"
 	"homework10/internal/service"
-	"homework10/internal/util"
 	"time"
 )
 
 var (
-	errInvalidArgument = status.Error(codes.InvalidArgument, "invalid argument")
-	errNotFound        = status.Error(codes.NotFound, "not found")
-	errUnknown         = status.Error(codes.Unknown, "unknown error")
-	errForbidden       = status.Error(codes.PermissionDenied, "permission denied")
+	errFileFormat   = apperr.Field("format", "bad_file_format", "unknown file format")
+	errExportFormat = apperr.Field("format", "bad_export_format", "unknown export format")
+	errAdsPolicy    = apperr.Field("ads_policy", "bad_ads_policy", "unknown ads policy")
+	errEmptyImport  = apperr.New(apperr.InvalidArgument, "empty_import", "import stream has no messages")
+	errTooSlow      = apperr.New(apperr.ResourceExhausted, "too_slow", "client does not keep up with events")
+	errUpdateMask   = apperr.Field("update_mask", "bad_update_mask", "update_mask contains an unknown field")
 )
 
+// maskedFields поля из update_mask, "*" означает все поля fields
+func maskedFields(mask *fieldmaskpb.FieldMask, fields ...string) (map[string]bool, error) {
+	known := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		known[field] = true
+	}
+	masked := make(map[string]bool, len(fields))
+	for _, path := range mask.GetPaths() {
+		switch {
+		case path == "*":
+			return known, nil
+		case !known[path]:
+			return nil, errUpdateMask
+		}
+		masked[path] = true
+	}
+	return masked, nil
+}
+
+// maskedValue значение поля для service.AdPatch и service.UserPatch, nil - поля нет в маске и оно не меняется
+func maskedValue(masked map[string]bool, field string, value string) *string {
+	if !masked[field] {
+		return nil
+	}
+	return &value
+}
+
+var fileFormats = map[FileFormat]adfile.Format{
+	FileFormat_FILE_FORMAT_JSONL: adfile.JSONL,
+	FileFormat_FILE_FORMAT_CSV:   adfile.CSV,
+}
+
+var adEventTypes = map[service.AdEventType]AdEvent_Type{
+	service.AdCreated:       AdEvent_CREATED,
+	service.AdUpdated:       AdEvent_UPDATED,
+	service.AdStatusChanged: AdEvent_UPDATED,
+	service.AdDeleted:       AdEvent_DELETED,
+}
+
+var adsPolicies = map[DeleteUserRequest_AdsPolicy]app.AdsPolicy{
+	DeleteUserRequest_CASCADE:  app.CascadeAds,
+	DeleteUserRequest_TRANSFER: app.TransferAds,
+}
+
+var exportFormats = map[ExportUserDataRequest_Format]app.ExportFormat{
+	ExportUserDataRequest_JSON: app.ExportJSON,
+	ExportUserDataRequest_ZIP:  app.ExportZIP,
+}
+
 type GServer struct {
 	app.App
 }
@@ -31,20 +80,11 @@ func (s GServer) AddAd(ctx context.Context, req *CreateAdRequest) (*AdResponse,
 	empty := &AdResponse{}
 	id, err := s.GetUserByID(ctx, req.UserId)
 	if err != nil {
-		return empty, errNotFound
+		return empty, resourceError(err, resources{"user": req.UserId})
 	}
 	ad, err := s.App.CreateAd(ctx, req.Title, req.Text, id.ID)
 	if err != nil {
-		if isEmptyAuthorID := errors.Is(err, userrepo.ErrEmptyUser); isEmptyAuthorID {
-			return empty, errNotFound
-		}
-		isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-		isBadText := errors.Is(err, ValidationAds.ErrBadText)
-		isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-		if isBadTitle || isBadText || isBadAuthorID {
-			return empty, errInvalidArgument
-		}
-		return empty, errUnknown
+		return empty, resourceError(err, resources{"user": req.UserId})
 	}
 	return AdSuccessResponse(ad), nil
 
@@ -56,21 +96,12 @@ func (s GServer) UpdateAdStatus(ctx context.Context, req *ChangeAdStatusRequest)
 	_, err := app.GetUserByID(ctx, req.UserId)
 
 	if err != nil {
-		return empty, errNotFound
+		return empty, resourceError(err, resources{"user": req.UserId})
 	}
 	adStatus, err := app.ChangeAdStatus(ctx, req.AdId, req.UserId, req.Published)
 
 	if err != nil {
-		isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-		if isBadAuthorID {
-			return empty, errForbidden
-		}
-		isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-		isBadText := errors.Is(err, ValidationAds.ErrBadText)
-		if isBadTitle || isBadText {
-			return empty, errInvalidArgument
-		}
-		return empty, errUnknown
+		return empty, resourceError(err, resources{"ad": req.AdId, "user": req.UserId})
 	}
 	return AdSuccessResponse(adStatus), nil
 
@@ -82,20 +113,23 @@ func (s GServer) ModifyAd(ctx context.Context, req *UpdateAdRequest) (*AdRespons
 	_, err := app.GetUserByID(ctx, req.UserId)
 
 	if err != nil {
-		return empty, errNotFound
+		return empty, resourceError(err, resources{"user": req.UserId})
 	}
-	ad, err := app.UpdateAd(ctx, req.AdId, req.UserId, req.Title, req.Text)
-	if err != nil {
-		isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-		if isBadAuthorID {
-			return empty, errForbidden
-		}
-		isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-		isBadText := errors.Is(err, ValidationAds.ErrBadText)
-		if isBadTitle || isBadText {
-			return empty, errInvalidArgument
+	var ad *entities.Ad
+	if req.UpdateMask == nil {
+		ad, err = app.UpdateAd(ctx, req.AdId, req.UserId, req.Title, req.Text)
+	} else {
+		masked, maskErr := maskedFields(req.UpdateMask, "title", "text")
+		if maskErr != nil {
+			return empty, statusError(maskErr)
 		}
-		return empty, errUnknown
+		ad, err = app.PatchAd(ctx, req.AdId, req.UserId, service.AdPatch{
+			Title: maskedValue(masked, "title", req.Title),
+			Text:  maskedValue(masked, "text", req.Text),
+		})
+	}
+	if err != nil {
+		return empty, resourceError(err, resources{"ad": req.AdId, "user": req.UserId})
 	}
 	return AdSuccessResponse(ad), nil
 }
@@ -104,52 +138,18 @@ func (s GServer) GetAd(ctx context.Context, req *GetADByIDRequest) (*AdResponse,
 	empty := &AdResponse{}
 	ad, err := s.App.GetAdByID(ctx, req.AdId)
 	if err != nil {
-		isNotFound := errors.Is(err, util.ErrNotFound)
-		if isNotFound {
-			return empty, errNotFound
-		}
-		return empty, errUnknown
+		return empty, resourceError(err, resources{"ad": req.AdId})
 	}
 	return AdSuccessResponse(ad), nil
 }
 
 func (s GServer) GetAds(ctx context.Context, filters *AdFilters) (*ListAdResponse, error) {
 	empty := &ListAdResponse{}
-	dateTime := time.Time{}
-
-	cDate := filters.GetOptionalCreateDate()
-	if cDate == nil {
-		formatter := s.App.GetDateTimeFormat()
-		dateTime, _ = formatter.ToTime(dateTime)
-	} else {
-		dateTime = cDate.AsTime().UTC()
-	}
-
-	title := filters.GetOptionalTitle()
-	if title == nil {
-		title = &wrapperspb.StringValue{Value: ""}
-	}
-
-	AuthorId := filters.GetOptionalAuthorId()
-	if AuthorId == nil {
-		AuthorId = &wrapperspb.Int64Value{Value: -1}
-	}
-
-	published := filters.GetOptionalPublished()
-	if published == nil {
-		published = &wrapperspb.BoolValue{Value: true}
-	}
-
-	adFilters := service.AdFilters{
-		CreateDate: dateTime,
-		Title:      title.GetValue(),
-		AuthorID:   AuthorId.GetValue(),
-		Published:  published.GetValue(),
-	}
+	adFilters := s.toServiceFilters(filters)
 
 	ads, err := s.App.GetAdsByFilter(ctx, adFilters)
 	if err != nil {
-		return empty, errUnknown
+		return empty, statusError(err)
 	}
 
 	response := AdListSuccessResponse(&ads)
@@ -160,19 +160,29 @@ func (s GServer) RemoveAd(ctx context.Context, req *DeleteAdRequest) (*DeleteAdR
 	empty := &DeleteAdResponse{}
 	err := s.App.RemoveAd(ctx, req.AdId, req.AuthorId)
 	if err != nil {
-		isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-		if isBadAuthorID {
-			return empty, errForbidden
-		}
+		return empty, resourceError(err, resources{"ad": req.AdId})
 	}
 	return &DeleteAdResponse{AdId: req.AdId, UserId: req.AuthorId}, nil
 }
 
 func (s GServer) ModifyUser(ctx context.Context, req *UserUpdateRequest) (*UserResponse, error) {
 	empty := &UserResponse{}
-	user, err := s.App.UpdateUser(ctx, req.Id, req.Nickname, req.Email)
+	var user *entities.User
+	var err error
+	if req.UpdateMask == nil {
+		user, err = s.App.UpdateUser(ctx, req.Id, req.Nickname, req.Email)
+	} else {
+		masked, maskErr := maskedFields(req.UpdateMask, "nickname", "email")
+		if maskErr != nil {
+			return empty, statusError(maskErr)
+		}
+		user, err = s.App.PatchUser(ctx, req.Id, service.UserPatch{
+			Nickname: maskedValue(masked, "nickname", req.Nickname),
+			Email:    maskedValue(masked, "email", req.Email),
+		})
+	}
 	if err != nil {
-		return empty, errUnknown
+		return empty, resourceError(err, resources{"user": req.Id})
 	}
 	return UserSuccessResponse(user), nil
 }
@@ -181,7 +191,7 @@ func (s GServer) AddUser(ctx context.Context, req *UserRequest) (*UserResponse,
 	empty := &UserResponse{}
 	user, err := s.App.CreateUser(ctx, req.Nickname, req.Email)
 	if err != nil {
-		return empty, errUnknown
+		return empty, statusError(err)
 	}
 	return UserSuccessResponse(user), nil
 }
@@ -190,20 +200,42 @@ func (s GServer) GetUser(ctx context.Context, req *GetUserRequest) (*UserRespons
 	empty := &UserResponse{}
 	user, err := s.App.GetUserByID(ctx, req.Id)
 	if err != nil {
-		isNotFound := errors.Is(err, util.ErrNotFound)
-		if isNotFound {
-			return empty, errNotFound
-		}
-		return empty, errUnknown
+		return empty, resourceError(err, resources{"user": req.Id})
+	}
+	return UserSuccessResponse(user), nil
+}
+
+func (s GServer) GetUserByNickname(ctx context.Context, req *GetUserByNicknameRequest) (*UserResponse, error) {
+	empty := &UserResponse{}
+	user, err := s.App.GetUserByNickname(ctx, req.Nickname)
+	if err != nil {
+		return empty, statusError(err)
 	}
 	return UserSuccessResponse(user), nil
 }
 
 func (s GServer) RemoveUser(ctx context.Context, req *DeleteUserRequest) (*DeleteUserResponse, error) {
-	_ = s.App.RemoveUser(ctx, req.Id)
+	empty := &DeleteUserResponse{}
+	policy, ok := adsPolicies[req.AdsPolicy]
+	if !ok {
+		return empty, statusError(errAdsPolicy)
+	}
+	err := s.App.RemoveUserWithAds(ctx, req.Id, policy, req.NewOwnerId)
+	if err != nil {
+		return empty, resourceError(err, resources{"user": req.Id})
+	}
 	return &DeleteUserResponse{Id: req.Id}, nil
 }
 
+func (s GServer) VerifyUser(ctx context.Context, req *VerifyUserRequest) (*UserResponse, error) {
+	empty := &UserResponse{}
+	user, err := s.App.VerifyUser(ctx, req.Token)
+	if err != nil {
+		return empty, statusError(err)
+	}
+	return UserSuccessResponse(user), nil
+}
+
 func (s GServer) mustEmbedUnimplementedAdServiceServer() {
 }
 
@@ -224,6 +256,7 @@ func UserSuccessResponse(user *entities.User) *UserResponse {
 		Id:       user.ID,
 		Nickname: user.Nickname,
 		Email:    user.Email,
+		Verified: user.Verified,
 	}
 }
 
@@ -245,3 +278,210 @@ func AdListSuccessResponse(ads *[]entities.Ad) ListAdResponse {
 	}
 	return ListAdResponse{List: adsResponse}
 }
+
+func (s GServer) ExportUserData(ctx context.Context, req *ExportUserDataRequest) (*ExportUserDataResponse, error) {
+	empty := &ExportUserDataResponse{}
+	format, ok := exportFormats[req.Format]
+	if !ok {
+		return empty, statusError(errExportFormat)
+	}
+	export, err := s.App.ExportUserData(ctx, req.RequesterId, req.UserId)
+	if err != nil {
+		return empty, resourceError(err, resources{"user": req.UserId})
+	}
+	data, err := export.Encode(format)
+	if err != nil {
+		return empty, statusError(err)
+	}
+	return &ExportUserDataResponse{ContentType: format.ContentType(), Data: data}, nil
+}
+
+func (s GServer) EraseUser(ctx context.Context, req *EraseUserRequest) (*UserResponse, error) {
+	empty := &UserResponse{}
+	user, err := s.App.EraseUser(ctx, req.RequesterId, req.UserId)
+	if err != nil {
+		return empty, resourceError(err, resources{"user": req.UserId})
+	}
+	return UserSuccessResponse(user), nil
+}
+
+func (s GServer) BatchCreateAds(ctx context.Context, req *BatchCreateAdsRequest) (*BatchAdsResponse, error) {
+	ads := make([]service.NewAd, 0, len(req.Items))
+	for _, item := range req.Items {
+		ads = append(ads, service.NewAd{Title: item.Title, Text: item.Text, AuthorID: item.UserId})
+	}
+	results, err := s.App.CreateAds(ctx, ads, req.AllOrNothing)
+	return BatchSuccessResponse(results, err)
+}
+
+func (s GServer) BatchUpdateAdStatus(ctx context.Context, req *BatchUpdateAdStatusRequest) (*BatchAdsResponse, error) {
+	changes := make([]service.AdStatusChange, 0, len(req.Items))
+	for _, item := range req.Items {
+		changes = append(changes, service.AdStatusChange{AdID: item.AdId, AuthorID: item.UserId, Published: item.Published})
+	}
+	results, err := s.App.ChangeAdsStatus(ctx, changes, req.AllOrNothing)
+	return BatchSuccessResponse(results, err)
+}
+
+func (s GServer) BatchDeleteAds(ctx context.Context, req *BatchDeleteAdsRequest) (*BatchAdsResponse, error) {
+	refs := make([]service.AdRef, 0, len(req.Items))
+	for _, item := range req.Items {
+		refs = append(refs, service.AdRef{AdID: item.AdId, AuthorID: item.AuthorId})
+	}
+	results, err := s.App.RemoveAds(ctx, refs, req.AllOrNothing)
+	return BatchSuccessResponse(results, err)
+}
+
+// BatchSuccessResponse прерванный пакет не считается ошибкой вызова: результаты по элементам нужны клиенту
+func BatchSuccessResponse(results []service.BatchResult, err error) (*BatchAdsResponse, error) {
+	empty := &BatchAdsResponse{}
+	if err != nil {
+		if isAborted := errors.Is(err, service.ErrBatchAborted); !isAborted {
+			return empty, statusError(err)
+		}
+	}
+	res := &BatchAdsResponse{Aborted: err != nil}
+	for _, r := range results {
+		item := &BatchAdResult{Code: int32(grpcCode(r.Err))}
+		if r.Ad != nil {
+			item.Ad = AdSuccessResponse(r.Ad)
+		}
+		if r.Err != nil {
+			item.Error = r.Err.Error()
+		}
+		res.Results = append(res.Results, item)
+	}
+	return res, nil
+}
+
+// toServiceFilters незаданные фильтры заменяются значениями по умолчанию, как в REST
+func (s GServer) toServiceFilters(filters *AdFilters) service.AdFilters {
+	dateTime := time.Time{}
+
+	cDate := filters.GetOptionalCreateDate()
+	if cDate == nil {
+		formatter := s.App.GetDateTimeFormat()
+		dateTime, _ = formatter.ToTime(dateTime)
+	} else {
+		dateTime = cDate.AsTime().UTC()
+	}
+
+	title := filters.GetOptionalTitle()
+	if title == nil {
+		title = &wrapperspb.StringValue{Value: ""}
+	}
+
+	AuthorId := filters.GetOptionalAuthorId()
+	if AuthorId == nil {
+		AuthorId = &wrapperspb.Int64Value{Value: -1}
+	}
+
+	published := filters.GetOptionalPublished()
+	if published == nil {
+		published = &wrapperspb.BoolValue{Value: true}
+	}
+
+	adFilters := service.AdFilters{
+		CreateDate: dateTime,
+		Title:      title.GetValue(),
+		AuthorID:   AuthorId.GetValue(),
+		Published:  published.GetValue(),
+	}
+	return adFilters
+}
+
+// ImportAds формат и dry_run задаются первым сообщением потока
+func (s GServer) ImportAds(stream AdService_ImportAdsServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return statusError(errEmptyImport)
+	}
+	format, ok := fileFormats[first.Format]
+	if !ok {
+		return statusError(errFileFormat)
+	}
+	reader := &chunkReader{stream: stream, buf: first.Data}
+	report, err := s.App.ImportAds(stream.Context(), reader, format, first.DryRun)
+	if err != nil {
+		return statusError(err)
+	}
+	return stream.SendAndClose(ImportSuccessResponse(report))
+}
+
+func (s GServer) ExportAds(req *ExportAdsRequest, stream AdService_ExportAdsServer) error {
+	format, ok := fileFormats[req.Format]
+	if !ok {
+		return statusError(errFileFormat)
+	}
+	filters := s.toServiceFilters(req.GetFilters())
+	if err := s.App.ExportAds(stream.Context(), filters, format, &chunkWriter{stream: stream}); err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+func ImportSuccessResponse(report *app.ImportReport) *ImportAdsResponse {
+	res := &ImportAdsResponse{
+		DryRun: report.DryRun,
+		Total:  int32(report.Total),
+		Valid:  int32(report.Valid),
+		Failed: int32(report.Failed),
+	}
+	for _, row := range report.Rows {
+		item := &ImportAdsRow{Line: int32(row.Line)}
+		if row.AdID != nil {
+			item.AdId = wrapperspb.Int64(*row.AdID)
+		}
+		if row.Error != nil {
+			item.Error = *row.Error
+		}
+		res.Rows = append(res.Rows, item)
+	}
+	return res
+}
+
+// StreamAds отправляет объявления по одному, читая их из репозитория страницами через EachAd.
+// Send блокируется, пока клиент не освободит окно HTTP/2, поэтому медленный клиент держит в памяти
+// сервера не больше одной страницы, а не всю выборку
+func (s GServer) StreamAds(filters *AdFilters, stream AdService_StreamAdsServer) error {
+	var sendErr error
+	err := s.App.EachAd(stream.Context(), s.toServiceFilters(filters), func(ad entities.Ad) error {
+		sendErr = stream.Send(AdSuccessResponse(&ad))
+		return sendErr
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	if err != nil {
+		return statusError(err)
+	}
+	return nil
+}
+
+// WatchAds поток завершается, когда клиент отключается. Если клиент не успевает читать события,
+// поток завершается с ResourceExhausted, и клиенту нужно переподключиться
+func (s GServer) WatchAds(filters *AdFilters, stream AdService_WatchAdsServer) error {
+	ctx := stream.Context()
+	ads, events, err := s.App.WatchAds(ctx, s.toServiceFilters(filters))
+	if err != nil {
+		return statusError(err)
+	}
+	for i := range ads {
+		if err = stream.Send(&AdEvent{Type: AdEvent_SNAPSHOT, Ad: AdSuccessResponse(&ads[i])}); err != nil {
+			return err
+		}
+	}
+	if err = stream.Send(&AdEvent{Type: AdEvent_SYNCED}); err != nil {
+		return err
+	}
+	for event := range events {
+		ad := event.Ad
+		if err = stream.Send(&AdEvent{Type: adEventTypes[event.Type], Ad: AdSuccessResponse(&ad)}); err != nil {
+			return err
+		}
+	}
+	if ctx.Err() != nil {
+		return nil
+	}
+	return statusError(errTooSlow)
+}
@@ -2,14 +2,27 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	"github.com/AirstaNs/ValidationAds"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
+	"homework10/internal/adapters/adfile"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/app"
+	"homework10/internal/apperr"
 	"homework10/internal/entities"
 	mocks "homework10/internal/mocks/appemocks"
+	"homework10/internal/quota"
 	"homework10/internal/service"
 	"homework10/internal/util"
+	"homework10/internal/webhook"
+	"io"
 	"strings"
 	"testing"
 	"time"
@@ -50,6 +63,17 @@ type rpcAppSuite struct {
 	serv *GServer
 }
 
+// errorDetail подробность типа T из статуса ошибки err, как ее получит клиент
+func errorDetail[T any](err error) (T, bool) {
+	for _, d := range status.Convert(err).Details() {
+		if detail, ok := d.(T); ok {
+			return detail, true
+		}
+	}
+	var zero T
+	return zero, false
+}
+
 func (s *rpcAppSuite) SetupSuite() {
 	s.app = new(mocks.App)
 	s.serv = &GServer{App: s.app}
@@ -92,7 +116,7 @@ func (s *rpcAppSuite) Test_AddAd_NotFoundUser() {
 	background := context.Background()
 	app.
 		On("GetUserByID", mock.Anything, tAd.AuthorID).
-		Return(emptyUser, errNotFound)
+		Return(emptyUser, userrepo.ErrEmptyUser)
 
 	ad, err := s.serv.AddAd(background, &CreateAdRequest{
 		Title:  tAd.Title,
@@ -122,6 +146,38 @@ func (s *rpcAppSuite) Test_AddAd_BadTitle() {
 	})
 	s.Error(err, ValidationAds.ErrBadTitle)
 	s.Equal(emptyAdResp, ad)
+
+	badRequest, ok := errorDetail[*errdetails.BadRequest](err)
+	s.Require().True(ok)
+	s.Require().Len(badRequest.FieldViolations, 1)
+	s.Equal("title", badRequest.FieldViolations[0].Field)
+}
+
+func (s *rpcAppSuite) Test_AddAd_QuotaExceeded() {
+	app := new(mocks.App)
+	s.serv.App = app
+	app.
+		On("GetUserByID", mock.Anything, tAd.AuthorID).
+		Return(&tUser, nil)
+	app.
+		On("CreateAd", mock.Anything, tAd.Title, tAd.Text, tAd.AuthorID).
+		Return(emptyAd, &quota.ExceededError{Kind: quota.DailyAds, Plan: "free", Limit: 20, RetryAfter: time.Hour})
+
+	_, err := s.serv.AddAd(context.Background(), &CreateAdRequest{
+		Title:  tAd.Title,
+		Text:   tAd.Text,
+		UserId: tAd.AuthorID,
+	})
+	s.Equal(codes.ResourceExhausted, status.Code(err))
+	s.Contains(status.Convert(err).Message(), "daily_ads")
+
+	retry, ok := errorDetail[*errdetails.RetryInfo](err)
+	s.Require().True(ok)
+	s.Equal(time.Hour, retry.RetryDelay.AsDuration())
+	info, ok := errorDetail[*errdetails.ErrorInfo](err)
+	s.Require().True(ok)
+	s.Equal("QUOTA_EXCEEDED", info.Reason)
+	s.Equal(map[string]string{"quota": "daily_ads", "plan": "free", "limit": "20"}, info.Metadata)
 }
 
 func (s *rpcAppSuite) Test_UpdateAdStatus() {
@@ -160,10 +216,10 @@ func (s *rpcAppSuite) TestUpdateAdStatus_BadAdID() {
 
 	nApp.
 		On("GetUserByID", mock.Anything, tAd.AuthorID).
-		Return(emptyUser, errNotFound)
+		Return(emptyUser, userrepo.ErrEmptyUser)
 
 	ad, err := s.serv.UpdateAdStatus(background, cReq)
-	s.Error(err, errNotFound)
+	s.Equal(codes.NotFound, status.Code(err))
 	s.Equal(emptyAdResp, ad)
 }
 
@@ -190,7 +246,7 @@ func (s *rpcAppSuite) Test_UpdateAdStatus_Forbidden() {
 		Return(&tAd, ValidationAds.ErrBadAuthorID)
 
 	ad, err := s.serv.UpdateAdStatus(background, cReq)
-	s.Error(err, errForbidden)
+	s.Equal(codes.PermissionDenied, status.Code(err))
 	s.Equal(emptyAdResp, ad)
 }
 
@@ -247,6 +303,39 @@ func (s *rpcAppSuite) Test_ModifyAd() {
 	s.Equal(AdSuccessResponse(&nAd), ad)
 }
 
+func (s *rpcAppSuite) Test_ModifyAd_UpdateMask() {
+	nAd := tAd
+	nAd.Title = nTitle
+
+	s.app.
+		On("PatchAd", mock.Anything, nAd.ID, nAd.AuthorID, service.AdPatch{Title: &nTitle}).
+		Return(&nAd, nil)
+
+	ad, err := s.serv.ModifyAd(context.Background(), &UpdateAdRequest{
+		AdId:       nAd.ID,
+		UserId:     nAd.AuthorID,
+		Title:      nTitle,
+		Text:       "ignored",
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title"}},
+	})
+	s.NoError(err)
+	s.Equal(AdSuccessResponse(&nAd), ad)
+}
+
+func (s *rpcAppSuite) Test_ModifyAd_BadUpdateMask() {
+	ad, err := s.serv.ModifyAd(context.Background(), &UpdateAdRequest{
+		AdId:       tAd.ID,
+		UserId:     tAd.AuthorID,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title", "author_id"}},
+	})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Equal(emptyAdResp, ad)
+
+	badRequest, ok := errorDetail[*errdetails.BadRequest](err)
+	s.Require().True(ok)
+	s.Equal("update_mask", badRequest.FieldViolations[0].Field)
+}
+
 func (s *rpcAppSuite) Test_ModifyAd_BadAdID() {
 	nApp := new(mocks.App)
 	s.serv.App = nApp
@@ -261,10 +350,10 @@ func (s *rpcAppSuite) Test_ModifyAd_BadAdID() {
 
 	nApp.
 		On("GetUserByID", mock.Anything, tAd.AuthorID).
-		Return(emptyUser, errNotFound)
+		Return(emptyUser, userrepo.ErrEmptyUser)
 
 	ad, err := s.serv.ModifyAd(background, mReq)
-	s.Error(err, errNotFound)
+	s.Equal(codes.NotFound, status.Code(err))
 	s.Equal(emptyAdResp, ad)
 }
 
@@ -292,7 +381,7 @@ func (s *rpcAppSuite) Test_ModifyAd_Forbidden() {
 		Return(&tAd, ValidationAds.ErrBadAuthorID)
 
 	ad, err := s.serv.ModifyAd(background, mReq)
-	s.Error(err, errForbidden)
+	s.Equal(codes.PermissionDenied, status.Code(err))
 	s.Equal(emptyAdResp, ad)
 }
 
@@ -352,11 +441,20 @@ func (s *rpcAppSuite) Test_GetAd_BadAdID() {
 
 	nApp.
 		On("GetAdByID", mock.Anything, gReq.AdId).
-		Return(emptyAd, errNotFound)
+		Return(emptyAd, util.ErrNotFound)
 
 	ad, err := s.serv.GetAd(background, gReq)
-	s.Error(err, errNotFound)
+	s.Equal(codes.NotFound, status.Code(err))
 	s.Equal(emptyAdResp, ad)
+
+	resource, ok := errorDetail[*errdetails.ResourceInfo](err)
+	s.Require().True(ok)
+	s.Equal("ad", resource.ResourceType)
+	s.Equal("-11111", resource.ResourceName)
+	info, ok := errorDetail[*errdetails.ErrorInfo](err)
+	s.Require().True(ok)
+	s.Equal("AD_NOT_FOUND", info.Reason)
+	s.Equal(errorDomain, info.Domain)
 }
 
 func (s *rpcAppSuite) Test_RemoveAd() {
@@ -394,10 +492,43 @@ func (s *rpcAppSuite) Test_RemoveAd_Forbidden() {
 		Return(ValidationAds.ErrBadAuthorID)
 
 	ad, err := s.serv.RemoveAd(background, rReq)
-	s.Error(err, errForbidden)
+	s.Equal(codes.PermissionDenied, status.Code(err))
+	s.Equal(emptyAdRem, ad)
+}
+
+func (s *rpcAppSuite) Test_RemoveAd_NotFound() {
+	app := new(mocks.App)
+	s.serv.App = app
+	rReq := &DeleteAdRequest{AdId: badID, AuthorId: tAd.AuthorID}
+
+	app.
+		On("RemoveAd", mock.Anything, rReq.AdId, rReq.AuthorId).
+		Return(util.ErrNotFound)
+
+	ad, err := s.serv.RemoveAd(context.Background(), rReq)
+	s.Equal(codes.NotFound, status.Code(err))
+	s.Equal("ad not found", status.Convert(err).Message())
 	s.Equal(emptyAdRem, ad)
 }
 
+func (s *rpcAppSuite) Test_statusError() {
+	s.Equal(codes.OK, grpcCode(nil))
+	s.Equal(codes.Internal, status.Code(statusError(errors.New("db is down"))))
+	s.Equal("internal error", status.Convert(statusError(errors.New("db is down"))).Message())
+	for kind, code := range grpcCodes {
+		s.Equal(code, status.Code(statusError(apperr.New(kind, "test", "test"))), kind)
+	}
+
+	info, ok := errorDetail[*errdetails.ErrorInfo](statusError(errors.New("db is down")))
+	s.Require().True(ok)
+	s.Equal("INTERNAL", info.Reason)
+	s.Empty(info.Metadata)
+	_, ok = errorDetail[*errdetails.ResourceInfo](statusError(util.ErrNotFound))
+	s.True(ok)
+	_, ok = errorDetail[*errdetails.RetryInfo](statusError(util.ErrNotFound))
+	s.False(ok)
+}
+
 func (s *rpcAppSuite) Test_GetAds() {
 	background := context.Background()
 	ent := &[]entities.Ad{tAd}
@@ -467,6 +598,77 @@ func (s *rpcAppSuite) Test_AddUser() {
 	s.Equal(UserSuccessResponse(&tUser), user)
 }
 
+func (s *rpcAppSuite) Test_AddUser_BadEmail() {
+	background := context.Background()
+	uReq := &UserRequest{
+		Nickname: tUser.Nickname,
+		Email:    wrongMoreStr,
+	}
+	s.app.
+		On("CreateUser", mock.Anything, tUser.Nickname, wrongMoreStr).
+		Return(emptyUser, service.ErrBadEmail)
+
+	user, err := s.serv.AddUser(background, uReq)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Equal(emptyUserResp, user)
+
+	badRequest, ok := errorDetail[*errdetails.BadRequest](err)
+	s.Require().True(ok)
+	s.Require().Len(badRequest.FieldViolations, 1)
+	s.Equal("email", badRequest.FieldViolations[0].Field)
+	s.Equal(service.ErrBadEmail.Error(), badRequest.FieldViolations[0].Description)
+}
+
+func (s *rpcAppSuite) Test_AddUser_Conflict() {
+	background := context.Background()
+	uReq := &UserRequest{
+		Nickname: tUser.Nickname,
+		Email:    tUser.Email,
+	}
+	s.app.
+		On("CreateUser", mock.Anything, tUser.Nickname, tUser.Email).
+		Return(emptyUser, userrepo.ErrConflict)
+
+	user, err := s.serv.AddUser(background, uReq)
+	s.Equal(codes.AlreadyExists, status.Code(err))
+	s.Equal(emptyUserResp, user)
+}
+
+func (s *rpcAppSuite) Test_GetUserByNickname() {
+	background := context.Background()
+	s.app.
+		On("GetUserByNickname", mock.Anything, tUser.Nickname).
+		Return(&tUser, nil)
+
+	user, err := s.serv.GetUserByNickname(background, &GetUserByNicknameRequest{Nickname: tUser.Nickname})
+	s.NoError(err)
+	s.Equal(UserSuccessResponse(&tUser), user)
+}
+
+func (s *rpcAppSuite) Test_VerifyUser() {
+	background := context.Background()
+	vUser := tUser
+	vUser.Verified = true
+	s.app.
+		On("VerifyUser", mock.Anything, "token").
+		Return(&vUser, nil)
+
+	user, err := s.serv.VerifyUser(background, &VerifyUserRequest{Token: "token"})
+	s.NoError(err)
+	s.True(user.Verified)
+}
+
+func (s *rpcAppSuite) Test_VerifyUser_BadToken() {
+	background := context.Background()
+	s.app.
+		On("VerifyUser", mock.Anything, wrongMoreStr).
+		Return(emptyUser, util.ErrBadToken)
+
+	user, err := s.serv.VerifyUser(background, &VerifyUserRequest{Token: wrongMoreStr})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Equal(emptyUserResp, user)
+}
+
 func (s *rpcAppSuite) Test_GetUser() {
 	background := context.Background()
 	uReq := &GetUserRequest{
@@ -490,10 +692,10 @@ func (s *rpcAppSuite) Test_GetUser_BadID() {
 	}
 	app.
 		On("GetUserByID", mock.Anything, uReq.Id).
-		Return(emptyUser, util.ErrNotFound)
+		Return(emptyUser, userrepo.ErrEmptyUser)
 
 	user, err := s.serv.GetUser(background, uReq)
-	s.Error(err, errNotFound)
+	s.Equal(codes.NotFound, status.Code(err))
 	s.Equal(emptyUserResp, user)
 }
 
@@ -503,7 +705,7 @@ func (s *rpcAppSuite) Test_RemoveUser() {
 		Id: tUser.ID,
 	}
 	s.app.
-		On("RemoveUser", mock.Anything, tUser.ID).
+		On("RemoveUserWithAds", mock.Anything, tUser.ID, app.CascadeAds, int64(0)).
 		Return(nil)
 
 	user, err := s.serv.RemoveUser(background, uReq)
@@ -511,6 +713,32 @@ func (s *rpcAppSuite) Test_RemoveUser() {
 	s.Equal(&DeleteUserResponse{Id: user.Id}, user)
 }
 
+func (s *rpcAppSuite) Test_RemoveUser_TransferBadOwner() {
+	background := context.Background()
+	uReq := &DeleteUserRequest{
+		Id:         tUser.ID,
+		AdsPolicy:  DeleteUserRequest_TRANSFER,
+		NewOwnerId: badID,
+	}
+	s.app.
+		On("RemoveUserWithAds", mock.Anything, tUser.ID, app.TransferAds, badID).
+		Return(app.ErrBadNewOwner)
+
+	_, err := s.serv.RemoveUser(background, uReq)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_RemoveUser_BadPolicy() {
+	background := context.Background()
+	uReq := &DeleteUserRequest{
+		Id:        tUser.ID,
+		AdsPolicy: DeleteUserRequest_AdsPolicy(100),
+	}
+
+	_, err := s.serv.RemoveUser(background, uReq)
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
 func (s *rpcAppSuite) Test_ModifyUser() {
 	background := context.Background()
 	uReq := &UserUpdateRequest{
@@ -527,6 +755,21 @@ func (s *rpcAppSuite) Test_ModifyUser() {
 	s.Equal(UserSuccessResponse(&tUser), user)
 }
 
+func (s *rpcAppSuite) Test_ModifyUser_UpdateMask() {
+	empty := ""
+	s.app.
+		On("PatchUser", mock.Anything, tUser.ID, service.UserPatch{Nickname: &empty, Email: &empty}).
+		Return(&tUser, service.ErrBadNickname)
+
+	// пустые поля из маски не пропускаются, а проверяются
+	user, err := s.serv.ModifyUser(context.Background(), &UserUpdateRequest{
+		Id:         tUser.ID,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"*"}},
+	})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Equal(emptyUserResp, user)
+}
+
 func (s *rpcAppSuite) Test_ModifyUser_BadID() {
 	app := new(mocks.App)
 	s.serv.App = app
@@ -541,6 +784,335 @@ func (s *rpcAppSuite) Test_ModifyUser_BadID() {
 		Return(emptyUser, util.ErrNotFound)
 
 	user, err := s.serv.ModifyUser(background, uReq)
-	s.Error(err, errNotFound)
+	s.Equal(codes.NotFound, status.Code(err))
 	s.Equal(emptyUserResp, user)
 }
+
+func (s *rpcAppSuite) Test_ExportUserData() {
+	background := context.Background()
+	export := &app.UserExport{Profile: tUser, Ads: []entities.Ad{tAd}}
+	s.app.
+		On("ExportUserData", mock.Anything, tUser.ID, tUser.ID).
+		Return(export, nil)
+
+	res, err := s.serv.ExportUserData(background, &ExportUserDataRequest{
+		UserId:      tUser.ID,
+		RequesterId: tUser.ID,
+		Format:      ExportUserDataRequest_ZIP,
+	})
+	s.NoError(err)
+	s.Equal("application/zip", res.ContentType)
+	s.NotEmpty(res.Data)
+}
+
+func (s *rpcAppSuite) Test_ExportUserData_Forbidden() {
+	background := context.Background()
+	s.app.
+		On("ExportUserData", mock.Anything, badID, tUser.ID).
+		Return(&app.UserExport{}, app.ErrForbidden)
+
+	_, err := s.serv.ExportUserData(background, &ExportUserDataRequest{UserId: tUser.ID, RequesterId: badID})
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_EraseUser() {
+	background := context.Background()
+	erased := entities.User{ID: tUser.ID, Nickname: "erased", Email: "erased@erased.invalid"}
+	s.app.
+		On("EraseUser", mock.Anything, tUser.ID, tUser.ID).
+		Return(&erased, nil)
+
+	res, err := s.serv.EraseUser(background, &EraseUserRequest{UserId: tUser.ID, RequesterId: tUser.ID})
+	s.NoError(err)
+	s.Equal(erased.Email, res.Email)
+}
+
+func (s *rpcAppSuite) Test_EraseUser_NotFound() {
+	background := context.Background()
+	s.app.
+		On("EraseUser", mock.Anything, badID, badID).
+		Return(emptyUser, userrepo.ErrEmptyUser)
+
+	_, err := s.serv.EraseUser(background, &EraseUserRequest{UserId: badID, RequesterId: badID})
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_BatchCreateAds() {
+	background := context.Background()
+	ads := []service.NewAd{{Title: tAd.Title, Text: tAd.Text, AuthorID: tUser.ID}}
+	s.app.
+		On("CreateAds", mock.Anything, ads, false).
+		Return([]service.BatchResult{{Ad: &tAd}}, nil)
+
+	res, err := s.serv.BatchCreateAds(background, &BatchCreateAdsRequest{
+		Items: []*CreateAdRequest{{Title: tAd.Title, Text: tAd.Text, UserId: tUser.ID}},
+	})
+	s.NoError(err)
+	s.False(res.Aborted)
+	s.Equal(int32(codes.OK), res.Results[0].Code)
+	s.Equal(tAd.ID, res.Results[0].Ad.Id)
+}
+
+func (s *rpcAppSuite) Test_BatchUpdateAdStatus_Aborted() {
+	background := context.Background()
+	changes := []service.AdStatusChange{{AdID: tAd.ID, AuthorID: badID, Published: true}}
+	s.app.
+		On("ChangeAdsStatus", mock.Anything, changes, true).
+		Return([]service.BatchResult{{Err: ValidationAds.ErrBadAuthorID}}, service.ErrBatchAborted)
+
+	res, err := s.serv.BatchUpdateAdStatus(background, &BatchUpdateAdStatusRequest{
+		Items:        []*ChangeAdStatusRequest{{AdId: tAd.ID, UserId: badID, Published: true}},
+		AllOrNothing: true,
+	})
+	s.NoError(err)
+	s.True(res.Aborted)
+	s.Equal(int32(codes.PermissionDenied), res.Results[0].Code)
+}
+
+func (s *rpcAppSuite) Test_BatchDeleteAds_Empty() {
+	background := context.Background()
+	s.app.
+		On("RemoveAds", mock.Anything, []service.AdRef{}, false).
+		Return(nil, service.ErrBatchEmpty)
+
+	_, err := s.serv.BatchDeleteAds(background, &BatchDeleteAdsRequest{})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+// importStream отдает заранее заданные сообщения и запоминает ответ
+type importStream struct {
+	grpc.ServerStream
+	chunks   []*ImportAdsChunk
+	response *ImportAdsResponse
+}
+
+func (s *importStream) Context() context.Context { return context.Background() }
+
+func (s *importStream) Recv() (*ImportAdsChunk, error) {
+	if len(s.chunks) == 0 {
+		return nil, io.EOF
+	}
+	chunk := s.chunks[0]
+	s.chunks = s.chunks[1:]
+	return chunk, nil
+}
+
+func (s *importStream) SendAndClose(res *ImportAdsResponse) error {
+	s.response = res
+	return nil
+}
+
+type exportStream struct {
+	grpc.ServerStream
+	data []byte
+}
+
+func (s *exportStream) Context() context.Context { return context.Background() }
+
+func (s *exportStream) Send(chunk *ExportAdsChunk) error {
+	s.data = append(s.data, chunk.Data...)
+	return nil
+}
+
+func (s *rpcAppSuite) Test_ImportAds() {
+	adID := tAd.ID
+	report := &app.ImportReport{Total: 1, Valid: 1, Rows: []app.ImportRowReport{{Line: 2, AdID: &adID}}}
+	var file string
+	s.app.
+		On("ImportAds", mock.Anything, mock.Anything, adfile.CSV, false).
+		Run(func(args mock.Arguments) {
+			data, _ := io.ReadAll(args.Get(1).(io.Reader))
+			file = string(data)
+		}).
+		Return(report, nil)
+
+	stream := &importStream{chunks: []*ImportAdsChunk{
+		{Format: FileFormat_FILE_FORMAT_CSV, Data: []byte("title,text,")},
+		{Data: []byte("user_id\nhello,world,0\n")},
+	}}
+	err := s.serv.ImportAds(stream)
+	s.NoError(err)
+	s.Equal("title,text,user_id\nhello,world,0\n", file)
+	s.Equal(int32(1), stream.response.Valid)
+	s.Equal(adID, stream.response.Rows[0].AdId.GetValue())
+}
+
+func (s *rpcAppSuite) Test_ImportAds_Empty() {
+	err := s.serv.ImportAds(&importStream{})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_ExportAds() {
+	s.app.
+		On("GetDateTimeFormat").
+		Return(util.NewDateTimeFormatter(time.DateOnly), nil)
+	s.app.
+		On("ExportAds", mock.Anything, mock.AnythingOfType("service.AdFilters"), adfile.JSONL, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, _ = args.Get(3).(io.Writer).Write([]byte("{}\n"))
+		}).
+		Return(nil)
+
+	stream := &exportStream{}
+	err := s.serv.ExportAds(&ExportAdsRequest{}, stream)
+	s.NoError(err)
+	s.Equal("{}\n", string(stream.data))
+}
+
+type adsStream struct {
+	grpc.ServerStream
+	ads []*AdResponse
+}
+
+func (s *adsStream) Context() context.Context { return context.Background() }
+
+func (s *adsStream) Send(ad *AdResponse) error {
+	s.ads = append(s.ads, ad)
+	return nil
+}
+
+type watchStream struct {
+	grpc.ServerStream
+	ctx    context.Context
+	events []*AdEvent
+}
+
+func (s *watchStream) Context() context.Context { return s.ctx }
+
+func (s *watchStream) Send(event *AdEvent) error {
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *rpcAppSuite) Test_StreamAds() {
+	s.app.
+		On("GetDateTimeFormat").
+		Return(util.NewDateTimeFormatter(time.DateOnly), nil)
+	s.app.
+		On("EachAd", mock.Anything, mock.AnythingOfType("service.AdFilters"), mock.Anything).
+		Return(func(ctx context.Context, filters service.AdFilters, fn func(entities.Ad) error) error {
+			for _, ad := range []entities.Ad{tAd, tAd} {
+				if err := fn(ad); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+
+	stream := &adsStream{}
+	err := s.serv.StreamAds(&AdFilters{}, stream)
+	s.NoError(err)
+	s.Len(stream.ads, 2)
+	s.Equal(AdSuccessResponse(&tAd), stream.ads[0])
+}
+
+func (s *rpcAppSuite) Test_WatchAds() {
+	events := make(chan service.AdEvent, 1)
+	events <- service.AdEvent{Type: service.AdCreated, Ad: tAd}
+	close(events)
+	s.app.
+		On("GetDateTimeFormat").
+		Return(util.NewDateTimeFormatter(time.DateOnly), nil)
+	s.app.
+		On("WatchAds", mock.Anything, mock.AnythingOfType("service.AdFilters")).
+		Return([]entities.Ad{tAd}, (<-chan service.AdEvent)(events), nil)
+
+	// канал закрыт без отмены контекста - подписчик не успевал читать события
+	stream := &watchStream{ctx: context.Background()}
+	err := s.serv.WatchAds(&AdFilters{}, stream)
+	s.Equal(codes.ResourceExhausted, status.Code(err))
+	s.Len(stream.events, 3)
+	s.Equal(AdEvent_SNAPSHOT, stream.events[0].Type)
+	s.Equal(AdEvent_SYNCED, stream.events[1].Type)
+	s.Equal(AdEvent_CREATED, stream.events[2].Type)
+}
+
+func (s *rpcAppSuite) Test_WatchAds_Canceled() {
+	events := make(chan service.AdEvent)
+	close(events)
+	s.app.
+		On("GetDateTimeFormat").
+		Return(util.NewDateTimeFormatter(time.DateOnly), nil)
+	s.app.
+		On("WatchAds", mock.Anything, mock.AnythingOfType("service.AdFilters")).
+		Return([]entities.Ad{}, (<-chan service.AdEvent)(events), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.serv.WatchAds(&AdFilters{}, &watchStream{ctx: ctx})
+	s.NoError(err)
+}
+
+func (s *rpcAppSuite) Test_AddWebhook() {
+	background := context.Background()
+	eventTypes := []string{"ad.created"}
+	s.app.
+		On("CreateWebhook", mock.Anything, tUser.ID, "https://partner.example/hook", "", eventTypes).
+		Return(&webhook.Subscription{ID: 3, URL: "https://partner.example/hook", Secret: "generated secret", Events: eventTypes}, nil)
+	s.app.
+		On("CreateWebhook", mock.Anything, tUser.ID, "ftp://partner.example", "", eventTypes).
+		Return(&webhook.Subscription{}, webhook.ErrBadURL)
+
+	res, err := s.serv.AddWebhook(background, &CreateWebhookRequest{RequesterId: tUser.ID, Url: "https://partner.example/hook", Events: eventTypes})
+	s.NoError(err)
+	s.Equal(int64(3), res.Id)
+	s.Equal("generated secret", res.Secret)
+
+	_, err = s.serv.AddWebhook(background, &CreateWebhookRequest{RequesterId: tUser.ID, Url: "ftp://partner.example", Events: eventTypes})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_ListWebhooks() {
+	background := context.Background()
+	s.app.
+		On("Webhooks", mock.Anything, tUser.ID).
+		Return([]webhook.Subscription{{ID: 3, Secret: "hidden secret"}}, nil)
+	s.app.
+		On("Webhooks", mock.Anything, badID).
+		Return(nil, app.ErrForbidden)
+
+	res, err := s.serv.ListWebhooks(background, &ListWebhooksRequest{RequesterId: tUser.ID})
+	s.NoError(err)
+	s.Len(res.List, 1)
+	s.Empty(res.List[0].Secret)
+
+	_, err = s.serv.ListWebhooks(background, &ListWebhooksRequest{RequesterId: badID})
+	s.Equal(codes.PermissionDenied, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_RemoveWebhook() {
+	background := context.Background()
+	s.app.
+		On("DeleteWebhook", mock.Anything, tUser.ID, int64(3)).
+		Return(nil)
+	s.app.
+		On("DeleteWebhook", mock.Anything, tUser.ID, int64(4)).
+		Return(webhook.ErrNotFound)
+
+	res, err := s.serv.RemoveWebhook(background, &WebhookRequest{RequesterId: tUser.ID, WebhookId: 3})
+	s.NoError(err)
+	s.Equal(int64(3), res.Id)
+
+	_, err = s.serv.RemoveWebhook(background, &WebhookRequest{RequesterId: tUser.ID, WebhookId: 4})
+	s.Equal(codes.NotFound, status.Code(err))
+}
+
+func (s *rpcAppSuite) Test_ListWebhookDeliveries() {
+	background := context.Background()
+	s.app.
+		On("WebhookDeliveries", mock.Anything, tUser.ID, int64(3)).
+		Return([]webhook.Delivery{{EventID: "abc", Attempt: 2, StatusCode: 500}}, nil)
+	s.app.
+		On("WebhookDeadLetters", mock.Anything, tUser.ID, int64(3)).
+		Return([]webhook.DeadLetter{{EventID: "abc", Attempts: 10, Payload: []byte(`{}`)}}, nil)
+
+	deliveries, err := s.serv.ListWebhookDeliveries(background, &WebhookRequest{RequesterId: tUser.ID, WebhookId: 3})
+	s.NoError(err)
+	s.Equal(int32(2), deliveries.List[0].Attempt)
+	s.Equal(int32(500), deliveries.List[0].StatusCode)
+
+	letters, err := s.serv.ListWebhookDeadLetters(background, &WebhookRequest{RequesterId: tUser.ID, WebhookId: 3})
+	s.NoError(err)
+	s.Equal(int32(10), letters.List[0].Attempts)
+	s.Equal([]byte(`{}`), letters.List[0].Payload)
+}
@@ -0,0 +1,101 @@
+package grpc
+
+import (
+	"context"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"log"
+	"time"
+)
+
+// DefaultHealthInterval как часто по умолчанию выполняются проверки здоровья
+const DefaultHealthInterval = 5 * time.Second
+
+// HealthCheck проверяет зависимость сервера, ошибка переводит его в NOT_SERVING
+type HealthCheck func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check HealthCheck
+}
+
+// WithHealthCheck добавляет проверку name к состоянию grpc.health.v1
+func WithHealthCheck(name string, check HealthCheck) Option {
+	return func(o *options) {
+		o.healthChecks = append(o.healthChecks, namedCheck{name: name, check: check})
+	}
+}
+
+// WithHealthInterval как часто выполнять проверки, по умолчанию DefaultHealthInterval
+func WithHealthInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.healthInterval = interval
+	}
+}
+
+// healthChecker выставляет одно состояние всему серверу ("") и AdService по результатам проверок
+type healthChecker struct {
+	server   *health.Server
+	checks   []namedCheck
+	interval time.Duration
+	log      *log.Logger
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	// failed проверки, которые не прошли в прошлый раз, чтобы писать в лог только изменения
+	failed map[string]bool
+}
+
+func newHealthChecker(logger *log.Logger, checks []namedCheck, interval time.Duration) *healthChecker {
+	h := &healthChecker{server: health.NewServer(), checks: checks, interval: interval, log: logger,
+		failed: make(map[string]bool)}
+	h.ctx, h.cancel = context.WithCancel(context.Background())
+	// до первой проверки сервер не готов
+	h.set(healthpb.HealthCheckResponse_NOT_SERVING)
+	return h
+}
+
+func (h *healthChecker) set(status healthpb.HealthCheckResponse_ServingStatus) {
+	h.server.SetServingStatus("", status)
+	h.server.SetServingStatus(AdService_ServiceDesc.ServiceName, status)
+}
+
+// run выполняет проверки сразу и затем каждые interval до shutdown
+func (h *healthChecker) run() {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		h.update(h.ctx)
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update выполняет все проверки, каждой дается не больше interval
+func (h *healthChecker) update(ctx context.Context) {
+	status := healthpb.HealthCheckResponse_SERVING
+	for _, c := range h.checks {
+		checkCtx, cancel := context.WithTimeout(ctx, h.interval)
+		err := c.check(checkCtx)
+		cancel()
+		if err != nil {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+			if !h.failed[c.name] {
+				h.log.Printf("health check %q failed: %v", c.name, err)
+			}
+		} else if h.failed[c.name] {
+			h.log.Printf("health check %q recovered", c.name)
+		}
+		h.failed[c.name] = err != nil
+	}
+	h.set(status)
+}
+
+// shutdown переводит сервер в NOT_SERVING насовсем и останавливает проверки
+func (h *healthChecker) shutdown() {
+	h.server.Shutdown()
+	h.cancel()
+}
@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"io"
+	"log"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errUnreachable = errors.New("repository is unreachable")
+
+// startServer запускает NewServer на свободном порту и возвращает соединение с ним
+func startServer(t *testing.T, opts ...Option) (*grpcServer, *grpc.ClientConn) {
+	t.Helper()
+	s := NewServer(log.New(io.Discard, "", 0), nil, opts...).(*grpcServer)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go func() {
+		_ = s.serve(lis)
+	}()
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return s, conn
+}
+
+func servingStatus(client healthpb.HealthClient, service string) healthpb.HealthCheckResponse_ServingStatus {
+	resp, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		return healthpb.HealthCheckResponse_UNKNOWN
+	}
+	return resp.Status
+}
+
+func TestHealth_Checks(t *testing.T) {
+	var down atomic.Bool
+	s, conn := startServer(t, WithHealthInterval(time.Millisecond),
+		WithHealthCheck("repository", func(ctx context.Context) error {
+			if down.Load() {
+				return errUnreachable
+			}
+			return nil
+		}))
+	defer s.Stop()
+	client := healthpb.NewHealthClient(conn)
+
+	for _, service := range []string{"", "ad.AdService"} {
+		assert.Eventually(t, func() bool {
+			return servingStatus(client, service) == healthpb.HealthCheckResponse_SERVING
+		}, time.Second, time.Millisecond)
+	}
+
+	down.Store(true)
+	assert.Eventually(t, func() bool {
+		return servingStatus(client, "ad.AdService") == healthpb.HealthCheckResponse_NOT_SERVING
+	}, time.Second, time.Millisecond)
+
+	down.Store(false)
+	assert.Eventually(t, func() bool {
+		return servingStatus(client, "") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond)
+}
+
+func TestHealth_NotServingOnStop(t *testing.T) {
+	s, conn := startServer(t, WithHealthInterval(time.Millisecond))
+	client := healthpb.NewHealthClient(conn)
+	assert.Eventually(t, func() bool {
+		return servingStatus(client, "") == healthpb.HealthCheckResponse_SERVING
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watch, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	assert.NoError(t, err)
+	resp, err := watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.Status)
+
+	// Stop ждет текущие вызовы, но NOT_SERVING клиенты получают сразу
+	stopped := make(chan error)
+	go func() {
+		stopped <- s.Stop()
+	}()
+	resp, err = watch.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, resp.Status)
+	cancel()
+	assert.NoError(t, <-stopped)
+}
+
+func TestReflection_ListServices(t *testing.T) {
+	s, conn := startServer(t)
+	defer s.Stop()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	assert.NoError(t, err)
+	assert.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	assert.NoError(t, err)
+	_ = stream.CloseSend()
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.Name)
+	}
+	assert.Subset(t, services, []string{"ad.AdService", "grpc.health.v1.Health", "grpc.channelz.v1.Channelz",
+		"grpc.reflection.v1.ServerReflection"})
+}
@@ -3,14 +3,21 @@ package grpc
 import (
 	"fmt"
 	"google.golang.org/grpc"
+	channelz "google.golang.org/grpc/channelz/service"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
 	"homework10/internal/app"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
 	"log"
 	"net"
+	"time"
 )
 
 type grpcServer struct {
 	gServer *grpc.Server
 	log     *log.Logger
+	health  *healthChecker
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.25.0 --name=Server --filename=mocServer.go --output ../../mocks/servermock
@@ -19,18 +26,67 @@ type Server interface {
 	Stop() error
 }
 
-func NewServer(loggerRPC *log.Logger, newApp app.App) Server {
+type Option func(*options)
+
+type options struct {
+	idempotency idempotency.Store
+	limiter     ratelimit.Limiter
+	rateLimits  ratelimit.Rules
+	// healthChecks без проверок сервер в SERVING, пока не остановлен
+	healthChecks   []namedCheck
+	healthInterval time.Duration
+}
+
+// WithIdempotencyStore задает хранилище ответов для idempotency-key, по умолчанию ответы хранятся сутки
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(o *options) {
+		o.idempotency = store
+	}
+}
+
+// WithRateLimits задает лимиты вызовов по полным именам методов, по умолчанию DefaultRateLimits
+func WithRateLimits(limiter ratelimit.Limiter, rules ratelimit.Rules) Option {
+	return func(o *options) {
+		o.limiter = limiter
+		o.rateLimits = rules
+	}
+}
+
+// idempotentMethods вызовы, которые можно безопасно повторить с idempotency-key
+var idempotentMethods = []string{"/ad.AdService/AddAd", "/ad.AdService/AddUser"}
+
+func NewServer(loggerRPC *log.Logger, newApp app.App, opts ...Option) Server {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.idempotency == nil {
+		o.idempotency = idempotency.NewStore()
+	}
+	if o.limiter == nil {
+		o.limiter, o.rateLimits = ratelimit.NewLimiter(), DefaultRateLimits
+	}
+	if o.healthInterval <= 0 {
+		o.healthInterval = DefaultHealthInterval
+	}
 
 	loggerInterceptor := LoggerInterceptor(loggerRPC)
 	recoveryInterceptor := RecoveryInterceptor(loggerRPC)
 
 	server := grpc.NewServer(
 		grpc.Creds(nil),
-		grpc.ChainUnaryInterceptor(loggerInterceptor, recoveryInterceptor),
+		grpc.ChainUnaryInterceptor(loggerInterceptor, recoveryInterceptor, AuditInterceptor(),
+			RateLimitInterceptor(o.limiter, o.rateLimits), IdempotencyInterceptor(o.idempotency, idempotentMethods...)),
+		grpc.ChainStreamInterceptor(AuditStreamInterceptor(), RateLimitStreamInterceptor(o.limiter, o.rateLimits)),
 	)
 	RegisterAdServiceServer(server, GServer{App: newApp})
+	// grpc.health.v1 для балансировщиков, reflection и channelz для grpcurl и отладки
+	checker := newHealthChecker(loggerRPC, o.healthChecks, o.healthInterval)
+	healthpb.RegisterHealthServer(server, checker.server)
+	reflection.Register(server)
+	channelz.RegisterChannelzServiceToServer(server)
 
-	return &grpcServer{gServer: server, log: loggerRPC}
+	return &grpcServer{gServer: server, log: loggerRPC, health: checker}
 }
 
 func (s *grpcServer) Start(network, address string) error {
@@ -42,6 +98,11 @@ func (s *grpcServer) Start(network, address string) error {
 		return fmt.Errorf(msg)
 	}
 	s.log.Printf("starting gRPC server on %s", lis.Addr())
+	return s.serve(lis)
+}
+
+func (s *grpcServer) serve(lis net.Listener) error {
+	go s.health.run()
 	if err1 := s.gServer.Serve(lis); err1 != nil && err1 != grpc.ErrServerStopped {
 		s.log.Fatalf("failed to start gRPC server: %v", err1)
 		return err1
@@ -49,8 +110,11 @@ func (s *grpcServer) Start(network, address string) error {
 	return nil
 }
 
+// Stop сначала переводит health в NOT_SERVING, чтобы балансировщики перестали слать запросы,
+// потом дожидается текущих вызовов
 func (s *grpcServer) Stop() error {
 	if s.gServer != nil {
+		s.health.shutdown()
 		s.gServer.GracefulStop()
 	} else {
 		msg := "gServer is nil"
@@ -32,6 +32,22 @@ type AdServiceClient interface {
 	AddUser(ctx context.Context, in *UserRequest, opts ...grpc.CallOption) (*UserResponse, error)
 	GetUser(ctx context.Context, in *GetUserRequest, opts ...grpc.CallOption) (*UserResponse, error)
 	RemoveUser(ctx context.Context, in *DeleteUserRequest, opts ...grpc.CallOption) (*DeleteUserResponse, error)
+	VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	GetUserByNickname(ctx context.Context, in *GetUserByNicknameRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (*ExportUserDataResponse, error)
+	EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*UserResponse, error)
+	BatchCreateAds(ctx context.Context, in *BatchCreateAdsRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error)
+	BatchUpdateAdStatus(ctx context.Context, in *BatchUpdateAdStatusRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error)
+	BatchDeleteAds(ctx context.Context, in *BatchDeleteAdsRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error)
+	ImportAds(ctx context.Context, opts ...grpc.CallOption) (AdService_ImportAdsClient, error)
+	ExportAds(ctx context.Context, in *ExportAdsRequest, opts ...grpc.CallOption) (AdService_ExportAdsClient, error)
+	StreamAds(ctx context.Context, in *AdFilters, opts ...grpc.CallOption) (AdService_StreamAdsClient, error)
+	WatchAds(ctx context.Context, in *AdFilters, opts ...grpc.CallOption) (AdService_WatchAdsClient, error)
+	AddWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*WebhookResponse, error)
+	ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error)
+	RemoveWebhook(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error)
+	ListWebhookDeliveries(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error)
+	ListWebhookDeadLetters(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*ListWebhookDeadLettersResponse, error)
 }
 
 type adServiceClient struct {
@@ -132,6 +148,244 @@ func (c *adServiceClient) RemoveUser(ctx context.Context, in *DeleteUserRequest,
 	return out, nil
 }
 
+func (c *adServiceClient) VerifyUser(ctx context.Context, in *VerifyUserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/VerifyUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) GetUserByNickname(ctx context.Context, in *GetUserByNicknameRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/GetUserByNickname", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) ExportUserData(ctx context.Context, in *ExportUserDataRequest, opts ...grpc.CallOption) (*ExportUserDataResponse, error) {
+	out := new(ExportUserDataResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/ExportUserData", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) EraseUser(ctx context.Context, in *EraseUserRequest, opts ...grpc.CallOption) (*UserResponse, error) {
+	out := new(UserResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/EraseUser", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) BatchCreateAds(ctx context.Context, in *BatchCreateAdsRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error) {
+	out := new(BatchAdsResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/BatchCreateAds", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) BatchUpdateAdStatus(ctx context.Context, in *BatchUpdateAdStatusRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error) {
+	out := new(BatchAdsResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/BatchUpdateAdStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) BatchDeleteAds(ctx context.Context, in *BatchDeleteAdsRequest, opts ...grpc.CallOption) (*BatchAdsResponse, error) {
+	out := new(BatchAdsResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/BatchDeleteAds", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) ImportAds(ctx context.Context, opts ...grpc.CallOption) (AdService_ImportAdsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdService_ServiceDesc.Streams[0], "/ad.AdService/ImportAds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adServiceImportAdsClient{stream}
+	return x, nil
+}
+
+type AdService_ImportAdsClient interface {
+	Send(*ImportAdsChunk) error
+	CloseAndRecv() (*ImportAdsResponse, error)
+	grpc.ClientStream
+}
+
+type adServiceImportAdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adServiceImportAdsClient) Send(m *ImportAdsChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *adServiceImportAdsClient) CloseAndRecv() (*ImportAdsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ImportAdsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adServiceClient) ExportAds(ctx context.Context, in *ExportAdsRequest, opts ...grpc.CallOption) (AdService_ExportAdsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdService_ServiceDesc.Streams[1], "/ad.AdService/ExportAds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adServiceExportAdsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdService_ExportAdsClient interface {
+	Recv() (*ExportAdsChunk, error)
+	grpc.ClientStream
+}
+
+type adServiceExportAdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adServiceExportAdsClient) Recv() (*ExportAdsChunk, error) {
+	m := new(ExportAdsChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adServiceClient) StreamAds(ctx context.Context, in *AdFilters, opts ...grpc.CallOption) (AdService_StreamAdsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdService_ServiceDesc.Streams[2], "/ad.AdService/StreamAds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adServiceStreamAdsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdService_StreamAdsClient interface {
+	Recv() (*AdResponse, error)
+	grpc.ClientStream
+}
+
+type adServiceStreamAdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adServiceStreamAdsClient) Recv() (*AdResponse, error) {
+	m := new(AdResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adServiceClient) WatchAds(ctx context.Context, in *AdFilters, opts ...grpc.CallOption) (AdService_WatchAdsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &AdService_ServiceDesc.Streams[3], "/ad.AdService/WatchAds", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &adServiceWatchAdsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type AdService_WatchAdsClient interface {
+	Recv() (*AdEvent, error)
+	grpc.ClientStream
+}
+
+type adServiceWatchAdsClient struct {
+	grpc.ClientStream
+}
+
+func (x *adServiceWatchAdsClient) Recv() (*AdEvent, error) {
+	m := new(AdEvent)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *adServiceClient) AddWebhook(ctx context.Context, in *CreateWebhookRequest, opts ...grpc.CallOption) (*WebhookResponse, error) {
+	out := new(WebhookResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/AddWebhook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) ListWebhooks(ctx context.Context, in *ListWebhooksRequest, opts ...grpc.CallOption) (*ListWebhooksResponse, error) {
+	out := new(ListWebhooksResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/ListWebhooks", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) RemoveWebhook(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*DeleteWebhookResponse, error) {
+	out := new(DeleteWebhookResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/RemoveWebhook", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) ListWebhookDeliveries(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*ListWebhookDeliveriesResponse, error) {
+	out := new(ListWebhookDeliveriesResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/ListWebhookDeliveries", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adServiceClient) ListWebhookDeadLetters(ctx context.Context, in *WebhookRequest, opts ...grpc.CallOption) (*ListWebhookDeadLettersResponse, error) {
+	out := new(ListWebhookDeadLettersResponse)
+	err := c.cc.Invoke(ctx, "/ad.AdService/ListWebhookDeadLetters", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AdServiceServer is the server API for AdService service.
 // All implementations must embed UnimplementedAdServiceServer
 // for forward compatibility
@@ -146,6 +400,22 @@ type AdServiceServer interface {
 	AddUser(context.Context, *UserRequest) (*UserResponse, error)
 	GetUser(context.Context, *GetUserRequest) (*UserResponse, error)
 	RemoveUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error)
+	VerifyUser(context.Context, *VerifyUserRequest) (*UserResponse, error)
+	GetUserByNickname(context.Context, *GetUserByNicknameRequest) (*UserResponse, error)
+	ExportUserData(context.Context, *ExportUserDataRequest) (*ExportUserDataResponse, error)
+	EraseUser(context.Context, *EraseUserRequest) (*UserResponse, error)
+	BatchCreateAds(context.Context, *BatchCreateAdsRequest) (*BatchAdsResponse, error)
+	BatchUpdateAdStatus(context.Context, *BatchUpdateAdStatusRequest) (*BatchAdsResponse, error)
+	BatchDeleteAds(context.Context, *BatchDeleteAdsRequest) (*BatchAdsResponse, error)
+	ImportAds(AdService_ImportAdsServer) error
+	ExportAds(*ExportAdsRequest, AdService_ExportAdsServer) error
+	StreamAds(*AdFilters, AdService_StreamAdsServer) error
+	WatchAds(*AdFilters, AdService_WatchAdsServer) error
+	AddWebhook(context.Context, *CreateWebhookRequest) (*WebhookResponse, error)
+	ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error)
+	RemoveWebhook(context.Context, *WebhookRequest) (*DeleteWebhookResponse, error)
+	ListWebhookDeliveries(context.Context, *WebhookRequest) (*ListWebhookDeliveriesResponse, error)
+	ListWebhookDeadLetters(context.Context, *WebhookRequest) (*ListWebhookDeadLettersResponse, error)
 	mustEmbedUnimplementedAdServiceServer()
 }
 
@@ -183,6 +453,54 @@ func (UnimplementedAdServiceServer) GetUser(context.Context, *GetUserRequest) (*
 func (UnimplementedAdServiceServer) RemoveUser(context.Context, *DeleteUserRequest) (*DeleteUserResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveUser not implemented")
 }
+func (UnimplementedAdServiceServer) VerifyUser(context.Context, *VerifyUserRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VerifyUser not implemented")
+}
+func (UnimplementedAdServiceServer) GetUserByNickname(context.Context, *GetUserByNicknameRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetUserByNickname not implemented")
+}
+func (UnimplementedAdServiceServer) ExportUserData(context.Context, *ExportUserDataRequest) (*ExportUserDataResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ExportUserData not implemented")
+}
+func (UnimplementedAdServiceServer) EraseUser(context.Context, *EraseUserRequest) (*UserResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EraseUser not implemented")
+}
+func (UnimplementedAdServiceServer) BatchCreateAds(context.Context, *BatchCreateAdsRequest) (*BatchAdsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchCreateAds not implemented")
+}
+func (UnimplementedAdServiceServer) BatchUpdateAdStatus(context.Context, *BatchUpdateAdStatusRequest) (*BatchAdsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchUpdateAdStatus not implemented")
+}
+func (UnimplementedAdServiceServer) BatchDeleteAds(context.Context, *BatchDeleteAdsRequest) (*BatchAdsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchDeleteAds not implemented")
+}
+func (UnimplementedAdServiceServer) ImportAds(AdService_ImportAdsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportAds not implemented")
+}
+func (UnimplementedAdServiceServer) ExportAds(*ExportAdsRequest, AdService_ExportAdsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ExportAds not implemented")
+}
+func (UnimplementedAdServiceServer) StreamAds(*AdFilters, AdService_StreamAdsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamAds not implemented")
+}
+func (UnimplementedAdServiceServer) WatchAds(*AdFilters, AdService_WatchAdsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchAds not implemented")
+}
+func (UnimplementedAdServiceServer) AddWebhook(context.Context, *CreateWebhookRequest) (*WebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddWebhook not implemented")
+}
+func (UnimplementedAdServiceServer) ListWebhooks(context.Context, *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhooks not implemented")
+}
+func (UnimplementedAdServiceServer) RemoveWebhook(context.Context, *WebhookRequest) (*DeleteWebhookResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveWebhook not implemented")
+}
+func (UnimplementedAdServiceServer) ListWebhookDeliveries(context.Context, *WebhookRequest) (*ListWebhookDeliveriesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeliveries not implemented")
+}
+func (UnimplementedAdServiceServer) ListWebhookDeadLetters(context.Context, *WebhookRequest) (*ListWebhookDeadLettersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWebhookDeadLetters not implemented")
+}
 func (UnimplementedAdServiceServer) mustEmbedUnimplementedAdServiceServer() {}
 
 // UnsafeAdServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -376,6 +694,311 @@ func _AdService_RemoveUser_Handler(srv interface{}, ctx context.Context, dec fun
 	return interceptor(ctx, in, info, handler)
 }
 
+func _AdService_VerifyUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VerifyUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).VerifyUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/VerifyUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).VerifyUser(ctx, req.(*VerifyUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_GetUserByNickname_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetUserByNicknameRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).GetUserByNickname(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/GetUserByNickname",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).GetUserByNickname(ctx, req.(*GetUserByNicknameRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_ExportUserData_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExportUserDataRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).ExportUserData(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/ExportUserData",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).ExportUserData(ctx, req.(*ExportUserDataRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_EraseUser_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EraseUserRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).EraseUser(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/EraseUser",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).EraseUser(ctx, req.(*EraseUserRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_BatchCreateAds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchCreateAdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).BatchCreateAds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/BatchCreateAds",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).BatchCreateAds(ctx, req.(*BatchCreateAdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_BatchUpdateAdStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchUpdateAdStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).BatchUpdateAdStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/BatchUpdateAdStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).BatchUpdateAdStatus(ctx, req.(*BatchUpdateAdStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_BatchDeleteAds_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchDeleteAdsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).BatchDeleteAds(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/BatchDeleteAds",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).BatchDeleteAds(ctx, req.(*BatchDeleteAdsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_ImportAds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(AdServiceServer).ImportAds(&adServiceImportAdsServer{stream})
+}
+
+type AdService_ImportAdsServer interface {
+	SendAndClose(*ImportAdsResponse) error
+	Recv() (*ImportAdsChunk, error)
+	grpc.ServerStream
+}
+
+type adServiceImportAdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adServiceImportAdsServer) SendAndClose(m *ImportAdsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *adServiceImportAdsServer) Recv() (*ImportAdsChunk, error) {
+	m := new(ImportAdsChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _AdService_ExportAds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ExportAdsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdServiceServer).ExportAds(m, &adServiceExportAdsServer{stream})
+}
+
+type AdService_ExportAdsServer interface {
+	Send(*ExportAdsChunk) error
+	grpc.ServerStream
+}
+
+type adServiceExportAdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adServiceExportAdsServer) Send(m *ExportAdsChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AdService_StreamAds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AdFilters)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdServiceServer).StreamAds(m, &adServiceStreamAdsServer{stream})
+}
+
+type AdService_StreamAdsServer interface {
+	Send(*AdResponse) error
+	grpc.ServerStream
+}
+
+type adServiceStreamAdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adServiceStreamAdsServer) Send(m *AdResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AdService_WatchAds_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AdFilters)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AdServiceServer).WatchAds(m, &adServiceWatchAdsServer{stream})
+}
+
+type AdService_WatchAdsServer interface {
+	Send(*AdEvent) error
+	grpc.ServerStream
+}
+
+type adServiceWatchAdsServer struct {
+	grpc.ServerStream
+}
+
+func (x *adServiceWatchAdsServer) Send(m *AdEvent) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _AdService_AddWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateWebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).AddWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/AddWebhook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).AddWebhook(ctx, req.(*CreateWebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_ListWebhooks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWebhooksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).ListWebhooks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/ListWebhooks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).ListWebhooks(ctx, req.(*ListWebhooksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_RemoveWebhook_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).RemoveWebhook(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/RemoveWebhook",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).RemoveWebhook(ctx, req.(*WebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_ListWebhookDeliveries_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).ListWebhookDeliveries(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/ListWebhookDeliveries",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).ListWebhookDeliveries(ctx, req.(*WebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdService_ListWebhookDeadLetters_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WebhookRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdServiceServer).ListWebhookDeadLetters(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/ad.AdService/ListWebhookDeadLetters",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdServiceServer).ListWebhookDeadLetters(ctx, req.(*WebhookRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // AdService_ServiceDesc is the grpc.ServiceDesc for AdService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -423,7 +1046,76 @@ var AdService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "RemoveUser",
 			Handler:    _AdService_RemoveUser_Handler,
 		},
+		{
+			MethodName: "VerifyUser",
+			Handler:    _AdService_VerifyUser_Handler,
+		},
+		{
+			MethodName: "GetUserByNickname",
+			Handler:    _AdService_GetUserByNickname_Handler,
+		},
+		{
+			MethodName: "ExportUserData",
+			Handler:    _AdService_ExportUserData_Handler,
+		},
+		{
+			MethodName: "EraseUser",
+			Handler:    _AdService_EraseUser_Handler,
+		},
+		{
+			MethodName: "BatchCreateAds",
+			Handler:    _AdService_BatchCreateAds_Handler,
+		},
+		{
+			MethodName: "BatchUpdateAdStatus",
+			Handler:    _AdService_BatchUpdateAdStatus_Handler,
+		},
+		{
+			MethodName: "BatchDeleteAds",
+			Handler:    _AdService_BatchDeleteAds_Handler,
+		},
+		{
+			MethodName: "AddWebhook",
+			Handler:    _AdService_AddWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhooks",
+			Handler:    _AdService_ListWebhooks_Handler,
+		},
+		{
+			MethodName: "RemoveWebhook",
+			Handler:    _AdService_RemoveWebhook_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeliveries",
+			Handler:    _AdService_ListWebhookDeliveries_Handler,
+		},
+		{
+			MethodName: "ListWebhookDeadLetters",
+			Handler:    _AdService_ListWebhookDeadLetters_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ImportAds",
+			Handler:       _AdService_ImportAds_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ExportAds",
+			Handler:       _AdService_ExportAds_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "StreamAds",
+			Handler:       _AdService_StreamAds_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "WatchAds",
+			Handler:       _AdService_WatchAds_Handler,
+			ServerStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "internal/ports/grpc/service.proto",
 }
@@ -0,0 +1,41 @@
+package grpc
+
+import (
+	"io"
+)
+
+// chunkReader склеивает data из сообщений ImportAdsChunk в один поток байт
+type chunkReader struct {
+	stream AdService_ImportAdsServer
+	buf    []byte
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		chunk, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = chunk.Data
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// chunkWriter отправляет каждый Write отдельным сообщением ExportAdsChunk
+type chunkWriter struct {
+	stream AdService_ExportAdsServer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := w.stream.Send(&ExportAdsChunk{Data: data}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+var _ io.Reader = (*chunkReader)(nil)
+var _ io.Writer = (*chunkWriter)(nil)
@@ -0,0 +1,98 @@
+package grpc
+
+import (
+	"context"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"homework10/internal/webhook"
+)
+
+func (s GServer) AddWebhook(ctx context.Context, req *CreateWebhookRequest) (*WebhookResponse, error) {
+	empty := &WebhookResponse{}
+	sub, err := s.App.CreateWebhook(ctx, req.RequesterId, req.Url, req.Secret, req.Events)
+	if err != nil {
+		return empty, statusError(err)
+	}
+	resp := WebhookSuccessResponse(sub)
+	resp.Secret = sub.Secret
+	return resp, nil
+}
+
+func (s GServer) ListWebhooks(ctx context.Context, req *ListWebhooksRequest) (*ListWebhooksResponse, error) {
+	subs, err := s.App.Webhooks(ctx, req.RequesterId)
+	if err != nil {
+		return &ListWebhooksResponse{}, statusError(err)
+	}
+	return WebhookListSuccessResponse(subs), nil
+}
+
+func (s GServer) RemoveWebhook(ctx context.Context, req *WebhookRequest) (*DeleteWebhookResponse, error) {
+	if err := s.App.DeleteWebhook(ctx, req.RequesterId, req.WebhookId); err != nil {
+		return &DeleteWebhookResponse{}, resourceError(err, resources{"webhook": req.WebhookId})
+	}
+	return &DeleteWebhookResponse{Id: req.WebhookId}, nil
+}
+
+func (s GServer) ListWebhookDeliveries(ctx context.Context, req *WebhookRequest) (*ListWebhookDeliveriesResponse, error) {
+	deliveries, err := s.App.WebhookDeliveries(ctx, req.RequesterId, req.WebhookId)
+	if err != nil {
+		return &ListWebhookDeliveriesResponse{}, resourceError(err, resources{"webhook": req.WebhookId})
+	}
+	return WebhookDeliveriesSuccessResponse(deliveries), nil
+}
+
+func (s GServer) ListWebhookDeadLetters(ctx context.Context, req *WebhookRequest) (*ListWebhookDeadLettersResponse, error) {
+	letters, err := s.App.WebhookDeadLetters(ctx, req.RequesterId, req.WebhookId)
+	if err != nil {
+		return &ListWebhookDeadLettersResponse{}, resourceError(err, resources{"webhook": req.WebhookId})
+	}
+	return WebhookDeadLettersSuccessResponse(letters), nil
+}
+
+// WebhookSuccessResponse подписка без секрета
+func WebhookSuccessResponse(sub *webhook.Subscription) *WebhookResponse {
+	return &WebhookResponse{
+		Id:        sub.ID,
+		Url:       sub.URL,
+		Events:    sub.Events,
+		CreatedAt: timestamppb.New(sub.CreatedAt),
+	}
+}
+
+func WebhookListSuccessResponse(subs []webhook.Subscription) *ListWebhooksResponse {
+	list := make([]*WebhookResponse, 0, len(subs))
+	for i := range subs {
+		list = append(list, WebhookSuccessResponse(&subs[i]))
+	}
+	return &ListWebhooksResponse{List: list}
+}
+
+func WebhookDeliveriesSuccessResponse(deliveries []webhook.Delivery) *ListWebhookDeliveriesResponse {
+	list := make([]*WebhookDelivery, 0, len(deliveries))
+	for _, d := range deliveries {
+		list = append(list, &WebhookDelivery{
+			EventId:    d.EventID,
+			Event:      d.Event,
+			Attempt:    int32(d.Attempt),
+			StatusCode: int32(d.StatusCode),
+			Error:      d.Error,
+			DurationMs: d.DurationMs,
+			At:         timestamppb.New(d.At),
+		})
+	}
+	return &ListWebhookDeliveriesResponse{List: list}
+}
+
+func WebhookDeadLettersSuccessResponse(letters []webhook.DeadLetter) *ListWebhookDeadLettersResponse {
+	list := make([]*WebhookDeadLetter, 0, len(letters))
+	for _, l := range letters {
+		list = append(list, &WebhookDeadLetter{
+			EventId:   l.EventID,
+			Event:     l.Event,
+			Payload:   l.Payload,
+			Attempts:  int32(l.Attempts),
+			LastError: l.LastError,
+			FailedAt:  timestamppb.New(l.FailedAt),
+		})
+	}
+	return &ListWebhookDeadLettersResponse{List: list}
+}
@@ -0,0 +1,82 @@
+package httpgin
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"homework10/internal/apperr"
+	grpc2 "homework10/internal/ports/grpc"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	problemContentType = "application/problem+json"
+	// problemTypeBase к нему добавляется класс ошибки, получается type из RFC 7807
+	problemTypeBase = "/problems/"
+)
+
+// httpStatuses статусы ответа по классу ошибки, gRPC коды задаются такой же таблицей в grpc
+var httpStatuses = map[apperr.Kind]int{
+	apperr.InvalidArgument:    http.StatusBadRequest,
+	apperr.NotFound:           http.StatusNotFound,
+	apperr.AlreadyExists:      http.StatusConflict,
+	apperr.PermissionDenied:   http.StatusForbidden,
+	apperr.FailedPrecondition: http.StatusUnprocessableEntity,
+	apperr.Aborted:            http.StatusConflict,
+	apperr.ResourceExhausted:  http.StatusTooManyRequests,
+	apperr.QuotaExceeded:      http.StatusForbidden,
+	apperr.Unimplemented:      http.StatusNotImplemented,
+	apperr.Unavailable:        http.StatusServiceUnavailable,
+	apperr.Internal:           http.StatusInternalServerError,
+}
+
+// httpStatus статус ответа для ошибки err, для nil - 200
+func httpStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, errNotAcceptable):
+		return http.StatusNotAcceptable
+	case errors.Is(err, errUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	}
+	if status, ok := httpStatuses[apperr.KindOf(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError отвечает ошибкой err со статусом по ее классу и Retry-After, если он известен.
+// Тело - прежний конверт {"data": null, "error": "..."}, в том числе без Accept и для */*,
+// application/problem+json - только клиентам, которые явно его просят. В MessagePack ошибка та же,
+// что в problem+json, в protobuf - google.rpc.Status с подробностями, как у gRPC
+func writeError(c *gin.Context, err error) {
+	e := apperr.From(err)
+	if e.RetryAfter > 0 {
+		c.Header(retryAfterHeader, strconv.Itoa(int(math.Ceil(e.RetryAfter.Seconds()))))
+	}
+	status := httpStatus(e)
+	switch c.NegotiateFormat(gin.MIMEJSON, problemContentType, mimeProtobuf, mimeMsgPack, mimeXMsgPack) {
+	case problemContentType:
+		c.Header("Content-Type", problemContentType)
+		c.JSON(status, ProblemResponse(e, status, c.Request.URL.Path))
+	case mimeProtobuf:
+		c.ProtoBuf(status, grpc2.ErrorStatus(e))
+	case mimeMsgPack, mimeXMsgPack:
+		c.Render(status, msgPack{data: ProblemResponse(e, status, c.Request.URL.Path)})
+	default:
+		c.JSON(status, ErrorResponse(err))
+	}
+}
+
+// badRequest ошибка разбора запроса. Если известно поле с неверным типом, оно попадает в ответ
+func badRequest(err error) error {
+	e := apperr.Wrap(apperr.InvalidArgument, "bad_request", err)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		e.Fields = []apperr.FieldViolation{{Field: typeErr.Field, Code: "bad_type", Message: "must be " + typeErr.Type.String()}}
+	}
+	return e
+}
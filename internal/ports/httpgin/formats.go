@@ -0,0 +1,164 @@
+package httpgin
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"homework10/internal/apperr"
+	"net/http"
+	"reflect"
+	"slices"
+)
+
+// Кроме JSON обработчики понимают MessagePack с тем же конвертом data/error и protobuf с сообщениями
+// из service.proto. Файлы экспорта и импорта и поток событий отдаются как есть, без согласования
+const (
+	mimeProtobuf = binding.MIMEPROTOBUF
+	mimeMsgPack  = binding.MIMEMSGPACK2
+	// mimeXMsgPack прежнее название MessagePack, ответ на него приходит как application/msgpack
+	mimeXMsgPack = binding.MIMEMSGPACK
+)
+
+var (
+	errNotAcceptable = apperr.New(apperr.InvalidArgument, "not_acceptable",
+		"response has no protobuf message, use application/json or application/msgpack")
+	errUnsupportedMediaType = apperr.New(apperr.InvalidArgument, "unsupported_media_type",
+		"request has no protobuf message, use application/json or application/msgpack")
+	errUpdateMask = apperr.Field("update_mask", "bad_update_mask", "update_mask contains an unknown field")
+)
+
+// msgpackHandle MessagePack по текущей спецификации: строки - str, время - расширение timestamp,
+// нулевое время - nil. render.MsgPack из gin пишет время байтами time.Time.MarshalBinary,
+// которые другие клиенты не разберут
+var msgpackHandle = func() *codec.MsgpackHandle {
+	h := &codec.MsgpackHandle{WriteExt: true}
+	h.RawToString = true
+	h.MapType = reflect.TypeOf(map[string]any(nil))
+	return h
+}()
+
+// msgPack ответ в MessagePack с msgpackHandle
+type msgPack struct {
+	data any
+}
+
+func (r msgPack) Render(w http.ResponseWriter) error {
+	r.WriteContentType(w)
+	return codec.NewEncoder(w, msgpackHandle).Encode(r.data)
+}
+
+func (r msgPack) WriteContentType(w http.ResponseWriter) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", mimeMsgPack)
+	}
+}
+
+// responseFormat формат ответа по Accept, без заголовка и для неизвестных форматов - JSON
+func responseFormat(c *gin.Context) string {
+	switch format := c.NegotiateFormat(gin.MIMEJSON, mimeProtobuf, mimeMsgPack, mimeXMsgPack); format {
+	case mimeXMsgPack:
+		return mimeMsgPack
+	case "":
+		return gin.MIMEJSON
+	default:
+		return format
+	}
+}
+
+// respond отвечает body в JSON или MessagePack либо сообщением из service.proto в protobuf.
+// message собирает сообщение только для protobuf, nil - сообщения у ответа нет, клиент получает 406
+func respond(c *gin.Context, status int, body any, message func() proto.Message) {
+	switch responseFormat(c) {
+	case mimeProtobuf:
+		if message == nil {
+			writeError(c, errNotAcceptable)
+			return
+		}
+		c.ProtoBuf(status, message())
+	case mimeMsgPack:
+		c.Render(status, msgPack{data: body})
+	default:
+		c.JSON(status, body)
+	}
+}
+
+// protoMessage откладывает convert(value) до ответа в protobuf
+func protoMessage[T any, M proto.Message](convert func(T) M, value T) func() proto.Message {
+	return func() proto.Message {
+		return convert(value)
+	}
+}
+
+// protoRequest запрос, у которого есть сообщение в service.proto
+type protoRequest interface {
+	bindProto(data []byte) error
+}
+
+// fromProto разбирает data в сообщение и переносит его в запрос через convert
+func fromProto[M any, P interface {
+	*M
+	proto.Message
+}](data []byte, convert func(P) error) error {
+	m := P(new(M))
+	if err := proto.Unmarshal(data, m); err != nil {
+		return badRequest(err)
+	}
+	return convert(m)
+}
+
+// bind разбирает тело запроса в req по Content-Type, без заголовка - как JSON.
+// Ошибки уже с классом, их можно сразу передавать в writeError
+func bind(c *gin.Context, req any) error {
+	switch c.ContentType() {
+	case mimeProtobuf:
+		r, ok := req.(protoRequest)
+		if !ok {
+			return errUnsupportedMediaType
+		}
+		data, err := c.GetRawData()
+		if err != nil {
+			return badRequest(err)
+		}
+		return r.bindProto(data)
+	case mimeMsgPack, mimeXMsgPack:
+		if err := codec.NewDecoder(c.Request.Body, msgpackHandle).Decode(req); err != nil {
+			return badRequest(err)
+		}
+	default:
+		if err := c.ShouldBindJSON(req); err != nil {
+			return badRequest(err)
+		}
+	}
+	return nil
+}
+
+// protoPatchRequest запрос PATCH в protobuf: вместо документа Merge Patch изменяемые поля задает update_mask
+type protoPatchRequest interface {
+	bindProtoPatch(data []byte) (mergeFields, error)
+}
+
+// msgpackToJSON документ MessagePack как JSON, чтобы Merge Patch в обоих форматах разбирался одинаково
+func msgpackToJSON(data []byte) ([]byte, error) {
+	var doc map[string]any
+	if err := codec.NewDecoderBytes(data, msgpackHandle).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
+
+// maskFields поля из update_mask как поля документа Merge Patch, "*" - все fields
+func maskFields(paths []string, fields ...string) (mergeFields, error) {
+	masked := make(mergeFields, len(fields))
+	for _, path := range paths {
+		switch {
+		case path == "*":
+			return maskFields(fields, fields...)
+		case !slices.Contains(fields, path):
+			return nil, errUpdateMask
+		}
+		masked[path] = nil
+	}
+	return masked, nil
+}
@@ -0,0 +1,362 @@
+package httpgin
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"homework10/internal/adapters/adfile"
+	"homework10/internal/adapters/repository/eventrepo"
+	"homework10/internal/app"
+	"homework10/internal/audit"
+	"homework10/internal/entities"
+	mocks "homework10/internal/mocks/appemocks"
+	"homework10/internal/outbox"
+	grpc2 "homework10/internal/ports/grpc"
+	"homework10/internal/quota"
+	"homework10/internal/service"
+	"homework10/internal/util"
+	"homework10/internal/webhook"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var (
+	fCreated = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	fAd      = entities.Ad{ID: 1, Title: "title", Text: "text", AuthorID: 2, CreateDate: fCreated, UpdateDate: fCreated}
+	fUser    = entities.User{ID: 2, Nickname: "nick", Email: "nick@mail.ru"}
+	fWebhook = webhook.Subscription{ID: 3, URL: "http://hook", Secret: "secret", Events: []string{"ad.created"}, CreatedAt: fCreated}
+	fReport  = app.ImportReport{Total: 1, Valid: 1, Rows: []app.ImportRowReport{}}
+	fBatch   = []service.BatchResult{{Ad: &fAd}}
+)
+
+// formatCase один маршрут: тело запроса в JSON и MessagePack, то же тело в protobuf и ожидаемое сообщение ответа
+type formatCase struct {
+	name   string
+	method string
+	target string
+	body   map[string]any
+	// raw тело как есть во всех форматах, например файл импорта
+	raw string
+	// message тело в protobuf, nil при непустом body - у запроса нет сообщения
+	message proto.Message
+	status  int
+	// want ответ в protobuf, nil - у ответа нет сообщения
+	want proto.Message
+}
+
+func formatCases() []formatCase {
+	batch := &grpc2.BatchAdsResponse{Results: []*grpc2.BatchAdResult{{Ad: grpc2.AdSuccessResponse(&fAd)}}}
+	created := grpc2.WebhookSuccessResponse(&fWebhook)
+	created.Secret = fWebhook.Secret
+	return []formatCase{
+		{name: "createAd", method: http.MethodPost, target: "/ads",
+			body:    map[string]any{"title": "title", "text": "text", "user_id": 2},
+			message: &grpc2.CreateAdRequest{Title: "title", Text: "text", UserId: 2},
+			status:  http.StatusCreated, want: grpc2.AdSuccessResponse(&fAd)},
+		{name: "changeAdStatus", method: http.MethodPut, target: "/ads/1/status",
+			body:    map[string]any{"user_id": 2, "published": true},
+			message: &grpc2.ChangeAdStatusRequest{UserId: 2, Published: true},
+			status:  http.StatusOK, want: grpc2.AdSuccessResponse(&fAd)},
+		{name: "updateAd", method: http.MethodPut, target: "/ads/1",
+			body:    map[string]any{"user_id": 2, "title": "title", "text": "text"},
+			message: &grpc2.UpdateAdRequest{UserId: 2, Title: "title", Text: "text"},
+			status:  http.StatusOK, want: grpc2.AdSuccessResponse(&fAd)},
+		{name: "patchAd", method: http.MethodPatch, target: "/ads/1",
+			body:    map[string]any{"user_id": 2, "title": "title", "text": "text"},
+			message: &grpc2.UpdateAdRequest{UserId: 2, Title: "title", Text: "text"},
+			status:  http.StatusOK, want: grpc2.AdSuccessResponse(&fAd)},
+		{name: "getAdByID", method: http.MethodGet, target: "/ads/1",
+			status: http.StatusOK, want: grpc2.AdSuccessResponse(&fAd)},
+		{name: "getAdsByFilter", method: http.MethodGet, target: "/ads",
+			status: http.StatusOK, want: &grpc2.ListAdResponse{List: []*grpc2.AdResponse{grpc2.AdSuccessResponse(&fAd)}}},
+		{name: "deleteAd", method: http.MethodDelete, target: "/ads/1?user_id=2",
+			status: http.StatusOK, want: &grpc2.DeleteAdResponse{AdId: 1, UserId: 2}},
+		{name: "batchCreate", method: http.MethodPost, target: "/ads:batchCreate",
+			body: map[string]any{"items": []any{map[string]any{"title": "title", "text": "text", "user_id": 2}}},
+			message: &grpc2.BatchCreateAdsRequest{Items: []*grpc2.CreateAdRequest{
+				{Title: "title", Text: "text", UserId: 2},
+			}},
+			status: http.StatusOK, want: batch},
+		{name: "batchUpdateStatus", method: http.MethodPost, target: "/ads:batchUpdateStatus",
+			body: map[string]any{"items": []any{map[string]any{"ad_id": 1, "user_id": 2, "published": true}}},
+			message: &grpc2.BatchUpdateAdStatusRequest{Items: []*grpc2.ChangeAdStatusRequest{
+				{AdId: 1, UserId: 2, Published: true},
+			}},
+			status: http.StatusOK, want: batch},
+		{name: "batchDelete", method: http.MethodPost, target: "/ads:batchDelete",
+			body: map[string]any{"items": []any{map[string]any{"ad_id": 1, "user_id": 2}}, "all_or_nothing": true},
+			message: &grpc2.BatchDeleteAdsRequest{Items: []*grpc2.DeleteAdRequest{
+				{AdId: 1, AuthorId: 2},
+			}, AllOrNothing: true},
+			status: http.StatusOK, want: batch},
+		{name: "importAds", method: http.MethodPost, target: "/ads/import",
+			raw:    `{"title":"title","text":"text","author_id":2}`,
+			status: http.StatusOK, want: grpc2.ImportSuccessResponse(&fReport)},
+		{name: "getUserByID", method: http.MethodGet, target: "/users/2",
+			status: http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "getUserByNickname", method: http.MethodGet, target: "/users?nickname=nick",
+			status: http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "createUser", method: http.MethodPost, target: "/users",
+			body:    map[string]any{"nickname": "nick", "email": "nick@mail.ru"},
+			message: &grpc2.UserRequest{Nickname: "nick", Email: "nick@mail.ru"},
+			status:  http.StatusCreated, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "verifyUser", method: http.MethodPost, target: "/users/verify",
+			body:    map[string]any{"token": "token"},
+			message: &grpc2.VerifyUserRequest{Token: "token"},
+			status:  http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "updateUser", method: http.MethodPut, target: "/users/2",
+			body:    map[string]any{"nickname": "nick", "email": "nick@mail.ru"},
+			message: &grpc2.UserUpdateRequest{Nickname: "nick", Email: "nick@mail.ru"},
+			status:  http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "patchUser", method: http.MethodPatch, target: "/users/2",
+			body:    map[string]any{"nickname": "nick"},
+			message: &grpc2.UserUpdateRequest{Nickname: "nick"},
+			status:  http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "deleteUser", method: http.MethodDelete, target: "/users/2",
+			status: http.StatusOK, want: &grpc2.DeleteUserResponse{Id: 2}},
+		{name: "userQuota", method: http.MethodGet, target: "/users/2/quota",
+			status: http.StatusOK},
+		{name: "eraseUser", method: http.MethodPost, target: "/users/2/erase",
+			body:    map[string]any{"requester_id": 1},
+			message: &grpc2.EraseUserRequest{RequesterId: 1},
+			status:  http.StatusOK, want: grpc2.UserSuccessResponse(&fUser)},
+		{name: "outboxEntries", method: http.MethodGet, target: "/admin/outbox?requester_id=1",
+			status: http.StatusOK},
+		{name: "replayOutboxEntry", method: http.MethodPost, target: "/admin/outbox/e1/replay",
+			body:   map[string]any{"requester_id": 1},
+			status: http.StatusOK},
+		{name: "createWebhook", method: http.MethodPost, target: "/admin/webhooks",
+			body: map[string]any{"requester_id": 1, "url": "http://hook", "secret": "secret", "events": []any{"ad.created"}},
+			message: &grpc2.CreateWebhookRequest{
+				RequesterId: 1, Url: "http://hook", Secret: "secret", Events: []string{"ad.created"},
+			},
+			status: http.StatusOK, want: created},
+		{name: "webhooks", method: http.MethodGet, target: "/admin/webhooks?requester_id=1",
+			status: http.StatusOK, want: &grpc2.ListWebhooksResponse{List: []*grpc2.WebhookResponse{grpc2.WebhookSuccessResponse(&fWebhook)}}},
+		{name: "deleteWebhook", method: http.MethodDelete, target: "/admin/webhooks/3?requester_id=1",
+			status: http.StatusOK, want: &grpc2.DeleteWebhookResponse{Id: 3}},
+		{name: "webhookDeliveries", method: http.MethodGet, target: "/admin/webhooks/3/deliveries?requester_id=1",
+			status: http.StatusOK, want: grpc2.WebhookDeliveriesSuccessResponse([]webhook.Delivery{{EventID: "e1", At: fCreated}})},
+		{name: "webhookDeadLetters", method: http.MethodGet, target: "/admin/webhooks/3/dead_letters?requester_id=1",
+			status: http.StatusOK, want: grpc2.WebhookDeadLettersSuccessResponse([]webhook.DeadLetter{{EventID: "e1", FailedAt: fCreated}})},
+		{name: "rebuildProjections", method: http.MethodPost, target: "/admin/projections/rebuild",
+			body:   map[string]any{"requester_id": 1},
+			status: http.StatusOK},
+		{name: "adHistory", method: http.MethodGet, target: "/admin/ads/1/history?requester_id=1",
+			status: http.StatusOK},
+		{name: "auditLog", method: http.MethodGet, target: "/admin/audit?requester_id=1",
+			status: http.StatusOK},
+	}
+}
+
+// formatsApp приложение, которое отвечает на formatCases. Аргументы заданы точно,
+// поэтому тело запроса в любом формате должно разобраться в те же значения
+func formatsApp() *mocks.App {
+	title, text, nickname := fAd.Title, fAd.Text, fUser.Nickname
+	a := new(mocks.App)
+	a.On("GetAdByID", mock.Anything, int64(1)).Return(&fAd, nil)
+	a.On("GetUserByID", mock.Anything, int64(2)).Return(&fUser, nil)
+	a.On("CreateAd", mock.Anything, "title", "text", int64(2)).Return(&fAd, nil)
+	a.On("ChangeAdStatus", mock.Anything, int64(1), int64(2), true).Return(&fAd, nil)
+	a.On("UpdateAd", mock.Anything, int64(1), int64(2), "title", "text").Return(&fAd, nil)
+	a.On("PatchAd", mock.Anything, int64(1), int64(2), service.AdPatch{Title: &title, Text: &text}).Return(&fAd, nil)
+	a.On("GetAdsByFilter", mock.Anything, mock.Anything).Return([]entities.Ad{fAd}, nil)
+	a.On("RemoveAd", mock.Anything, int64(1), int64(2)).Return(nil)
+	a.On("CreateAds", mock.Anything, []service.NewAd{{Title: "title", Text: "text", AuthorID: 2}}, false).Return(fBatch, nil)
+	a.On("ChangeAdsStatus", mock.Anything, []service.AdStatusChange{{AdID: 1, AuthorID: 2, Published: true}}, false).
+		Return(fBatch, nil)
+	a.On("RemoveAds", mock.Anything, []service.AdRef{{AdID: 1, AuthorID: 2}}, true).Return(fBatch, nil)
+	a.On("ImportAds", mock.Anything, mock.Anything, adfile.JSONL, false).Return(&fReport, nil)
+	a.On("GetUserByNickname", mock.Anything, "nick").Return(&fUser, nil)
+	a.On("CreateUser", mock.Anything, "nick", "nick@mail.ru").Return(&fUser, nil)
+	a.On("VerifyUser", mock.Anything, "token").Return(&fUser, nil)
+	a.On("UpdateUser", mock.Anything, int64(2), "nick", "nick@mail.ru").Return(&fUser, nil)
+	a.On("PatchUser", mock.Anything, int64(2), service.UserPatch{Nickname: &nickname}).Return(&fUser, nil)
+	a.On("RemoveUserWithAds", mock.Anything, int64(2), app.CascadeAds, int64(0)).Return(nil)
+	a.On("UserQuota", mock.Anything, int64(-1), int64(2)).Return(&quota.Usage{ActiveAds: 1, ResetsAt: fCreated}, nil)
+	a.On("EraseUser", mock.Anything, int64(1), int64(2)).Return(&fUser, nil)
+	a.On("OutboxEntries", mock.Anything, int64(1), outbox.Status("")).
+		Return([]outbox.Entry{{ID: "e1", Event: "ad.created", CreatedAt: fCreated, NextAttemptAt: fCreated}}, nil)
+	a.On("ReplayOutboxEntry", mock.Anything, int64(1), "e1").Return(&outbox.Entry{ID: "e1", CreatedAt: fCreated, NextAttemptAt: fCreated}, nil)
+	a.On("CreateWebhook", mock.Anything, int64(1), "http://hook", "secret", []string{"ad.created"}).Return(&fWebhook, nil)
+	a.On("Webhooks", mock.Anything, int64(1)).Return([]webhook.Subscription{fWebhook}, nil)
+	a.On("DeleteWebhook", mock.Anything, int64(1), int64(3)).Return(nil)
+	a.On("WebhookDeliveries", mock.Anything, int64(1), int64(3)).Return([]webhook.Delivery{{EventID: "e1", At: fCreated}}, nil)
+	a.On("WebhookDeadLetters", mock.Anything, int64(1), int64(3)).
+		Return([]webhook.DeadLetter{{EventID: "e1", FailedAt: fCreated}}, nil)
+	a.On("RebuildProjections", mock.Anything, int64(1)).Return(&eventrepo.RebuildReport{Events: 1}, nil)
+	a.On("AdHistory", mock.Anything, int64(1), int64(1)).
+		Return([]eventrepo.Event{{Seq: 1, AdID: 1, RecordedAt: fCreated, CreateDate: fCreated, UpdateDate: fCreated}}, nil)
+	a.On("AuditLog", mock.Anything, int64(1), mock.Anything).Return([]audit.Entry{{ID: 1, At: fCreated}}, nil)
+	return a
+}
+
+func formatsRouter(a app.App) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	AppRouter(r.Group("/api/v1"), a, log.New(io.Discard, "", 0))
+	return r
+}
+
+func serveFormat(r http.Handler, method, target string, body []byte, contentType, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/api/v1"+target, bytes.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", accept)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func encodeMsgPack(t *testing.T, v any) []byte {
+	t.Helper()
+	var data []byte
+	assert.NoError(t, codec.NewEncoderBytes(&data, msgpackHandle).Encode(v))
+	return data
+}
+
+// msgpackAsJSON ответ в MessagePack, переписанный в JSON для сравнения с ответом в JSON
+func msgpackAsJSON(t *testing.T, data []byte) string {
+	t.Helper()
+	converted, err := msgpackToJSON(data)
+	assert.NoError(t, err)
+	return string(converted)
+}
+
+func TestFormats_MsgPackMatchesJSON(t *testing.T) {
+	r := formatsRouter(formatsApp())
+	for _, tc := range formatCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			jsonBody, msgpackBody := []byte(tc.raw), []byte(tc.raw)
+			if tc.body != nil {
+				jsonBody, _ = json.Marshal(tc.body)
+				msgpackBody = encodeMsgPack(t, tc.body)
+			}
+
+			w := serveFormat(r, tc.method, tc.target, jsonBody, gin.MIMEJSON, gin.MIMEJSON)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+			assert.Contains(t, w.Header().Get("Content-Type"), gin.MIMEJSON)
+			expected := w.Body.String()
+
+			for _, mime := range []string{mimeMsgPack, mimeXMsgPack} {
+				w = serveFormat(r, tc.method, tc.target, msgpackBody, mime, mime)
+				assert.Equal(t, tc.status, w.Code)
+				assert.Equal(t, mimeMsgPack, w.Header().Get("Content-Type"))
+				assert.JSONEq(t, expected, msgpackAsJSON(t, w.Body.Bytes()))
+			}
+		})
+	}
+}
+
+func TestFormats_Protobuf(t *testing.T) {
+	r := formatsRouter(formatsApp())
+	for _, tc := range formatCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			body, contentType := []byte(tc.raw), ""
+			if tc.message != nil {
+				body, _ = proto.Marshal(tc.message)
+				contentType = mimeProtobuf
+			}
+			if tc.body != nil && tc.message == nil {
+				// у запроса нет сообщения в service.proto
+				w := serveFormat(r, tc.method, tc.target, []byte{}, mimeProtobuf, mimeProtobuf)
+				assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+				body, _ = json.Marshal(tc.body)
+				contentType = gin.MIMEJSON
+			}
+
+			w := serveFormat(r, tc.method, tc.target, body, contentType, mimeProtobuf)
+			assert.Equal(t, mimeProtobuf, w.Header().Get("Content-Type"))
+			if tc.want == nil {
+				assert.Equal(t, http.StatusNotAcceptable, w.Code)
+				var st spb.Status
+				assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &st))
+				assert.Contains(t, st.Message, "protobuf")
+				return
+			}
+			assert.Equal(t, tc.status, w.Code)
+			got := tc.want.ProtoReflect().New().Interface()
+			assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), got))
+			assert.True(t, proto.Equal(tc.want, got), "want %v, got %v", tc.want, got)
+		})
+	}
+}
+
+func TestFormats_AcceptFallsBackToJSON(t *testing.T) {
+	r := formatsRouter(formatsApp())
+
+	for _, accept := range []string{"", "*/*", "text/html"} {
+		w := serveFormat(r, http.MethodGet, "/ads/1", nil, "", accept)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), gin.MIMEJSON)
+	}
+	// при равных весах выбирается первый формат из Accept
+	w := serveFormat(r, http.MethodGet, "/ads/1", nil, "", "application/msgpack, application/json")
+	assert.Equal(t, mimeMsgPack, w.Header().Get("Content-Type"))
+}
+
+func TestFormats_Errors(t *testing.T) {
+	a := new(mocks.App)
+	a.On("GetAdByID", mock.Anything, int64(100)).Return(nil, util.ErrNotFound)
+	r := formatsRouter(a)
+
+	w := serveFormat(r, http.MethodGet, "/ads/100", nil, "", mimeProtobuf)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, mimeProtobuf, w.Header().Get("Content-Type"))
+	var st spb.Status
+	assert.NoError(t, proto.Unmarshal(w.Body.Bytes(), &st))
+	assert.EqualValues(t, 5, st.Code)
+	if assert.NotEmpty(t, st.Details) {
+		var info errdetails.ErrorInfo
+		assert.NoError(t, st.Details[0].UnmarshalTo(&info))
+		assert.Equal(t, "AD_NOT_FOUND", info.Reason)
+	}
+
+	w = serveFormat(r, http.MethodGet, "/ads/100", nil, "", mimeMsgPack)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, mimeMsgPack, w.Header().Get("Content-Type"))
+	var problem problemResponse
+	assert.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), msgpackHandle).Decode(&problem))
+	assert.Equal(t, "ad_not_found", problem.Code)
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+
+	// битое тело - 400 в любом формате
+	w = serveFormat(r, http.MethodPost, "/users", []byte{0xff, 0xff}, mimeProtobuf, mimeProtobuf)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveFormat(r, http.MethodPost, "/users", []byte{0xc1}, mimeMsgPack, mimeMsgPack)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestFormats_PatchMask(t *testing.T) {
+	a := new(mocks.App)
+	a.On("PatchAd", mock.Anything, int64(1), int64(2), service.AdPatch{Text: new(string)}).Return(&fAd, nil).Once()
+	r := formatsRouter(a)
+
+	// в маске только text, title из сообщения не меняется, пустой text передается как есть
+	body, _ := proto.Marshal(&grpc2.UpdateAdRequest{UserId: 2, Title: "ignored", UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"text"}}})
+	w := serveFormat(r, http.MethodPatch, "/ads/1", body, mimeProtobuf, mimeProtobuf)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	body, _ = proto.Marshal(&grpc2.UpdateAdRequest{UserId: 2, UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"author_id"}}})
+	w = serveFormat(r, http.MethodPatch, "/ads/1", body, mimeProtobuf, problemContentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "bad_update_mask")
+
+	// null в MessagePack отклоняется, как в JSON Merge Patch
+	w = serveFormat(r, http.MethodPatch, "/users/2", encodeMsgPack(t, map[string]any{"nickname": nil}), mimeMsgPack, problemContentType)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "null_field")
+	a.AssertExpectations(t)
+}
@@ -1,50 +1,71 @@
 package httpgin
 
 import (
+	"encoding/json"
 	"errors"
-	"github.com/AirstaNs/ValidationAds"
+	"fmt"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
-	"homework10/internal/adapters/repository/userrepo"
+	"google.golang.org/protobuf/proto"
+	"homework10/internal/adapters/adfile"
 	"homework10/internal/app"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
+	"homework10/internal/outbox"
+	grpc2 "homework10/internal/ports/grpc"
 	"homework10/internal/service"
-	"homework10/internal/util"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
 )
 
-var errConvert = errors.New("ad_id is not int")
+var (
+	errConvert     = apperr.New(apperr.InvalidArgument, "bad_id", "ad_id is not int")
+	errNickname    = apperr.Field("nickname", "nickname_required", "nickname query parameter is required")
+	errBatchMethod = apperr.New(apperr.NotFound, "unknown_batch_method", "unknown batch method")
+	errFileFormat  = apperr.Field("format", "bad_file_format", "format must be csv or jsonl")
+	errEventID     = apperr.New(apperr.InvalidArgument, "bad_last_event_id", "Last-Event-ID is not a non-negative int")
+	errWebhookID   = apperr.New(apperr.InvalidArgument, "bad_webhook_id", "webhook_id is not int")
+)
+
+var exportFormats = map[string]app.ExportFormat{
+	"json": app.ExportJSON,
+	"zip":  app.ExportZIP,
+}
+
+var fileFormats = map[string]adfile.Format{
+	"csv":   adfile.CSV,
+	"jsonl": adfile.JSONL,
+}
+
+var adsPolicies = map[string]app.AdsPolicy{
+	"cascade":  app.CascadeAds,
+	"transfer": app.TransferAds,
+}
 
 func createAd(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req createAdRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
 			return
 		}
 		id, err2 := a.GetUserByID(c, req.UserID)
 
 		if err2 != nil {
-			c.JSON(http.StatusNotFound, ErrorResponse(err2))
+			writeError(c, err2)
 			return
 		}
 
 		ad, err := a.CreateAd(c, req.Title, req.Text, id.ID)
 		if err != nil {
-			if isEmptyAuthorID := errors.Is(err, userrepo.ErrEmptyUser); isEmptyAuthorID {
-				c.JSON(http.StatusNotFound, ErrorResponse(err))
-				return
-			}
-			isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-			isBadText := errors.Is(err, ValidationAds.ErrBadText)
-			isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-			if isBadTitle || isBadText || isBadAuthorID {
-				c.JSON(http.StatusBadRequest, ErrorResponse(err))
-				return
-			}
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+			writeError(c, err)
+			return
 		}
 
-		c.JSON(http.StatusCreated, AdSuccessResponse(ad))
+		respond(c, http.StatusCreated, AdSuccessResponse(ad), protoMessage(grpc2.AdSuccessResponse, ad))
 
 	}
 }
@@ -52,87 +73,144 @@ func createAd(a app.App) gin.HandlerFunc {
 func changeAdStatus(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req changeAdStatusRequest
-		if err := c.Bind(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
 			return
 		}
 		strId := c.Param("ad_id")
 		id, err := strconv.ParseInt(strId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 		_, err2 := a.GetUserByID(c, req.UserID)
 
 		if err2 != nil {
-			c.JSON(http.StatusForbidden, ErrorResponse(err2))
+			writeError(c, err2)
 			return
 		}
 
 		ad, err := a.ChangeAdStatus(c, id, req.UserID, req.Published)
 		if err != nil {
-			isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-			if isBadAuthorID {
-				c.JSON(http.StatusForbidden, ErrorResponse(err))
-				return
-			}
-			isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-			isBadText := errors.Is(err, ValidationAds.ErrBadText)
-
-			if isBadTitle || isBadText {
-				c.JSON(http.StatusBadRequest, ErrorResponse(err))
-				return
-			}
-
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+		respond(c, http.StatusOK, AdSuccessResponse(ad), protoMessage(grpc2.AdSuccessResponse, ad))
 	}
 }
 
 func updateAd(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req updateAdRequest
-		if err := c.Bind(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
 			return
 		}
 
 		strId := c.Param("ad_id")
 		id, err := strconv.ParseInt(strId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 		gAd, err2 := a.GetAdByID(c, id)
 
 		if err2 != nil {
-			c.JSON(http.StatusNotFound, ErrorResponse(err2))
+			writeError(c, err2)
 			return
 		}
 
 		ad, err := a.UpdateAd(c, gAd.ID, req.UserID, req.Title, req.Text)
 		if err != nil {
-			isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-			if isBadAuthorID {
-				c.JSON(http.StatusForbidden, ErrorResponse(err))
-				return
-			}
-			isBadTitle := errors.Is(err, ValidationAds.ErrBadTitle)
-			isBadText := errors.Is(err, ValidationAds.ErrBadText)
+			writeError(c, err)
+			return
+		}
 
-			if isBadTitle || isBadText {
-				c.JSON(http.StatusBadRequest, ErrorResponse(err))
-				return
-			}
+		respond(c, http.StatusOK, AdSuccessResponse(ad), protoMessage(grpc2.AdSuccessResponse, ad))
+	}
+}
 
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+// mergeFields поля, которые есть в документе JSON Merge Patch
+type mergeFields map[string]json.RawMessage
+
+// bindMergePatch разбирает тело JSON Merge Patch в req. Заголовок Content-Type: application/merge-patch+json
+// не обязателен, тело разбирается как JSON, если это не MessagePack или protobuf.
+// Все поля объявления и пользователя обязательны, поэтому null, который в Merge Patch удаляет поле, - 400.
+// Ошибки уже с классом, как у bind
+func bindMergePatch(c *gin.Context, req any) (mergeFields, error) {
+	body, err := c.GetRawData()
+	if err != nil {
+		return nil, badRequest(err)
+	}
+	switch c.ContentType() {
+	case mimeProtobuf:
+		r, ok := req.(protoPatchRequest)
+		if !ok {
+			return nil, errUnsupportedMediaType
+		}
+		return r.bindProtoPatch(body)
+	case mimeMsgPack, mimeXMsgPack:
+		if body, err = msgpackToJSON(body); err != nil {
+			return nil, badRequest(err)
+		}
+	}
+	var fields mergeFields
+	if err = json.Unmarshal(body, &fields); err != nil {
+		return nil, badRequest(err)
+	}
+	nulls := make([]string, 0)
+	for field, value := range fields {
+		if string(value) == "null" {
+			nulls = append(nulls, field)
+		}
+	}
+	if len(nulls) > 0 {
+		slices.Sort(nulls)
+		return nil, apperr.Field(nulls[0], "null_field", nulls[0]+" can't be null, fields can't be cleared")
+	}
+	if err = json.Unmarshal(body, req); err != nil {
+		return nil, badRequest(err)
+	}
+	return fields, nil
+}
+
+// value значение поля field для service.AdPatch и service.UserPatch: nil - поля нет в документе
+// и оно не меняется. Поле из update_mask protobuf без значения - пустая строка, ее отклонит проверка полей
+func (f mergeFields) value(field string, value *string) *string {
+	if _, ok := f[field]; !ok {
+		return nil
+	}
+	if value == nil {
+		return new(string)
+	}
+	return value
+}
+
+// patchAd меняет только поля, которые есть в теле
+func patchAd(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req patchAdRequest
+		fields, err := bindMergePatch(c, &req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		id, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
 			return
 		}
 
-		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+		ad, err := a.PatchAd(c, id, req.UserID, service.AdPatch{
+			Title: fields.value("title", req.Title),
+			Text:  fields.value("text", req.Text),
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, AdSuccessResponse(ad), protoMessage(grpc2.AdSuccessResponse, ad))
 	}
 }
 
@@ -141,22 +219,17 @@ func getAdByID(a app.App) gin.HandlerFunc {
 		adId := c.Param("ad_id")
 		id, err := strconv.ParseInt(adId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 
 		ad, err := a.GetAdByID(c, id)
 		if err != nil {
-			isNotFound := errors.Is(err, util.ErrNotFound)
-			if isNotFound {
-				c.JSON(http.StatusNotFound, ErrorResponse(err))
-				return
-			}
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, AdSuccessResponse(ad))
+		respond(c, http.StatusOK, AdSuccessResponse(ad), protoMessage(grpc2.AdSuccessResponse, ad))
 	}
 }
 
@@ -164,15 +237,75 @@ func getAdsByFilter(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var filters service.AdFilters
 		if err := c.ShouldBindQuery(&filters); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			writeError(c, badRequest(err))
 			return
 		}
 		ads, err := a.GetAdsByFilter(c, filters)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, AdListSuccessResponse(&ads))
+		respond(c, http.StatusOK, AdListSuccessResponse(&ads), func() proto.Message {
+			list := grpc2.AdListSuccessResponse(&ads)
+			return &list
+		})
+	}
+}
+
+// adEvents поток изменений объявлений в формате Server-Sent Events с фильтрами getAdsByFilter.
+// Сначала приходят объявления выборки (snapshot) и событие synced, затем изменения с ID.
+// Клиент, переподключившийся с Last-Event-ID, вместо выборки получает пропущенные изменения
+// из истории, а если они уже вытеснены - событие reset и поток заново с выборки
+func adEvents(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filters service.AdFilters
+		if err := c.ShouldBindQuery(&filters); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		lastEventID := int64(-1)
+		if header := c.GetHeader("Last-Event-ID"); header != "" {
+			id, err := strconv.ParseInt(header, 10, 64)
+			if err != nil || id < 0 {
+				writeError(c, errEventID)
+				return
+			}
+			lastEventID = id
+		}
+		// gin.Context не отменяется при отключении клиента, поэтому контекст берется из запроса
+		watch, err := a.ResumeAds(c.Request.Context(), filters, lastEventID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+
+		c.Header("X-Accel-Buffering", "no")
+		if watch.Reset {
+			c.Render(-1, sse.Event{Event: "reset", Data: ""})
+		}
+		for i := range watch.Snapshot {
+			c.Render(-1, sse.Event{Event: "snapshot", Data: AdSuccessResponse(&watch.Snapshot[i])})
+		}
+		for _, event := range watch.Missed {
+			c.Render(-1, adEventMessage(event))
+		}
+		c.Render(-1, sse.Event{Event: "synced", Id: strconv.FormatInt(watch.Head, 10), Data: ""})
+		c.Writer.Flush()
+
+		// канал закрывается при отключении клиента или если он не успевает читать,
+		// во втором случае клиент переподключится с Last-Event-ID
+		for event := range watch.Events {
+			c.Render(-1, adEventMessage(event))
+			c.Writer.Flush()
+		}
+	}
+}
+
+func adEventMessage(event service.AdEvent) sse.Event {
+	return sse.Event{
+		Event: string(event.Type),
+		Id:    strconv.FormatInt(event.ID, 10),
+		Data:  AdSuccessResponse(&event.Ad),
 	}
 }
 
@@ -184,18 +317,17 @@ func deleteAd(a app.App) gin.HandlerFunc {
 		uID, err1 := strconv.ParseInt(strUserID, 10, 64)
 
 		if err != nil || err1 != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 		err = a.RemoveAd(c, id, uID)
 		if err != nil {
-			isBadAuthorID := errors.Is(err, ValidationAds.ErrBadAuthorID)
-			if isBadAuthorID {
-				c.JSON(http.StatusForbidden, ErrorResponse(err))
-				return
-			}
+			writeError(c, err)
+			return
 		}
-		c.JSON(http.StatusOK, DeleteAdSuccessResponse(id, uID))
+		respond(c, http.StatusOK, DeleteAdSuccessResponse(id, uID), func() proto.Message {
+			return &grpc2.DeleteAdResponse{AdId: id, UserId: uID}
+		})
 	}
 }
 
@@ -206,38 +338,65 @@ func deleteAd(a app.App) gin.HandlerFunc {
 func updateUser(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req UpdateUserRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
 			return
 		}
 		strUserId := c.Param("user_id")
 		userId, err := strconv.ParseInt(strUserId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 		user, err := a.UpdateUser(c, userId, req.Nickname, req.Email)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, UserSuccessResponse(user))
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
+	}
+}
+
+// patchUser как patchAd, в отличие от PUT пустая строка или null в поле не пропускаются, а проверяются
+func patchUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req patchUserRequest
+		fields, err := bindMergePatch(c, &req)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+
+		user, err := a.PatchUser(c, userID, service.UserPatch{
+			Nickname: fields.value("nickname", req.Nickname),
+			Email:    fields.value("email", req.Email),
+		})
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
 	}
 }
 
 func createUser(a app.App) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req createUserRequest
-		if err := c.BindJSON(&req); err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
 			return
 		}
 		user, err := a.CreateUser(c, req.Nickname, req.Email)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusCreated, UserSuccessResponse(user))
+		respond(c, http.StatusCreated, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
 	}
 }
 
@@ -246,20 +405,64 @@ func getUserByID(a app.App) gin.HandlerFunc {
 		strUserId := c.Param("user_id")
 		userId, err := strconv.ParseInt(strUserId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
 			return
 		}
 		user, err := a.GetUserByID(c, userId)
 		if err != nil {
-			isNotFound := errors.Is(err, userrepo.ErrEmptyUser)
-			if isNotFound {
-				c.JSON(http.StatusNotFound, ErrorResponse(err))
-				return
-			}
-			c.JSON(http.StatusInternalServerError, ErrorResponse(err))
+			writeError(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, UserSuccessResponse(user))
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
+	}
+}
+
+func getUserByNickname(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		nickname := c.Query("nickname")
+		if nickname == "" {
+			writeError(c, errNickname)
+			return
+		}
+		user, err := a.GetUserByNickname(c, nickname)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
+	}
+}
+
+func verifyUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req verifyUserRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		user, err := a.VerifyUser(c, req.Token)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
+	}
+}
+
+// resendVerification отправляет новый токен подтверждения, если письмо после создания или смены email не дошло
+func resendVerification(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+		user, err := a.ResendVerification(c, userID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusAccepted, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
 	}
 }
 
@@ -268,10 +471,444 @@ func deleteUser(a app.App) gin.HandlerFunc {
 		strUserId := c.Param("user_id")
 		userId, err := strconv.ParseInt(strUserId, 10, 64)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, ErrorResponse(errConvert))
+			writeError(c, errConvert)
+			return
+		}
+		var req deleteUserRequest
+		if err = c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		policy, ok := adsPolicies[req.Ads]
+		if !ok {
+			writeError(c, app.ErrBadAdsPolicy)
+			return
+		}
+		err = a.RemoveUserWithAds(c, userId, policy, req.NewOwnerID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, DeleteUserSuccessResponse(userId), func() proto.Message {
+			return &grpc2.DeleteUserResponse{Id: userId}
+		})
+	}
+}
+
+// exportUser отдает персональные данные файлом, без общей обертки data/error
+func exportUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+		var req exportUserRequest
+		if err = c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		format, ok := exportFormats[req.Format]
+		if !ok {
+			writeError(c, app.ErrBadExportFormat)
+			return
+		}
+		export, err := a.ExportUserData(c, req.RequesterID, userID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		data, err := export.Encode(format)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="user_%d.%s"`, userID, format))
+		c.Data(http.StatusOK, format.ContentType(), data)
+	}
+}
+
+func eraseUser(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+		var req eraseUserRequest
+		if err = bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		user, err := a.EraseUser(c, req.RequesterID, userID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, UserSuccessResponse(user), protoMessage(grpc2.UserSuccessResponse, user))
+	}
+}
+
+// outboxEntries записи outbox для администратора, ?status=failed - застрявшие
+func outboxEntries(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req outboxEntriesRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		entries, err := a.OutboxEntries(c, req.RequesterID, outbox.Status(req.Status))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, OutboxListSuccessResponse(entries), nil)
+	}
+}
+
+// replayOutboxEntry возвращает запись outbox в очередь доставки
+func replayOutboxEntry(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req replayOutboxRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		entry, err := a.ReplayOutboxEntry(c, req.RequesterID, c.Param("entry_id"))
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, OutboxEntrySuccessResponse(entry), nil)
+	}
+}
+
+// batchAds обрабатывает POST /ads:batchCreate, /ads:batchUpdateStatus и /ads:batchDelete
+func batchAds(a app.App) gin.HandlerFunc {
+	methods := map[string]gin.HandlerFunc{
+		":batchCreate":       batchCreateAds(a),
+		":batchUpdateStatus": batchUpdateAdStatus(a),
+		":batchDelete":       batchDeleteAds(a),
+	}
+	return func(c *gin.Context) {
+		handler, ok := methods[c.Param("method")]
+		if !ok {
+			writeError(c, errBatchMethod)
+			return
+		}
+		handler(c)
+	}
+}
+
+func batchCreateAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchCreateAdsRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		ads := make([]service.NewAd, 0, len(req.Items))
+		for _, item := range req.Items {
+			ads = append(ads, service.NewAd{Title: item.Title, Text: item.Text, AuthorID: item.UserID})
+		}
+		results, err := a.CreateAds(c, ads, req.AllOrNothing)
+		batchResponse(c, results, err)
+	}
+}
+
+func batchUpdateAdStatus(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchUpdateAdStatusRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		changes := make([]service.AdStatusChange, 0, len(req.Items))
+		for _, item := range req.Items {
+			changes = append(changes, service.AdStatusChange{AdID: item.AdID, AuthorID: item.UserID, Published: item.Published})
+		}
+		results, err := a.ChangeAdsStatus(c, changes, req.AllOrNothing)
+		batchResponse(c, results, err)
+	}
+}
+
+func batchDeleteAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req batchDeleteAdsRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		refs := make([]service.AdRef, 0, len(req.Items))
+		for _, item := range req.Items {
+			refs = append(refs, service.AdRef{AdID: item.AdID, AuthorID: item.UserID})
+		}
+		results, err := a.RemoveAds(c, refs, req.AllOrNothing)
+		batchResponse(c, results, err)
+	}
+}
+
+func batchResponse(c *gin.Context, results []service.BatchResult, err error) {
+	if err != nil {
+		if isAborted := errors.Is(err, service.ErrBatchAborted); !isAborted {
+			writeError(c, err)
+			return
+		}
+	}
+	respond(c, httpStatus(err), BatchResponse(results, err), func() proto.Message {
+		// коды элементов в protobuf - коды gRPC, как в BatchAdsResponse
+		res, _ := grpc2.BatchSuccessResponse(results, err)
+		return res
+	})
+}
+
+func userQuota(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseInt(c.Param("user_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+		var req userQuotaRequest
+		if err = c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		usage, err := a.UserQuota(c, req.RequesterID, userID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, QuotaSuccessResponse(usage), nil)
+	}
+}
+
+// importAds принимает файл телом запроса или полем file в multipart/form-data
+func importAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req importAdsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		format, ok := fileFormats[req.Format]
+		if !ok {
+			writeError(c, errFileFormat)
+			return
+		}
+
+		var body io.Reader = c.Request.Body
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			header, err := c.FormFile("file")
+			if err != nil {
+				writeError(c, badRequest(err))
+				return
+			}
+			file, err := header.Open()
+			if err != nil {
+				writeError(c, badRequest(err))
+				return
+			}
+			defer file.Close()
+			body = file
+		}
+
+		report, err := a.ImportAds(c, body, format, req.DryRun)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, ImportSuccessResponse(report), protoMessage(grpc2.ImportSuccessResponse, report))
+	}
+}
+
+// exportAds фильтры те же, что у getAdsByFilter. Ответ пишется построчно, поэтому ошибка
+// после начала записи только логируется
+func exportAds(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var filters service.AdFilters
+		if err := c.ShouldBindQuery(&filters); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		var req exportAdsRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		format, ok := fileFormats[req.Format]
+		if !ok {
+			writeError(c, errFileFormat)
+			return
+		}
+
+		c.Header("Content-Type", format.ContentType())
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="ads.%s"`, format))
+		c.Status(http.StatusOK)
+		if err := a.ExportAds(c, filters, format, c.Writer); err != nil {
+			_ = c.Error(err)
+		}
+	}
+}
+
+// createWebhook подписывает партнера на события объявлений, secret возвращается только здесь
+func createWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req createWebhookRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		sub, err := a.CreateWebhook(c, req.RequesterID, req.URL, req.Secret, req.Events)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, WebhookSuccessResponse(sub), func() proto.Message {
+			res := grpc2.WebhookSuccessResponse(sub)
+			res.Secret = sub.Secret
+			return res
+		})
+	}
+}
+
+func webhooks(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req webhooksRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		subs, err := a.Webhooks(c, req.RequesterID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, WebhookListSuccessResponse(subs), protoMessage(grpc2.WebhookListSuccessResponse, subs))
+	}
+}
+
+func deleteWebhook(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhookID, req, ok := bindWebhookRequest(c)
+		if !ok {
+			return
+		}
+		if err := a.DeleteWebhook(c, req.RequesterID, webhookID); err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, DeleteWebhookSuccessResponse(webhookID), func() proto.Message {
+			return &grpc2.DeleteWebhookResponse{Id: webhookID}
+		})
+	}
+}
+
+// webhookDeliveries журнал попыток доставки подписки, новые первыми
+func webhookDeliveries(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhookID, req, ok := bindWebhookRequest(c)
+		if !ok {
+			return
+		}
+		deliveries, err := a.WebhookDeliveries(c, req.RequesterID, webhookID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, WebhookDeliveriesSuccessResponse(deliveries), protoMessage(grpc2.WebhookDeliveriesSuccessResponse, deliveries))
+	}
+}
+
+// webhookDeadLetters события, которые подписка так и не приняла
+func webhookDeadLetters(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		webhookID, req, ok := bindWebhookRequest(c)
+		if !ok {
+			return
+		}
+		letters, err := a.WebhookDeadLetters(c, req.RequesterID, webhookID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, WebhookDeadLettersSuccessResponse(letters), protoMessage(grpc2.WebhookDeadLettersSuccessResponse, letters))
+	}
+}
+
+func bindWebhookRequest(c *gin.Context) (int64, webhooksRequest, bool) {
+	var req webhooksRequest
+	webhookID, err := strconv.ParseInt(c.Param("webhook_id"), 10, 64)
+	if err != nil {
+		writeError(c, errWebhookID)
+		return 0, req, false
+	}
+	if err = c.ShouldBindQuery(&req); err != nil {
+		writeError(c, badRequest(err))
+		return 0, req, false
+	}
+	return webhookID, req, true
+}
+
+// rebuildProjections перестраивает проекции объявлений из журнала событий
+func rebuildProjections(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req rebuildProjectionsRequest
+		if err := bind(c, &req); err != nil {
+			writeError(c, err)
+			return
+		}
+		report, err := a.RebuildProjections(c, req.RequesterID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, RebuildSuccessResponse(report), nil)
+	}
+}
+
+// adHistory события объявления из журнала, доступны и после удаления
+func adHistory(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adID, err := strconv.ParseInt(c.Param("ad_id"), 10, 64)
+		if err != nil {
+			writeError(c, errConvert)
+			return
+		}
+		var req adHistoryRequest
+		if err = c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		history, err := a.AdHistory(c, req.RequesterID, adID)
+		if err != nil {
+			writeError(c, err)
+			return
+		}
+		respond(c, http.StatusOK, AdHistorySuccessResponse(history), nil)
+	}
+}
+
+// auditLog журнал аудита с фильтрами по автору, цели и времени, новые записи первыми
+func auditLog(a app.App) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req auditLogRequest
+		if err := c.ShouldBindQuery(&req); err != nil {
+			writeError(c, badRequest(err))
+			return
+		}
+		entries, err := a.AuditLog(c, req.RequesterID, audit.Filter{
+			Actor:    req.Actor,
+			Target:   req.Target,
+			TargetID: req.TargetID,
+			From:     req.From,
+			To:       req.To,
+			Limit:    req.Limit,
+		})
+		if err != nil {
+			writeError(c, err)
 			return
 		}
-		_ = a.RemoveUser(c, userId)
-		c.JSON(http.StatusOK, DeleteUserSuccessResponse(userId))
+		respond(c, http.StatusOK, AuditLogSuccessResponse(entries), nil)
 	}
 }
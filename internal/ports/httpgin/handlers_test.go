@@ -3,17 +3,25 @@ package httpgin
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/AirstaNs/ValidationAds"
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"homework10/internal/adapters/adfile"
+	"homework10/internal/adapters/repository/eventrepo"
 	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/app"
+	"homework10/internal/audit"
 	"homework10/internal/entities"
 	mocks "homework10/internal/mocks/appemocks"
+	"homework10/internal/outbox"
+	"homework10/internal/quota"
 	"homework10/internal/service"
 	"homework10/internal/util"
+	"homework10/internal/webhook"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -179,6 +187,65 @@ func (s *httpAppSuite) Test_CreateAd_CreateAd_BadText() {
 	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
 }
 
+func (s *httpAppSuite) Test_CreateAd_Problem() {
+	nAd := tAd
+	nAd.Title = "problem " + wrongMoreStr
+	body := map[string]any{"user_id": nAd.AuthorID, "title": nAd.Title, "text": nAd.Text}
+	s.app.
+		On("CreateAd", mock.AnythingOfType("*gin.Context"), nAd.Title, nAd.Text, nAd.AuthorID).
+		Return(emptyAd, ValidationAds.ErrBadTitle)
+
+	MockJsonPost(s.ctx, body)
+	s.ctx.Request.Header.Set("Accept", problemContentType)
+	s.ctx.Request.URL.Path = "/api/v1/ads"
+	createAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+	assert.Equal(s.T(), problemContentType, s.recorder.Header().Get("Content-Type"))
+
+	var problem problemResponse
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &problem))
+	assert.Equal(s.T(), problemResponse{
+		Type:     "/problems/invalid_argument",
+		Title:    "Bad Request",
+		Status:   http.StatusBadRequest,
+		Detail:   ValidationAds.ErrBadTitle.Error(),
+		Instance: "/api/v1/ads",
+		Code:     "bad_title",
+		Errors:   []problemField{{Field: "title", Code: "bad_title", Detail: ValidationAds.ErrBadTitle.Error()}},
+	}, problem)
+
+	// старые клиенты получают прежний конверт, если просят только application/json
+	s.SetupTest()
+	MockJsonPost(s.ctx, body)
+	s.ctx.Request.Header.Set("Accept", "application/json")
+	createAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+	assert.JSONEq(s.T(), `{"data": null, "error": "`+ValidationAds.ErrBadTitle.Error()+`"}`, s.recorder.Body.String())
+}
+
+func (s *httpAppSuite) Test_writeError_Internal() {
+	s.ctx.Request.Header.Set("Accept", "application/problem+json")
+	writeError(s.ctx, errors.New("db password is wrong"))
+	assert.EqualValues(s.T(), http.StatusInternalServerError, s.recorder.Code)
+
+	var problem problemResponse
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &problem))
+	assert.Equal(s.T(), "internal error", problem.Detail)
+	assert.Equal(s.T(), "internal", problem.Code)
+}
+
+func (s *httpAppSuite) Test_badRequest_Field() {
+	MockJsonPost(s.ctx, map[string]any{"user_id": "abc", "title": tAd.Title, "text": tAd.Text})
+	s.ctx.Request.Header.Set("Accept", problemContentType)
+	createAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+
+	var problem problemResponse
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &problem))
+	assert.Equal(s.T(), "bad_request", problem.Code)
+	assert.Equal(s.T(), []problemField{{Field: "user_id", Code: "bad_type", Detail: "must be int64"}}, problem.Errors)
+}
+
 func (s *httpAppSuite) Test_ChangeAdStatus() {
 	body := map[string]any{
 		"user_id":   tUser.ID,
@@ -230,7 +297,7 @@ func (s *httpAppSuite) Test_ChangeAdStatus_InvalidUserID() {
 
 	MockJsonPut(s.ctx, body, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(tAd.ID, 10)}})
 	changeAdStatus(s.app)(s.ctx)
-	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
 }
 
 func (s *httpAppSuite) Test_ChangeAdStatus_InvalidAdUserIDForbidden() {
@@ -301,6 +368,47 @@ func (s *httpAppSuite) Test_UpdateAd() {
 	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
 }
 
+func (s *httpAppSuite) Test_PatchAd() {
+	nAd := tAd
+	nAd.Title = nTitle
+	s.app.
+		On("PatchAd", mock.AnythingOfType("*gin.Context"), tAd.ID, tAd.AuthorID, service.AdPatch{Title: &nTitle}).
+		Return(&nAd, nil)
+
+	MockMergePatch(s.ctx, `{"user_id": 0, "title": "newTest"}`, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(tAd.ID, 10)}})
+	patchAd(s.app)(s.ctx)
+
+	response, err := getResponse(s.recorder)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), nAd, unmarshalResponse(response))
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_PatchAd_NullText() {
+	// поля обязательны, null отклоняется до вызова приложения
+	MockMergePatch(s.ctx, `{"user_id": 0, "text": null}`, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(badID, 10)}})
+	s.ctx.Request.Header.Set("Accept", problemContentType)
+	patchAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+
+	var problem problemResponse
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &problem))
+	assert.Equal(s.T(), []problemField{{Field: "text", Code: "null_field", Detail: "text can't be null, fields can't be cleared"}},
+		problem.Errors)
+}
+
+func (s *httpAppSuite) Test_PatchAd_BadType() {
+	MockMergePatch(s.ctx, `{"user_id": 0, "title": 5}`, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(tAd.ID, 10)}})
+	s.ctx.Request.Header.Set("Accept", problemContentType)
+	patchAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+
+	var problem problemResponse
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &problem))
+	assert.Len(s.T(), problem.Errors, 1)
+	assert.Equal(s.T(), "title", problem.Errors[0].Field)
+}
+
 func (s *httpAppSuite) Test_UpdateAdInvalidBody() {
 	body := map[string]any{
 		"user_id": "кккк",
@@ -435,6 +543,18 @@ func (s *httpAppSuite) Test_DeleteAd_InvalidUserID() {
 	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
 }
 
+func (s *httpAppSuite) Test_DeleteAd_NotFound() {
+	s.app.
+		On("RemoveAd", mock.AnythingOfType("*gin.Context"), badID, tUser.ID).
+		Return(util.ErrNotFound)
+
+	MockJsonDelete(s.ctx, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(badID, 10)}}, url.Values{
+		"user_id": []string{strconv.FormatInt(tUser.ID, 10)},
+	})
+	deleteAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
+}
+
 func (s *httpAppSuite) Test_getAdsByFilter() {
 	newAD := tAd
 	newAD.Published = true
@@ -494,6 +614,33 @@ func (s *httpAppSuite) Test_createUser() {
 
 }
 
+func (s *httpAppSuite) Test_createUser_Conflict() {
+	mApp := new(mocks.App)
+	mApp.
+		On("CreateUser", mock.AnythingOfType("*gin.Context"), tUser.Nickname, tUser.Email).
+		Return(emptyUser, userrepo.ErrConflict)
+
+	MockJsonPost(s.ctx, tUser)
+	createUser(mApp)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusConflict, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_getUserByNickname() {
+	s.app.
+		On("GetUserByNickname", mock.AnythingOfType("*gin.Context"), tUser.Nickname).
+		Return(&tUser, nil)
+
+	MockJsonGet(s.ctx, gin.Params{}, url.Values{"nickname": {tUser.Nickname}})
+	getUserByNickname(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_getUserByNickname_Empty() {
+	MockJsonGet(s.ctx, gin.Params{}, url.Values{})
+	getUserByNickname(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
 func (s *httpAppSuite) Test_createUser_InvalidUser() {
 	body := map[string]any{
 		"nickname": time.Now().UTC().String(),
@@ -503,6 +650,46 @@ func (s *httpAppSuite) Test_createUser_InvalidUser() {
 	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
 }
 
+func (s *httpAppSuite) Test_verifyUser() {
+	vUser := tUser
+	vUser.Verified = true
+	s.app.
+		On("VerifyUser", mock.AnythingOfType("*gin.Context"), "token").
+		Return(&vUser, nil)
+
+	MockJsonPost(s.ctx, map[string]any{"token": "token"})
+	verifyUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_verifyUser_BadToken() {
+	s.app.
+		On("VerifyUser", mock.AnythingOfType("*gin.Context"), wrongMoreStr).
+		Return(emptyUser, util.ErrBadToken)
+
+	MockJsonPost(s.ctx, map[string]any{"token": wrongMoreStr})
+	verifyUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_ChangeAdStatus_NotVerified() {
+	mApp := new(mocks.App)
+	body := map[string]any{
+		"user_id":   tUser.ID,
+		"published": nPublished,
+	}
+	mApp.
+		On("GetUserByID", mock.AnythingOfType("*gin.Context"), testID).
+		Return(&tUser, nil)
+	mApp.
+		On("ChangeAdStatus", mock.AnythingOfType("*gin.Context"), tAd.ID, tAd.AuthorID, nPublished).
+		Return(&tAd, service.ErrNotVerified)
+
+	MockJsonPut(s.ctx, body, gin.Params{{Key: "ad_id", Value: strconv.FormatInt(tAd.ID, 10)}})
+	changeAdStatus(mApp)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
 func (s *httpAppSuite) Test_getUserByID() {
 	s.app.
 		On("GetUserByID", mock.AnythingOfType("*gin.Context"), tUser.ID).
@@ -548,12 +735,38 @@ func (s *httpAppSuite) Test_updateUser() {
 
 }
 
+func (s *httpAppSuite) Test_patchUser() {
+	nUser := tUser
+	nUser.Email = "patched@mail.ru"
+	s.app.
+		On("PatchUser", mock.AnythingOfType("*gin.Context"), tUser.ID, service.UserPatch{Email: &nUser.Email}).
+		Return(&nUser, nil)
+
+	MockMergePatch(s.ctx, `{"email": "patched@mail.ru"}`, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}})
+	patchUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_patchUser_NullNickname() {
+	MockMergePatch(s.ctx, `{"nickname": null}`, gin.Params{{Key: "user_id", Value: strconv.FormatInt(badID, 10)}})
+	s.ctx.Request.Header.Set("Accept", problemContentType)
+	patchUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), "null_field")
+}
+
 func (s *httpAppSuite) Test_updateUser_InvalidUserID() {
 	MockJsonPut(s.ctx, 4, gin.Params{{Key: "user_id", Value: wrongMoreStr}})
 	updateUser(s.app)(s.ctx)
 	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
 }
 
+func (s *httpAppSuite) Test_updateUser_BadUserID() {
+	MockJsonPut(s.ctx, map[string]any{"nickname": tUser.Nickname}, gin.Params{{Key: "user_id", Value: wrongMoreStr}})
+	updateUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
 func (s *httpAppSuite) Test_updateUser_InvalidID() {
 	body := map[string]any{
 		"nickname": tUser.Nickname,
@@ -565,12 +778,12 @@ func (s *httpAppSuite) Test_updateUser_InvalidID() {
 
 	MockJsonPut(s.ctx, body, gin.Params{{Key: "user_id", Value: strconv.FormatInt(badID, 10)}})
 	updateUser(s.app)(s.ctx)
-	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
 }
 
 func (s *httpAppSuite) Test_deleteUser() {
 	s.app.
-		On("RemoveUser", mock.AnythingOfType("*gin.Context"), tUser.ID).
+		On("RemoveUserWithAds", mock.AnythingOfType("*gin.Context"), tUser.ID, app.CascadeAds, int64(0)).
 		Return(nil)
 
 	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, nil)
@@ -579,6 +792,52 @@ func (s *httpAppSuite) Test_deleteUser() {
 
 }
 
+func (s *httpAppSuite) Test_deleteUser_Transfer() {
+	newOwnerID := tUser.ID + 1
+	s.app.
+		On("RemoveUserWithAds", mock.AnythingOfType("*gin.Context"), tUser.ID, app.TransferAds, newOwnerID).
+		Return(nil)
+
+	u := url.Values{}
+	u.Set("ads", "transfer")
+	u.Set("new_owner_id", strconv.FormatInt(newOwnerID, 10))
+	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	deleteUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_deleteUser_TransferNotVerified() {
+	newOwnerID := tUser.ID + 2
+	s.app.
+		On("RemoveUserWithAds", mock.AnythingOfType("*gin.Context"), tUser.ID, app.TransferAds, newOwnerID).
+		Return(service.ErrNotVerified)
+
+	u := url.Values{}
+	u.Set("ads", "transfer")
+	u.Set("new_owner_id", strconv.FormatInt(newOwnerID, 10))
+	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	deleteUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_deleteUser_NotFound() {
+	s.app.
+		On("RemoveUserWithAds", mock.AnythingOfType("*gin.Context"), badID, app.CascadeAds, int64(0)).
+		Return(userrepo.ErrEmptyUser)
+
+	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(badID, 10)}}, nil)
+	deleteUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_deleteUser_BadPolicy() {
+	u := url.Values{}
+	u.Set("ads", "keep")
+	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	deleteUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
 func (s *httpAppSuite) Test_deleteUser_InvalidUserID() {
 	MockJsonDelete(s.ctx, gin.Params{{Key: "user_id", Value: wrongMoreStr}}, nil)
 	deleteUser(s.app)(s.ctx)
@@ -626,6 +885,14 @@ func MockJsonPut(c *gin.Context, content any, params gin.Params) {
 	c.Request.Body = io.NopCloser(bytes.NewBuffer(response))
 }
 
+// MockMergePatch тело передается как есть, чтобы в нем можно было указать null
+func MockMergePatch(c *gin.Context, body string, params gin.Params) {
+	c.Request.Method = "PATCH"
+	c.Request.Header.Set("Content-Type", "application/merge-patch+json")
+	c.Params = params
+	c.Request.Body = io.NopCloser(strings.NewReader(body))
+}
+
 func GetTestGinContext(w *httptest.ResponseRecorder) *gin.Context {
 	gin.SetMode(gin.TestMode)
 
@@ -693,3 +960,485 @@ func unmarshalResponseList(response adListResp) []entities.Ad {
 	}
 	return ads
 }
+
+func (s *httpAppSuite) Test_exportUser() {
+	export := &app.UserExport{Profile: tUser, Ads: []entities.Ad{tAd}}
+	s.app.
+		On("ExportUserData", mock.AnythingOfType("*gin.Context"), tUser.ID, tUser.ID).
+		Return(export, nil)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	exportUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Equal(s.T(), "application/json", s.recorder.Header().Get("Content-Type"))
+
+	var act app.UserExport
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &act))
+	assert.Equal(s.T(), tUser, act.Profile)
+}
+
+func (s *httpAppSuite) Test_exportUser_Forbidden() {
+	s.app.
+		On("ExportUserData", mock.AnythingOfType("*gin.Context"), badID, tUser.ID).
+		Return(&app.UserExport{}, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(badID, 10))
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	exportUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_exportUser_BadFormat() {
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	u.Set("format", "xml")
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}, u)
+	exportUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_eraseUser() {
+	erased := entities.User{ID: tUser.ID, Nickname: "erased", Email: "erased@erased.invalid"}
+	s.app.
+		On("EraseUser", mock.AnythingOfType("*gin.Context"), tUser.ID, tUser.ID).
+		Return(&erased, nil)
+
+	body := map[string]any{"requester_id": tUser.ID}
+	MockJsonPost(s.ctx, body)
+	s.ctx.Params = gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}
+	eraseUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_eraseUser_Forbidden() {
+	s.app.
+		On("EraseUser", mock.AnythingOfType("*gin.Context"), badID, tUser.ID).
+		Return(emptyUser, app.ErrForbidden)
+
+	body := map[string]any{"requester_id": badID}
+	MockJsonPost(s.ctx, body)
+	s.ctx.Params = gin.Params{{Key: "user_id", Value: strconv.FormatInt(tUser.ID, 10)}}
+	eraseUser(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_batchAds_Create() {
+	ads := []service.NewAd{{Title: tAd.Title, Text: tAd.Text, AuthorID: tUser.ID}}
+	s.app.
+		On("CreateAds", mock.AnythingOfType("*gin.Context"), ads, true).
+		Return([]service.BatchResult{{Ad: &tAd}}, nil)
+
+	body := map[string]any{
+		"items":          []map[string]any{{"title": tAd.Title, "text": tAd.Text, "user_id": tUser.ID}},
+		"all_or_nothing": true,
+	}
+	MockJsonPost(s.ctx, body)
+	s.ctx.Params = gin.Params{{Key: "method", Value: ":batchCreate"}}
+	batchAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_batchAds_UpdateStatusAborted() {
+	changes := []service.AdStatusChange{{AdID: tAd.ID, AuthorID: badID, Published: true}}
+	s.app.
+		On("ChangeAdsStatus", mock.AnythingOfType("*gin.Context"), changes, true).
+		Return([]service.BatchResult{{Err: ValidationAds.ErrBadAuthorID}}, service.ErrBatchAborted)
+
+	body := map[string]any{
+		"items":          []map[string]any{{"ad_id": tAd.ID, "user_id": badID, "published": true}},
+		"all_or_nothing": true,
+	}
+	MockJsonPost(s.ctx, body)
+	s.ctx.Params = gin.Params{{Key: "method", Value: ":batchUpdateStatus"}}
+	batchAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusConflict, s.recorder.Code)
+
+	var act struct {
+		Data []batchItemResponse `json:"data"`
+	}
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &act))
+	assert.Equal(s.T(), http.StatusForbidden, act.Data[0].Status)
+}
+
+func (s *httpAppSuite) Test_batchAds_DeleteTooLarge() {
+	refs := []service.AdRef{{AdID: badID, AuthorID: badID}}
+	s.app.
+		On("RemoveAds", mock.AnythingOfType("*gin.Context"), refs, false).
+		Return(nil, service.ErrBatchTooLarge)
+
+	body := map[string]any{"items": []map[string]any{{"ad_id": badID, "user_id": badID}}}
+	MockJsonPost(s.ctx, body)
+	s.ctx.Params = gin.Params{{Key: "method", Value: ":batchDelete"}}
+	batchAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_batchAds_UnknownMethod() {
+	MockJsonPost(s.ctx, map[string]any{})
+	s.ctx.Params = gin.Params{{Key: "method", Value: ":batchPublish"}}
+	batchAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_importAds() {
+	report := &app.ImportReport{DryRun: true, Total: 1, Valid: 1, Rows: []app.ImportRowReport{{Line: 2}}}
+	s.app.
+		On("ImportAds", mock.AnythingOfType("*gin.Context"), mock.Anything, adfile.CSV, true).
+		Return(report, nil)
+
+	s.ctx.Request.Method = "POST"
+	s.ctx.Request.Header.Set("Content-Type", "text/csv")
+	s.ctx.Request.URL.RawQuery = "format=csv&dry_run=true"
+	s.ctx.Request.Body = io.NopCloser(strings.NewReader("title,text,user_id\nhello,world,0\n"))
+	importAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+
+	var act struct {
+		Data app.ImportReport `json:"data"`
+	}
+	assert.NoError(s.T(), json.Unmarshal(s.recorder.Body.Bytes(), &act))
+	assert.Equal(s.T(), *report, act.Data)
+}
+
+func (s *httpAppSuite) Test_importAds_BadHeader() {
+	s.app.
+		On("ImportAds", mock.AnythingOfType("*gin.Context"), mock.Anything, adfile.CSV, false).
+		Return(&app.ImportReport{}, adfile.ErrBadHeader)
+
+	s.ctx.Request.Method = "POST"
+	s.ctx.Request.URL.RawQuery = "format=csv"
+	s.ctx.Request.Body = io.NopCloser(strings.NewReader("title\n"))
+	importAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_importAds_BadFormat() {
+	s.ctx.Request.Method = "POST"
+	s.ctx.Request.URL.RawQuery = "format=xml"
+	s.ctx.Request.Body = io.NopCloser(strings.NewReader(""))
+	importAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_exportAds() {
+	s.app.
+		On("ExportAds", mock.AnythingOfType("*gin.Context"), mock.AnythingOfType("service.AdFilters"), adfile.CSV, mock.Anything).
+		Run(func(args mock.Arguments) {
+			_, _ = args.Get(3).(io.Writer).Write([]byte("id,title\n"))
+		}).
+		Return(nil)
+
+	u := url.Values{}
+	u.Set("format", "csv")
+	MockJsonGet(s.ctx, nil, u)
+	exportAds(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Equal(s.T(), "text/csv", s.recorder.Header().Get("Content-Type"))
+	assert.Equal(s.T(), "id,title\n", s.recorder.Body.String())
+}
+
+func (s *httpAppSuite) Test_adEvents() {
+	events := make(chan service.AdEvent, 1)
+	events <- service.AdEvent{ID: 3, Type: service.AdStatusChanged, Ad: tAd}
+	close(events)
+	s.app.
+		On("ResumeAds", mock.Anything, dFilters, int64(-1)).
+		Return(&service.AdWatch{
+			Snapshot: []entities.Ad{tAd},
+			Missed:   []service.AdEvent{{ID: 2, Type: service.AdCreated, Ad: tAd}},
+			Head:     1,
+			Events:   events,
+		}, nil)
+
+	MockJsonGet(s.ctx, nil, url.Values{})
+	adEvents(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Equal(s.T(), "text/event-stream", s.recorder.Header().Get("Content-Type"))
+
+	body := s.recorder.Body.String()
+	assert.NotContains(s.T(), body, "event:reset")
+	for _, part := range []string{"event:snapshot\n", "id:1\nevent:synced\n", "id:2\nevent:created\n", "id:3\nevent:status\n"} {
+		assert.Contains(s.T(), body, part)
+	}
+	assert.Less(s.T(), strings.Index(body, "event:created"), strings.Index(body, "event:synced"))
+	assert.Less(s.T(), strings.Index(body, "event:synced"), strings.Index(body, "event:status"))
+}
+
+func (s *httpAppSuite) Test_adEvents_Reset() {
+	events := make(chan service.AdEvent)
+	close(events)
+	s.app.
+		On("ResumeAds", mock.Anything, dFilters, int64(5)).
+		Return(&service.AdWatch{Snapshot: []entities.Ad{tAd}, Head: 1, Reset: true, Events: events}, nil)
+
+	MockJsonGet(s.ctx, nil, url.Values{})
+	s.ctx.Request.Header.Set("Last-Event-ID", "5")
+	adEvents(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.True(s.T(), strings.HasPrefix(s.recorder.Body.String(), "event:reset\n"))
+}
+
+func (s *httpAppSuite) Test_adEvents_BadLastEventID() {
+	MockJsonGet(s.ctx, nil, url.Values{})
+	s.ctx.Request.Header.Set("Last-Event-ID", "abc")
+	adEvents(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_outboxEntries() {
+	entries := []outbox.Entry{{ID: "abc", Event: "ad.created", Status: outbox.Failed, Payload: []byte(`{}`)}}
+	s.app.
+		On("OutboxEntries", mock.AnythingOfType("*gin.Context"), tUser.ID, outbox.Failed).
+		Return(entries, nil)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	u.Set("status", "failed")
+	MockJsonGet(s.ctx, nil, u)
+	outboxEntries(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"id":"abc"`)
+}
+
+func (s *httpAppSuite) Test_outboxEntries_Forbidden() {
+	s.app.
+		On("OutboxEntries", mock.AnythingOfType("*gin.Context"), badID, outbox.Status("")).
+		Return(nil, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(badID, 10))
+	MockJsonGet(s.ctx, nil, u)
+	outboxEntries(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_replayOutboxEntry() {
+	s.app.
+		On("ReplayOutboxEntry", mock.AnythingOfType("*gin.Context"), tUser.ID, "abc").
+		Return(&outbox.Entry{ID: "abc", Status: outbox.Pending}, nil)
+	s.app.
+		On("ReplayOutboxEntry", mock.AnythingOfType("*gin.Context"), tUser.ID, "done").
+		Return(&outbox.Entry{ID: "done", Status: outbox.Delivered}, outbox.ErrDelivered)
+
+	MockJsonPost(s.ctx, map[string]any{"requester_id": tUser.ID})
+	s.ctx.Params = gin.Params{{Key: "entry_id", Value: "abc"}}
+	replayOutboxEntry(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+
+	s.SetupTest()
+	MockJsonPost(s.ctx, map[string]any{"requester_id": tUser.ID})
+	s.ctx.Params = gin.Params{{Key: "entry_id", Value: "done"}}
+	replayOutboxEntry(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusConflict, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_createWebhook() {
+	hookURL := "https://partner.example/hook"
+	eventTypes := []string{"ad.created"}
+	s.app.
+		On("CreateWebhook", mock.AnythingOfType("*gin.Context"), tUser.ID, hookURL, "0123456789abcdef", eventTypes).
+		Return(&webhook.Subscription{ID: 3, URL: hookURL, Secret: "0123456789abcdef", Events: eventTypes}, nil)
+	s.app.
+		On("CreateWebhook", mock.AnythingOfType("*gin.Context"), tUser.ID, "ftp://partner.example", "", eventTypes).
+		Return(&webhook.Subscription{}, webhook.ErrBadURL)
+
+	MockJsonPost(s.ctx, map[string]any{"requester_id": tUser.ID, "url": hookURL, "secret": "0123456789abcdef", "events": eventTypes})
+	createWebhook(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"secret":"0123456789abcdef"`)
+
+	s.SetupTest()
+	MockJsonPost(s.ctx, map[string]any{"requester_id": tUser.ID, "url": "ftp://partner.example", "events": eventTypes})
+	createWebhook(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_webhooks() {
+	s.app.
+		On("Webhooks", mock.AnythingOfType("*gin.Context"), tUser.ID).
+		Return([]webhook.Subscription{{ID: 3, URL: "https://partner.example/hook", Secret: "hidden secret"}}, nil)
+	s.app.
+		On("Webhooks", mock.AnythingOfType("*gin.Context"), badID).
+		Return(nil, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	MockJsonGet(s.ctx, nil, u)
+	webhooks(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"url":"https://partner.example/hook"`)
+	assert.NotContains(s.T(), s.recorder.Body.String(), "hidden secret")
+
+	s.SetupTest()
+	u.Set("requester_id", strconv.FormatInt(badID, 10))
+	MockJsonGet(s.ctx, nil, u)
+	webhooks(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_deleteWebhook() {
+	s.app.
+		On("DeleteWebhook", mock.AnythingOfType("*gin.Context"), tUser.ID, int64(3)).
+		Return(nil)
+	s.app.
+		On("DeleteWebhook", mock.AnythingOfType("*gin.Context"), tUser.ID, int64(4)).
+		Return(webhook.ErrNotFound)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	MockJsonDelete(s.ctx, gin.Params{{Key: "webhook_id", Value: "3"}}, u)
+	deleteWebhook(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+
+	s.SetupTest()
+	MockJsonDelete(s.ctx, gin.Params{{Key: "webhook_id", Value: "4"}}, u)
+	deleteWebhook(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
+
+	s.SetupTest()
+	MockJsonDelete(s.ctx, gin.Params{{Key: "webhook_id", Value: "abc"}}, u)
+	deleteWebhook(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_webhookDeliveries() {
+	s.app.
+		On("WebhookDeliveries", mock.AnythingOfType("*gin.Context"), tUser.ID, int64(3)).
+		Return([]webhook.Delivery{{EventID: "abc", Attempt: 2, StatusCode: http.StatusOK}}, nil)
+	s.app.
+		On("WebhookDeadLetters", mock.AnythingOfType("*gin.Context"), badID, int64(3)).
+		Return(nil, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	MockJsonGet(s.ctx, gin.Params{{Key: "webhook_id", Value: "3"}}, u)
+	webhookDeliveries(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"attempt":2`)
+
+	s.SetupTest()
+	u.Set("requester_id", strconv.FormatInt(badID, 10))
+	MockJsonGet(s.ctx, gin.Params{{Key: "webhook_id", Value: "3"}}, u)
+	webhookDeadLetters(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_rebuildProjections() {
+	s.app.
+		On("RebuildProjections", mock.AnythingOfType("*gin.Context"), tUser.ID).
+		Return(&eventrepo.RebuildReport{Events: 3, Projections: map[string]int{"ads": 1}}, nil)
+	s.app.
+		On("RebuildProjections", mock.AnythingOfType("*gin.Context"), badID).
+		Return(&eventrepo.RebuildReport{}, app.ErrNotEventSourced)
+
+	MockJsonPost(s.ctx, map[string]any{"requester_id": tUser.ID})
+	rebuildProjections(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"events":3`)
+
+	s.SetupTest()
+	MockJsonPost(s.ctx, map[string]any{"requester_id": badID})
+	rebuildProjections(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotImplemented, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_adHistory() {
+	s.app.
+		On("AdHistory", mock.AnythingOfType("*gin.Context"), tUser.ID, int64(3)).
+		Return([]eventrepo.Event{{AdID: 3, Version: 1, Type: eventrepo.Created}}, nil)
+	s.app.
+		On("AdHistory", mock.AnythingOfType("*gin.Context"), tUser.ID, int64(4)).
+		Return(nil, util.ErrNotFound)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	MockJsonGet(s.ctx, gin.Params{{Key: "ad_id", Value: "3"}}, u)
+	adHistory(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"type":"created"`)
+
+	s.SetupTest()
+	MockJsonGet(s.ctx, gin.Params{{Key: "ad_id", Value: "4"}}, u)
+	adHistory(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusNotFound, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_auditLog() {
+	actor := int64(7)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s.app.
+		On("AuditLog", mock.AnythingOfType("*gin.Context"), tUser.ID, audit.Filter{Actor: &actor, Target: audit.TargetAd, From: from, Limit: 20}).
+		Return([]audit.Entry{{ID: 1, Actor: actor, Action: "ad.updated", Transport: audit.HTTP}}, nil)
+	s.app.
+		On("AuditLog", mock.AnythingOfType("*gin.Context"), badID, audit.Filter{}).
+		Return(nil, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", strconv.FormatInt(tUser.ID, 10))
+	u.Set("actor", "7")
+	u.Set("target", audit.TargetAd)
+	u.Set("from", from.Format(time.RFC3339))
+	u.Set("limit", "20")
+	MockJsonGet(s.ctx, nil, u)
+	auditLog(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"transport":"http"`)
+
+	s.SetupTest()
+	u = url.Values{}
+	u.Set("requester_id", strconv.FormatInt(badID, 10))
+	MockJsonGet(s.ctx, nil, u)
+	auditLog(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+
+	s.SetupTest()
+	u.Set("from", "yesterday")
+	MockJsonGet(s.ctx, nil, u)
+	auditLog(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
+
+func (s *httpAppSuite) Test_CreateAd_QuotaExceeded() {
+	s.app.
+		On("CreateAd", mock.AnythingOfType("*gin.Context"), "Quota", tAd.Text, tAd.ID).
+		Return(&tAd, &quota.ExceededError{Kind: quota.DailyAds, Plan: "free", Limit: 20, RetryAfter: 90 * time.Minute})
+
+	MockJsonPost(s.ctx, map[string]any{"user_id": tAd.AuthorID, "title": "Quota", "text": tAd.Text})
+	createAd(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusTooManyRequests, s.recorder.Code)
+	assert.Equal(s.T(), "5400", s.recorder.Header().Get(retryAfterHeader))
+
+	// лимит опубликованных объявлений сам не восстановится
+	assert.Equal(s.T(), http.StatusForbidden, httpStatus(&quota.ExceededError{Kind: quota.ActiveAds, Plan: "free", Limit: 10}))
+}
+
+func (s *httpAppSuite) Test_userQuota() {
+	usage := &quota.Usage{Plan: quota.Free, ActiveAds: 2, DailyAds: 3}
+	s.app.
+		On("UserQuota", mock.AnythingOfType("*gin.Context"), int64(5), int64(5)).
+		Return(usage, nil)
+	s.app.
+		On("UserQuota", mock.AnythingOfType("*gin.Context"), int64(-1), int64(5)).
+		Return(nil, app.ErrForbidden)
+
+	u := url.Values{}
+	u.Set("requester_id", "5")
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: "5"}}, u)
+	userQuota(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusOK, s.recorder.Code)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"plan":{"name":"free","max_active_ads":10,"max_daily_ads":20}`)
+	assert.Contains(s.T(), s.recorder.Body.String(), `"daily_ads":3`)
+
+	s.SetupTest()
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: "5"}}, url.Values{})
+	userQuota(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusForbidden, s.recorder.Code)
+
+	s.SetupTest()
+	MockJsonGet(s.ctx, gin.Params{{Key: "user_id", Value: "x"}}, u)
+	userQuota(s.app)(s.ctx)
+	assert.EqualValues(s.T(), http.StatusBadRequest, s.recorder.Code)
+}
@@ -0,0 +1,131 @@
+package httpgin
+
+import (
+	grpc2 "homework10/internal/ports/grpc"
+)
+
+// Запросы в protobuf приходят сообщениями из service.proto. ad_id и id в сообщениях не учитываются,
+// идентификатор берется из пути
+
+func (r *createAdRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.CreateAdRequest) error {
+		*r = createAdRequest{Title: m.Title, Text: m.Text, UserID: m.UserId}
+		return nil
+	})
+}
+
+func (r *changeAdStatusRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.ChangeAdStatusRequest) error {
+		*r = changeAdStatusRequest{UserID: m.UserId, Published: m.Published}
+		return nil
+	})
+}
+
+// bindProto PUT заменяет оба поля, update_mask не учитывается
+func (r *updateAdRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.UpdateAdRequest) error {
+		*r = updateAdRequest{UserID: m.UserId, Title: m.Title, Text: m.Text}
+		return nil
+	})
+}
+
+// bindProtoPatch без update_mask меняются оба поля, как в gRPC
+func (r *patchAdRequest) bindProtoPatch(data []byte) (mergeFields, error) {
+	var fields mergeFields
+	err := fromProto(data, func(m *grpc2.UpdateAdRequest) (err error) {
+		*r = patchAdRequest{UserID: m.UserId, Title: &m.Title, Text: &m.Text}
+		paths := m.GetUpdateMask().GetPaths()
+		if m.UpdateMask == nil {
+			paths = []string{"*"}
+		}
+		fields, err = maskFields(paths, "title", "text")
+		return err
+	})
+	return fields, err
+}
+
+func (r *batchCreateAdsRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.BatchCreateAdsRequest) error {
+		*r = batchCreateAdsRequest{Items: make([]createAdRequest, 0, len(m.Items)), AllOrNothing: m.AllOrNothing}
+		for _, item := range m.Items {
+			r.Items = append(r.Items, createAdRequest{Title: item.Title, Text: item.Text, UserID: item.UserId})
+		}
+		return nil
+	})
+}
+
+func (r *batchUpdateAdStatusRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.BatchUpdateAdStatusRequest) error {
+		*r = batchUpdateAdStatusRequest{Items: make([]batchAdStatusItem, 0, len(m.Items)), AllOrNothing: m.AllOrNothing}
+		for _, item := range m.Items {
+			r.Items = append(r.Items, batchAdStatusItem{AdID: item.AdId, UserID: item.UserId, Published: item.Published})
+		}
+		return nil
+	})
+}
+
+func (r *batchDeleteAdsRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.BatchDeleteAdsRequest) error {
+		*r = batchDeleteAdsRequest{Items: make([]batchAdRefItem, 0, len(m.Items)), AllOrNothing: m.AllOrNothing}
+		for _, item := range m.Items {
+			r.Items = append(r.Items, batchAdRefItem{AdID: item.AdId, UserID: item.AuthorId})
+		}
+		return nil
+	})
+}
+
+func (r *createUserRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.UserRequest) error {
+		*r = createUserRequest{Nickname: m.Nickname, Email: m.Email}
+		return nil
+	})
+}
+
+func (r *UpdateUserRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.UserUpdateRequest) error {
+		*r = UpdateUserRequest{Nickname: m.Nickname, Email: m.Email}
+		return nil
+	})
+}
+
+// bindProtoPatch без update_mask пустые поля не меняются, как в gRPC
+func (r *patchUserRequest) bindProtoPatch(data []byte) (mergeFields, error) {
+	var fields mergeFields
+	err := fromProto(data, func(m *grpc2.UserUpdateRequest) (err error) {
+		*r = patchUserRequest{Nickname: &m.Nickname, Email: &m.Email}
+		if m.UpdateMask != nil {
+			fields, err = maskFields(m.UpdateMask.Paths, "nickname", "email")
+			return err
+		}
+		fields = make(mergeFields, 2)
+		if m.Nickname != "" {
+			fields["nickname"] = nil
+		}
+		if m.Email != "" {
+			fields["email"] = nil
+		}
+		return nil
+	})
+	return fields, err
+}
+
+func (r *verifyUserRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.VerifyUserRequest) error {
+		*r = verifyUserRequest{Token: m.Token}
+		return nil
+	})
+}
+
+func (r *eraseUserRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.EraseUserRequest) error {
+		*r = eraseUserRequest{RequesterID: m.RequesterId}
+		return nil
+	})
+}
+
+func (r *createWebhookRequest) bindProto(data []byte) error {
+	return fromProto(data, func(m *grpc2.CreateWebhookRequest) error {
+		*r = createWebhookRequest{RequesterID: m.RequesterId, URL: m.Url, Secret: m.Secret, Events: m.Events}
+		return nil
+	})
+}
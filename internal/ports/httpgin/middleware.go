@@ -1,9 +1,19 @@
 package httpgin
 
 import (
+	"bytes"
 	"errors"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
+	"io"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -34,9 +44,165 @@ func RecoveryMiddleware(logger *log.Logger) gin.HandlerFunc {
 			if r := recover(); r != nil {
 				logger.Printf("PANIC ERROR: %v\n", r)
 
-				c.JSON(http.StatusInternalServerError, ErrorResponse(errors.New("internal server error")))
+				writeError(c, errors.New("internal server error"))
 			}
 		}()
 		c.Next()
 	}
 }
+
+// AuditMiddleware кладет в контекст запроса адрес клиента для журнала аудита, X-Forwarded-For
+// учитывается только от доверенных прокси, см. WithTrustedProxies. Обработчики передают в App *gin.Context, поэтому у движка должен быть включен ContextWithFallback
+func AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		origin := audit.Origin{Transport: audit.HTTP, IP: c.ClientIP()}
+		c.Request = c.Request.WithContext(audit.WithOrigin(c.Request.Context(), origin))
+		c.Next()
+	}
+}
+
+// ClientAddrMiddleware подставляет в RemoteAddr адрес клиента с учетом доверенных прокси
+// для обработчиков вне gin, которые берут адрес из запроса, например для шлюза /api/v2
+func ClientAddrMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, port, err := net.SplitHostPort(c.Request.RemoteAddr)
+		if err != nil {
+			port = "0"
+		}
+		c.Request.RemoteAddr = net.JoinHostPort(c.ClientIP(), port)
+		c.Next()
+	}
+}
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader отмечает ответ, сохраненный при первом запросе
+	idempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// storedResponse ответ, который повторяется на запрос с тем же Idempotency-Key
+type storedResponse struct {
+	status      int
+	contentType string
+	body        []byte
+}
+
+// recordingWriter копирует тело ответа, чтобы сохранить его для повторов
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// IdempotencyMiddleware запоминает успешный ответ на запрос с заголовком Idempotency-Key и отдает его на повторы.
+// Тот же ключ с другим телом запроса или другим форматом ответа по Accept - 422, пока первый запрос выполняется - 409.
+// Неуспешный ответ не сохраняется: клиент может повторить запрос с тем же ключом
+func IdempotencyMiddleware(store idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			writeError(c, badRequest(err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		// адрес клиента в scope не входит: мобильный клиент повторяет запрос уже из другой сети.
+		// Чужой ответ по тому же ключу не вернется, отпечаток связывает ключ с телом запроса вместе с user_id
+		scope := c.Request.Method + " " + c.FullPath()
+		stored, replay, err := store.Begin(scope, key, idempotency.Fingerprint([]byte(scope), []byte(responseFormat(c)), idempotency.CanonicalJSON(body)))
+		if err != nil {
+			writeError(c, err)
+			c.Abort()
+			return
+		}
+		if replay {
+			response := stored.(storedResponse)
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(response.status, response.contentType, response.body)
+			c.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		// Release и при панике обработчика, иначе ключ останется занятым до истечения TTL
+		defer func() {
+			if !completed {
+				store.Release(scope, key)
+			}
+		}()
+		c.Next()
+
+		if status := writer.Status(); status >= http.StatusOK && status < http.StatusMultipleChoices {
+			store.Complete(scope, key, storedResponse{
+				status:      status,
+				contentType: writer.Header().Get("Content-Type"),
+				body:        bytes.Clone(writer.body.Bytes()),
+			})
+			completed = true
+		}
+	}
+}
+
+const retryAfterHeader = "Retry-After"
+
+var ErrRateLimited = apperr.New(apperr.ResourceExhausted, "rate_limited", "rate limit exceeded")
+
+// DefaultRateLimits ограничивает только создание объявлений и пользователей и повторную отправку письма,
+// остальные маршруты без лимита
+var DefaultRateLimits = ratelimit.Rules{
+	"POST /ads":                         ratelimit.CreateAd,
+	"POST /ads:method":                  ratelimit.BatchAds,
+	"POST /ads/import":                  ratelimit.ImportAds,
+	"POST /users":                       ratelimit.CreateUser,
+	"POST /users/:user_id/verification": ratelimit.ResendVerification,
+}
+
+// RateLimitMiddleware ограничивает частоту запросов к маршрутам из rules, маршрут задается как "POST /ads" без basePath.
+// Запросы считаются отдельно для каждого адреса клиента, см. clientKey.
+// Сверх лимита - 429 и Retry-After в секундах
+func RateLimitMiddleware(limiter ratelimit.Limiter, rules ratelimit.Rules, basePath string) gin.HandlerFunc {
+	basePath = strings.TrimSuffix(basePath, "/")
+	return func(c *gin.Context) {
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		route := c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), basePath)
+		limit, ok := rules.Lookup(route)
+		if !ok {
+			c.Next()
+			return
+		}
+		allowed, retryAfter := limiter.Allow(limit.Key(route, clientKey(c)), limit)
+		if !allowed {
+			c.Header(retryAfterHeader, strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			writeError(c, ErrRateLimited)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientKey адрес клиента с учетом доверенных прокси. requester_id и user_id из запроса не учитываются:
+// их присылает сам клиент, и по ним можно было бы обойти чужой или свой лимит
+func clientKey(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
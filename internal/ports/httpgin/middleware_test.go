@@ -3,9 +3,14 @@ package httpgin
 import (
 	"bytes"
 	"github.com/stretchr/testify/assert"
+	"homework10/internal/audit"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
+	"io"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -45,3 +50,194 @@ func TestRecoveryMiddleware(t *testing.T) {
 	assert.Equal(t, "/panic", req.URL.Path)
 	assert.NotEqual(t, len(logOutput.String()), 0)
 }
+
+func TestAuditMiddleware(t *testing.T) {
+	router := gin.New()
+	router.ContextWithFallback = true
+	router.Use(AuditMiddleware())
+	var origin audit.Origin
+	router.GET("/origin", func(c *gin.Context) {
+		origin = audit.OriginFrom(c)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/origin", nil)
+	req.RemoteAddr = "10.0.0.3:4567"
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, audit.Origin{Transport: audit.HTTP, IP: "10.0.0.3"}, origin)
+}
+
+func TestAuditMiddleware_TrustedProxies(t *testing.T) {
+	router := gin.New()
+	router.ContextWithFallback = true
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.100"}))
+	router.Use(AuditMiddleware())
+	var origin audit.Origin
+	router.GET("/origin", func(c *gin.Context) {
+		origin = audit.OriginFrom(c)
+	})
+	ip := func(remote string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/origin", nil)
+		req.RemoteAddr = remote + ":4567"
+		req.Header.Set("X-Forwarded-For", "192.0.2.1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		return origin.IP
+	}
+
+	assert.Equal(t, "192.0.2.1", ip("10.0.0.100"))
+	// клиент не может подменить свой адрес заголовком
+	assert.Equal(t, "10.0.0.3", ip("10.0.0.3"))
+}
+
+func TestClientAddrMiddleware(t *testing.T) {
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.100"}))
+	router.Use(ClientAddrMiddleware())
+	var remote string
+	router.GET("/addr", func(c *gin.Context) {
+		remote = c.Request.RemoteAddr
+	})
+	send := func(remoteAddr string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/addr", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Forwarded-For", "192.0.2.1")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+		return remote
+	}
+
+	assert.Equal(t, "192.0.2.1:4567", send("10.0.0.100:4567"))
+	assert.Equal(t, "10.0.0.3:4567", send("10.0.0.3:4567"))
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	router := gin.New()
+	calls := 0
+	router.POST("/ads", IdempotencyMiddleware(idempotency.NewStore()), func(c *gin.Context) {
+		calls++
+		if c.GetHeader("X-Fail") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"calls": calls})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"calls": calls})
+	})
+	post := func(key, body string, fail ...string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ads", strings.NewReader(body))
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("Idempotency-Key", key)
+		if len(fail) > 0 {
+			req.Header.Set("X-Fail", fail[0])
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := post("key", `{"title":"a","text":"b"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+	assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+
+	// порядок полей и пробелы не меняют запрос
+	w = post("key", `{ "text": "b", "title": "a" }`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	w = post("key", `{"title":"a","text":"c"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	// сохраненный ответ в JSON не отдается клиенту, который просит protobuf
+	w = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/ads", strings.NewReader(`{"title":"a","text":"b"}`))
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Idempotency-Key", "key")
+	req.Header.Set("Accept", mimeProtobuf)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	w = post("bad key", `{}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, calls)
+
+	// неуспешный ответ не сохраняется
+	w = post("other", `{}`, "1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = post("other", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 3, calls)
+
+	// без ключа запрос выполняется каждый раз
+	w = post("", `{"title":"a","text":"b"}`)
+	assert.JSONEq(t, `{"calls":4}`, w.Body.String())
+
+	// повтор с другого адреса, например после смены сети, получает сохраненный ответ
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest(http.MethodPost, "/ads", strings.NewReader(`{"title":"a","text":"b"}`))
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("Idempotency-Key", "key")
+	router.ServeHTTP(w, req)
+	assert.JSONEq(t, `{"calls":1}`, w.Body.String())
+	assert.Equal(t, "true", w.Header().Get(idempotentReplayedHeader))
+	assert.Equal(t, 4, calls)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	router := gin.New()
+	api := router.Group("/api/v1/")
+	api.Use(RateLimitMiddleware(ratelimit.NewLimiter(), ratelimit.Rules{"POST /ads": {Rate: 0.5, Burst: 2}}, api.BasePath()))
+	api.POST("/ads", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(body))
+	})
+	api.GET("/ads", func(c *gin.Context) {})
+	send := func(method, target, body, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		w := send(http.MethodPost, "/api/v1/ads", `{"user_id":1}`, "10.0.0.1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		// обработчик получает тело запроса целиком
+		assert.Equal(t, `{"user_id":1}`, w.Body.String())
+	}
+	// лимит считается по адресу, другой user_id его не обходит
+	w := send(http.MethodPost, "/api/v1/ads", `{"user_id":2}`, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get(retryAfterHeader))
+	w = send(http.MethodPost, "/api/v1/ads?user_id=3", `{}`, "10.0.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	// чужой user_id не тратит лимит его владельца, маршруты без лимита не ограничены
+	w = send(http.MethodPost, "/api/v1/ads", `{"user_id":1}`, "10.0.0.2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send(http.MethodGet, "/api/v1/ads", "", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitMiddleware_TrustedProxies(t *testing.T) {
+	router := gin.New()
+	assert.NoError(t, router.SetTrustedProxies([]string{"10.0.0.100"}))
+	router.Use(RateLimitMiddleware(ratelimit.NewLimiter(), ratelimit.Rules{"POST /ads": {Rate: 0.5, Burst: 1}}, ""))
+	router.POST("/ads", func(c *gin.Context) {})
+	send := func(remote, forwarded string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/ads", nil)
+		req.RemoteAddr = remote + ":1234"
+		req.Header.Set("X-Forwarded-For", forwarded)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// за доверенным прокси клиенты различаются по X-Forwarded-For
+	assert.Equal(t, http.StatusOK, send("10.0.0.100", "192.0.2.1"))
+	assert.Equal(t, http.StatusOK, send("10.0.0.100", "192.0.2.2"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.100", "192.0.2.1"))
+	// от остальных X-Forwarded-For не учитывается
+	assert.Equal(t, http.StatusOK, send("10.0.0.7", "192.0.2.3"))
+	assert.Equal(t, http.StatusTooManyRequests, send("10.0.0.7", "192.0.2.4"))
+}
@@ -0,0 +1,510 @@
+package httpgin
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"homework10/internal/adapters/repository/eventrepo"
+	"homework10/internal/app"
+	"homework10/internal/audit"
+	"homework10/internal/entities"
+	"homework10/internal/outbox"
+	"homework10/internal/quota"
+	"homework10/internal/service"
+	"homework10/internal/webhook"
+	"net/http"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	openAPIVersion        = "3.0.3"
+	mergePatchContentType = "application/merge-patch+json"
+)
+
+// schema готовая схема OpenAPI, в описании операции используется как есть, без разбора типа
+type schema map[string]any
+
+var binarySchema = schema{"type": "string", "format": "binary"}
+
+// operation описание маршрута AppRouter. Схемы параметров, тела и ответа строятся по типам из query, body
+// и data, те же типы разбирают обработчики, поэтому документ не расходится с кодом
+type operation struct {
+	method string
+	// route путь, как он зарегистрирован в gin
+	route string
+	// path путь в документе, если отличается от route
+	path    string
+	tag     string
+	summary string
+	// query структуры с тегами form, как у ShouldBindQuery
+	query []any
+	// headers заголовки из headerParams
+	headers []string
+	// body тело запроса в JSON, bodyTypes - его типы, если это не только application/json
+	body      any
+	bodyTypes []string
+	// bodyContent тело запроса не в JSON: тип - значение или готовая schema, nil - файл
+	bodyContent map[string]any
+	// status код успешного ответа, по умолчанию 200
+	status int
+	// data поле data ответа в конверте {"data": ..., "error": null}
+	data any
+	// content ответ не в конверте, например, файл или поток событий, значения как у bodyContent
+	content map[string]any
+	// bodyMessage и message сообщения service.proto для тела и ответа в protobuf, пусто - protobuf не принимается
+	bodyMessage string
+	message     string
+}
+
+// operations описание каждого маршрута AppRouter, тест проверяет, что ни один маршрут не пропущен
+var operations = []operation{
+	{method: http.MethodGet, route: "/ads/:ad_id", tag: "ads", summary: "Get an ad", data: adResponse{},
+		message: "ad.AdResponse"},
+	{method: http.MethodGet, route: "/ads", tag: "ads", summary: "List ads matching the filters",
+		query: []any{service.AdFilters{}}, data: []adResponse{}, message: "ad.ListAdResponse"},
+	{method: http.MethodGet, route: "/ads/export", tag: "ads", summary: "Export ads matching the filters to a file",
+		query:   []any{service.AdFilters{}, exportAdsRequest{}},
+		content: map[string]any{"application/x-ndjson": nil, "text/csv": nil}},
+	{method: http.MethodGet, route: "/ads/events", tag: "ads", summary: "Stream ad changes as server-sent events",
+		query: []any{service.AdFilters{}}, headers: []string{"Last-Event-ID"},
+		content: map[string]any{"text/event-stream": schema{"type": "string"}}},
+	{method: http.MethodPost, route: "/ads/import", tag: "ads", summary: "Import ads from a file",
+		query: []any{importAdsRequest{}},
+		bodyContent: map[string]any{
+			"application/x-ndjson": nil,
+			"text/csv":             nil,
+			"multipart/form-data":  schema{"type": "object", "properties": map[string]any{"file": binarySchema}},
+		},
+		data: app.ImportReport{}, message: "ad.ImportAdsResponse"},
+	{method: http.MethodPost, route: "/ads", tag: "ads", summary: "Create an ad", headers: []string{idempotencyKeyHeader},
+		body: createAdRequest{}, status: http.StatusCreated, data: adResponse{},
+		bodyMessage: "ad.CreateAdRequest", message: "ad.AdResponse"},
+	{method: http.MethodPut, route: "/ads/:ad_id/status", tag: "ads", summary: "Publish or unpublish an ad",
+		body: changeAdStatusRequest{}, data: adResponse{},
+		bodyMessage: "ad.ChangeAdStatusRequest", message: "ad.AdResponse"},
+	{method: http.MethodPut, route: "/ads/:ad_id", tag: "ads", summary: "Replace the title and text of an ad",
+		body: updateAdRequest{}, data: adResponse{}, bodyMessage: "ad.UpdateAdRequest", message: "ad.AdResponse"},
+	{method: http.MethodPatch, route: "/ads/:ad_id", tag: "ads", summary: "Change the listed fields of an ad, null fields are rejected",
+		body: patchAdRequest{}, bodyTypes: []string{mergePatchContentType, gin.MIMEJSON}, data: adResponse{},
+		bodyMessage: "ad.UpdateAdRequest", message: "ad.AdResponse"},
+	{method: http.MethodDelete, route: "/ads/:ad_id", tag: "ads", summary: "Delete an ad",
+		query: []any{struct {
+			UserID int64 `form:"user_id,query" binding:"required"`
+		}{}},
+		data: struct {
+			AdID     int64 `json:"ad_id"`
+			AuthorID int64 `json:"author_id"`
+		}{}, message: "ad.DeleteAdResponse"},
+	{method: http.MethodPost, route: "/ads:method", path: "/ads:batchCreate", tag: "ads", summary: "Create several ads",
+		body: batchCreateAdsRequest{}, data: []batchItemResponse{},
+		bodyMessage: "ad.BatchCreateAdsRequest", message: "ad.BatchAdsResponse"},
+	{method: http.MethodPost, route: "/ads:method", path: "/ads:batchUpdateStatus", tag: "ads", summary: "Change the status of several ads",
+		body: batchUpdateAdStatusRequest{}, data: []batchItemResponse{},
+		bodyMessage: "ad.BatchUpdateAdStatusRequest", message: "ad.BatchAdsResponse"},
+	{method: http.MethodPost, route: "/ads:method", path: "/ads:batchDelete", tag: "ads", summary: "Delete several ads",
+		body: batchDeleteAdsRequest{}, data: []batchItemResponse{},
+		bodyMessage: "ad.BatchDeleteAdsRequest", message: "ad.BatchAdsResponse"},
+
+	{method: http.MethodGet, route: "/users/:user_id", tag: "users", summary: "Get a user", data: entities.User{},
+		message: "ad.UserResponse"},
+	{method: http.MethodGet, route: "/users", tag: "users", summary: "Find a user by nickname",
+		query: []any{struct {
+			Nickname string `form:"nickname,query" binding:"required"`
+		}{}},
+		data: entities.User{}, message: "ad.UserResponse"},
+	{method: http.MethodPost, route: "/users", tag: "users", summary: "Register a user", headers: []string{idempotencyKeyHeader},
+		body: createUserRequest{}, status: http.StatusCreated, data: entities.User{},
+		bodyMessage: "ad.UserRequest", message: "ad.UserResponse"},
+	{method: http.MethodPost, route: "/users/verify", tag: "users", summary: "Confirm an email with the token from the letter",
+		body: verifyUserRequest{}, data: entities.User{},
+		bodyMessage: "ad.VerifyUserRequest", message: "ad.UserResponse"},
+	{method: http.MethodPost, route: "/users/:user_id/verification", tag: "users", summary: "Send the verification letter again",
+		status: http.StatusAccepted, data: entities.User{}, message: "ad.UserResponse"},
+	{method: http.MethodPut, route: "/users/:user_id", tag: "users", summary: "Change a user, empty fields are left as is",
+		body: UpdateUserRequest{}, data: entities.User{},
+		bodyMessage: "ad.UserUpdateRequest", message: "ad.UserResponse"},
+	{method: http.MethodPatch, route: "/users/:user_id", tag: "users", summary: "Change the listed fields of a user, null fields are rejected",
+		body: patchUserRequest{}, bodyTypes: []string{mergePatchContentType, gin.MIMEJSON}, data: entities.User{},
+		bodyMessage: "ad.UserUpdateRequest", message: "ad.UserResponse"},
+	{method: http.MethodDelete, route: "/users/:user_id", tag: "users", summary: "Delete a user and cascade or transfer the ads",
+		query: []any{deleteUserRequest{}},
+		data: struct {
+			UserID int64 `json:"user_id"`
+		}{}, message: "ad.DeleteUserResponse"},
+	{method: http.MethodGet, route: "/users/:user_id/export", tag: "users", summary: "Export personal data of a user",
+		query:   []any{exportUserRequest{}},
+		content: map[string]any{gin.MIMEJSON: app.UserExport{}, "application/zip": nil}},
+	{method: http.MethodGet, route: "/users/:user_id/quota", tag: "users", summary: "Get the quota usage of a user",
+		query: []any{userQuotaRequest{}}, data: quota.Usage{}},
+	{method: http.MethodPost, route: "/users/:user_id/erase", tag: "users", summary: "Erase personal data of a user",
+		body: eraseUserRequest{}, data: entities.User{},
+		bodyMessage: "ad.EraseUserRequest", message: "ad.UserResponse"},
+
+	{method: http.MethodGet, route: "/admin/outbox", tag: "admin", summary: "List outbox entries",
+		query: []any{outboxEntriesRequest{}}, data: []outbox.Entry{}},
+	{method: http.MethodPost, route: "/admin/outbox/:entry_id/replay", tag: "admin", summary: "Deliver an outbox entry again",
+		body: replayOutboxRequest{}, data: outbox.Entry{}},
+	{method: http.MethodPost, route: "/admin/webhooks", tag: "admin", summary: "Subscribe a webhook, the secret is returned only here",
+		body: createWebhookRequest{}, data: webhookResponse{},
+		bodyMessage: "ad.CreateWebhookRequest", message: "ad.WebhookResponse"},
+	{method: http.MethodGet, route: "/admin/webhooks", tag: "admin", summary: "List webhooks",
+		query: []any{webhooksRequest{}}, data: []webhook.Subscription{}, message: "ad.ListWebhooksResponse"},
+	{method: http.MethodDelete, route: "/admin/webhooks/:webhook_id", tag: "admin", summary: "Delete a webhook",
+		query: []any{webhooksRequest{}},
+		data: struct {
+			WebhookID int64 `json:"webhook_id"`
+		}{}, message: "ad.DeleteWebhookResponse"},
+	{method: http.MethodGet, route: "/admin/webhooks/:webhook_id/deliveries", tag: "admin", summary: "List delivery attempts of a webhook",
+		query: []any{webhooksRequest{}}, data: []webhook.Delivery{}, message: "ad.ListWebhookDeliveriesResponse"},
+	{method: http.MethodGet, route: "/admin/webhooks/:webhook_id/dead_letters", tag: "admin", summary: "List events a webhook failed to receive",
+		query: []any{webhooksRequest{}}, data: []webhook.DeadLetter{}, message: "ad.ListWebhookDeadLettersResponse"},
+	{method: http.MethodPost, route: "/admin/projections/rebuild", tag: "admin", summary: "Rebuild ad projections from the event log",
+		body: rebuildProjectionsRequest{}, data: eventrepo.RebuildReport{}},
+	{method: http.MethodGet, route: "/admin/ads/:ad_id/history", tag: "admin", summary: "List events of an ad",
+		query: []any{adHistoryRequest{}}, data: []eventrepo.Event{}},
+	{method: http.MethodGet, route: "/admin/audit", tag: "admin", summary: "Search the audit log",
+		query: []any{auditLogRequest{}}, data: []audit.Entry{}},
+
+	{method: http.MethodGet, route: "/openapi.json", tag: "meta", summary: "This document",
+		content: map[string]any{gin.MIMEJSON: schema{"type": "object"}}},
+	{method: http.MethodGet, route: "/debug/pprof/", tag: "debug", summary: "pprof index",
+		content: map[string]any{gin.MIMEHTML: schema{"type": "string"}}},
+	{method: http.MethodGet, route: "/debug/pprof/cmdline", tag: "debug", summary: "pprof command line",
+		content: map[string]any{gin.MIMEPlain: schema{"type": "string"}}},
+	{method: http.MethodGet, route: "/debug/pprof/profile", tag: "debug", summary: "pprof CPU profile",
+		content: map[string]any{"application/octet-stream": nil}},
+	{method: http.MethodGet, route: "/debug/pprof/symbol", tag: "debug", summary: "pprof symbol lookup",
+		content: map[string]any{gin.MIMEPlain: schema{"type": "string"}}},
+	{method: http.MethodGet, route: "/debug/pprof/trace", tag: "debug", summary: "pprof execution trace",
+		content: map[string]any{"application/octet-stream": nil}},
+}
+
+var int64Schema = schema{"type": "integer", "format": "int64"}
+
+// pathParams схемы параметров пути, остальные параметры - строки
+var pathParams = map[string]schema{
+	"ad_id":      int64Schema,
+	"user_id":    int64Schema,
+	"webhook_id": int64Schema,
+}
+
+// headerParams заголовки запроса, которые читают обработчики и middleware
+var headerParams = map[string]schema{
+	idempotencyKeyHeader: {
+		"name": idempotencyKeyHeader, "in": "header", "schema": schema{"type": "string"},
+		"description": "Repeated requests with the same key get the stored response",
+	},
+	"Last-Event-ID": {
+		"name": "Last-Event-ID", "in": "header", "schema": int64Schema,
+		"description": "Resume the stream after this event instead of starting with a snapshot",
+	},
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// openAPIBuilder собирает документ, именованные структуры попадают в components.schemas
+type openAPIBuilder struct {
+	schemas map[string]any
+}
+
+// openAPIDocument описание API в формате OpenAPI 3 для маршрутов под basePath
+func openAPIDocument(basePath string) map[string]any {
+	b := &openAPIBuilder{schemas: make(map[string]any)}
+	paths := make(map[string]map[string]any)
+	for _, op := range operations {
+		p := openAPIPath(op)
+		if paths[p] == nil {
+			paths[p] = make(map[string]any)
+		}
+		paths[p][strings.ToLower(op.method)] = b.operation(op, p)
+	}
+
+	problem := b.schemaOf(reflect.TypeOf(problemResponse{}))
+	b.schemas["errorEnvelope"] = schema{
+		"type": "object",
+		"properties": map[string]any{
+			"data":  schema{"nullable": true},
+			"error": schema{"type": "string"},
+		},
+	}
+	return map[string]any{
+		"openapi": openAPIVersion,
+		"info":    map[string]any{"title": "Ads API", "version": "v1"},
+		"servers": []any{map[string]any{"url": basePath}},
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": b.schemas,
+			"responses": map[string]any{
+				"error": map[string]any{
+					"description": "Error. application/problem+json by default, the legacy envelope if only application/json is accepted, " +
+						"the same problem in MessagePack or google.rpc.Status in protobuf",
+					"headers": map[string]any{
+						retryAfterHeader: map[string]any{"description": "Seconds until the request may be repeated", "schema": schema{"type": "integer"}},
+					},
+					"content": map[string]any{
+						problemContentType: map[string]any{"schema": problem},
+						gin.MIMEJSON:       map[string]any{"schema": schema{"$ref": "#/components/schemas/errorEnvelope"}},
+						mimeMsgPack:        map[string]any{"schema": problem},
+						mimeProtobuf:       protobufContent("google.rpc.Status"),
+					},
+				},
+			},
+		},
+	}
+}
+
+// protobufContent тело в protobuf, сообщение указано расширением x-protobuf-message
+func protobufContent(message string) map[string]any {
+	return map[string]any{"schema": binarySchema, "x-protobuf-message": message}
+}
+
+// openAPIPath путь операции в документе, параметры gin :name записываются как {name}
+func openAPIPath(op operation) string {
+	p := op.path
+	if p == "" {
+		p = op.route
+	}
+	segments := strings.Split(p, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func (b *openAPIBuilder) operation(op operation, p string) map[string]any {
+	res := map[string]any{"summary": op.summary, "tags": []string{op.tag}}
+
+	params := make([]any, 0)
+	for _, s := range strings.Split(p, "/") {
+		if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+			name := s[1 : len(s)-1]
+			paramSchema, ok := pathParams[name]
+			if !ok {
+				paramSchema = schema{"type": "string"}
+			}
+			params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": paramSchema})
+		}
+	}
+	for _, q := range op.query {
+		params = append(params, b.queryParams(reflect.TypeOf(q))...)
+	}
+	for _, h := range op.headers {
+		params = append(params, headerParams[h])
+	}
+	if len(params) > 0 {
+		res["parameters"] = params
+	}
+
+	if op.body != nil || op.bodyContent != nil {
+		content := make(map[string]any)
+		if op.body != nil {
+			types := op.bodyTypes
+			if len(types) == 0 {
+				types = []string{gin.MIMEJSON}
+			}
+			bodySchema := b.schemaOf(reflect.TypeOf(op.body))
+			for _, t := range append(types, mimeMsgPack) {
+				content[t] = map[string]any{"schema": bodySchema}
+			}
+		}
+		if op.bodyMessage != "" {
+			content[mimeProtobuf] = protobufContent(op.bodyMessage)
+		}
+		for t, v := range op.bodyContent {
+			content[t] = map[string]any{"schema": b.valueSchema(v)}
+		}
+		res["requestBody"] = map[string]any{"required": true, "content": content}
+	}
+
+	status := op.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	success := map[string]any{"description": http.StatusText(status)}
+	content := make(map[string]any)
+	if op.content != nil {
+		for t, v := range op.content {
+			content[t] = map[string]any{"schema": b.valueSchema(v)}
+		}
+	} else {
+		envelope := map[string]any{"schema": schema{
+			"type": "object",
+			"properties": map[string]any{
+				"data":  b.valueSchema(op.data),
+				"error": schema{"type": "string", "nullable": true},
+			},
+		}}
+		content[gin.MIMEJSON] = envelope
+		content[mimeMsgPack] = envelope
+		if op.message != "" {
+			content[mimeProtobuf] = protobufContent(op.message)
+		}
+	}
+	success["content"] = content
+	res["responses"] = map[string]any{
+		strconv.Itoa(status): success,
+		"default":            schema{"$ref": "#/components/responses/error"},
+	}
+	return res
+}
+
+// queryParams параметры запроса по тегам form структуры t, default и binding:"required" тоже учитываются
+func (b *openAPIBuilder) queryParams(t reflect.Type) []any {
+	params := make([]any, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("form")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		fieldType := f.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		paramSchema := b.schemaOf(fieldType)
+		for _, option := range strings.Split(options, ",") {
+			if value, ok := strings.CutPrefix(option, "default="); ok {
+				paramSchema = withDefault(paramSchema, fieldType, value)
+			}
+		}
+		param := map[string]any{"name": name, "in": "query", "schema": paramSchema}
+		if strings.Contains(f.Tag.Get("binding"), "required") {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	return params
+}
+
+// withDefault копия s со значением по умолчанию из тега form, приведенным к типу поля
+func withDefault(s schema, t reflect.Type, value string) schema {
+	res := make(schema, len(s)+1)
+	for k, v := range s {
+		res[k] = v
+	}
+	res["default"] = value
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			res["default"] = n
+		}
+	case reflect.Bool:
+		if v, err := strconv.ParseBool(value); err == nil {
+			res["default"] = v
+		}
+	}
+	return res
+}
+
+// valueSchema схема значения из описания операции: готовая schema, файл для nil или схема типа значения
+func (b *openAPIBuilder) valueSchema(v any) schema {
+	switch v := v.(type) {
+	case nil:
+		return binarySchema
+	case schema:
+		return v
+	}
+	return b.schemaOf(reflect.TypeOf(v))
+}
+
+// schemaOf схема типа t в том виде, в каком его записывает encoding/json
+func (b *openAPIBuilder) schemaOf(t reflect.Type) schema {
+	switch t {
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return schema{"allOf": []any{b.schemaOf(t.Elem())}, "nullable": true}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return int64Schema
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return schema{"type": "integer", "format": "int32"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return schema{"type": "string", "format": "byte"}
+		}
+		return schema{"type": "array", "items": b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		name := componentName(t)
+		if name == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.schemas[name]; !ok {
+			// заглушка на случай структуры, которая ссылается на себя
+			b.schemas[name] = schema{}
+			b.schemas[name] = b.structSchema(t)
+		}
+		return schema{"$ref": "#/components/schemas/" + name}
+	}
+	return schema{}
+}
+
+// componentName имя схемы в components, типы других пакетов записываются с пакетом: outbox.Entry
+func componentName(t reflect.Type) string {
+	if t.Name() == "" {
+		return ""
+	}
+	if pkg := path.Base(t.PkgPath()); pkg != "httpgin" {
+		return pkg + "." + t.Name()
+	}
+	return t.Name()
+}
+
+func (b *openAPIBuilder) structSchema(t reflect.Type) schema {
+	properties := make(map[string]any)
+	b.addFields(t, properties)
+	return schema{"type": "object", "properties": properties}
+}
+
+// addFields поля встроенных структур добавляются первыми, собственные поля их перекрывают, как в encoding/json
+func (b *openAPIBuilder) addFields(t reflect.Type, properties map[string]any) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" && f.Type.Kind() == reflect.Struct {
+			b.addFields(f.Type, properties)
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || (f.Anonymous && f.Tag.Get("json") == "") {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = b.schemaOf(f.Type)
+	}
+}
+
+// openAPI отдает описание API, документ собирается один раз при регистрации маршрута
+func openAPI(basePath string) gin.HandlerFunc {
+	doc := openAPIDocument(basePath)
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, doc)
+	}
+}
@@ -0,0 +1,127 @@
+package httpgin
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	mocks "homework10/internal/mocks/appemocks"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const openAPIBasePath = "/api/v1"
+
+func openAPITestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	AppRouter(g.Group(openAPIBasePath), new(mocks.App), log.New(io.Discard, "", 0))
+	return g
+}
+
+// маршрут, добавленный в AppRouter без описания в operations, роняет тест, как и описание без маршрута
+func TestOpenAPI_CoversRoutes(t *testing.T) {
+	registered := make(map[string]bool)
+	for _, route := range openAPITestRouter().Routes() {
+		registered[route.Method+" "+strings.TrimPrefix(route.Path, openAPIBasePath)] = true
+	}
+	documented := make(map[string]bool)
+	for _, op := range operations {
+		documented[op.method+" "+op.route] = true
+	}
+
+	for route := range registered {
+		assert.True(t, documented[route], "route %s is not described in operations", route)
+	}
+	for route := range documented {
+		assert.True(t, registered[route], "operation %s has no route in AppRouter", route)
+	}
+}
+
+// collectRefs все значения $ref в документе
+func collectRefs(node any, refs map[string]bool) {
+	switch node := node.(type) {
+	case map[string]any:
+		for k, v := range node {
+			if ref, ok := v.(string); ok && k == "$ref" {
+				refs[ref] = true
+				continue
+			}
+			collectRefs(v, refs)
+		}
+	case []any:
+		for _, v := range node {
+			collectRefs(v, refs)
+		}
+	}
+}
+
+func TestOpenAPI_Document(t *testing.T) {
+	w := httptest.NewRecorder()
+	openAPITestRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, openAPIBasePath+"/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var doc map[string]any
+	if !assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc)) {
+		return
+	}
+	assert.Equal(t, openAPIVersion, doc["openapi"])
+	assert.Equal(t, []any{map[string]any{"url": openAPIBasePath}}, doc["servers"])
+
+	paths := doc["paths"].(map[string]any)
+	ad := paths["/ads/{ad_id}"].(map[string]any)
+	for _, method := range []string{"get", "put", "patch", "delete"} {
+		assert.Contains(t, ad, method)
+	}
+	assert.Contains(t, paths, "/ads:batchCreate")
+
+	// тело createAd строится по createAdRequest
+	create := paths["/ads"].(map[string]any)["post"].(map[string]any)
+	body := create["requestBody"].(map[string]any)["content"].(map[string]any)[gin.MIMEJSON].(map[string]any)
+	assert.Equal(t, "#/components/schemas/createAdRequest", body["schema"].(map[string]any)["$ref"])
+	assert.Contains(t, create["responses"], "201")
+	content := create["responses"].(map[string]any)["201"].(map[string]any)["content"].(map[string]any)
+	assert.Contains(t, content, mimeMsgPack)
+	assert.Equal(t, "ad.AdResponse", content[mimeProtobuf].(map[string]any)["x-protobuf-message"])
+
+	components := doc["components"].(map[string]any)
+	schemas := components["schemas"].(map[string]any)
+	adSchema := schemas["adResponse"].(map[string]any)["properties"].(map[string]any)
+	assert.Equal(t, map[string]any{"type": "string", "format": "date-time"}, adSchema["create_date"])
+	webhookSchema := schemas["webhookResponse"].(map[string]any)["properties"].(map[string]any)
+	assert.Contains(t, webhookSchema, "secret")
+	assert.Contains(t, webhookSchema, "url")
+
+	refs := make(map[string]bool)
+	collectRefs(doc, refs)
+	for ref := range refs {
+		section, name, _ := strings.Cut(strings.TrimPrefix(ref, "#/components/"), "/")
+		assert.Contains(t, components[section], name, "dangling reference %s", ref)
+	}
+}
+
+// сообщение тела описано ровно у тех запросов, которые разбирают protobuf, и все сообщения есть в service.proto
+func TestOpenAPI_ProtobufMessages(t *testing.T) {
+	for _, op := range operations {
+		name := op.method + " " + openAPIPath(op)
+		if op.body != nil {
+			req := reflect.New(reflect.TypeOf(op.body)).Interface()
+			_, isRequest := req.(protoRequest)
+			_, isPatch := req.(protoPatchRequest)
+			assert.Equal(t, isRequest || isPatch, op.bodyMessage != "", "bodyMessage of %s", name)
+		}
+		for _, message := range []string{op.bodyMessage, op.message} {
+			if message == "" {
+				continue
+			}
+			_, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(message))
+			assert.NoError(t, err, "message %s of %s", message, name)
+		}
+	}
+}
@@ -2,7 +2,16 @@ package httpgin
 
 import (
 	"github.com/gin-gonic/gin"
+	"homework10/internal/adapters/repository/eventrepo"
+	"homework10/internal/app"
+	"homework10/internal/apperr"
+	"homework10/internal/audit"
 	"homework10/internal/entities"
+	"homework10/internal/outbox"
+	"homework10/internal/quota"
+	"homework10/internal/service"
+	"homework10/internal/webhook"
+	"net/http"
 	"time"
 )
 
@@ -33,12 +42,62 @@ type updateAdRequest struct {
 	Text   string `json:"text"`
 }
 
+// patchAdRequest документ JSON Merge Patch (RFC 7396), какие поля в нем есть, возвращает bindMergePatch.
+// user_id - автор, который вносит изменения, сам он не меняется
+type patchAdRequest struct {
+	UserID int64   `json:"user_id"`
+	Title  *string `json:"title"`
+	Text   *string `json:"text"`
+}
+
 type FilterAdRequest struct {
 	Published  bool      `json:"published"`
 	UserID     int64     `json:"user_id"`
 	CreateDate time.Time `json:"create_Date"`
 }
 
+type batchCreateAdsRequest struct {
+	Items        []createAdRequest `json:"items"`
+	AllOrNothing bool              `json:"all_or_nothing"`
+}
+
+type batchAdStatusItem struct {
+	AdID      int64 `json:"ad_id"`
+	UserID    int64 `json:"user_id"`
+	Published bool  `json:"published"`
+}
+
+type batchUpdateAdStatusRequest struct {
+	Items        []batchAdStatusItem `json:"items"`
+	AllOrNothing bool                `json:"all_or_nothing"`
+}
+
+type batchAdRefItem struct {
+	AdID   int64 `json:"ad_id"`
+	UserID int64 `json:"user_id"`
+}
+
+type batchDeleteAdsRequest struct {
+	Items        []batchAdRefItem `json:"items"`
+	AllOrNothing bool             `json:"all_or_nothing"`
+}
+
+// batchItemResponse status - HTTP статус, который вернул бы одиночный запрос для этого элемента
+type batchItemResponse struct {
+	Status int         `json:"status"`
+	Ad     *adResponse `json:"ad"`
+	Error  *string     `json:"error"`
+}
+
+type importAdsRequest struct {
+	Format string `form:"format,query,default=jsonl"`
+	DryRun bool   `form:"dry_run,query"`
+}
+
+type exportAdsRequest struct {
+	Format string `form:"format,query,default=jsonl"`
+}
+
 type createUserRequest struct {
 	Nickname string `json:"nickname"`
 	Email    string `json:"email"`
@@ -49,6 +108,79 @@ type UpdateUserRequest struct {
 	Email    string `json:"email"`
 }
 
+type patchUserRequest struct {
+	Nickname *string `json:"nickname"`
+	Email    *string `json:"email"`
+}
+
+// deleteUserRequest ads=cascade удаляет объявления пользователя, ads=transfer передает их new_owner_id
+type deleteUserRequest struct {
+	Ads        string `form:"ads,query,default=cascade"`
+	NewOwnerID int64  `form:"new_owner_id,query"`
+}
+
+type userQuotaRequest struct {
+	RequesterID int64 `form:"requester_id,query,default=-1"`
+}
+
+type exportUserRequest struct {
+	RequesterID int64  `form:"requester_id,query,default=-1"`
+	Format      string `form:"format,query,default=json"`
+}
+
+type eraseUserRequest struct {
+	RequesterID int64 `json:"requester_id"`
+}
+
+type outboxEntriesRequest struct {
+	RequesterID int64  `form:"requester_id,query,default=-1"`
+	Status      string `form:"status,query"`
+}
+
+type replayOutboxRequest struct {
+	RequesterID int64 `json:"requester_id"`
+}
+
+type createWebhookRequest struct {
+	RequesterID int64    `json:"requester_id"`
+	URL         string   `json:"url"`
+	Secret      string   `json:"secret"`
+	Events      []string `json:"events"`
+}
+
+type webhooksRequest struct {
+	RequesterID int64 `form:"requester_id,query,default=-1"`
+}
+
+// webhookResponse подписка вместе с секретом, отдается только при создании
+type webhookResponse struct {
+	webhook.Subscription
+	Secret string `json:"secret"`
+}
+
+type rebuildProjectionsRequest struct {
+	RequesterID int64 `json:"requester_id"`
+}
+
+type adHistoryRequest struct {
+	RequesterID int64 `form:"requester_id,query,default=-1"`
+}
+
+// auditLogRequest from и to в формате RFC 3339, to не включается
+type auditLogRequest struct {
+	RequesterID int64     `form:"requester_id,query,default=-1"`
+	Actor       *int64    `form:"actor,query"`
+	Target      string    `form:"target,query"`
+	TargetID    *int64    `form:"target_id,query"`
+	From        time.Time `form:"from,query" time_format:"2006-01-02T15:04:05Z07:00"`
+	To          time.Time `form:"to,query" time_format:"2006-01-02T15:04:05Z07:00"`
+	Limit       int       `form:"limit,query"`
+}
+
+type verifyUserRequest struct {
+	Token string `json:"token"`
+}
+
 func AdSuccessResponse(ad *entities.Ad) gin.H {
 	return gin.H{
 		"data": adResponse{
@@ -83,6 +215,121 @@ func AdListSuccessResponse(ads *[]entities.Ad) gin.H {
 	}
 }
 
+func BatchResponse(results []service.BatchResult, err error) gin.H {
+	items := make([]batchItemResponse, 0, len(results))
+	for _, r := range results {
+		item := batchItemResponse{Status: httpStatus(r.Err)}
+		if r.Ad != nil {
+			item.Ad = &adResponse{
+				ID:         r.Ad.ID,
+				Title:      r.Ad.Title,
+				Text:       r.Ad.Text,
+				AuthorID:   r.Ad.AuthorID,
+				Published:  r.Ad.Published,
+				CreateDate: r.Ad.CreateDate,
+				UpdateDate: r.Ad.UpdateDate,
+			}
+		}
+		if r.Err != nil {
+			msg := r.Err.Error()
+			item.Error = &msg
+		}
+		items = append(items, item)
+	}
+	var errMsg any
+	if err != nil {
+		errMsg = err.Error()
+	}
+	return gin.H{
+		"data":  items,
+		"error": errMsg,
+	}
+}
+
+func ImportSuccessResponse(report *app.ImportReport) gin.H {
+	return gin.H{
+		"data":  report,
+		"error": nil,
+	}
+}
+
+func OutboxListSuccessResponse(entries []outbox.Entry) gin.H {
+	return gin.H{
+		"data":  entries,
+		"error": nil,
+	}
+}
+
+func OutboxEntrySuccessResponse(entry *outbox.Entry) gin.H {
+	return gin.H{
+		"data":  entry,
+		"error": nil,
+	}
+}
+
+func WebhookSuccessResponse(sub *webhook.Subscription) gin.H {
+	return gin.H{
+		"data":  webhookResponse{Subscription: *sub, Secret: sub.Secret},
+		"error": nil,
+	}
+}
+
+func WebhookListSuccessResponse(subs []webhook.Subscription) gin.H {
+	return gin.H{
+		"data":  subs,
+		"error": nil,
+	}
+}
+
+func DeleteWebhookSuccessResponse(webhookID int64) gin.H {
+	return gin.H{
+		"data":  gin.H{"webhook_id": webhookID},
+		"error": nil,
+	}
+}
+
+func WebhookDeliveriesSuccessResponse(deliveries []webhook.Delivery) gin.H {
+	return gin.H{
+		"data":  deliveries,
+		"error": nil,
+	}
+}
+
+func WebhookDeadLettersSuccessResponse(letters []webhook.DeadLetter) gin.H {
+	return gin.H{
+		"data":  letters,
+		"error": nil,
+	}
+}
+
+func RebuildSuccessResponse(report *eventrepo.RebuildReport) gin.H {
+	return gin.H{
+		"data":  report,
+		"error": nil,
+	}
+}
+
+func AdHistorySuccessResponse(history []eventrepo.Event) gin.H {
+	return gin.H{
+		"data":  history,
+		"error": nil,
+	}
+}
+
+func AuditLogSuccessResponse(entries []audit.Entry) gin.H {
+	return gin.H{
+		"data":  entries,
+		"error": nil,
+	}
+}
+
+func QuotaSuccessResponse(usage *quota.Usage) gin.H {
+	return gin.H{
+		"data":  usage,
+		"error": nil,
+	}
+}
+
 func ErrorResponse(err error) gin.H {
 	return gin.H{
 		"data":  nil,
@@ -90,6 +337,44 @@ func ErrorResponse(err error) gin.H {
 	}
 }
 
+type problemField struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+type problemResponse struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail"`
+	Instance string         `json:"instance"`
+	Code     string         `json:"code"`
+	Errors   []problemField `json:"errors,omitempty"`
+	Details  map[string]any `json:"details,omitempty"`
+}
+
+// ProblemResponse ошибка в формате RFC 7807. Тип задается классом ошибки, code - конкретной ошибкой.
+// Текст внутренних ошибок клиенту не показывается
+func ProblemResponse(e *apperr.Error, status int, instance string) problemResponse {
+	res := problemResponse{
+		Type:     problemTypeBase + string(e.Kind),
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.Message,
+		Instance: instance,
+		Code:     e.Code,
+		Details:  e.Details,
+	}
+	if e.Kind == apperr.Internal {
+		res.Detail = "internal error"
+	}
+	for _, f := range e.Fields {
+		res.Errors = append(res.Errors, problemField{Field: f.Field, Code: f.Code, Detail: f.Message})
+	}
+	return res
+}
+
 func UserSuccessResponse(user *entities.User) gin.H {
 	return gin.H{
 		"data":  user,
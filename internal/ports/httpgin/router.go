@@ -3,26 +3,117 @@ package httpgin
 import (
 	"github.com/gin-gonic/gin"
 	"homework10/internal/app"
+	"homework10/internal/idempotency"
+	"homework10/internal/ratelimit"
 	"log"
 	"net/http"
 	"net/http/pprof"
 )
 
-func AppRouter(r *gin.RouterGroup, a app.App, logger *log.Logger) {
+type Option func(*options)
+
+type options struct {
+	idempotency idempotency.Store
+	limiter     ratelimit.Limiter
+	rateLimits  ratelimit.Rules
+	gateway     http.Handler
+	graphql     http.Handler
+	// trustedProxies адреса и подсети прокси, которым можно верить в X-Forwarded-For
+	trustedProxies []string
+}
+
+// WithIdempotencyStore задает хранилище ответов для Idempotency-Key, по умолчанию ответы хранятся сутки
+func WithIdempotencyStore(store idempotency.Store) Option {
+	return func(o *options) {
+		o.idempotency = store
+	}
+}
+
+// WithRateLimits задает лимиты запросов по маршрутам вида "POST /ads", по умолчанию DefaultRateLimits
+func WithRateLimits(limiter ratelimit.Limiter, rules ratelimit.Rules) Option {
+	return func(o *options) {
+		o.limiter = limiter
+		o.rateLimits = rules
+	}
+}
+
+// WithTrustedProxies адреса или подсети прокси перед сервером. Только от них X-Forwarded-For и X-Real-IP
+// считаются адресом клиента для лимитов и аудита, по умолчанию адрес клиента - адрес соединения
+func WithTrustedProxies(proxies ...string) Option {
+	return func(o *options) {
+		o.trustedProxies = proxies
+	}
+}
+
+// WithGateway монтирует шлюз из grpc.NewGateway на /api/v2/
+func WithGateway(gateway http.Handler) Option {
+	return func(o *options) {
+		o.gateway = gateway
+	}
+}
+
+// WithGraphQL монтирует обработчик из graphql.NewHandler на /graphql
+func WithGraphQL(handler http.Handler) Option {
+	return func(o *options) {
+		o.graphql = handler
+	}
+}
+
+func AppRouter(r *gin.RouterGroup, a app.App, logger *log.Logger, opts ...Option) {
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.idempotency == nil {
+		o.idempotency = idempotency.NewStore()
+	}
+	if o.limiter == nil {
+		o.limiter, o.rateLimits = ratelimit.NewLimiter(), DefaultRateLimits
+	}
+	idempotent := IdempotencyMiddleware(o.idempotency)
+
 	r.Use(LoggerMiddleware(logger))
 	r.Use(RecoveryMiddleware(logger))
+	r.Use(AuditMiddleware())
+	r.Use(RateLimitMiddleware(o.limiter, o.rateLimits, r.BasePath()))
 
 	r.GET("/ads/:ad_id", getAdByID(a))
 	r.GET("/ads", getAdsByFilter(a))
-	r.POST("/ads", createAd(a))
+	r.GET("/ads/export", exportAds(a))
+	r.GET("/ads/events", adEvents(a))
+	r.POST("/ads/import", importAds(a))
+	r.POST("/ads", idempotent, createAd(a))
 	r.PUT("/ads/:ad_id/status", changeAdStatus(a))
 	r.PUT("/ads/:ad_id", updateAd(a))
+	r.PATCH("/ads/:ad_id", patchAd(a))
 	r.DELETE("/ads/:ad_id", deleteAd(a))
+	// gin не различает несколько маршрутов вида /ads:name, поэтому пакетные методы разбирает batchAds
+	r.POST("/ads:method", batchAds(a))
 
 	r.GET("/users/:user_id", getUserByID(a))
-	r.POST("/users", createUser(a))
+	r.GET("/users", getUserByNickname(a))
+	r.POST("/users", idempotent, createUser(a))
+	r.POST("/users/verify", verifyUser(a))
+	r.POST("/users/:user_id/verification", resendVerification(a))
 	r.PUT("/users/:user_id", updateUser(a))
+	r.PATCH("/users/:user_id", patchUser(a))
 	r.DELETE("/users/:user_id", deleteUser(a))
+	r.GET("/users/:user_id/export", exportUser(a))
+	r.GET("/users/:user_id/quota", userQuota(a))
+	r.POST("/users/:user_id/erase", eraseUser(a))
+
+	r.GET("/admin/outbox", outboxEntries(a))
+	r.POST("/admin/outbox/:entry_id/replay", replayOutboxEntry(a))
+	r.POST("/admin/webhooks", createWebhook(a))
+	r.GET("/admin/webhooks", webhooks(a))
+	r.DELETE("/admin/webhooks/:webhook_id", deleteWebhook(a))
+	r.GET("/admin/webhooks/:webhook_id/deliveries", webhookDeliveries(a))
+	r.GET("/admin/webhooks/:webhook_id/dead_letters", webhookDeadLetters(a))
+	r.POST("/admin/projections/rebuild", rebuildProjections(a))
+	r.GET("/admin/ads/:ad_id/history", adHistory(a))
+	r.GET("/admin/audit", auditLog(a))
+	// описание каждого маршрута выше лежит в operations, см. openapi.go
+	r.GET("/openapi.json", openAPI(r.BasePath()))
 	// регистрируем маршруты для обработки запросов pprof
 	r.GET("/debug/pprof/", gin.WrapH(http.HandlerFunc(pprof.Index)))
 	r.GET("/debug/pprof/cmdline", gin.WrapH(http.HandlerFunc(pprof.Cmdline)))
@@ -30,3 +121,24 @@ func AppRouter(r *gin.RouterGroup, a app.App, logger *log.Logger) {
 	r.GET("/debug/pprof/symbol", gin.WrapH(http.HandlerFunc(pprof.Symbol)))
 	r.GET("/debug/pprof/trace", gin.WrapH(http.HandlerFunc(pprof.Trace)))
 }
+
+// GatewayRouter отдает все запросы группы шлюзу, маршруты описаны аннотациями google.api.http в service.proto
+func GatewayRouter(r *gin.RouterGroup, gateway http.Handler, logger *log.Logger) {
+	r.Use(LoggerMiddleware(logger))
+	r.Use(RecoveryMiddleware(logger))
+	r.Use(AuditMiddleware())
+	r.Use(ClientAddrMiddleware())
+
+	r.Any("/*path", gin.WrapH(gateway))
+}
+
+// GraphQLRouter отдает POST /graphql обработчику схемы, журнал, восстановление и аудит - как у /api/v1.
+// Лимиты и Idempotency-Key мутаций проверяет сам обработчик по адресу клиента
+func GraphQLRouter(r *gin.RouterGroup, handler http.Handler, logger *log.Logger) {
+	r.Use(LoggerMiddleware(logger))
+	r.Use(RecoveryMiddleware(logger))
+	r.Use(AuditMiddleware())
+	r.Use(ClientAddrMiddleware())
+
+	r.POST("/graphql", gin.WrapH(handler))
+}
@@ -42,11 +42,34 @@ func (s *httpAppSuiteRoute) TestAppRouter() {
 		{http.MethodPost, "/ads"},
 		{http.MethodPut, "/ads/:ad_id/status"},
 		{http.MethodPut, "/ads/:ad_id"},
+		{http.MethodPatch, "/ads/:ad_id"},
 		{http.MethodDelete, "/ads/:ad_id"},
+		{http.MethodPost, "/ads:method"},
+		{http.MethodGet, "/ads/export"},
+		{http.MethodPost, "/ads/import"},
+		{http.MethodGet, "/ads/events"},
 		{http.MethodGet, "/users/:user_id"},
+		{http.MethodGet, "/users"},
 		{http.MethodPost, "/users"},
+		{http.MethodPost, "/users/verify"},
+		{http.MethodPost, "/users/:user_id/verification"},
 		{http.MethodPut, "/users/:user_id"},
+		{http.MethodPatch, "/users/:user_id"},
 		{http.MethodDelete, "/users/:user_id"},
+		{http.MethodGet, "/users/:user_id/export"},
+		{http.MethodGet, "/users/:user_id/quota"},
+		{http.MethodPost, "/users/:user_id/erase"},
+		{http.MethodGet, "/admin/outbox"},
+		{http.MethodPost, "/admin/outbox/:entry_id/replay"},
+		{http.MethodPost, "/admin/webhooks"},
+		{http.MethodGet, "/admin/webhooks"},
+		{http.MethodDelete, "/admin/webhooks/:webhook_id"},
+		{http.MethodGet, "/admin/webhooks/:webhook_id/deliveries"},
+		{http.MethodGet, "/admin/webhooks/:webhook_id/dead_letters"},
+		{http.MethodPost, "/admin/projections/rebuild"},
+		{http.MethodGet, "/admin/ads/:ad_id/history"},
+		{http.MethodGet, "/admin/audit"},
+		{http.MethodGet, "/openapi.json"},
 	}
 
 	g := gin.New()
@@ -15,11 +15,28 @@ type HttpServer struct {
 	certFile, keyFile string
 }
 
-func NewHTTPServer(port string, a app.App, logger *log.Logger, certFile, keyFile string) grpc2.Server {
+func NewHTTPServer(port string, a app.App, logger *log.Logger, certFile, keyFile string, opts ...Option) grpc2.Server {
 	gin.SetMode(gin.ReleaseMode)
 	handler := gin.New()
+	// значения из контекста запроса, например для аудита, доступны через *gin.Context
+	handler.ContextWithFallback = true
+	o := options{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	// gin по умолчанию верит X-Forwarded-For от кого угодно, и адрес клиента можно подделать
+	if err := handler.SetTrustedProxies(o.trustedProxies); err != nil {
+		logger.Printf("bad trusted proxies %q, trusting none: %v", o.trustedProxies, err)
+		_ = handler.SetTrustedProxies(nil)
+	}
 	api := handler.Group("/api/v1/")
-	AppRouter(api, a, logger)
+	AppRouter(api, a, logger, opts...)
+	if o.gateway != nil {
+		GatewayRouter(handler.Group("/api/v2/"), o.gateway, logger)
+	}
+	if o.graphql != nil {
+		GraphQLRouter(handler.Group("/"), o.graphql, logger)
+	}
 	srv := &http.Server{
 		Addr:    port,
 		Handler: handler,
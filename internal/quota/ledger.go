@@ -0,0 +1,71 @@
+package quota
+
+import (
+	"sync"
+	"time"
+)
+
+// Ledger считает объявления, созданные пользователями за текущие сутки UTC.
+// Удаление объявления не возвращает лимит, иначе его можно обойти, удаляя и создавая заново
+type Ledger interface {
+	// Reserve учитывает создание объявления, если с ним не будет превышен limit. limit 0 - без ограничений
+	Reserve(userID int64, now time.Time, limit int) bool
+	// Cancel возвращает резерв, сделанный в now, если объявление не удалось сохранить или его создание откачено.
+	// Резерв прошедших суток уже не учитывается и не возвращается
+	Cancel(userID int64, now time.Time)
+	Created(userID int64, now time.Time) int
+}
+
+type memLedger struct {
+	mutex sync.Mutex
+	// day сутки, за которые ведется счет, с наступлением новых счетчики обнуляются
+	day     time.Time
+	created map[int64]int
+}
+
+func NewLedger() Ledger {
+	return &memLedger{created: make(map[int64]int)}
+}
+
+func (l *memLedger) Reserve(userID int64, now time.Time, limit int) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rollover(now)
+	if limit > 0 && l.created[userID] >= limit {
+		return false
+	}
+	l.created[userID]++
+	return true
+}
+
+func (l *memLedger) Cancel(userID int64, now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if startOfDay(now).Before(l.day) {
+		return
+	}
+	l.rollover(now)
+	if l.created[userID] > 1 {
+		l.created[userID]--
+	} else {
+		delete(l.created, userID)
+	}
+}
+
+func (l *memLedger) Created(userID int64, now time.Time) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.rollover(now)
+	return l.created[userID]
+}
+
+// rollover вызывается под блокировкой
+func (l *memLedger) rollover(now time.Time) {
+	if day := startOfDay(now); !day.Equal(l.day) {
+		l.day = day
+		l.created = make(map[int64]int)
+	}
+}
@@ -0,0 +1,109 @@
+package quota
+
+import (
+	"fmt"
+	"homework10/internal/apperr"
+	"time"
+)
+
+var ErrExceeded = apperr.New(apperr.QuotaExceeded, "quota_exceeded", "quota exceeded")
+
+type Kind string
+
+const (
+	// ActiveAds опубликованные объявления пользователя
+	ActiveAds Kind = "active_ads"
+	// DailyAds объявления, созданные пользователем с начала суток UTC
+	DailyAds Kind = "daily_ads"
+)
+
+// ExceededError лимит тарифа исчерпан, errors.Is(err, ErrExceeded)
+type ExceededError struct {
+	Kind  Kind
+	Plan  string
+	Limit int
+	// RetryAfter когда лимит восстановится, для ActiveAds - 0: нужно снять объявление с публикации
+	RetryAfter time.Duration
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded: plan %q allows %d %s", e.Plan, e.Limit, e.Kind)
+}
+
+func (e *ExceededError) Is(target error) bool {
+	return target == ErrExceeded
+}
+
+// AppError дневной лимит восстановится сам, поэтому это ResourceExhausted, а лимит
+// опубликованных объявлений - QuotaExceeded
+func (e *ExceededError) AppError() *apperr.Error {
+	kind := apperr.QuotaExceeded
+	if e.Kind == DailyAds {
+		kind = apperr.ResourceExhausted
+	}
+	return &apperr.Error{
+		Kind:       kind,
+		Code:       ErrExceeded.Code,
+		Message:    e.Error(),
+		Details:    map[string]any{"quota": string(e.Kind), "plan": e.Plan, "limit": e.Limit},
+		RetryAfter: e.RetryAfter,
+		Err:        e,
+	}
+}
+
+// Plan лимиты тарифа, 0 - без ограничений
+type Plan struct {
+	Name         string `json:"name"`
+	MaxActiveAds int    `json:"max_active_ads"`
+	MaxDailyAds  int    `json:"max_daily_ads"`
+}
+
+var (
+	Unlimited = Plan{Name: "unlimited"}
+	Free      = Plan{Name: "free", MaxActiveAds: 10, MaxDailyAds: 20}
+)
+
+type Role string
+
+const (
+	RoleUser  Role = "user"
+	RoleAdmin Role = "admin"
+)
+
+// Policy тариф пользователя: назначенный ему лично, иначе тариф его роли, иначе Default
+type Policy struct {
+	Default Plan
+	Roles   map[Role]Plan
+	Users   map[int64]Plan
+}
+
+// DefaultPolicy бесплатный тариф для всех, кроме администраторов
+var DefaultPolicy = Policy{Default: Free, Roles: map[Role]Plan{RoleAdmin: Unlimited}}
+
+func (p Policy) PlanFor(userID int64, role Role) Plan {
+	if plan, ok := p.Users[userID]; ok {
+		return plan
+	}
+	if plan, ok := p.Roles[role]; ok {
+		return plan
+	}
+	return p.Default
+}
+
+// Usage использование лимитов пользователем
+type Usage struct {
+	Plan      Plan `json:"plan"`
+	ActiveAds int  `json:"active_ads"`
+	DailyAds  int  `json:"daily_ads"`
+	// ResetsAt когда обнулится счетчик DailyAds
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// NextDay начало следующих суток UTC, когда обнуляется счетчик созданных объявлений
+func NextDay(now time.Time) time.Time {
+	return startOfDay(now).AddDate(0, 0, 1)
+}
+
+func startOfDay(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,69 @@
+package quota
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/apperr"
+	"testing"
+	"time"
+)
+
+var tNow = time.Date(2024, 1, 2, 22, 30, 0, 0, time.UTC)
+
+func TestLedger(t *testing.T) {
+	ledger := NewLedger()
+	assert.True(t, ledger.Reserve(1, tNow, 2))
+	assert.True(t, ledger.Reserve(1, tNow, 2))
+	assert.False(t, ledger.Reserve(1, tNow, 2))
+	assert.True(t, ledger.Reserve(2, tNow, 2))
+	assert.Equal(t, 2, ledger.Created(1, tNow))
+
+	ledger.Cancel(1, tNow)
+	assert.Equal(t, 1, ledger.Created(1, tNow))
+	assert.True(t, ledger.Reserve(1, tNow, 2))
+
+	// с началом новых суток UTC счетчики обнуляются
+	tomorrow := NextDay(tNow)
+	assert.Equal(t, time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC), tomorrow)
+	assert.Equal(t, 0, ledger.Created(1, tomorrow))
+	assert.True(t, ledger.Reserve(1, tomorrow, 2))
+
+	// без лимита создания все равно учитываются
+	for i := 0; i < 5; i++ {
+		assert.True(t, ledger.Reserve(3, tomorrow, 0))
+	}
+	assert.Equal(t, 5, ledger.Created(3, tomorrow))
+
+	// резерв прошедших суток не возвращается и не сбрасывает счетчики текущих
+	ledger.Cancel(3, tNow)
+	assert.Equal(t, 5, ledger.Created(3, tomorrow))
+}
+
+func TestPolicy_PlanFor(t *testing.T) {
+	pro := Plan{Name: "pro", MaxActiveAds: 100}
+	policy := Policy{Default: Free, Roles: map[Role]Plan{RoleAdmin: Unlimited}, Users: map[int64]Plan{7: pro}}
+
+	assert.Equal(t, Free, policy.PlanFor(1, RoleUser))
+	assert.Equal(t, Unlimited, policy.PlanFor(1, RoleAdmin))
+	assert.Equal(t, pro, policy.PlanFor(7, RoleAdmin))
+	assert.Equal(t, Plan{}, Policy{}.PlanFor(1, RoleUser))
+}
+
+func TestExceededError(t *testing.T) {
+	var err error = &ExceededError{Kind: DailyAds, Plan: "free", Limit: 20, RetryAfter: time.Hour}
+	assert.ErrorIs(t, err, ErrExceeded)
+	assert.Equal(t, `quota exceeded: plan "free" allows 20 daily_ads`, err.Error())
+
+	var exceeded *ExceededError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, time.Hour, exceeded.RetryAfter)
+
+	e := apperr.From(err)
+	assert.Equal(t, apperr.ResourceExhausted, e.Kind)
+	assert.Equal(t, time.Hour, e.RetryAfter)
+	assert.Equal(t, 20, e.Details["limit"])
+	assert.ErrorIs(t, e, ErrExceeded)
+
+	active := &ExceededError{Kind: ActiveAds, Plan: "free", Limit: 10}
+	assert.Equal(t, apperr.QuotaExceeded, apperr.KindOf(active))
+}
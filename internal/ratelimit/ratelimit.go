@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxKeys сколько ключей хранится одновременно, при переполнении вытесняются давно не использованные
+	DefaultMaxKeys = 100000
+	// sweepInterval как часто удалять заполнившиеся ведра
+	sweepInterval = time.Minute
+)
+
+// Limit token bucket: Burst запросов подряд, дальше Rate запросов в секунду.
+// Маршруты с лимитом одного Name тратят одно ведро клиента, без Name у каждого маршрута свое ведро
+type Limit struct {
+	Name  string
+	Rate  float64
+	Burst int
+}
+
+// Named тот же лимит с ведром name, общим для всех маршрутов с этим именем
+func (l Limit) Named(name string) Limit {
+	l.Name = name
+	return l
+}
+
+// Key ключ ведра клиента client на маршруте route
+func (l Limit) Key(route, client string) string {
+	if l.Name != "" {
+		return l.Name + " " + client
+	}
+	return route + " " + client
+}
+
+func PerSecond(n int) Limit {
+	return Limit{Rate: float64(n), Burst: n}
+}
+
+func PerMinute(n int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: n}
+}
+
+// Лимиты создания объявлений и пользователей, общие для /api/v1, /api/v2, gRPC и GraphQL:
+// клиент не получает лишние запросы, переходя с одного транспорта на другой
+var (
+	CreateAd   = PerMinute(30).Named("create_ad")
+	BatchAds   = PerMinute(10).Named("batch_ads")
+	ImportAds  = PerMinute(5).Named("import_ads")
+	CreateUser = PerMinute(10).Named("create_user")
+	// ResendVerification каждый запрос отправляет письмо
+	ResendVerification = PerMinute(3).Named("resend_verification")
+)
+
+// Rules лимиты по маршруту или методу, "*" - для всех остальных
+type Rules map[string]Limit
+
+const anyRoute = "*"
+
+func (r Rules) Lookup(route string) (Limit, bool) {
+	if limit, ok := r[route]; ok {
+		return limit, true
+	}
+	limit, ok := r[anyRoute]
+	return limit, ok
+}
+
+type Limiter interface {
+	// Allow забирает токен из ведра key. Если токенов нет, возвращает false и через сколько появится следующий
+	Allow(key string, limit Limit) (allowed bool, retryAfter time.Duration)
+}
+
+type bucket struct {
+	key    string
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// refill пополняет ведро на время с последнего обращения
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Seconds()
+	if elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Burst), b.tokens+elapsed*b.limit.Rate)
+		b.last = now
+	}
+}
+
+// full полное ведро ничем не отличается от нового, его можно удалить
+func (b *bucket) full(now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*b.limit.Rate >= float64(b.limit.Burst)
+}
+
+type memLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*list.Element
+	// recent ведра от недавно использованных к давно не использованным, вытесняется последнее
+	recent    *list.List
+	maxKeys   int
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type Option func(*memLimiter)
+
+func WithMaxKeys(n int) Option {
+	return func(l *memLimiter) {
+		l.maxKeys = n
+	}
+}
+
+// withClock подменяет время в тестах
+func withClock(now func() time.Time) Option {
+	return func(l *memLimiter) {
+		l.now = now
+	}
+}
+
+func NewLimiter(opts ...Option) Limiter {
+	l := &memLimiter{buckets: make(map[string]*list.Element), recent: list.New(), maxKeys: DefaultMaxKeys, now: time.Now}
+	for _, opt := range opts {
+		opt(l)
+	}
+	l.lastSweep = l.now()
+	return l
+}
+
+func (l *memLimiter) Allow(key string, limit Limit) (bool, time.Duration) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return true, 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	b := l.bucket(key, limit, now)
+	b.refill(now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	wait := (1 - b.tokens) / limit.Rate
+	return false, time.Duration(math.Ceil(wait * float64(time.Second)))
+}
+
+// bucket ведро key с limit, новое или сброшенное при смене лимита. Вызывается под блокировкой
+func (l *memLimiter) bucket(key string, limit Limit, now time.Time) *bucket {
+	if e, ok := l.buckets[key]; ok {
+		b := e.Value.(*bucket)
+		if b.limit == limit {
+			l.recent.MoveToFront(e)
+			return b
+		}
+		l.remove(e)
+	}
+	// при переполнении вытесняется ведро, к которому дольше всего не обращались
+	if len(l.buckets) >= l.maxKeys && l.recent.Len() > 0 {
+		l.remove(l.recent.Back())
+	}
+	b := &bucket{key: key, tokens: float64(limit.Burst), last: now, limit: limit}
+	l.buckets[key] = l.recent.PushFront(b)
+	return b
+}
+
+func (l *memLimiter) remove(e *list.Element) {
+	delete(l.buckets, e.Value.(*bucket).key)
+	l.recent.Remove(e)
+}
+
+// sweep удаляет заполнившиеся ведра не чаще раза в sweepInterval, вызывается под блокировкой
+func (l *memLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for e := l.recent.Front(); e != nil; {
+		next := e.Next()
+		if e.Value.(*bucket).full(now) {
+			l.remove(e)
+		}
+		e = next
+	}
+}
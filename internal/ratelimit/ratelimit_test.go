@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newClock() *clock {
+	return &clock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func TestLimiter_Burst(t *testing.T) {
+	c := newClock()
+	limiter := NewLimiter(withClock(c.Now))
+	limit := Limit{Rate: 2, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		allowed, _ := limiter.Allow("user:1", limit)
+		assert.True(t, allowed)
+	}
+	allowed, retryAfter := limiter.Allow("user:1", limit)
+	assert.False(t, allowed)
+	assert.Equal(t, 500*time.Millisecond, retryAfter)
+
+	// ведра разных ключей независимы
+	allowed, _ = limiter.Allow("user:2", limit)
+	assert.True(t, allowed)
+
+	c.now = c.now.Add(retryAfter)
+	allowed, _ = limiter.Allow("user:1", limit)
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow("user:1", limit)
+	assert.False(t, allowed)
+
+	// ведро не наполняется больше Burst
+	c.now = c.now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		allowed, _ = limiter.Allow("user:1", limit)
+		assert.True(t, allowed)
+	}
+	allowed, _ = limiter.Allow("user:1", limit)
+	assert.False(t, allowed)
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	limiter := NewLimiter()
+	for i := 0; i < 10; i++ {
+		allowed, _ := limiter.Allow("ip:10.0.0.1", Limit{})
+		assert.True(t, allowed)
+	}
+}
+
+func TestLimiter_Eviction(t *testing.T) {
+	c := newClock()
+	limiter := NewLimiter(WithMaxKeys(2), withClock(c.Now))
+	limit := PerMinute(1)
+
+	_, _ = limiter.Allow("a", limit)
+	c.now = c.now.Add(time.Second)
+	_, _ = limiter.Allow("b", limit)
+	c.now = c.now.Add(time.Second)
+	_, _ = limiter.Allow("a", limit)
+	_, _ = limiter.Allow("c", limit)
+	// вытеснено ведро, к которому дольше всего не обращались
+	buckets := limiter.(*memLimiter).buckets
+	assert.Len(t, buckets, 2)
+	assert.NotContains(t, buckets, "b")
+
+	// заполнившиеся ведра удаляются и без обращения к ним
+	c.now = c.now.Add(2 * time.Minute)
+	_, _ = limiter.Allow("d", limit)
+	assert.Len(t, buckets, 1)
+	assert.Contains(t, buckets, "d")
+}
+
+func BenchmarkLimiter_NewKeys(b *testing.B) {
+	limiter := NewLimiter(WithMaxKeys(1000))
+	limit := PerSecond(1000)
+	for i := 0; i < b.N; i++ {
+		limiter.Allow(fmt.Sprint("ip:", i), limit)
+	}
+}
+
+func TestLimit_Key(t *testing.T) {
+	assert.Equal(t, "POST /ads ip:10.0.0.1", PerMinute(1).Key("POST /ads", "ip:10.0.0.1"))
+	// маршруты с общим лимитом тратят одно ведро
+	assert.Equal(t, CreateAd.Key("POST /ads", "ip:10.0.0.1"), CreateAd.Key("/ad.AdService/AddAd", "ip:10.0.0.1"))
+	assert.NotEqual(t, CreateAd.Key("POST /ads", "ip:10.0.0.1"), CreateUser.Key("POST /users", "ip:10.0.0.1"))
+}
+
+func TestRules_Lookup(t *testing.T) {
+	rules := Rules{"POST /ads": PerMinute(10), "*": PerSecond(100)}
+	limit, ok := rules.Lookup("POST /ads")
+	assert.True(t, ok)
+	assert.Equal(t, 10, limit.Burst)
+	limit, ok = rules.Lookup("GET /ads")
+	assert.True(t, ok)
+	assert.Equal(t, PerSecond(100), limit)
+
+	_, ok = Rules{"POST /ads": PerMinute(10)}.Lookup("GET /ads")
+	assert.False(t, ok)
+}
+
+func BenchmarkLimiter_Allow(b *testing.B) {
+	limiter := NewLimiter()
+	limit := PerSecond(1000)
+	for i := 0; i < b.N; i++ {
+		limiter.Allow(fmt.Sprint("user:", i%1000), limit)
+	}
+}
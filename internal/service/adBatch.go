@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AirstaNs/ValidationAds"
+	"homework10/internal/apperr"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"time"
+)
+
+// MaxBatchSize ограничивает число элементов в одном пакетном запросе
+const MaxBatchSize = 100
+
+var (
+	ErrBatchTooLarge = apperr.New(apperr.InvalidArgument, "batch_too_large", "too many items in batch")
+	ErrBatchEmpty    = apperr.New(apperr.InvalidArgument, "batch_empty", "batch is empty")
+	// ErrBatchAborted элемент не применен или откачен, потому что в режиме "все или ничего" упал другой элемент
+	ErrBatchAborted = apperr.New(apperr.Aborted, "batch_aborted", "batch aborted")
+)
+
+type NewAd struct {
+	Title    string
+	Text     string
+	AuthorID int64
+}
+
+type AdStatusChange struct {
+	AdID      int64
+	AuthorID  int64
+	Published bool
+}
+
+type AdRef struct {
+	AdID     int64
+	AuthorID int64
+}
+
+// BatchResult результат одного элемента пакета, порядок совпадает с порядком запроса
+type BatchResult struct {
+	Ad  *entities.Ad
+	Err error
+}
+
+// batchStep применяет один элемент и возвращает функцию отката
+type batchStep func(i int) (*entities.Ad, func() error, error)
+
+// CreateAds создает объявления через ImportAd, автор каждого объявления должен существовать
+func (a *adService) CreateAds(ctx context.Context, ads []NewAd, allOrNothing bool) ([]BatchResult, error) {
+	return runBatch(len(ads), allOrNothing, func(i int) (*entities.Ad, func() error, error) {
+		reservedAt := time.Now().UTC()
+		ad, err := a.ImportAd(ctx, ads[i], false)
+		if err != nil {
+			return ad, nil, err
+		}
+		return ad, func() error {
+			if err := a.undo(ctx, events.AdDeleted{Ad: *ad}); err != nil {
+				return err
+			}
+			// объявление не удалено пользователем, а не создано вовсе: лимит за сутки возвращается
+			a.ledger.Cancel(ad.AuthorID, reservedAt)
+			return nil
+		}, nil
+	})
+}
+
+// ImportAd проверяет объявление так же, как CreateAd, и дополнительно существование автора.
+// В режиме dryRun объявление только проверяется и не сохраняется
+func (a *adService) ImportAd(ctx context.Context, ad NewAd, dryRun bool) (*entities.Ad, error) {
+	draft := &entities.Ad{Title: ad.Title, Text: ad.Text, AuthorID: ad.AuthorID}
+	if _, err := a.userRepository.GetUserByID(ad.AuthorID); err != nil {
+		return draft, err
+	}
+	if !dryRun {
+		return a.CreateAd(ctx, ad.Title, ad.Text, ad.AuthorID)
+	}
+	if err := ValidationAds.ValidateTitle(ad.Title); err != nil {
+		return draft, err
+	}
+	if err := ValidationAds.ValidateText(ad.Text); err != nil {
+		return draft, err
+	}
+	return draft, nil
+}
+
+// ChangeAdsStatus меняет статус через ChangeAdStatus, проверки автора и подтверждения email те же
+func (a *adService) ChangeAdsStatus(ctx context.Context, changes []AdStatusChange, allOrNothing bool) ([]BatchResult, error) {
+	return runBatch(len(changes), allOrNothing, func(i int) (*entities.Ad, func() error, error) {
+		item := changes[i]
+		prev, err := a.adRepository.GetAdByID(item.AdID)
+		if err != nil {
+			return nil, nil, err
+		}
+		ad, err := a.ChangeAdStatus(ctx, item.AdID, item.AuthorID, item.Published)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ad, func() error { return a.undo(ctx, events.AdStatusChanged{Ad: *prev, Prev: *ad}) }, nil
+	})
+}
+
+// RemoveAds удаляет объявления через RemoveAd, удалить можно только свои объявления
+func (a *adService) RemoveAds(ctx context.Context, refs []AdRef, allOrNothing bool) ([]BatchResult, error) {
+	return runBatch(len(refs), allOrNothing, func(i int) (*entities.Ad, func() error, error) {
+		item := refs[i]
+		prev, err := a.adRepository.GetAdByID(item.AdID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err = a.RemoveAd(ctx, item.AdID, item.AuthorID); err != nil {
+			return nil, nil, err
+		}
+		return prev, func() error { return a.undo(ctx, events.AdCreated{Ad: *prev}) }, nil
+	})
+}
+
+// undo применяет обратное изменение и сообщает о нем подписчикам, как об обычном изменении
+func (a *adService) undo(ctx context.Context, event events.Event) error {
+	var err error
+	switch e := event.(type) {
+	case events.AdDeleted:
+		err = a.adRepository.DeleteAd(e.Ad.ID)
+	case events.AdCreated:
+		err = a.adRepository.RestoreAds([]entities.Ad{e.Ad})
+	case events.AdStatusChanged:
+		err = a.adRepository.RestoreAds([]entities.Ad{e.Ad})
+	}
+	if err != nil {
+		return err
+	}
+	a.bus.Publish(ctx, event)
+	return nil
+}
+
+// runBatch применяет элементы по порядку. В режиме allOrNothing после первой ошибки
+// примененные элементы откатываются в обратном порядке, остальные не применяются
+func runBatch(n int, allOrNothing bool, step batchStep) ([]BatchResult, error) {
+	if n == 0 {
+		return nil, ErrBatchEmpty
+	}
+	if n > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	results := make([]BatchResult, n)
+	undo := make([]func() error, 0, n)
+	for i := 0; i < n; i++ {
+		ad, rollback, err := step(i)
+		results[i] = BatchResult{Ad: ad, Err: err}
+		if err == nil {
+			undo = append(undo, rollback)
+			continue
+		}
+		if !allOrNothing {
+			continue
+		}
+
+		var undoErr error
+		for j := len(undo) - 1; j >= 0; j-- {
+			undoErr = errors.Join(undoErr, undo[j]())
+		}
+		for j := range results {
+			if j != i {
+				results[j] = BatchResult{Ad: results[j].Ad, Err: ErrBatchAborted}
+			}
+		}
+		return results, errors.Join(ErrBatchAborted, undoErr)
+	}
+	return results, nil
+}
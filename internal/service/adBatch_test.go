@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"github.com/AirstaNs/ValidationAds"
+	"github.com/stretchr/testify/suite"
+	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/util"
+	"testing"
+	"time"
+)
+
+type batchSuite struct {
+	suite.Suite
+	service AdService
+	adRepo  adrepo.AdRepository
+	author  int64
+	other   int64
+}
+
+func TestSuiteAdBatch(t *testing.T) {
+	suite.Run(t, new(batchSuite))
+}
+
+func (s *batchSuite) SetupTest() {
+	s.adRepo = adrepo.New()
+	userRepo := userrepo.New()
+	s.service = NewAdsService(s.adRepo, userRepo, util.NewDateTimeFormatter(time.DateTime), events.NewBus())
+	s.author, _ = userRepo.AddUser(entities.User{Nickname: "author", Email: "author@mail.ru", Verified: true})
+	s.other, _ = userRepo.AddUser(entities.User{Nickname: "other", Email: "other@mail.ru", Verified: true})
+}
+
+func (s *batchSuite) createAds(n int) []int64 {
+	ids := make([]int64, 0, n)
+	for i := 0; i < n; i++ {
+		ad, err := s.service.CreateAd(context.Background(), "title", "text", s.author)
+		s.Require().NoError(err)
+		ids = append(ids, ad.ID)
+	}
+	return ids
+}
+
+func (s *batchSuite) Test_CreateAds_Partial() {
+	results, err := s.service.CreateAds(context.Background(), []NewAd{
+		{Title: "title", Text: "text", AuthorID: s.author},
+		{Title: "", Text: "text", AuthorID: s.author},
+		{Title: "title", Text: "text", AuthorID: badID},
+	}, false)
+	s.NoError(err)
+	s.Len(results, 3)
+	s.NoError(results[0].Err)
+	s.ErrorIs(results[1].Err, ValidationAds.ErrBadTitle)
+	s.ErrorIs(results[2].Err, userrepo.ErrEmptyUser)
+
+	_, err = s.adRepo.GetAdByID(results[0].Ad.ID)
+	s.NoError(err)
+}
+
+func (s *batchSuite) Test_CreateAds_AllOrNothing() {
+	results, err := s.service.CreateAds(context.Background(), []NewAd{
+		{Title: "title", Text: "text", AuthorID: s.author},
+		{Title: "", Text: "text", AuthorID: s.author},
+		{Title: "title", Text: "text", AuthorID: s.author},
+	}, true)
+	s.ErrorIs(err, ErrBatchAborted)
+	s.ErrorIs(results[0].Err, ErrBatchAborted)
+	s.ErrorIs(results[1].Err, ValidationAds.ErrBadTitle)
+	s.ErrorIs(results[2].Err, ErrBatchAborted)
+
+	_, err = s.adRepo.GetAdByID(results[0].Ad.ID)
+	s.ErrorIs(err, util.ErrNotFound)
+	// откаченные объявления не расходуют лимит за сутки
+	usage, err := s.service.QuotaUsage(context.Background(), s.author)
+	s.NoError(err)
+	s.Equal(0, usage.DailyAds)
+}
+
+func (s *batchSuite) Test_ChangeAdsStatus_AllOrNothing() {
+	ids := s.createAds(2)
+
+	results, err := s.service.ChangeAdsStatus(context.Background(), []AdStatusChange{
+		{AdID: ids[0], AuthorID: s.author, Published: true},
+		{AdID: ids[1], AuthorID: s.other, Published: true},
+	}, true)
+	s.ErrorIs(err, ErrBatchAborted)
+	s.ErrorIs(results[1].Err, ValidationAds.ErrBadAuthorID)
+
+	ad, err := s.adRepo.GetAdByID(ids[0])
+	s.NoError(err)
+	s.False(ad.Published)
+}
+
+func (s *batchSuite) Test_ChangeAdsStatus() {
+	ids := s.createAds(2)
+
+	results, err := s.service.ChangeAdsStatus(context.Background(), []AdStatusChange{
+		{AdID: ids[0], AuthorID: s.author, Published: true},
+		{AdID: ids[1], AuthorID: s.author, Published: true},
+	}, true)
+	s.NoError(err)
+	for _, r := range results {
+		s.NoError(r.Err)
+		s.True(r.Ad.Published)
+	}
+}
+
+func (s *batchSuite) Test_RemoveAds_AllOrNothing() {
+	ids := s.createAds(2)
+
+	results, err := s.service.RemoveAds(context.Background(), []AdRef{
+		{AdID: ids[0], AuthorID: s.author},
+		{AdID: ids[1], AuthorID: s.author},
+		{AdID: badID, AuthorID: s.author},
+	}, true)
+	s.ErrorIs(err, ErrBatchAborted)
+	s.ErrorIs(results[2].Err, util.ErrNotFound)
+
+	for _, id := range ids {
+		_, err = s.adRepo.GetAdByID(id)
+		s.NoError(err)
+	}
+}
+
+func (s *batchSuite) Test_RemoveAds_Partial() {
+	ids := s.createAds(2)
+
+	results, err := s.service.RemoveAds(context.Background(), []AdRef{
+		{AdID: ids[0], AuthorID: s.author},
+		{AdID: ids[1], AuthorID: s.other},
+	}, false)
+	s.NoError(err)
+	s.NoError(results[0].Err)
+	s.ErrorIs(results[1].Err, ValidationAds.ErrBadAuthorID)
+
+	_, err = s.adRepo.GetAdByID(ids[0])
+	s.ErrorIs(err, util.ErrNotFound)
+}
+
+func (s *batchSuite) Test_Batch_Size() {
+	_, err := s.service.RemoveAds(context.Background(), nil, false)
+	s.ErrorIs(err, ErrBatchEmpty)
+
+	_, err = s.service.RemoveAds(context.Background(), make([]AdRef, MaxBatchSize+1), false)
+	s.ErrorIs(err, ErrBatchTooLarge)
+}
+
+func (s *batchSuite) Test_ImportAd_DryRun() {
+	ad, err := s.service.ImportAd(context.Background(), NewAd{Title: "title", Text: "text", AuthorID: s.author}, true)
+	s.NoError(err)
+	s.Equal("title", ad.Title)
+
+	_, err = s.service.ImportAd(context.Background(), NewAd{Title: "", Text: "text", AuthorID: s.author}, true)
+	s.ErrorIs(err, ValidationAds.ErrBadTitle)
+	_, err = s.service.ImportAd(context.Background(), NewAd{Title: "title", Text: "text", AuthorID: badID}, true)
+	s.ErrorIs(err, userrepo.ErrEmptyUser)
+
+	ads, err := s.adRepo.GetAdsByFilters(nil)
+	s.NoError(err)
+	s.Empty(ads)
+}
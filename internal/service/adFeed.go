@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"homework10/internal/apperr"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"sync"
+)
+
+// ErrEventsExpired события после запрошенного ID уже вытеснены из истории, продолжить поток нельзя
+var ErrEventsExpired = apperr.New(apperr.FailedPrecondition, "events_expired", "events after the given id are no longer available")
+
+type AdEventType string
+
+const (
+	AdCreated       AdEventType = "created"
+	AdUpdated       AdEventType = "updated"
+	AdStatusChanged AdEventType = "status"
+	AdDeleted       AdEventType = "deleted"
+)
+
+// feedHistory столько последних событий хранится для продолжения потока после переподключения
+const feedHistory = 1024
+
+// AdEvent изменение объявления. Prev - состояние до изменения, для AdCreated не заполняется.
+// ID назначается при публикации и возрастает на 1 с каждым событием
+type AdEvent struct {
+	ID   int64
+	Type AdEventType
+	Ad   entities.Ad
+	Prev *entities.Ad
+}
+
+// AdFeed рассылает изменения объявлений подписчикам внутри процесса
+type AdFeed interface {
+	Publish(event AdEvent)
+	// Subscribe возвращает канал событий. Если подписчик не успевает читать и буфер заполнен,
+	// канал закрывается, чтобы Publish никогда не блокировался
+	Subscribe(buffer int) (events <-chan AdEvent, cancel func())
+	// SubscribeSince как Subscribe, но дополнительно возвращает события после lastID из истории.
+	// При отрицательном lastID пропущенных событий нет. head - ID последнего события на момент подписки
+	SubscribeSince(lastID int64, buffer int) (missed []AdEvent, head int64, events <-chan AdEvent, cancel func(), err error)
+}
+
+type adFeed struct {
+	mutex       sync.Mutex
+	subscribers map[int]chan AdEvent
+	nextID      int
+	// history кольцевой буфер, lastID - ID последнего события
+	history []AdEvent
+	lastID  int64
+}
+
+func NewAdFeed() AdFeed {
+	return newAdFeed(feedHistory)
+}
+
+func newAdFeed(history int) *adFeed {
+	return &adFeed{subscribers: make(map[int]chan AdEvent), history: make([]AdEvent, 0, history)}
+}
+
+func (f *adFeed) Publish(event AdEvent) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.lastID++
+	event.ID = f.lastID
+	if len(f.history) < cap(f.history) {
+		f.history = append(f.history, event)
+	} else if cap(f.history) > 0 {
+		f.history[(event.ID-1)%int64(cap(f.history))] = event
+	}
+
+	for id, ch := range f.subscribers {
+		select {
+		case ch <- event:
+		default:
+			close(ch)
+			delete(f.subscribers, id)
+		}
+	}
+}
+
+func (f *adFeed) Subscribe(buffer int) (<-chan AdEvent, func()) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.subscribe(buffer)
+}
+
+func (f *adFeed) SubscribeSince(lastID int64, buffer int) ([]AdEvent, int64, <-chan AdEvent, func(), error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if lastID < 0 {
+		lastID = f.lastID
+	}
+	oldest := f.lastID - int64(len(f.history)) + 1
+	if lastID < oldest-1 || lastID > f.lastID {
+		return nil, f.lastID, nil, nil, ErrEventsExpired
+	}
+	missed := make([]AdEvent, 0, f.lastID-lastID)
+	for id := lastID + 1; id <= f.lastID; id++ {
+		missed = append(missed, f.history[(id-1)%int64(cap(f.history))])
+	}
+	events, cancel := f.subscribe(buffer)
+	return missed, f.lastID, events, cancel, nil
+}
+
+func (f *adFeed) subscribe(buffer int) (<-chan AdEvent, func()) {
+	id := f.nextID
+	f.nextID++
+	ch := make(chan AdEvent, buffer)
+	f.subscribers[id] = ch
+	cancel := func() {
+		f.mutex.Lock()
+		defer f.mutex.Unlock()
+		if _, ok := f.subscribers[id]; ok {
+			close(ch)
+			delete(f.subscribers, id)
+		}
+	}
+	return ch, cancel
+}
+
+// feedSubscriber переводит доменные события объявлений в события ленты. Подписчик синхронный,
+// чтобы событие попало в ленту до ответа на запрос, который его вызвал
+func feedSubscriber(feed AdFeed) events.Subscriber {
+	return events.Subscriber{
+		Name:   "ad feed",
+		Events: []string{events.NameAdCreated, events.NameAdUpdated, events.NameAdStatusChanged, events.NameAdDeleted},
+		Retry:  events.NoRetry,
+		Handler: func(ctx context.Context, event events.Event) error {
+			switch e := event.(type) {
+			case events.AdCreated:
+				feed.Publish(AdEvent{Type: AdCreated, Ad: e.Ad})
+			case events.AdUpdated:
+				feed.Publish(AdEvent{Type: AdUpdated, Ad: e.Ad, Prev: &e.Prev})
+			case events.AdStatusChanged:
+				feed.Publish(AdEvent{Type: AdStatusChanged, Ad: e.Ad, Prev: &e.Prev})
+			case events.AdDeleted:
+				feed.Publish(AdEvent{Type: AdDeleted, Ad: e.Ad, Prev: &e.Ad})
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/util"
+	"testing"
+	"time"
+)
+
+func TestAdFeed_Publish(t *testing.T) {
+	feed := NewAdFeed()
+	events, cancel := feed.Subscribe(1)
+	defer cancel()
+
+	feed.Publish(AdEvent{Type: AdCreated, Ad: entities.Ad{ID: 1}})
+	event := <-events
+	assert.Equal(t, AdCreated, event.Type)
+	assert.Equal(t, int64(1), event.Ad.ID)
+}
+
+func TestAdFeed_SlowSubscriber(t *testing.T) {
+	feed := NewAdFeed()
+	events, cancel := feed.Subscribe(1)
+	defer cancel()
+
+	feed.Publish(AdEvent{Type: AdCreated})
+	feed.Publish(AdEvent{Type: AdUpdated})
+
+	<-events
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestAdFeed_Cancel(t *testing.T) {
+	feed := NewAdFeed()
+	events, cancel := feed.Subscribe(1)
+	cancel()
+	cancel()
+
+	feed.Publish(AdEvent{Type: AdCreated})
+	_, ok := <-events
+	assert.False(t, ok)
+}
+
+func TestAdFeed_SubscribeSince(t *testing.T) {
+	feed := newAdFeed(2)
+	for i := 0; i < 3; i++ {
+		feed.Publish(AdEvent{Type: AdCreated, Ad: entities.Ad{ID: int64(i)}})
+	}
+
+	missed, head, _, cancel, err := feed.SubscribeSince(1, 1)
+	assert.NoError(t, err)
+	cancel()
+	assert.Equal(t, int64(3), head)
+	assert.Len(t, missed, 2)
+	assert.Equal(t, int64(2), missed[0].ID)
+	assert.Equal(t, int64(3), missed[1].ID)
+
+	missed, head, _, cancel, err = feed.SubscribeSince(-1, 1)
+	assert.NoError(t, err)
+	cancel()
+	assert.Empty(t, missed)
+	assert.Equal(t, int64(3), head)
+
+	// событие 1 вытеснено из истории, а события 4 еще не было
+	_, _, _, _, err = feed.SubscribeSince(0, 1)
+	assert.ErrorIs(t, err, ErrEventsExpired)
+	_, _, _, _, err = feed.SubscribeSince(4, 1)
+	assert.ErrorIs(t, err, ErrEventsExpired)
+}
+
+func TestAdService_ResumeAds(t *testing.T) {
+	userRepo := userrepo.New()
+	service := NewAdsService(adrepo.New(), userRepo, util.NewDateTimeFormatter(time.DateTime), events.NewBus())
+	author, _ := userRepo.AddUser(entities.User{Nickname: "author", Email: "author@mail.ru", Verified: true})
+	other, _ := userRepo.AddUser(entities.User{Nickname: "other", Email: "other@mail.ru", Verified: true})
+	ad, err := service.CreateAd(context.Background(), "title", "text", author)
+	assert.NoError(t, err)
+	_, err = service.CreateAd(context.Background(), "title", "text", other)
+	assert.NoError(t, err)
+	_, err = service.ChangeAdStatus(context.Background(), ad.ID, author, true)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := AdFilters{AuthorID: author, Published: true}
+	watch, err := service.ResumeAds(ctx, filters, 1)
+	assert.NoError(t, err)
+	assert.False(t, watch.Reset)
+	assert.Nil(t, watch.Snapshot)
+	assert.Equal(t, int64(3), watch.Head)
+	assert.Len(t, watch.Missed, 1)
+	assert.Equal(t, AdStatusChanged, watch.Missed[0].Type)
+
+	watch, err = service.ResumeAds(ctx, filters, 100)
+	assert.NoError(t, err)
+	assert.True(t, watch.Reset)
+	assert.Len(t, watch.Snapshot, 1)
+}
+
+func TestAdService_WatchAds(t *testing.T) {
+	userRepo := userrepo.New()
+	service := NewAdsService(adrepo.New(), userRepo, util.NewDateTimeFormatter(time.DateTime), events.NewBus())
+	author, _ := userRepo.AddUser(entities.User{Nickname: "author", Email: "author@mail.ru", Verified: true})
+	other, _ := userRepo.AddUser(entities.User{Nickname: "other", Email: "other@mail.ru", Verified: true})
+	existing, err := service.CreateAd(context.Background(), "title", "text", author)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ads, events, err := service.WatchAds(ctx, AdFilters{AuthorID: -1, Published: true})
+	assert.NoError(t, err)
+	assert.Empty(t, ads)
+
+	// неопубликованное объявление не подходит под фильтры
+	_, err = service.CreateAd(context.Background(), "title", "text", other)
+	assert.NoError(t, err)
+	_, err = service.ChangeAdStatus(context.Background(), existing.ID, author, true)
+	assert.NoError(t, err)
+	_, err = service.ChangeAdStatus(context.Background(), existing.ID, author, false)
+	assert.NoError(t, err)
+
+	event := <-events
+	assert.Equal(t, AdStatusChanged, event.Type)
+	assert.True(t, event.Ad.Published)
+	// снятие с публикации приходит, потому что прежнее состояние подходило под фильтры
+	event = <-events
+	assert.Equal(t, AdStatusChanged, event.Type)
+	assert.False(t, event.Ad.Published)
+
+	cancel()
+	for range events {
+	}
+}
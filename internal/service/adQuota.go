@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"homework10/internal/quota"
+	"time"
+)
+
+// QuotaUsage тариф пользователя и сколько его лимитов уже использовано
+func (a *adService) QuotaUsage(ctx context.Context, userID int64) (*quota.Usage, error) {
+	if _, err := a.userRepository.GetUserByID(userID); err != nil {
+		return nil, err
+	}
+	active, err := a.activeAds(userID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	return &quota.Usage{
+		Plan:      a.planFor(userID),
+		ActiveAds: active,
+		DailyAds:  a.ledger.Created(userID, now),
+		ResetsAt:  quota.NextDay(now),
+	}, nil
+}
+
+func (a *adService) planFor(userID int64) quota.Plan {
+	return a.quotas.PlanFor(userID, a.roleOf(userID))
+}
+
+// reserveCreation учитывает новое объявление в лимите за сутки
+func (a *adService) reserveCreation(authorID int64, now time.Time) error {
+	plan := a.planFor(authorID)
+	if a.ledger.Reserve(authorID, now, plan.MaxDailyAds) {
+		return nil
+	}
+	return &quota.ExceededError{Kind: quota.DailyAds, Plan: plan.Name, Limit: plan.MaxDailyAds, RetryAfter: quota.NextDay(now).Sub(now)}
+}
+
+// checkActiveAds можно ли опубликовать еще одно объявление, вызывается под publishMutex
+func (a *adService) checkActiveAds(authorID int64) error {
+	plan := a.planFor(authorID)
+	if plan.MaxActiveAds <= 0 {
+		return nil
+	}
+	active, err := a.activeAds(authorID)
+	if err != nil {
+		return err
+	}
+	if active >= plan.MaxActiveAds {
+		return &quota.ExceededError{Kind: quota.ActiveAds, Plan: plan.Name, Limit: plan.MaxActiveAds}
+	}
+	return nil
+}
+
+// activeAds счетчик репозитория, а не выборка всех объявлений: вызывается при каждой публикации
+func (a *adService) activeAds(authorID int64) (int, error) {
+	return a.adRepository.ActiveAds(authorID)
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/quota"
+	"homework10/internal/util"
+	"testing"
+	"time"
+)
+
+func TestAdService_Quotas(t *testing.T) {
+	userRepo := userrepo.New()
+	author, _ := userRepo.AddUser(entities.User{Nickname: "author", Email: "author@mail.ru", Verified: true})
+	admin, _ := userRepo.AddUser(entities.User{Nickname: "admin", Email: "admin@mail.ru", Verified: true})
+	policy := quota.Policy{
+		Default: quota.Plan{Name: "free", MaxActiveAds: 1, MaxDailyAds: 2},
+		Roles:   map[quota.Role]quota.Plan{quota.RoleAdmin: quota.Unlimited},
+	}
+	roleOf := func(userID int64) quota.Role {
+		if userID == admin {
+			return quota.RoleAdmin
+		}
+		return quota.RoleUser
+	}
+	service := NewAdsService(adrepo.New(), userRepo, util.NewDateTimeFormatter(time.DateTime), events.NewBus(), WithQuotas(policy, roleOf))
+	ctx := context.Background()
+
+	first, err := service.CreateAd(ctx, "title", "text", author)
+	assert.NoError(t, err)
+	second, err := service.CreateAd(ctx, "title", "text", author)
+	assert.NoError(t, err)
+	_, err = service.CreateAd(ctx, "title", "text", author)
+	assert.ErrorIs(t, err, quota.ErrExceeded)
+	exceeded := err.(*quota.ExceededError)
+	assert.Equal(t, quota.DailyAds, exceeded.Kind)
+	assert.Positive(t, exceeded.RetryAfter)
+	// неудачная проверка не расходует лимит
+	_, err = service.CreateAd(ctx, "", "text", author)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, quota.ErrExceeded)
+
+	_, err = service.ChangeAdStatus(ctx, first.ID, author, true)
+	assert.NoError(t, err)
+	// повторная публикация опубликованного объявления не считается новой
+	_, err = service.ChangeAdStatus(ctx, first.ID, author, true)
+	assert.NoError(t, err)
+	_, err = service.ChangeAdStatus(ctx, second.ID, author, true)
+	assert.ErrorIs(t, err, quota.ErrExceeded)
+	assert.Equal(t, quota.ActiveAds, err.(*quota.ExceededError).Kind)
+
+	_, err = service.ChangeAdStatus(ctx, first.ID, author, false)
+	assert.NoError(t, err)
+	_, err = service.ChangeAdStatus(ctx, second.ID, author, true)
+	assert.NoError(t, err)
+
+	usage, err := service.QuotaUsage(ctx, author)
+	assert.NoError(t, err)
+	assert.Equal(t, policy.Default, usage.Plan)
+	assert.Equal(t, 1, usage.ActiveAds)
+	assert.Equal(t, 2, usage.DailyAds)
+	assert.Equal(t, quota.NextDay(time.Now()), usage.ResetsAt)
+
+	for i := 0; i < 3; i++ {
+		_, err = service.CreateAd(ctx, "title", "text", admin)
+		assert.NoError(t, err)
+	}
+	_, err = service.QuotaUsage(ctx, 100)
+	assert.ErrorIs(t, err, userrepo.ErrEmptyUser)
+}
@@ -1,18 +1,32 @@
 package service
 
 import (
+	"errors"
 	"github.com/AirstaNs/ValidationAds"
 	"golang.org/x/net/context"
 	"homework10/internal/adapters/repository/adrepo"
+	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/audit"
 	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/quota"
 	"homework10/internal/util"
 	"strings"
+	"sync"
 	"time"
 )
 
 type adService struct {
 	adRepository   adrepo.AdRepository
+	userRepository userrepo.UserRepository
 	dateTimeFormat util.DateTimeFormatter
+	bus            events.Bus
+	feed           AdFeed
+	quotas         quota.Policy
+	roleOf         func(userID int64) quota.Role
+	ledger         quota.Ledger
+	// publishMutex проверка лимита опубликованных объявлений и публикация выполняются вместе
+	publishMutex sync.Mutex
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.25.0 --name=AdService --filename=mockAdservice.go --output ../mocks/servicemocks
@@ -20,10 +34,22 @@ type AdService interface {
 	CreateAd(ctx context.Context, title string, text string, authorID int64) (*entities.Ad, error)
 	ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*entities.Ad, error)
 	UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*entities.Ad, error)
+	PatchAd(ctx context.Context, adID int64, authorID int64, patch AdPatch) (*entities.Ad, error)
 	GetAdByID(ctx context.Context, adID int64) (*entities.Ad, error)
 	GetAdsByFilter(ctx context.Context, filters AdFilters) ([]entities.Ad, error)
+	// EachAd вызывает fn для каждого объявления по фильтрам в порядке ID, читая их страницами по adPageSize.
+	// Между страницами репозиторий не заблокирован, поэтому объявления, измененные во время обхода,
+	// попадают в него в состоянии на момент чтения своей страницы
+	EachAd(ctx context.Context, filters AdFilters, fn func(ad entities.Ad) error) error
 	GetDateTimeFormat() util.DateTimeFormatter
 	RemoveAd(ctx context.Context, adID int64, authorID int64) error
+	CreateAds(ctx context.Context, ads []NewAd, allOrNothing bool) ([]BatchResult, error)
+	ChangeAdsStatus(ctx context.Context, changes []AdStatusChange, allOrNothing bool) ([]BatchResult, error)
+	RemoveAds(ctx context.Context, refs []AdRef, allOrNothing bool) ([]BatchResult, error)
+	ImportAd(ctx context.Context, ad NewAd, dryRun bool) (*entities.Ad, error)
+	WatchAds(ctx context.Context, filters AdFilters) ([]entities.Ad, <-chan AdEvent, error)
+	ResumeAds(ctx context.Context, filters AdFilters, lastEventID int64) (*AdWatch, error)
+	QuotaUsage(ctx context.Context, userID int64) (*quota.Usage, error)
 }
 
 type AdFilters struct {
@@ -31,16 +57,54 @@ type AdFilters struct {
 	Published  bool      `form:"published,query,default=true"`
 	CreateDate time.Time `form:"create_Date,query,default=0001-01-01T00:00:00Z"`
 	Title      string    `form:"title,query"`
+	// AuthorIDs объявления любого из авторов, например для выборки сразу для нескольких пользователей
+	AuthorIDs []int64 `form:"-"`
 }
 
-func NewAdsService(adRepo adrepo.AdRepository, dateTimeFormatter util.DateTimeFormatter) AdService {
-	return &adService{
+// AdPatch частичное изменение объявления: проверяются и записываются только заданные поля, nil - не менять
+type AdPatch struct {
+	Title *string
+	Text  *string
+}
+
+// adPageSize столько объявлений EachAd держит в памяти
+const adPageSize = 100
+
+// watchBuffer столько событий может накопиться у подписчика WatchAds, прежде чем его отключат
+const watchBuffer = 64
+
+type AdServiceOption func(*adService)
+
+// WithQuotas включает лимиты тарифов, roleOf определяет роль пользователя. По умолчанию лимитов нет
+func WithQuotas(policy quota.Policy, roleOf func(userID int64) quota.Role) AdServiceOption {
+	return func(a *adService) {
+		a.quotas = policy
+		a.roleOf = roleOf
+	}
+}
+
+func NewAdsService(adRepo adrepo.AdRepository, userRepo userrepo.UserRepository, dateTimeFormatter util.DateTimeFormatter, bus events.Bus, opts ...AdServiceOption) AdService {
+	// лента WatchAds - один из подписчиков шины, сервис о ней при публикации не знает
+	feed := NewAdFeed()
+	bus.Subscribe(feedSubscriber(feed))
+	a := &adService{
 		adRepository:   adRepo,
+		userRepository: userRepo,
 		dateTimeFormat: dateTimeFormatter,
+		bus:            bus,
+		feed:           feed,
+		quotas:         quota.Policy{Default: quota.Unlimited},
+		roleOf:         func(int64) quota.Role { return quota.RoleUser },
+		ledger:         quota.NewLedger(),
 	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 func (a *adService) CreateAd(ctx context.Context, title string, text string, authorID int64) (*entities.Ad, error) {
+	ctx = audit.WithActor(ctx, authorID)
 	parse, err := a.dateTimeFormat.ToTime(time.Now().UTC())
 	if err != nil {
 		return nil, err
@@ -64,18 +128,25 @@ func (a *adService) CreateAd(ctx context.Context, title string, text string, aut
 	if err = ValidationAds.ValidateText(text); err != nil {
 		return &ad, err
 	}
+	now := time.Now().UTC()
+	if err = a.reserveCreation(authorID, now); err != nil {
+		return &ad, err
+	}
 
 	id, err := a.adRepository.AddAd(ad)
 	ad.ID = id
 
 	if err != nil {
+		a.ledger.Cancel(authorID, now)
 		return &ad, err
 	}
 
+	a.bus.Publish(ctx, events.AdCreated{Ad: ad})
 	return &ad, nil
 }
 
 func (a *adService) ChangeAdStatus(ctx context.Context, adID int64, authorID int64, published bool) (*entities.Ad, error) {
+	ctx = audit.WithActor(ctx, authorID)
 	ad, err := a.adRepository.GetAdByID(adID)
 	if err != nil {
 		return ad, err
@@ -83,17 +154,44 @@ func (a *adService) ChangeAdStatus(ctx context.Context, adID int64, authorID int
 	if err = ValidationAds.ValidateAuthorID(ad.AuthorID, authorID); err != nil {
 		return ad, err
 	}
+	// публиковать объявления может только пользователь с подтвержденным email
+	if published {
+		author, err := a.userRepository.GetUserByID(authorID)
+		if err != nil {
+			return ad, err
+		}
+		if !author.Verified {
+			return ad, ErrNotVerified
+		}
+	}
+	if published && !ad.Published {
+		a.publishMutex.Lock()
+		defer a.publishMutex.Unlock()
+		if err = a.checkActiveAds(authorID); err != nil {
+			return ad, err
+		}
+	}
 
 	dateUpdate, err := a.dateTimeFormat.ToTime(time.Now().UTC())
 	if err != nil {
 		return ad, err
 	}
 
-	return a.adRepository.EditAdStatus(ad, published, dateUpdate)
-
+	prev := *ad
+	changed, err := a.adRepository.EditAdStatus(ad, published, dateUpdate)
+	if err != nil {
+		return changed, err
+	}
+	a.bus.Publish(ctx, events.AdStatusChanged{Ad: *changed, Prev: prev})
+	return changed, nil
 }
 
 func (a *adService) UpdateAd(ctx context.Context, adID int64, authorID int64, title string, text string) (*entities.Ad, error) {
+	return a.PatchAd(ctx, adID, authorID, AdPatch{Title: &title, Text: &text})
+}
+
+func (a *adService) PatchAd(ctx context.Context, adID int64, authorID int64, patch AdPatch) (*entities.Ad, error) {
+	ctx = audit.WithActor(ctx, authorID)
 	ad, err := a.adRepository.GetAdByID(adID)
 	if err != nil {
 		return ad, err
@@ -103,18 +201,34 @@ func (a *adService) UpdateAd(ctx context.Context, adID int64, authorID int64, ti
 		return ad, err
 	}
 
-	if err = ValidationAds.ValidateTitle(title); err != nil {
-		return ad, err
+	title, text := ad.Title, ad.Text
+	if patch.Title != nil {
+		if err = ValidationAds.ValidateTitle(*patch.Title); err != nil {
+			return ad, err
+		}
+		title = *patch.Title
 	}
-	if err = ValidationAds.ValidateText(text); err != nil {
-		return ad, err
+	if patch.Text != nil {
+		if err = ValidationAds.ValidateText(*patch.Text); err != nil {
+			return ad, err
+		}
+		text = *patch.Text
+	}
+	if patch.Title == nil && patch.Text == nil {
+		return ad, nil
 	}
 
 	dateUpdate, err := a.dateTimeFormat.ToTime(time.Now().UTC())
 	if err != nil {
 		return ad, err
 	}
-	return a.adRepository.ChangeAdText(adID, title, text, dateUpdate)
+	prev := *ad
+	changed, err := a.adRepository.ChangeAdText(adID, title, text, dateUpdate)
+	if err != nil {
+		return changed, err
+	}
+	a.bus.Publish(ctx, events.AdUpdated{Ad: *changed, Prev: prev})
+	return changed, nil
 }
 
 func (a *adService) GetAdByID(ctx context.Context, adID int64) (*entities.Ad, error) {
@@ -123,38 +237,168 @@ func (a *adService) GetAdByID(ctx context.Context, adID int64) (*entities.Ad, er
 
 // GetAdsByFilter Поиск объявлений по названию тоже организован через фильтры
 func (a *adService) GetAdsByFilter(ctx context.Context, filters AdFilters) ([]entities.Ad, error) {
+	return a.adRepository.GetAdsByFilters(filters.predicates())
+}
+
+func (a *adService) EachAd(ctx context.Context, filters AdFilters, fn func(ad entities.Ad) error) error {
+	predicates := filters.predicates()
+	afterID := int64(-1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		page, err := a.adRepository.GetAdsPage(predicates, afterID, adPageSize)
+		if err != nil {
+			return err
+		}
+		for _, ad := range page {
+			if err = fn(ad); err != nil {
+				return err
+			}
+		}
+		if len(page) < adPageSize {
+			return nil
+		}
+		afterID = page[len(page)-1].ID
+	}
+}
+
+// AdWatch начало подписки на изменения объявлений
+type AdWatch struct {
+	// Snapshot текущие объявления по фильтрам, заполняется, если поток начинается заново
+	Snapshot []entities.Ad
+	// Missed подходящие под фильтры события из истории после запрошенного ID
+	Missed []AdEvent
+	// Head ID последнего события, учтенного в Snapshot или Missed
+	Head int64
+	// Reset продолжить с запрошенного ID нельзя, поток начат заново
+	Reset  bool
+	Events <-chan AdEvent
+}
+
+// WatchAds возвращает текущие объявления по фильтрам и канал их последующих изменений.
+// Изменение попадает в канал, если под фильтры подходит новое или прежнее состояние объявления.
+// Канал закрывается при отмене ctx или если читатель не успевает за событиями
+func (a *adService) WatchAds(ctx context.Context, filters AdFilters) ([]entities.Ad, <-chan AdEvent, error) {
+	watch, err := a.ResumeAds(ctx, filters, -1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return watch.Snapshot, watch.Events, nil
+}
+
+// ResumeAds продолжает поток изменений после события lastEventID. При отрицательном lastEventID
+// или если событие уже вытеснено из истории, возвращает выборку по фильтрам, как WatchAds
+func (a *adService) ResumeAds(ctx context.Context, filters AdFilters, lastEventID int64) (*AdWatch, error) {
+	watch := &AdWatch{}
+	// подписка до выборки, чтобы не потерять изменения между ними
+	missed, head, live, cancel, err := a.feed.SubscribeSince(lastEventID, watchBuffer)
+	if errors.Is(err, ErrEventsExpired) {
+		watch.Reset = true
+		missed, head, live, cancel, err = a.feed.SubscribeSince(-1, watchBuffer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	watch.Head = head
+
+	if lastEventID < 0 || watch.Reset {
+		watch.Snapshot, err = a.GetAdsByFilter(ctx, filters)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	for _, event := range missed {
+		if filters.matchEvent(event) {
+			watch.Missed = append(watch.Missed, event)
+		}
+	}
+
+	out := make(chan AdEvent)
+	go func() {
+		defer close(out)
+		defer cancel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-live:
+				if !ok {
+					return
+				}
+				if !filters.matchEvent(event) {
+					continue
+				}
+				select {
+				case out <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	watch.Events = out
+	return watch, nil
+}
+
+func (f AdFilters) matchEvent(event AdEvent) bool {
+	return f.Match(event.Ad) || (event.Prev != nil && f.Match(*event.Prev))
+}
+
+// Match проверяет объявление так же, как GetAdsByFilter
+func (f AdFilters) Match(ad entities.Ad) bool {
+	for _, predicate := range f.predicates() {
+		if !predicate(ad) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f AdFilters) predicates() []func(ad entities.Ad) bool {
 	var adFilters []func(ad entities.Ad) bool
 
-	if filters.AuthorID != -1 {
+	if f.AuthorID != -1 {
 		adFilters = append(adFilters, func(ad entities.Ad) bool {
-			return ad.AuthorID == filters.AuthorID
+			return ad.AuthorID == f.AuthorID
 		})
 	}
 
-	if !filters.CreateDate.IsZero() {
+	if len(f.AuthorIDs) > 0 {
+		authors := make(map[int64]bool, len(f.AuthorIDs))
+		for _, id := range f.AuthorIDs {
+			authors[id] = true
+		}
 		adFilters = append(adFilters, func(ad entities.Ad) bool {
-			return ad.CreateDate.Equal(filters.CreateDate)
+			return authors[ad.AuthorID]
 		})
 	}
 
-	if filters.Title != "" {
+	if !f.CreateDate.IsZero() {
 		adFilters = append(adFilters, func(ad entities.Ad) bool {
-			return strings.EqualFold(ad.Title, filters.Title)
+			return ad.CreateDate.Equal(f.CreateDate)
+		})
+	}
+
+	if f.Title != "" {
+		adFilters = append(adFilters, func(ad entities.Ad) bool {
+			return strings.EqualFold(ad.Title, f.Title)
 		})
 	}
 
 	emptyFilters := len(adFilters) == 0
-	isPublished := !filters.Published
+	isPublished := !f.Published
 	if emptyFilters || isPublished {
 		adFilters = append(adFilters, func(ad entities.Ad) bool {
-			return ad.Published == filters.Published
+			return ad.Published == f.Published
 		})
 	}
-
-	return a.adRepository.GetAdsByFilters(adFilters)
+	return adFilters
 }
 
 func (a *adService) RemoveAd(ctx context.Context, adID int64, authorID int64) error {
+	ctx = audit.WithActor(ctx, authorID)
 	ad, err := a.adRepository.GetAdByID(adID)
 	if err != nil {
 		return err
@@ -162,7 +406,11 @@ func (a *adService) RemoveAd(ctx context.Context, adID int64, authorID int64) er
 	if err = ValidationAds.ValidateAuthorID(ad.AuthorID, authorID); err != nil {
 		return err
 	}
-	return a.adRepository.DeleteAd(adID)
+	if err = a.adRepository.DeleteAd(adID); err != nil {
+		return err
+	}
+	a.bus.Publish(ctx, events.AdDeleted{Ad: *ad})
+	return nil
 }
 
 func (a *adService) GetDateTimeFormat() util.DateTimeFormatter {
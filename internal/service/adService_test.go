@@ -2,11 +2,14 @@ package service
 
 import (
 	"context"
+	"errors"
 	"github.com/AirstaNs/ValidationAds"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"homework10/internal/adapters/repository/adrepo"
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	mocks "homework10/internal/mocks/repomocks"
 	"homework10/internal/util"
 	"strings"
@@ -19,6 +22,7 @@ type serviceSuite struct {
 	service   AdService
 	formatter util.DateTimeFormatter
 	adRepo    *mocks.AdRepository
+	userRepo  *mocks.UserRepository
 	util.UID
 }
 
@@ -52,10 +56,12 @@ func TestSuiteAdService(t *testing.T) {
 
 func (s *serviceSuite) SetupSuite() {
 	AdRepo := new(mocks.AdRepository)
+	UserRepo := new(mocks.UserRepository)
 	formatter := util.NewDateTimeFormatter(time.DateOnly)
-	s.service = NewAdsService(AdRepo, formatter)
+	s.service = NewAdsService(AdRepo, UserRepo, formatter, events.NewBus())
 	s.formatter = formatter
 	s.adRepo = AdRepo
+	s.userRepo = UserRepo
 
 	toTime, err2 := s.formatter.ToTime(time.Now().UTC())
 	assert.NoError(s.T(), err2)
@@ -72,6 +78,9 @@ func (s *serviceSuite) SetupSuite() {
 		On("AddAd", testAd).
 		Return(testID, nil)
 
+	s.userRepo.
+		On("GetUserByID", testAd.AuthorID).
+		Return(&entities.User{ID: testAd.AuthorID, Verified: true}, nil)
 }
 
 func (s *serviceSuite) TearDownSuite() {
@@ -180,6 +189,26 @@ func (s *serviceSuite) Test_AdService_ChangeAdStatus_WrongAuthorID() {
 	assert.Equal(s.T(), &cAd, uAd)
 }
 
+func (s *serviceSuite) Test_AdService_ChangeAdStatus_NotVerified() {
+	adRepo := new(mocks.AdRepository)
+	userRepo := new(mocks.UserRepository)
+	service := NewAdsService(adRepo, userRepo, s.formatter, events.NewBus())
+	cAd := testAd
+
+	adRepo.
+		On("GetAdByID", testID).
+		Return(&cAd, nil)
+	userRepo.
+		On("GetUserByID", cAd.AuthorID).
+		Return(&entities.User{ID: cAd.AuthorID}, nil)
+
+	uAd, err := service.ChangeAdStatus(context.Background(), testID, cAd.AuthorID, true)
+
+	assert.ErrorIs(s.T(), err, ErrNotVerified)
+	assert.False(s.T(), uAd.Published)
+	adRepo.AssertNotCalled(s.T(), "EditAdStatus")
+}
+
 func (s *serviceSuite) Test_AdService_UpdateAd() {
 	cAd := testAd
 
@@ -357,6 +386,36 @@ func (s *serviceSuite) Test_AdService_UpdateAd_WrongTextMoreLen() {
 	assert.Equal(s.T(), *uAd2, cAd)
 }
 
+func (s *serviceSuite) Test_AdService_PatchAd() {
+	cAd := testAd
+
+	updateDate, err := s.formatter.ToTime(time.Now().UTC())
+	assert.NoError(s.T(), err)
+
+	pAd := cAd
+	pAd.Title = "patchedTitle"
+	pAd.UpdateDate = updateDate
+	s.adRepo.
+		On("ChangeAdText", cAd.ID, pAd.Title, cAd.Text, updateDate).
+		Return(&pAd, nil)
+
+	ad, err := s.service.PatchAd(context.Background(), cAd.ID, cAd.AuthorID, AdPatch{Title: &pAd.Title})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), &pAd, ad)
+}
+
+func (s *serviceSuite) Test_AdService_PatchAd_Empty() {
+	ad, err := s.service.PatchAd(context.Background(), testID, testAd.AuthorID, AdPatch{})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), &testAd, ad)
+}
+
+func (s *serviceSuite) Test_AdService_PatchAd_ClearText() {
+	ad, err := s.service.PatchAd(context.Background(), testID, testAd.AuthorID, AdPatch{Text: &wrongEmptyStr})
+	assert.ErrorIs(s.T(), err, ValidationAds.ErrBadText)
+	assert.Equal(s.T(), &testAd, ad)
+}
+
 func (s *serviceSuite) Test_AdService_RemoveAd() {
 	cAd := testAd
 
@@ -411,7 +470,7 @@ func (s *serviceSuite) Test_AdService_GetAdByID() {
 
 func Test_AdService_GetAdsByFilter(t *testing.T) {
 	AdRepo := new(mocks.AdRepository)
-	service := NewAdsService(AdRepo, util.NewDateTimeFormatter(time.DateOnly))
+	service := NewAdsService(AdRepo, new(mocks.UserRepository), util.NewDateTimeFormatter(time.DateOnly), events.NewBus())
 
 	newAD := testAd
 	newAD.Published = true
@@ -426,6 +485,54 @@ func Test_AdService_GetAdsByFilter(t *testing.T) {
 	assert.Equal(t, ads, expAds)
 }
 
+func Test_AdService_EachAd(t *testing.T) {
+	repo := adrepo.New()
+	service := NewAdsService(repo, new(mocks.UserRepository), util.NewDateTimeFormatter(time.DateOnly), events.NewBus())
+	for i := range 2*adPageSize + 10 {
+		ad := testAd
+		ad.Published = i%2 == 0
+		_, err := repo.AddAd(ad)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, repo.DeleteAd(0))
+
+	// обход по нескольким страницам, в порядке ID, только подходящие под фильтры
+	var ids []int64
+	err := service.EachAd(context.Background(), AdFilters{AuthorID: -1, Published: true}, func(ad entities.Ad) error {
+		ids = append(ids, ad.ID)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, ids, adPageSize+4)
+	assert.Equal(t, int64(2), ids[0])
+	assert.IsIncreasing(t, ids)
+
+	stop := errors.New("stop")
+	calls := 0
+	err = service.EachAd(context.Background(), AdFilters{AuthorID: -1, Published: true}, func(ad entities.Ad) error {
+		calls++
+		return stop
+	})
+	assert.ErrorIs(t, err, stop)
+	assert.Equal(t, 1, calls)
+}
+
+// вместе с AuthorIDs Published: true не отбрасывает неопубликованные объявления, как и с AuthorID
+func TestAdFilters_AuthorIDs(t *testing.T) {
+	filters := AdFilters{AuthorID: -1, Published: true, AuthorIDs: []int64{1, 3}}
+	match := func(ad entities.Ad) bool {
+		for _, p := range filters.predicates() {
+			if !p(ad) {
+				return false
+			}
+		}
+		return true
+	}
+	assert.True(t, match(entities.Ad{AuthorID: 1, Published: true}))
+	assert.True(t, match(entities.Ad{AuthorID: 3}))
+	assert.False(t, match(entities.Ad{AuthorID: 2, Published: true}))
+}
+
 func (s *serviceSuite) Test_AdService_GetAdsByFilters() {
 	cAd := testAd
 	cAd.ID = testID
@@ -448,7 +555,7 @@ func (s *serviceSuite) Test_AdService_GetAdsByFilters() {
 
 func TestGetAdsByFilter(t *testing.T) {
 	adRepo := new(mocks.AdRepository)
-	service := NewAdsService(adRepo, util.NewDateTimeFormatter(time.DateOnly))
+	service := NewAdsService(adRepo, new(mocks.UserRepository), util.NewDateTimeFormatter(time.DateOnly), events.NewBus())
 
 	ad1 := entities.Ad{AuthorID: 1, CreateDate: time.Now(), Title: "Ad 1", Published: true}
 	ad2 := entities.Ad{AuthorID: 2, CreateDate: time.Now(), Title: "Ad 2", Published: true}
@@ -481,7 +588,7 @@ func TestGetAdsByFilter(t *testing.T) {
 
 func BenchmarkAdService_CreateAd(b *testing.B) {
 	adRepo := new(mocks.AdRepository)
-	service := NewAdsService(adRepo, util.NewDateTimeFormatter(time.DateOnly))
+	service := NewAdsService(adRepo, new(mocks.UserRepository), util.NewDateTimeFormatter(time.DateOnly), events.NewBus())
 
 	toTime, _ := service.GetDateTimeFormat().ToTime(time.Now().UTC())
 
@@ -0,0 +1,13 @@
+package service
+
+import (
+	"github.com/AirstaNs/ValidationAds"
+	"homework10/internal/apperr"
+)
+
+// ошибки проверки объявлений объявлены во внешнем пакете, поэтому их класс задается здесь
+func init() {
+	apperr.RegisterField("title", "bad_title", ValidationAds.ErrBadTitle)
+	apperr.RegisterField("text", "bad_text", ValidationAds.ErrBadText)
+	apperr.Register(apperr.PermissionDenied, "not_ad_author", ValidationAds.ErrBadAuthorID)
+}
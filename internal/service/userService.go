@@ -1,25 +1,75 @@
 package service
 
 import (
+	"encoding/json"
 	"golang.org/x/net/context"
 	"homework10/internal/adapters/repository/userrepo"
+	"homework10/internal/apperr"
 	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/util"
+	"log"
+	"net/mail"
+	"time"
 )
 
+var (
+	ErrBadEmail    = apperr.Field("email", "bad_email", "bad email")
+	ErrBadNickname = apperr.Field("nickname", "bad_nickname", "bad nickname")
+	ErrTokenUsed   = apperr.New(apperr.InvalidArgument, "token_used", "verification token already used")
+	ErrNotVerified = apperr.New(apperr.PermissionDenied, "user_not_verified", "user email is not verified")
+	ErrVerified    = apperr.New(apperr.FailedPrecondition, "user_verified", "user email is already verified")
+)
+
+const verificationTTL = 24 * time.Hour
+
 type usersService struct {
 	userRepository userrepo.UserRepository
+	signer         util.TokenSigner
+	sender         VerificationSender
+	bus            events.Bus
 }
 
 //go:generate go run github.com/vektra/mockery/v2@v2.25.0 --name=UserService --filename=mockUserService.go --output ../mocks/servicemocks
 type UserService interface {
 	CreateUser(ctx context.Context, nickname string, email string) (*entities.User, error)
 	UpdateUser(ctx context.Context, UserID int64, Nickname string, Email string) (*entities.User, error)
+	PatchUser(ctx context.Context, userID int64, patch UserPatch) (*entities.User, error)
 	GetUserByID(ctx context.Context, userID int64) (*entities.User, error)
+	// GetUsersByIDs пользователи с userIDs за одно обращение к репозиторию, отсутствующие пропускаются
+	GetUsersByIDs(ctx context.Context, userIDs []int64) ([]entities.User, error)
+	GetUserByNickname(ctx context.Context, nickname string) (*entities.User, error)
 	RemoveUser(ctx context.Context, userID int64) error
+	VerifyUser(ctx context.Context, token string) (*entities.User, error)
+	// ResendVerification отправляет новый токен подтверждения, если прежнее письмо не дошло
+	ResendVerification(ctx context.Context, userID int64) (*entities.User, error)
+}
+
+// UserPatch частичное изменение пользователя: проверяются и записываются только заданные поля, nil - не менять
+type UserPatch struct {
+	Nickname *string
+	Email    *string
+}
+
+// VerificationSender доставляет пользователю токен подтверждения email
+type VerificationSender interface {
+	SendVerification(ctx context.Context, user entities.User, token string) error
+}
+
+// verificationClaims привязывает токен к email: после подтверждения или смены email токен становится недействительным
+type verificationClaims struct {
+	UserID    int64  `json:"user_id"`
+	Email     string `json:"email"`
+	ExpiresAt int64  `json:"exp"`
 }
 
-func NewUserService(userRepository userrepo.UserRepository) UserService {
-	return &usersService{userRepository: userRepository}
+func NewUserService(userRepository userrepo.UserRepository, signer util.TokenSigner, sender VerificationSender, bus events.Bus) UserService {
+	return &usersService{
+		userRepository: userRepository,
+		signer:         signer,
+		sender:         sender,
+		bus:            bus,
+	}
 }
 
 func (a *usersService) CreateUser(ctx context.Context, nickname string, email string) (*entities.User, error) {
@@ -27,43 +77,161 @@ func (a *usersService) CreateUser(ctx context.Context, nickname string, email st
 		Nickname: nickname,
 		Email:    email,
 	}
+	if nickname == "" {
+		return &user, ErrBadNickname
+	}
+	if err := validateEmail(email); err != nil {
+		return &user, err
+	}
+
 	id, err := a.userRepository.AddUser(user)
 	user.ID = id
-
 	if err != nil {
 		return &user, err
 	}
-
+	a.bus.Publish(ctx, events.UserCreated{User: user})
+	a.trySendVerification(ctx, user)
 	return &user, nil
 }
 
+// UpdateUser пустые Nickname и Email не меняются, очистить поле нельзя ни здесь, ни через PatchUser
 func (a *usersService) UpdateUser(ctx context.Context, UserID int64, Nickname string, Email string) (*entities.User, error) {
-	userByID, err := a.userRepository.GetUserByID(UserID)
+	var patch UserPatch
+	if Nickname != "" {
+		patch.Nickname = &Nickname
+	}
+	if Email != "" {
+		patch.Email = &Email
+	}
+	return a.PatchUser(ctx, UserID, patch)
+}
+
+func (a *usersService) PatchUser(ctx context.Context, userID int64, patch UserPatch) (*entities.User, error) {
+	userByID, err := a.userRepository.GetUserByID(userID)
 	if err != nil {
 		return userByID, err
 	}
-	setUser := entities.User{}
-
-	setUser.ID = userByID.ID
+	if patch.Nickname == nil && patch.Email == nil {
+		return userByID, nil
+	}
 
-	if Nickname != "" {
-		setUser.Nickname = Nickname
+	setUser := *userByID
+	if patch.Nickname != nil {
+		if *patch.Nickname == "" {
+			return userByID, ErrBadNickname
+		}
+		setUser.Nickname = *patch.Nickname
 	}
-	if Email != "" {
-		setUser.Email = Email
+	emailChanged := patch.Email != nil && *patch.Email != userByID.Email
+	if emailChanged {
+		if err = validateEmail(*patch.Email); err != nil {
+			return userByID, err
+		}
+		setUser.Email = *patch.Email
+		setUser.Verified = false
 	}
 
-	return a.userRepository.EditUser(setUser)
+	user, err := a.userRepository.EditUser(setUser)
+	if err != nil {
+		return user, err
+	}
+	a.bus.Publish(ctx, events.UserUpdated{User: *user, Prev: *userByID})
+	if emailChanged {
+		a.trySendVerification(ctx, *user)
+	}
+	return user, nil
 }
 
 func (a *usersService) GetUserByID(ctx context.Context, userID int64) (*entities.User, error) {
 	return a.userRepository.GetUserByID(userID)
 }
 
+func (a *usersService) GetUsersByIDs(ctx context.Context, userIDs []int64) ([]entities.User, error) {
+	return a.userRepository.GetUsersByIDs(userIDs)
+}
+
+func (a *usersService) GetUserByNickname(ctx context.Context, nickname string) (*entities.User, error) {
+	return a.userRepository.GetUserByNickname(nickname)
+}
+
 func (a *usersService) RemoveUser(ctx context.Context, userID int64) error {
-	_, err := a.userRepository.GetUserByID(userID)
+	user, err := a.userRepository.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err = a.userRepository.DeleteUser(userID); err != nil {
+		return err
+	}
+	a.bus.Publish(ctx, events.UserDeleted{User: *user})
+	return nil
+}
+
+func (a *usersService) VerifyUser(ctx context.Context, token string) (*entities.User, error) {
+	empty := &entities.User{}
+	payload, err := a.signer.Verify(token)
+	if err != nil {
+		return empty, err
+	}
+	var claims verificationClaims
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return empty, util.ErrBadToken
+	}
+	if time.Now().UTC().Unix() > claims.ExpiresAt {
+		return empty, util.ErrBadToken
+	}
+
+	user, err := a.userRepository.GetUserByID(claims.UserID)
+	if err != nil {
+		return user, err
+	}
+	if user.Verified || user.Email != claims.Email {
+		return user, ErrTokenUsed
+	}
+	user.Verified = true
+	verified, err := a.userRepository.EditUser(*user)
+	if err != nil {
+		return verified, err
+	}
+	a.bus.Publish(ctx, events.UserVerified{User: *verified})
+	return verified, nil
+}
+
+func (a *usersService) ResendVerification(ctx context.Context, userID int64) (*entities.User, error) {
+	user, err := a.userRepository.GetUserByID(userID)
+	if err != nil {
+		return user, err
+	}
+	if user.Verified {
+		return user, ErrVerified
+	}
+	return user, a.sendVerification(ctx, *user)
+}
+
+// validateEmail проверяет синтаксис адреса, уникальность проверяет репозиторий при записи
+func validateEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return ErrBadEmail
+	}
+	return nil
+}
+
+// trySendVerification отправляет токен после того, как пользователь уже сохранен: ошибка только пишется в лог,
+// иначе клиент получил бы ошибку, а повтор запроса - конфликт. Токен можно запросить снова через ResendVerification
+func (a *usersService) trySendVerification(ctx context.Context, user entities.User) {
+	if err := a.sendVerification(ctx, user); err != nil {
+		log.Printf("send verification to user %d: %v", user.ID, err)
+	}
+}
+
+func (a *usersService) sendVerification(ctx context.Context, user entities.User) error {
+	payload, err := json.Marshal(verificationClaims{
+		UserID:    user.ID,
+		Email:     user.Email,
+		ExpiresAt: time.Now().UTC().Add(verificationTTL).Unix(),
+	})
 	if err != nil {
 		return err
 	}
-	return a.userRepository.DeleteUser(userID)
+	return a.sender.SendVerification(ctx, user, a.signer.Sign(payload))
 }
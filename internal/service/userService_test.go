@@ -2,10 +2,15 @@ package service
 
 import (
 	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 	"homework10/internal/adapters/repository/userrepo"
 	"homework10/internal/entities"
+	"homework10/internal/events"
 	mocks "homework10/internal/mocks/repomocks"
+	"homework10/internal/util"
 	"testing"
 )
 
@@ -13,8 +18,34 @@ type serviceSuiteUsers struct {
 	suite.Suite
 	service UserService
 	uRepo   *mocks.UserRepository
+	sender  *tokenCatcher
 }
 
+// tokenCatcher запоминает последний отправленный пользователю токен
+type tokenCatcher struct {
+	tokens map[int64]string
+}
+
+func (t *tokenCatcher) SendVerification(ctx context.Context, user entities.User, token string) error {
+	t.tokens[user.ID] = token
+	return nil
+}
+
+// flakySender не отправляет письма, пока fail
+type flakySender struct {
+	tokenCatcher
+	fail bool
+}
+
+func (f *flakySender) SendVerification(ctx context.Context, user entities.User, token string) error {
+	if f.fail {
+		return errors.New("smtp unavailable")
+	}
+	return f.tokenCatcher.SendVerification(ctx, user, token)
+}
+
+var testSigner = util.NewHMACTokenSigner([]byte("test"))
+
 var (
 	badUserID  = int64(-4124)
 	testUserID = int64(0)
@@ -33,7 +64,8 @@ func TestSuiteUserService(t *testing.T) {
 
 func (s *serviceSuiteUsers) SetupSuite() {
 	userRepo := new(mocks.UserRepository)
-	s.service = NewUserService(userRepo)
+	s.sender = &tokenCatcher{tokens: make(map[int64]string)}
+	s.service = NewUserService(userRepo, testSigner, s.sender, events.NewBus())
 	s.uRepo = userRepo
 
 	tUser.ID = testUserID
@@ -56,6 +88,141 @@ func (s *serviceSuiteUsers) TestCreateUser() {
 	user, err := s.service.CreateUser(context.Background(), tUser.Nickname, tUser.Email)
 	s.Nil(err)
 	s.Equal(&aUser, user)
+	s.NotEmpty(s.sender.tokens[user.ID])
+}
+
+func (s *serviceSuiteUsers) TestCreateUser_BadEmail() {
+	for _, email := range []string{"", "@mail.ru", "test", "Test <test@mail.ru>"} {
+		_, err := s.service.CreateUser(context.Background(), tUser.Nickname, email)
+		s.ErrorIs(err, ErrBadEmail, email)
+	}
+}
+
+func (s *serviceSuiteUsers) TestCreateUser_Conflict() {
+	userRepo := new(mocks.UserRepository)
+	service := NewUserService(userRepo, testSigner, s.sender, events.NewBus())
+	nUser := entities.User{Nickname: "other", Email: tUser.Email}
+
+	userRepo.
+		On("AddUser", nUser).
+		Return(int64(-1), userrepo.ErrConflict)
+
+	_, err := service.CreateUser(context.Background(), nUser.Nickname, nUser.Email)
+	s.ErrorIs(err, userrepo.ErrConflict)
+}
+
+func (s *serviceSuiteUsers) TestCreateUser_BadNickname() {
+	_, err := s.service.CreateUser(context.Background(), "", tUser.Email)
+	s.ErrorIs(err, ErrBadNickname)
+}
+
+func (s *serviceSuiteUsers) TestGetUserByNickname() {
+	s.uRepo.
+		On("GetUserByNickname", "TEST").
+		Return(&tUser, nil)
+
+	user, err := s.service.GetUserByNickname(context.Background(), "TEST")
+	s.NoError(err)
+	s.Equal(tUser.ID, user.ID)
+}
+
+func (s *serviceSuiteUsers) TestVerifyUser() {
+	userRepo := new(mocks.UserRepository)
+	service := NewUserService(userRepo, testSigner, s.sender, events.NewBus())
+	nUser := entities.User{Nickname: "verify", Email: "verify@mail.ru"}
+	vUser := nUser
+	vUser.Verified = true
+
+	userRepo.
+		On("AddUser", nUser).
+		Return(testUserID, nil)
+	userRepo.
+		On("GetUserByID", testUserID).
+		Return(func(int64) *entities.User { u := nUser; return &u }, nil)
+	userRepo.
+		On("EditUser", vUser).
+		Return(&vUser, nil)
+
+	_, err := service.CreateUser(context.Background(), nUser.Nickname, nUser.Email)
+	s.NoError(err)
+
+	user, err := service.VerifyUser(context.Background(), s.sender.tokens[testUserID])
+	s.NoError(err)
+	s.True(user.Verified)
+}
+
+func (s *serviceSuiteUsers) TestVerifyUser_BadToken() {
+	_, err := s.service.VerifyUser(context.Background(), "bad token")
+	s.ErrorIs(err, util.ErrBadToken)
+
+	forged := util.NewHMACTokenSigner([]byte("forged")).Sign([]byte(`{"user_id":0,"email":"test@mail.ru","exp":9999999999}`))
+	_, err = s.service.VerifyUser(context.Background(), forged)
+	s.ErrorIs(err, util.ErrBadToken)
+
+	expired := testSigner.Sign([]byte(`{"user_id":0,"email":"test@mail.ru","exp":1}`))
+	_, err = s.service.VerifyUser(context.Background(), expired)
+	s.ErrorIs(err, util.ErrBadToken)
+}
+
+func (s *serviceSuiteUsers) TestVerifyUser_TokenUsed() {
+	userRepo := new(mocks.UserRepository)
+	service := NewUserService(userRepo, testSigner, s.sender, events.NewBus())
+	vUser := entities.User{ID: testUserID, Nickname: "verify", Email: "verify@mail.ru", Verified: true}
+
+	userRepo.
+		On("GetUserByID", testUserID).
+		Return(&vUser, nil)
+
+	token := testSigner.Sign([]byte(`{"user_id":0,"email":"verify@mail.ru","exp":9999999999}`))
+	_, err := service.VerifyUser(context.Background(), token)
+	s.ErrorIs(err, ErrTokenUsed)
+	userRepo.AssertNotCalled(s.T(), "EditUser", mock.Anything)
+}
+
+func (s *serviceSuiteUsers) TestCreateUser_SendFailed() {
+	userRepo := new(mocks.UserRepository)
+	sender := &flakySender{tokenCatcher: tokenCatcher{tokens: make(map[int64]string)}, fail: true}
+	service := NewUserService(userRepo, testSigner, sender, events.NewBus())
+	nUser := entities.User{Nickname: "resend", Email: "resend@mail.ru"}
+	vUser := nUser
+	vUser.Verified = true
+
+	userRepo.
+		On("AddUser", nUser).
+		Return(testUserID, nil)
+	userRepo.
+		On("GetUserByID", testUserID).
+		Return(func(int64) *entities.User { u := nUser; return &u }, nil)
+	userRepo.
+		On("EditUser", vUser).
+		Return(&vUser, nil)
+
+	// пользователь уже сохранен, поэтому неудачная отправка письма не ошибка создания
+	user, err := service.CreateUser(context.Background(), nUser.Nickname, nUser.Email)
+	s.NoError(err)
+	s.Equal(testUserID, user.ID)
+	_, err = service.ResendVerification(context.Background(), testUserID)
+	s.Error(err)
+
+	sender.fail = false
+	_, err = service.ResendVerification(context.Background(), testUserID)
+	s.NoError(err)
+	user, err = service.VerifyUser(context.Background(), sender.tokens[testUserID])
+	s.NoError(err)
+	s.True(user.Verified)
+}
+
+func (s *serviceSuiteUsers) TestResendVerification_Verified() {
+	userRepo := new(mocks.UserRepository)
+	service := NewUserService(userRepo, testSigner, s.sender, events.NewBus())
+	vUser := entities.User{ID: testUserID, Nickname: "verify", Email: "verify@mail.ru", Verified: true}
+
+	userRepo.
+		On("GetUserByID", testUserID).
+		Return(&vUser, nil)
+
+	_, err := service.ResendVerification(context.Background(), testUserID)
+	s.ErrorIs(err, ErrVerified)
 }
 
 func (s *serviceSuiteUsers) TestGetUserByID() {
@@ -66,6 +233,16 @@ func (s *serviceSuiteUsers) TestGetUserByID() {
 	s.Equal(&aUser, user)
 }
 
+func (s *serviceSuiteUsers) TestGetUsersByIDs() {
+	s.uRepo.
+		On("GetUsersByIDs", []int64{testUserID, badUserID}).
+		Return([]entities.User{tUser}, nil)
+
+	users, err := s.service.GetUsersByIDs(context.Background(), []int64{testUserID, badUserID})
+	s.Nil(err)
+	s.Equal([]entities.User{tUser}, users)
+}
+
 func (s *serviceSuiteUsers) TestUpdateUser() {
 	uUser := tUser
 	uUser.Nickname = "testNew"
@@ -98,6 +275,30 @@ func (s *serviceSuiteUsers) TestUpdateUserWrongID() {
 	s.Equal(emptyUser, user)
 }
 
+func (s *serviceSuiteUsers) TestPatchUser_Nickname() {
+	pUser := tUser
+	pUser.Nickname = "patched"
+
+	s.uRepo.
+		On("EditUser", pUser).
+		Return(&pUser, nil)
+
+	user, err := s.service.PatchUser(context.Background(), testUserID, UserPatch{Nickname: &pUser.Nickname})
+	s.NoError(err)
+	s.Equal(&pUser, user)
+}
+
+func (s *serviceSuiteUsers) TestPatchUser_ClearNickname() {
+	empty := ""
+	user, err := s.service.PatchUser(context.Background(), testUserID, UserPatch{Nickname: &empty})
+	s.ErrorIs(err, ErrBadNickname)
+	s.Equal(&tUser, user)
+
+	user, err = s.service.PatchUser(context.Background(), testUserID, UserPatch{Email: &empty})
+	s.ErrorIs(err, ErrBadEmail)
+	s.Equal(&tUser, user)
+}
+
 func (s *serviceSuiteUsers) TestRemoveUser() {
 	s.uRepo.
 		On("DeleteUser", testUserID).
@@ -122,7 +323,7 @@ func (s *serviceSuiteUsers) TestRemoveUserWrongID() {
 
 func BenchmarkUsersService_CreateUser(b *testing.B) {
 	uRepo := new(mocks.UserRepository)
-	service := NewUserService(uRepo)
+	service := NewUserService(uRepo, testSigner, &tokenCatcher{tokens: make(map[int64]string)}, events.NewBus())
 
 	nUser := tUser
 	tUser.ID = testUserID
@@ -135,3 +336,30 @@ func BenchmarkUsersService_CreateUser(b *testing.B) {
 		_, _ = service.CreateUser(context.Background(), nUser.Nickname, nUser.Email)
 	}
 }
+
+func TestUsersService_Events(t *testing.T) {
+	bus := events.NewBus()
+	var names []string
+	bus.Subscribe(events.Subscriber{
+		Handler: func(ctx context.Context, event events.Event) error {
+			names = append(names, event.Name())
+			return nil
+		},
+	})
+	sender := &tokenCatcher{tokens: make(map[int64]string)}
+	service := NewUserService(userrepo.New(), testSigner, sender, bus)
+
+	user, err := service.CreateUser(context.Background(), "events", "events@mail.ru")
+	assert.NoError(t, err)
+	_, err = service.VerifyUser(context.Background(), sender.tokens[user.ID])
+	assert.NoError(t, err)
+	_, err = service.UpdateUser(context.Background(), user.ID, "renamed", "")
+	assert.NoError(t, err)
+	err = service.RemoveUser(context.Background(), user.ID)
+	assert.NoError(t, err)
+	// неудачные операции событий не публикуют
+	_, err = service.CreateUser(context.Background(), "", "bad")
+	assert.Error(t, err)
+
+	assert.Equal(t, []string{events.NameUserCreated, events.NameUserVerified, events.NameUserUpdated, events.NameUserDeleted}, names)
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	"google.golang.org/protobuf/types/known/wrapperspb"
 	"homework10/internal/entities"
@@ -52,7 +55,7 @@ func (s *adsSuite) Test_Ads_GetByID_NoExistID() {
 
 	getAdReq := &grpc.GetADByIDRequest{AdId: 100}
 	_, err2 := server.GetAd(context.Background(), getAdReq)
-	assert.ErrorIs(s.T(), err2, errNotFound)
+	assert.Equal(s.T(), codes.NotFound, status.Code(err2))
 }
 
 func (s *adsSuite) Test_Ads_GetByFilter_NoFilter() {
@@ -210,7 +213,7 @@ func (s *adsSuite) Test_Ads_Create_WithWrongAuthorID() {
 	newText := text + text
 
 	_, err2 := addAd(s.client, newTitle, newText, math.MaxInt)
-	assert.ErrorIs(s.T(), err2, errNotFound)
+	assert.Equal(s.T(), codes.NotFound, status.Code(err2))
 }
 
 func (s *adsSuite) Test_Ads_Update() {
@@ -235,6 +238,37 @@ func (s *adsSuite) Test_Ads_Update() {
 	assert.NoError(s.T(), err)
 }
 
+func (s *adsSuite) Test_Ads_UpdateMask() {
+	server := s.client.Server
+	ad, err := addAd(s.client, title, text, s.users[0].ID)
+	assert.NoError(s.T(), err)
+
+	// text не входит в маску и не меняется, хотя в запросе он пустой
+	updateAd, err := server.ModifyAd(context.Background(), &grpc.UpdateAdRequest{
+		AdId:       ad.ID,
+		UserId:     ad.AuthorID,
+		Title:      title + title,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"title"}},
+	})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), title+title, updateAd.Title)
+	assert.Equal(s.T(), text, updateAd.Text)
+
+	_, err = server.ModifyAd(context.Background(), &grpc.UpdateAdRequest{
+		AdId:       ad.ID,
+		UserId:     ad.AuthorID,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"text"}},
+	})
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+
+	_, err = server.ModifyAd(context.Background(), &grpc.UpdateAdRequest{
+		AdId:       ad.ID,
+		UserId:     ad.AuthorID,
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"published"}},
+	})
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+}
+
 func (s *adsSuite) Test_Ads_UpdateStatus() {
 	user, err := addUser(s.client, "test", "test@mail.ru")
 	assert.NoError(s.T(), err)
@@ -251,12 +285,24 @@ func (s *adsSuite) Test_Ads_UpdateStatus() {
 
 }
 
+func (s *adsSuite) Test_Ads_UpdateStatus_NotVerified() {
+	user, err := registerUser(s.client, "unverified", "unverified@mail.ru")
+	assert.NoError(s.T(), err)
+
+	ad, err := addAd(s.client, title, text, user.ID)
+	assert.NoError(s.T(), err)
+
+	sChange := &grpc.ChangeAdStatusRequest{AdId: ad.ID, UserId: user.ID, Published: true}
+	_, err = s.client.Server.UpdateAdStatus(context.Background(), sChange)
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
+}
+
 func (s *adsSuite) Test_Ads_Delete_Forbidden() {
 	server := s.client.Server
 	ad := s.ads[0]
 	deleteAdReq := &grpc.DeleteAdRequest{AdId: ad.ID, AuthorId: math.MaxInt}
 	_, err := server.RemoveAd(context.Background(), deleteAdReq)
-	assert.ErrorIs(s.T(), err, errForbidden)
+	assert.Equal(s.T(), codes.PermissionDenied, status.Code(err))
 }
 
 func (s *adsSuite) Test_Ads_Delete() {
@@ -271,10 +317,10 @@ func (s *adsSuite) Test_Ads_Delete() {
 
 	deleteAdReq = &grpc.DeleteAdRequest{AdId: ad.ID, AuthorId: ad.AuthorID}
 	_, err = server.RemoveAd(context.Background(), deleteAdReq)
-	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
 
 	_, err = server.GetAd(context.Background(), &grpc.GetADByIDRequest{AdId: ad.ID})
-	assert.ErrorIs(s.T(), err, errNotFound)
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
 }
 
 func TestSuiteAds(t *testing.T) {
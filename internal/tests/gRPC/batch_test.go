@@ -0,0 +1,92 @@
+package gRPC
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"homework10/internal/entities"
+	"homework10/internal/ports/grpc"
+	"testing"
+)
+
+type batchSuite struct {
+	suite.Suite
+	client *gRPCtestClient
+	users  []entities.User
+}
+
+func TestSuiteBatch(t *testing.T) {
+	suite.Run(t, new(batchSuite))
+}
+
+func (s *batchSuite) SetupSuite() {
+	s.client = getGRPCTestClient()
+	users, err := setupUsers(s.client)
+	assert.NoError(s.T(), err)
+	s.users = users
+}
+
+func (s *batchSuite) TearDownSuite() {
+	s.client.Stop()
+}
+
+func (s *batchSuite) Test_Batch_CreateAndDelete() {
+	server := s.client.Server
+	user := s.users[0]
+
+	created, err := server.BatchCreateAds(context.Background(), &grpc.BatchCreateAdsRequest{
+		Items: []*grpc.CreateAdRequest{
+			{Title: "hello", Text: "world", UserId: user.ID},
+			{Title: "hello", Text: "world", UserId: user.ID},
+		},
+		AllOrNothing: true,
+	})
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), created.Aborted)
+	assert.Len(s.T(), created.Results, 2)
+
+	refs := make([]*grpc.DeleteAdRequest, 0)
+	for _, r := range created.Results {
+		assert.Equal(s.T(), int32(codes.OK), r.Code)
+		refs = append(refs, &grpc.DeleteAdRequest{AdId: r.Ad.Id, AuthorId: user.ID})
+	}
+	deleted, err := server.BatchDeleteAds(context.Background(), &grpc.BatchDeleteAdsRequest{Items: refs})
+	assert.NoError(s.T(), err)
+	for _, r := range deleted.Results {
+		assert.Equal(s.T(), int32(codes.OK), r.Code)
+	}
+}
+
+func (s *batchSuite) Test_Batch_UpdateStatus_Aborted() {
+	server := s.client.Server
+	user, other := s.users[0], s.users[1]
+	ad, err := addAd(s.client, "hello", "world", user.ID)
+	assert.NoError(s.T(), err)
+	otherAd, err := addAd(s.client, "hello", "world", other.ID)
+	assert.NoError(s.T(), err)
+
+	res, err := server.BatchUpdateAdStatus(context.Background(), &grpc.BatchUpdateAdStatusRequest{
+		Items: []*grpc.ChangeAdStatusRequest{
+			{AdId: ad.ID, UserId: user.ID, Published: true},
+			{AdId: otherAd.ID, UserId: user.ID, Published: true},
+		},
+		AllOrNothing: true,
+	})
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), res.Aborted)
+	assert.Equal(s.T(), int32(codes.Aborted), res.Results[0].Code)
+	assert.Equal(s.T(), int32(codes.PermissionDenied), res.Results[1].Code)
+
+	got, err := server.GetAd(context.Background(), &grpc.GetADByIDRequest{AdId: ad.ID})
+	assert.NoError(s.T(), err)
+	assert.False(s.T(), got.Published)
+}
+
+func (s *batchSuite) Test_Batch_Empty() {
+	server := s.client.Server
+
+	_, err := server.BatchDeleteAds(context.Background(), &grpc.BatchDeleteAdsRequest{})
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,98 @@
+package gRPC
+
+import (
+	"context"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"homework10/internal/entities"
+	"homework10/internal/ports/grpc"
+	"testing"
+)
+
+type errorsSuite struct {
+	suite.Suite
+	client *gRPCtestClient
+	users  []entities.User
+}
+
+func TestSuiteErrors(t *testing.T) {
+	suite.Run(t, new(errorsSuite))
+}
+
+func (s *errorsSuite) SetupSuite() {
+	s.client = getGRPCTestClient()
+	users, err := setupUsers(s.client)
+	s.Require().NoError(err)
+	s.users = users
+}
+
+func (s *errorsSuite) TearDownSuite() {
+	s.client.Stop()
+}
+
+// details подробности ошибки, которые пришли клиенту
+func details(err error) (info *errdetails.ErrorInfo, badRequest *errdetails.BadRequest, resource *errdetails.ResourceInfo) {
+	for _, d := range status.Convert(err).Details() {
+		switch detail := d.(type) {
+		case *errdetails.ErrorInfo:
+			info = detail
+		case *errdetails.BadRequest:
+			badRequest = detail
+		case *errdetails.ResourceInfo:
+			resource = detail
+		}
+	}
+	return info, badRequest, resource
+}
+
+func (s *errorsSuite) Test_BadRequest_Email() {
+	_, err := s.client.Server.AddUser(context.Background(), &grpc.UserRequest{Nickname: "bad", Email: "not an email"})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	info, badRequest, resource := details(err)
+	s.Require().NotNil(info)
+	s.Equal("BAD_EMAIL", info.Reason)
+	s.Equal("ads.homework10", info.Domain)
+	s.Require().NotNil(badRequest)
+	s.Require().Len(badRequest.FieldViolations, 1)
+	s.Equal("email", badRequest.FieldViolations[0].Field)
+	s.Nil(resource)
+}
+
+func (s *errorsSuite) Test_BadRequest_Title() {
+	_, err := s.client.Server.AddAd(context.Background(), &grpc.CreateAdRequest{Title: "", Text: text, UserId: s.users[0].ID})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+
+	info, badRequest, _ := details(err)
+	s.Require().NotNil(info)
+	s.Equal("BAD_TITLE", info.Reason)
+	s.Require().NotNil(badRequest)
+	s.Equal("title", badRequest.FieldViolations[0].Field)
+}
+
+func (s *errorsSuite) Test_ResourceInfo_Ad() {
+	_, err := s.client.Server.GetAd(context.Background(), &grpc.GetADByIDRequest{AdId: 100})
+	s.Equal(codes.NotFound, status.Code(err))
+
+	info, badRequest, resource := details(err)
+	s.Require().NotNil(info)
+	s.Equal("AD_NOT_FOUND", info.Reason)
+	s.Nil(badRequest)
+	s.Require().NotNil(resource)
+	s.Equal("ad", resource.ResourceType)
+	s.Equal("100", resource.ResourceName)
+}
+
+func (s *errorsSuite) Test_ResourceInfo_User() {
+	_, err := s.client.Server.GetUser(context.Background(), &grpc.GetUserRequest{Id: 100})
+	s.Equal(codes.NotFound, status.Code(err))
+
+	info, _, resource := details(err)
+	s.Require().NotNil(info)
+	s.Equal("USER_NOT_FOUND", info.Reason)
+	s.Require().NotNil(resource)
+	s.Equal("user", resource.ResourceType)
+	s.Equal("100", resource.ResourceName)
+}
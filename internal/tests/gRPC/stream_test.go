@@ -0,0 +1,97 @@
+package gRPC
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"homework10/internal/entities"
+	"homework10/internal/ports/grpc"
+	"io"
+	"testing"
+)
+
+type streamSuite struct {
+	suite.Suite
+	client *gRPCtestClient
+	users  []entities.User
+}
+
+func TestSuiteStream(t *testing.T) {
+	suite.Run(t, new(streamSuite))
+}
+
+func (s *streamSuite) SetupSuite() {
+	s.client = getGRPCTestClient()
+	users, err := setupUsers(s.client)
+	assert.NoError(s.T(), err)
+	s.users = users
+}
+
+func (s *streamSuite) TearDownSuite() {
+	s.client.Stop()
+}
+
+func (s *streamSuite) Test_StreamAds() {
+	user := s.users[0]
+	for i := 0; i < 3; i++ {
+		_, err := addAd(s.client, "stream", "world", user.ID)
+		assert.NoError(s.T(), err)
+	}
+
+	stream, err := s.client.Server.StreamAds(context.Background(), &grpc.AdFilters{
+		OptionalAuthorId: wrapperspb.Int64(user.ID),
+		OptionalTitle:    wrapperspb.String("stream"),
+	})
+	assert.NoError(s.T(), err)
+	count := 0
+	for {
+		ad, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(s.T(), err)
+		assert.Equal(s.T(), user.ID, ad.AuthorId)
+		count++
+	}
+	assert.Equal(s.T(), 3, count)
+}
+
+func (s *streamSuite) Test_WatchAds() {
+	user := s.users[1]
+	existing, err := addAd(s.client, "watch", "world", user.ID)
+	assert.NoError(s.T(), err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stream, err := s.client.Server.WatchAds(ctx, &grpc.AdFilters{
+		OptionalAuthorId: wrapperspb.Int64(user.ID),
+		OptionalTitle:    wrapperspb.String("watch"),
+	})
+	assert.NoError(s.T(), err)
+
+	event, err := stream.Recv()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), grpc.AdEvent_SNAPSHOT, event.Type)
+	assert.Equal(s.T(), existing.ID, event.Ad.Id)
+	event, err = stream.Recv()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), grpc.AdEvent_SYNCED, event.Type)
+
+	// объявление другого автора в поток не попадает
+	_, err = addAd(s.client, "watch", "world", s.users[0].ID)
+	assert.NoError(s.T(), err)
+	created, err := addAd(s.client, "watch", "world", user.ID)
+	assert.NoError(s.T(), err)
+	_, err = s.client.Server.RemoveAd(context.Background(), &grpc.DeleteAdRequest{AdId: existing.ID, AuthorId: user.ID})
+	assert.NoError(s.T(), err)
+
+	event, err = stream.Recv()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), grpc.AdEvent_CREATED, event.Type)
+	assert.Equal(s.T(), created.ID, event.Ad.Id)
+	event, err = stream.Recv()
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), grpc.AdEvent_DELETED, event.Type)
+	assert.Equal(s.T(), existing.ID, event.Ad.Id)
+}
@@ -0,0 +1,97 @@
+package gRPC
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+	"homework10/internal/entities"
+	"homework10/internal/ports/grpc"
+	"io"
+	"strings"
+	"testing"
+)
+
+type transferSuite struct {
+	suite.Suite
+	client *gRPCtestClient
+	users  []entities.User
+}
+
+func TestSuiteTransfer(t *testing.T) {
+	suite.Run(t, new(transferSuite))
+}
+
+func (s *transferSuite) SetupSuite() {
+	s.client = getGRPCTestClient()
+	users, err := setupUsers(s.client)
+	assert.NoError(s.T(), err)
+	s.users = users
+}
+
+func (s *transferSuite) TearDownSuite() {
+	s.client.Stop()
+}
+
+func (s *transferSuite) importAds(format grpc.FileFormat, dryRun bool, chunks ...string) (*grpc.ImportAdsResponse, error) {
+	stream, err := s.client.Server.ImportAds(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	for i, chunk := range chunks {
+		msg := &grpc.ImportAdsChunk{Data: []byte(chunk)}
+		if i == 0 {
+			msg.Format = format
+			msg.DryRun = dryRun
+		}
+		if err = stream.Send(msg); err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func (s *transferSuite) Test_Import_DryRun() {
+	user := s.users[1]
+	file := fmt.Sprintf("title,text,user_id\nhello,world,%d\n,world,%d\n", user.ID, user.ID)
+
+	res, err := s.importAds(grpc.FileFormat_FILE_FORMAT_CSV, true, file[:10], file[10:])
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), res.DryRun)
+	assert.Equal(s.T(), int32(2), res.Total)
+	assert.Equal(s.T(), int32(1), res.Valid)
+	assert.Nil(s.T(), res.Rows[0].AdId)
+	assert.NotEmpty(s.T(), res.Rows[1].Error)
+}
+
+func (s *transferSuite) Test_Import_Export() {
+	user := s.users[0]
+	file := fmt.Sprintf(`{"title":"hello","text":"world","user_id":%d}`+"\n", user.ID)
+
+	res, err := s.importAds(grpc.FileFormat_FILE_FORMAT_JSONL, false, file)
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), int32(1), res.Valid)
+	assert.NotNil(s.T(), res.Rows[0].AdId)
+
+	stream, err := s.client.Server.ExportAds(context.Background(), &grpc.ExportAdsRequest{
+		Filters: &grpc.AdFilters{
+			OptionalAuthorId:  wrapperspb.Int64(user.ID),
+			OptionalPublished: wrapperspb.Bool(false),
+		},
+		Format: grpc.FileFormat_FILE_FORMAT_CSV,
+	})
+	assert.NoError(s.T(), err)
+	var exported strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(s.T(), err)
+		exported.Write(chunk.Data)
+	}
+	lines := strings.Split(strings.TrimSpace(exported.String()), "\n")
+	assert.Equal(s.T(), "id,title,text,user_id,published,create_date,update_date", lines[0])
+	assert.Len(s.T(), lines, 2)
+}
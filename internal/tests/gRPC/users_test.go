@@ -4,8 +4,11 @@ import (
 	"context"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"homework10/internal/entities"
 	"homework10/internal/ports/grpc"
+	"strings"
 	"testing"
 )
 
@@ -58,20 +61,70 @@ func (s *usersSuite) Test_User_GetByID_WrongID() {
 func (s *usersSuite) Test_User_Create() {
 	server := s.client.Server
 
-	userReq := &grpc.UserRequest{Nickname: name, Email: email}
+	userReq := &grpc.UserRequest{Nickname: "create" + name, Email: "create" + email}
 	res, err := server.AddUser(context.Background(), userReq)
 	assert.NoError(s.T(), err)
-	assert.Equal(s.T(), res.Nickname, name)
-	assert.Equal(s.T(), res.Email, email)
+	assert.Equal(s.T(), res.Nickname, "create"+name)
+	assert.Equal(s.T(), res.Email, "create"+email)
+	assert.False(s.T(), res.Verified)
+}
+
+func (s *usersSuite) Test_User_Create_BadEmail() {
+	server := s.client.Server
+
+	userReq := &grpc.UserRequest{Nickname: name, Email: name}
+	_, err := server.AddUser(context.Background(), userReq)
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+}
+
+func (s *usersSuite) Test_User_Create_Conflict() {
+	server := s.client.Server
+
+	userReq := &grpc.UserRequest{Nickname: "other" + name, Email: strings.ToUpper(email)}
+	_, err := server.AddUser(context.Background(), userReq)
+	assert.Equal(s.T(), codes.AlreadyExists, status.Code(err))
+
+	userReq = &grpc.UserRequest{Nickname: strings.ToLower(name), Email: "other" + email}
+	_, err = server.AddUser(context.Background(), userReq)
+	assert.Equal(s.T(), codes.AlreadyExists, status.Code(err))
+}
+
+func (s *usersSuite) Test_User_GetByNickname() {
+	server := s.client.Server
+	user := s.users[0]
+
+	res, err := server.GetUserByNickname(context.Background(), &grpc.GetUserByNicknameRequest{Nickname: strings.ToUpper(name)})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), user.ID, res.Id)
+
+	_, err = server.GetUserByNickname(context.Background(), &grpc.GetUserByNicknameRequest{Nickname: "unknown"})
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
+}
+
+func (s *usersSuite) Test_User_Verify() {
+	server := s.client.Server
+
+	user, err := registerUser(s.client, "verify"+name, "verify"+email)
+	assert.NoError(s.T(), err)
+
+	verifyReq := &grpc.VerifyUserRequest{Token: s.client.tokens.token(user.ID)}
+	res, err := server.VerifyUser(context.Background(), verifyReq)
+	assert.NoError(s.T(), err)
+	assert.True(s.T(), res.Verified)
+
+	_, err = server.VerifyUser(context.Background(), verifyReq)
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
+
+	_, err = server.VerifyUser(context.Background(), &grpc.VerifyUserRequest{Token: "bad token"})
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
 }
 
 func (s *usersSuite) Test_User_Update() {
 	server := s.client.Server
-	users, err := setupUsers(s.client)
+	user, err := addUser(s.client, "update"+name, "update"+email)
 	assert.NoError(s.T(), err)
-	user := users[0]
 
-	updateUserReq := &grpc.UserUpdateRequest{Id: user.ID, Nickname: "new name", Email: "new email"}
+	updateUserReq := &grpc.UserUpdateRequest{Id: user.ID, Nickname: "new name", Email: "new" + email}
 	res, err := server.ModifyUser(context.Background(), updateUserReq)
 	assert.NoError(s.T(), err)
 	assert.Equal(s.T(), res.Id, user.ID)
@@ -89,5 +142,40 @@ func (s *usersSuite) Test_User_Delete() {
 
 	deleteUserReq = &grpc.DeleteUserRequest{Id: user.ID}
 	_, err = server.RemoveUser(context.Background(), deleteUserReq)
+	assert.Equal(s.T(), codes.NotFound, status.Code(err))
+}
+
+func (s *usersSuite) Test_User_Delete_TransferAds() {
+	server := s.client.Server
+	user, err := addUser(s.client, "transfer"+name, "transfer"+email)
+	assert.NoError(s.T(), err)
+	ad, err := addAd(s.client, "hello", "world", user.ID)
+	assert.NoError(s.T(), err)
+	newOwner := s.users[0]
+
+	deleteUserReq := &grpc.DeleteUserRequest{
+		Id:         user.ID,
+		AdsPolicy:  grpc.DeleteUserRequest_TRANSFER,
+		NewOwnerId: newOwner.ID,
+	}
+	_, err = server.RemoveUser(context.Background(), deleteUserReq)
+	assert.NoError(s.T(), err)
+
+	res, err := server.GetAd(context.Background(), &grpc.GetADByIDRequest{AdId: ad.ID})
+	assert.NoError(s.T(), err)
+	assert.Equal(s.T(), newOwner.ID, res.AuthorId)
+}
+
+func (s *usersSuite) Test_User_Delete_TransferAds_BadOwner() {
+	server := s.client.Server
+	user, err := addUser(s.client, "badowner"+name, "badowner"+email)
 	assert.NoError(s.T(), err)
+
+	deleteUserReq := &grpc.DeleteUserRequest{
+		Id:         user.ID,
+		AdsPolicy:  grpc.DeleteUserRequest_TRANSFER,
+		NewOwnerId: user.ID,
+	}
+	_, err = server.RemoveUser(context.Background(), deleteUserReq)
+	assert.Equal(s.T(), codes.InvalidArgument, status.Code(err))
 }
@@ -3,9 +3,7 @@ package gRPC
 import (
 	"context"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/status"
 	"homework10/internal/adapters/repository/adrepo"
 	"homework10/internal/adapters/repository/userrepo"
 	"homework10/internal/app"
@@ -14,18 +12,34 @@ import (
 	"homework10/internal/util"
 	"log"
 	"net"
+	"sync"
 	"time"
 )
 
 type gRPCtestClient struct {
 	Server grpc2.AdServiceClient
 	Stop   func()
+	tokens *tokenCatcher
 }
 
-var (
-	errNotFound  = status.Error(codes.NotFound, "not found")
-	errForbidden = status.Error(codes.PermissionDenied, "permission denied")
-)
+// tokenCatcher перехватывает токены подтверждения email вместо отправки письма
+type tokenCatcher struct {
+	mutex  sync.Mutex
+	tokens map[int64]string
+}
+
+func (t *tokenCatcher) SendVerification(ctx context.Context, user entities.User, token string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tokens[user.ID] = token
+	return nil
+}
+
+func (t *tokenCatcher) token(userID int64) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.tokens[userID]
+}
 
 const (
 	name  = "Oleg"
@@ -39,7 +53,8 @@ func getGRPCTestClient() *gRPCtestClient {
 	repo := adrepo.New()
 	uRep := userrepo.New()
 	formatter := util.NewDateTimeFormatter(time.RFC3339)
-	newApp := app.NewApp(repo, uRep, formatter)
+	tokens := &tokenCatcher{tokens: make(map[int64]string)}
+	newApp := app.NewApp(repo, uRep, formatter, app.WithVerificationSender(tokens))
 
 	lis, err := net.Listen("tcp", addr)
 	if err != nil {
@@ -64,7 +79,7 @@ func getGRPCTestClient() *gRPCtestClient {
 	stop := func() {
 		grpcServer.Stop()
 	}
-	return &gRPCtestClient{Server: AdsClient, Stop: stop}
+	return &gRPCtestClient{Server: AdsClient, Stop: stop, tokens: tokens}
 }
 
 func setupUsers(client *gRPCtestClient) ([]entities.User, error) {
@@ -74,7 +89,7 @@ func setupUsers(client *gRPCtestClient) ([]entities.User, error) {
 	if err != nil {
 		return empty, err
 	}
-	user1, err1 := addUser(client, email, name)
+	user1, err1 := addUser(client, email, "1"+email)
 	if err1 != nil {
 		return empty, err
 	}
@@ -118,7 +133,21 @@ func setupUpdateAd(client *gRPCtestClient, sChange *grpc2.ChangeAdStatusRequest)
 	return newAd
 }
 
+// addUser регистрирует пользователя и сразу подтверждает его email
 func addUser(client *gRPCtestClient, nickname string, email string) (entities.User, error) {
+	user, err := registerUser(client, nickname, email)
+	if err != nil {
+		return user, err
+	}
+	verifyReq := &grpc2.VerifyUserRequest{Token: client.tokens.token(user.ID)}
+	if _, err = client.Server.VerifyUser(context.Background(), verifyReq); err != nil {
+		return entities.User{}, err
+	}
+	user.Verified = true
+	return user, nil
+}
+
+func registerUser(client *gRPCtestClient, nickname string, email string) (entities.User, error) {
 	server := client.Server
 
 	cUserReq := &grpc2.UserRequest{Nickname: nickname, Email: email}
@@ -11,7 +11,7 @@ import (
 func Test_Ads_GetByID(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -29,7 +29,7 @@ func Test_Ads_GetByID(t *testing.T) {
 func Test_Ads_GetByID_NoExistID(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -69,7 +69,7 @@ func Test_Ads_GetByFilter_WithAuthorID(t *testing.T) {
 	user, err := client.createUser("qwertys", "qw@mail.ru")
 	assert.NoError(t, err)
 
-	user1, err := client.createUser("qwertys1", "@mail.ru")
+	user1, err := client.createUser("qwertys1", "qwertys1@mail.ru")
 	assert.NoError(t, err)
 
 	_, err = client.createAd(user.Data.ID, "hello", "world")
@@ -91,7 +91,7 @@ func Test_Ads_GetByFilter_WithTitle(t *testing.T) {
 	user, err := client.createUser("qwertys", "qw@mail.ru")
 	assert.NoError(t, err)
 
-	user1, err := client.createUser("qwertys1", "@mail.ru")
+	user1, err := client.createUser("qwertys1", "qwertys1@mail.ru")
 	assert.NoError(t, err)
 
 	respone, err := client.createAd(user.Data.ID, "hello", "world")
@@ -113,7 +113,7 @@ func Test_Ads_GetByFilter_WithAllFilers(t *testing.T) {
 	user, err := client.createUser("qwertys", "qw@mail.ru")
 	assert.NoError(t, err)
 
-	user1, err := client.createUser("qwertys1", "@mail.ru")
+	user1, err := client.createUser("qwertys1", "qwertys1@mail.ru")
 	assert.NoError(t, err)
 
 	respone, err := client.createAd(user.Data.ID, "hello", "world")
@@ -179,11 +179,8 @@ func Test_Ads_Delete(t *testing.T) {
 	assert.Equal(t, deleteAds.AdId, ads.Data.ID)
 	assert.Equal(t, deleteAds.AuthorId, user.Data.ID)
 
-	deleteAds, err = client.deleteAd(param, ads.Data.ID)
-	assert.NoError(t, err)
-	assert.Equal(t, deleteAds.AdId, ads.Data.ID)
-	assert.Equal(t, deleteAds.AuthorId, user.Data.ID)
-
+	_, err = client.deleteAd(param, ads.Data.ID)
+	assert.ErrorIs(t, err, ErrorNotFound)
 }
 
 func Test_Ads_Delete_Forbidden(t *testing.T) {
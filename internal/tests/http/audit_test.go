@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAuditLog(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("auditor", "auditor@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	_, err = client.updateAd(user.Data.ID, ad.Data.ID, "hello", "changed")
+	assert.NoError(t, err)
+	_, err = client.eraseUser(adminID, user.Data.ID)
+	assert.NoError(t, err)
+
+	_, err = client.auditLog(user.Data.ID, nil)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	log, err := client.auditLog(adminID, queryParam{"target": "ad", "target_id": fmt.Sprint(ad.Data.ID)})
+	assert.NoError(t, err)
+	assert.Len(t, log.Data, 2)
+	updated := log.Data[0]
+	assert.Equal(t, "ad.updated", updated.Action)
+	assert.Equal(t, user.Data.ID, updated.Actor)
+	assert.Equal(t, "http", updated.Transport)
+	assert.Equal(t, "127.0.0.1", updated.IP)
+	var before, after struct{ Text string }
+	assert.NoError(t, json.Unmarshal(updated.Before, &before))
+	assert.NoError(t, json.Unmarshal(updated.After, &after))
+	assert.Equal(t, "world", before.Text)
+	assert.Equal(t, "changed", after.Text)
+
+	log, err = client.auditLog(adminID, queryParam{"actor": fmt.Sprint(adminID)})
+	assert.NoError(t, err)
+	assert.Len(t, log.Data, 1)
+	assert.Equal(t, "user.erased", log.Data[0].Action)
+
+	_, err = client.auditLog(adminID, queryParam{"from": "yesterday"})
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
@@ -15,7 +15,7 @@ import (
 func TestCreateAd(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -31,7 +31,7 @@ func TestCreateAd(t *testing.T) {
 func TestChangeAdStatus(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -54,7 +54,7 @@ func TestChangeAdStatus(t *testing.T) {
 func TestUpdateAd(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -70,7 +70,7 @@ func TestUpdateAd(t *testing.T) {
 func TestListAds(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -0,0 +1,73 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func Test_Ads_BatchCreate(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+
+	response, err := client.batchAds("batchCreate", []map[string]any{
+		{"title": "hello", "text": "world", "user_id": user.Data.ID},
+		{"title": "", "text": "world", "user_id": user.Data.ID},
+	}, false)
+	assert.NoError(t, err)
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, http.StatusOK, response.Data[0].Status)
+	assert.Equal(t, "hello", response.Data[0].Ad.Title)
+	assert.Equal(t, http.StatusBadRequest, response.Data[1].Status)
+	assert.NotNil(t, response.Data[1].Error)
+}
+
+func Test_Ads_BatchUpdateStatus(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	ad1, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	ad2, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	response, err := client.batchAds("batchUpdateStatus", []map[string]any{
+		{"ad_id": ad1.Data.ID, "user_id": user.Data.ID, "published": true},
+		{"ad_id": ad2.Data.ID, "user_id": user.Data.ID, "published": true},
+	}, true)
+	assert.NoError(t, err)
+	assert.True(t, response.Data[0].Ad.Published)
+	assert.True(t, response.Data[1].Ad.Published)
+}
+
+func Test_Ads_BatchDelete_AllOrNothing(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	other, err := client.createUser("other", "other@mail.ru")
+	assert.NoError(t, err)
+	ad1, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	ad2, err := client.createAd(other.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.batchAds("batchDelete", []map[string]any{
+		{"ad_id": ad1.Data.ID, "user_id": user.Data.ID},
+		{"ad_id": ad2.Data.ID, "user_id": user.Data.ID},
+	}, true)
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = client.getAdByID(ad1.Data.ID)
+	assert.NoError(t, err)
+}
+
+func Test_Ads_BatchUnknownMethod(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.batchAds("batchPublish", nil, false)
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
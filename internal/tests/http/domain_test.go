@@ -10,21 +10,27 @@ import (
 func TestChangeStatusAdOfAnotherUser(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
 	resp, err := client.createAd(userID, "hello", "world")
 	assert.NoError(t, err)
 
-	_, err = client.changeAdStatus(100, resp.Data.ID, true)
+	another, err := client.createUser("another", "another@mail.ru")
+	assert.NoError(t, err)
+
+	_, err = client.changeAdStatus(another.Data.ID, resp.Data.ID, true)
 	assert.ErrorIs(t, err, ErrForbidden)
+
+	_, err = client.changeAdStatus(100, resp.Data.ID, true)
+	assert.ErrorIs(t, err, ErrorNotFound)
 }
 
 func TestUpdateAdOfAnotherUser(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -38,7 +44,7 @@ func TestUpdateAdOfAnotherUser(t *testing.T) {
 func TestCreateAd_ID(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -58,7 +64,7 @@ func TestCreateAd_ID(t *testing.T) {
 func TestUpdateAd_changedUpdateTime(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
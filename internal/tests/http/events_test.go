@@ -0,0 +1,74 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func Test_Ads_Events(t *testing.T) {
+	client := getTestClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	existing, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	filters := queryParam{"user_id": strconv.FormatInt(user.Data.ID, 10)}
+	stream, err := client.adEvents(ctx, filters, "")
+	assert.NoError(t, err)
+	defer stream.Close()
+
+	event, err := stream.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "snapshot", event.Event)
+	var snapshot adResponse
+	assert.NoError(t, json.Unmarshal([]byte(event.Data), &snapshot))
+	assert.Equal(t, existing.Data.ID, snapshot.Data.ID)
+
+	synced, err := stream.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "synced", synced.Event)
+
+	created, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	event, err = stream.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "created", event.Event)
+	var ad adResponse
+	assert.NoError(t, json.Unmarshal([]byte(event.Data), &ad))
+	assert.Equal(t, created.Data.ID, ad.Data.ID)
+
+	// переподключение с Last-Event-ID возвращает пропущенные события без выборки
+	_, err = client.deleteAd(queryParam{"user_id": strconv.FormatInt(user.Data.ID, 10)}, existing.Data.ID)
+	assert.NoError(t, err)
+	resumed, err := client.adEvents(ctx, filters, event.ID)
+	assert.NoError(t, err)
+	defer resumed.Close()
+	event, err = resumed.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "deleted", event.Event)
+	event, err = resumed.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "synced", event.Event)
+}
+
+func Test_Ads_Events_Reset(t *testing.T) {
+	client := getTestClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.adEvents(ctx, queryParam{}, "100")
+	assert.NoError(t, err)
+	defer stream.Close()
+	event, err := stream.next()
+	assert.NoError(t, err)
+	assert.Equal(t, "reset", event.Event)
+
+	_, err = client.adEvents(ctx, queryParam{}, "abc")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
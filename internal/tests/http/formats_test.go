@@ -0,0 +1,81 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/ugorji/go/codec"
+	"google.golang.org/protobuf/proto"
+	"homework10/internal/ports/grpc"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+const (
+	mimeProtobuf = "application/x-protobuf"
+	mimeMsgPack  = "application/msgpack"
+)
+
+func TestFormats_ProtobufAndMsgPack(t *testing.T) {
+	client := getTestClient()
+	var handle codec.MsgpackHandle
+	handle.RawToString = true
+
+	body, _ := proto.Marshal(&grpc.UserRequest{Nickname: "binary", Email: "binary@mail.ru"})
+	resp, data, err := client.negotiate(http.MethodPost, "/users", body, mimeProtobuf, mimeProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var user grpc.UserResponse
+	assert.NoError(t, proto.Unmarshal(data, &user))
+	assert.Equal(t, "binary", user.Nickname)
+
+	// тело в MessagePack, ответ в protobuf
+	var encoded []byte
+	assert.NoError(t, codec.NewEncoderBytes(&encoded, &handle).Encode(map[string]any{"title": "packed", "text": "ad", "user_id": user.Id}))
+	resp, data, err = client.negotiate(http.MethodPost, "/ads", encoded, mimeMsgPack, mimeProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	var ad grpc.AdResponse
+	assert.NoError(t, proto.Unmarshal(data, &ad))
+	assert.Equal(t, user.Id, ad.AuthorId)
+
+	// выборка объявлений видна во всех форматах одинаково
+	query := "/ads?published=false&user_id=" + strconv.FormatInt(user.Id, 10)
+	resp, data, err = client.negotiate(http.MethodGet, query, nil, "", mimeProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, mimeProtobuf, resp.Header.Get("Content-Type"))
+	var list grpc.ListAdResponse
+	assert.NoError(t, proto.Unmarshal(data, &list))
+	if assert.Len(t, list.List, 1) {
+		assert.Equal(t, "packed", list.List[0].Title)
+	}
+
+	resp, data, err = client.negotiate(http.MethodGet, query, nil, "", mimeMsgPack)
+	assert.NoError(t, err)
+	assert.Equal(t, mimeMsgPack, resp.Header.Get("Content-Type"))
+	var ads adsResponse
+	assert.NoError(t, codec.NewDecoderBytes(data, &handle).Decode(&ads))
+	if assert.Len(t, ads.Data, 1) {
+		assert.Equal(t, ad.Id, ads.Data[0].ID)
+		assert.Equal(t, ad.CreateDate.AsTime(), ads.Data[0].CreateDate.UTC())
+	}
+
+	v1, err := client.getAdByID(ad.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "packed", v1.Data.Title)
+}
+
+func TestFormats_NotAcceptable(t *testing.T) {
+	client := getTestClient()
+	user, err := client.createUser("quota", "quota@mail.ru")
+	assert.NoError(t, err)
+
+	// у квоты нет сообщения в service.proto
+	path := "/users/" + strconv.FormatInt(user.Data.ID, 10) + "/quota?requester_id=" + strconv.FormatInt(adminID, 10)
+	resp, _, err := client.negotiate(http.MethodGet, path, nil, "", mimeProtobuf)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotAcceptable, resp.StatusCode)
+
+	resp, _, err = client.negotiate(http.MethodPost, "/admin/projections/rebuild", []byte{}, mimeProtobuf, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
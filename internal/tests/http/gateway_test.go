@@ -0,0 +1,90 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"testing"
+)
+
+// ответы /api/v2 - protojson, int64 приходят строками
+type gatewayAd struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+	AuthorID string `json:"author_id"`
+}
+
+type gatewayUser struct {
+	ID       string `json:"id"`
+	Nickname string `json:"nickname"`
+	Email    string `json:"email"`
+}
+
+type gatewayStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func TestGateway_SharesStateWithV1(t *testing.T) {
+	client := getTestClient()
+
+	var user gatewayUser
+	resp, err := client.gateway(http.MethodPost, "/users", map[string]any{"nickname": "gateway", "email": "gateway@mail.ru"}, &user)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "gateway", user.Nickname)
+
+	var ad gatewayAd
+	resp, err = client.gateway(http.MethodPost, "/ads", map[string]any{"title": "hello", "text": "world", "user_id": user.ID}, &ad)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, user.ID, ad.AuthorID)
+
+	// объявление из /api/v2 видно через /api/v1 и наоборот
+	adID, err := strconv.ParseInt(ad.ID, 10, 64)
+	assert.NoError(t, err)
+	v1, err := client.getAdByID(adID)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", v1.Data.Title)
+
+	_, err = client.updateAd(v1.Data.AuthorID, v1.Data.ID, "hello", "from v1")
+	assert.NoError(t, err)
+	resp, err = client.gateway(http.MethodGet, "/ads/"+ad.ID, nil, &ad)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "from v1", ad.Text)
+
+	resp, err = client.gateway(http.MethodPatch, "/ads/"+ad.ID, map[string]any{"user_id": user.ID, "text": "changed", "update_mask": "text"}, &ad)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "hello", ad.Title)
+	assert.Equal(t, "changed", ad.Text)
+
+	// журнал аудита отмечает вызов шлюза как HTTP
+	log, err := client.auditLog(adminID, queryParam{"action": "ad.updated"})
+	assert.NoError(t, err)
+	if assert.NotEmpty(t, log.Data) {
+		assert.Equal(t, "http", log.Data[0].Transport)
+	}
+}
+
+func TestGateway_Errors(t *testing.T) {
+	client := getTestClient()
+
+	var status gatewayStatus
+	resp, err := client.gateway(http.MethodGet, "/ads/100", nil, &status)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 5, status.Code)
+
+	resp, err = client.gateway(http.MethodPost, "/users", map[string]any{"nickname": "bad", "email": "not an email"}, &status)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, 3, status.Code)
+
+	// потоковые методы шлюз не обслуживает
+	resp, err = client.gateway(http.MethodGet, "/ads/export", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
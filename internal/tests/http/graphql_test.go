@@ -0,0 +1,110 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+type graphqlResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message    string         `json:"message"`
+		Extensions map[string]any `json:"extensions"`
+	} `json:"errors"`
+}
+
+func (tc *testClient) graphql(query string, variables map[string]any, out any) (graphqlResponse, error) {
+	var resp graphqlResponse
+	data, err := json.Marshal(map[string]any{"query": query, "variables": variables})
+	if err != nil {
+		return resp, fmt.Errorf("unable to marshal: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/graphql", bytes.NewReader(data))
+	if err != nil {
+		return resp, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if err = tc.getResponse(req, &resp); err != nil {
+		return resp, err
+	}
+	if out != nil && resp.Data != nil {
+		err = json.Unmarshal(resp.Data, out)
+	}
+	return resp, err
+}
+
+func TestGraphQL_AdWithAuthor(t *testing.T) {
+	client := getTestClient()
+
+	var created struct {
+		CreateUser struct{ ID string } `json:"createUser"`
+	}
+	resp, err := client.graphql(`mutation($nickname: String!, $email: String!) {
+		createUser(input: {nickname: $nickname, email: $email}) { id }
+	}`, map[string]any{"nickname": "graph", "email": "graph@mail.ru"}, &created)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Errors)
+
+	for _, title := range []string{"first", "second"} {
+		_, err = client.graphql(`mutation($user: ID!, $title: String!) {
+			createAd(input: {userId: $user, title: $title, text: "text"}) { id }
+		}`, map[string]any{"user": created.CreateUser.ID, "title": title}, nil)
+		assert.NoError(t, err)
+	}
+	// объявление из /api/v1 тоже видно через /graphql
+	user, err := client.getUserByNickname("graph")
+	assert.NoError(t, err)
+	_, err = client.createAd(user.Data.ID, "third", "text")
+	assert.NoError(t, err)
+
+	var result struct {
+		Ads struct {
+			TotalCount int `json:"totalCount"`
+			Nodes      []struct {
+				Title  string `json:"title"`
+				Author struct {
+					Nickname string `json:"nickname"`
+					Ads      struct {
+						TotalCount int `json:"totalCount"`
+					} `json:"ads"`
+				} `json:"author"`
+			} `json:"nodes"`
+		} `json:"ads"`
+	}
+	resp, err = client.graphql(`query($author: ID!) {
+		ads(filter: {authorId: $author, published: false}, first: 2) {
+			totalCount
+			nodes { title author { nickname ads(first: 0) { totalCount } } }
+		}
+	}`, map[string]any{"author": created.CreateUser.ID}, &result)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, 3, result.Ads.TotalCount)
+	if assert.Len(t, result.Ads.Nodes, 2) {
+		assert.Equal(t, "first", result.Ads.Nodes[0].Title)
+		assert.Equal(t, "graph", result.Ads.Nodes[0].Author.Nickname)
+		assert.Equal(t, 3, result.Ads.Nodes[0].Author.Ads.TotalCount)
+	}
+}
+
+func TestGraphQL_Errors(t *testing.T) {
+	client := getTestClient()
+
+	resp, err := client.graphql(`mutation { createAd(input: {userId: "100", title: "t", text: "t"}) { id } }`, nil, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "USER_NOT_FOUND", resp.Errors[0].Extensions["code"])
+	}
+
+	var result struct {
+		User *struct{ ID string } `json:"user"`
+	}
+	resp, err = client.graphql(`{ user(id: "100") { id } }`, nil, &result)
+	assert.NoError(t, err)
+	assert.Empty(t, resp.Errors)
+	assert.Nil(t, result.User)
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestCreateAd_IdempotencyKey(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("retry", "retry@mail.ru")
+	assert.NoError(t, err)
+
+	first, err := client.createAdWithKey("create-ad-1", user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	retry, err := client.createAdWithKey("create-ad-1", user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	assert.Equal(t, first, retry)
+
+	// повтор не создал второе объявление
+	ads, err := client.listAdsFilters(queryParam{"user_id": strconv.Itoa(int(user.Data.ID))})
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 1)
+
+	_, err = client.createAdWithKey("create-ad-1", user.Data.ID, "hello", "other")
+	assert.ErrorIs(t, err, ErrUnprocessable)
+
+	second, err := client.createAdWithKey("create-ad-2", user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Data.ID, second.Data.ID)
+}
+
+func TestCreateAd_IdempotencyKeyFailedRequest(t *testing.T) {
+	client := getTestClient()
+
+	// неуспешный ответ не сохраняется, после исправления причины повтор с тем же ключом выполняется
+	_, err := client.createAdWithKey("create-ad", 0, "hello", "world")
+	assert.ErrorIs(t, err, ErrorNotFound)
+
+	user, err := client.createUser("retry", "retry@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAdWithKey("create-ad", user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	assert.Equal(t, user.Data.ID, ad.Data.AuthorID)
+}
@@ -0,0 +1,55 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchAd(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	// меняется только title, text остается прежним
+	patched, err := client.patchAd(ad.Data.ID, map[string]any{"user_id": user.Data.ID, "title": "bye"})
+	assert.NoError(t, err)
+	assert.Equal(t, "bye", patched.Data.Title)
+	assert.Equal(t, "world", patched.Data.Text)
+
+	// null не очищает поле: все поля обязательны, такой запрос отклоняется
+	_, err = client.patchAd(ad.Data.ID, map[string]any{"user_id": user.Data.ID, "text": nil})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchAd(ad.Data.ID, map[string]any{"user_id": user.Data.ID + 1, "title": "stolen"})
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	got, err := client.getAdByID(ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "bye", got.Data.Title)
+	assert.Equal(t, "world", got.Data.Text)
+}
+
+func TestPatchUser(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+
+	patched, err := client.patchUser(user.Data.ID, map[string]any{"nickname": "renamed"})
+	assert.NoError(t, err)
+	assert.Equal(t, "renamed", patched.Data.Nickname)
+	assert.Equal(t, "qwertys@mail.ru", patched.Data.Email)
+
+	// в отличие от PUT пустые поля не пропускаются
+	_, err = client.patchUser(user.Data.ID, map[string]any{"email": ""})
+	assert.ErrorIs(t, err, ErrBadRequest)
+	_, err = client.patchUser(user.Data.ID, map[string]any{"nickname": nil})
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.patchUser(user.Data.ID+100, map[string]any{"nickname": "ghost"})
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
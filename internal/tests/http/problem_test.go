@@ -0,0 +1,49 @@
+package http
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestProblemResponse(t *testing.T) {
+	client := getTestClient()
+
+	resp, body, err := client.registerUserAccept("application/problem+json", "qwertys", "not an email")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var problem problemResponse
+	assert.NoError(t, json.Unmarshal(body, &problem))
+	assert.Equal(t, "/problems/invalid_argument", problem.Type)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "bad_email", problem.Code)
+	assert.Equal(t, "/api/v1/users", problem.Instance)
+	assert.Equal(t, []problemField{{Field: "email", Code: "bad_email", Detail: "bad email"}}, problem.Errors)
+
+	_, err = client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	resp, body, err = client.registerUserAccept("application/problem+json", "qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+
+	var conflict problemResponse
+	assert.NoError(t, json.Unmarshal(body, &conflict))
+	assert.Equal(t, "user_conflict", conflict.Code)
+	assert.Empty(t, conflict.Errors)
+}
+
+func TestProblemResponse_LegacyEnvelope(t *testing.T) {
+	client := getTestClient()
+
+	// без Accept и с */* - прежний конверт, problem+json только по явной просьбе
+	for _, accept := range []string{"", "*/*", "application/json"} {
+		resp, body, err := client.registerUserAccept(accept, "qwertys", "not an email")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		assert.Contains(t, resp.Header.Get("Content-Type"), "application/json")
+		assert.JSONEq(t, `{"data": null, "error": "bad email"}`, string(body))
+	}
+}
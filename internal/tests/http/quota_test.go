@@ -0,0 +1,38 @@
+package http
+
+import (
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/app"
+	"homework10/internal/quota"
+	"testing"
+)
+
+func TestQuotas(t *testing.T) {
+	plan := quota.Plan{Name: "free", MaxActiveAds: 1, MaxDailyAds: 2}
+	client := getTestClient(app.WithQuotas(quota.Policy{Default: plan}))
+
+	user, err := client.createUser("quota", "quota@mail.ru")
+	assert.NoError(t, err)
+	first, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	second, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	_, err = client.createAd(user.Data.ID, "hello", "world")
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+
+	_, err = client.changeAdStatus(user.Data.ID, first.Data.ID, true)
+	assert.NoError(t, err)
+	_, err = client.changeAdStatus(user.Data.ID, second.Data.ID, true)
+	assert.ErrorIs(t, err, ErrForbidden)
+
+	usage, err := client.userQuota(user.Data.ID, user.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "free", usage.Data.Plan.Name)
+	assert.Equal(t, 1, usage.Data.ActiveAds)
+	assert.Equal(t, 2, usage.Data.DailyAds)
+
+	_, err = client.userQuota(user.Data.ID+1, user.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.userQuota(adminID, user.Data.ID)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestCreateUser_RateLimit(t *testing.T) {
+	client := getTestClient()
+
+	// регистрация без пользователя в запросе ограничена по адресу: 10 в минуту
+	for i := 0; i < 10; i++ {
+		_, err := client.createUser(fmt.Sprint("user", i), fmt.Sprintf("user%d@mail.ru", i))
+		assert.NoError(t, err)
+	}
+	_, err := client.createUser("user10", "user10@mail.ru")
+	assert.ErrorIs(t, err, ErrTooManyRequests)
+
+	// лимит на регистрацию не мешает создавать объявления
+	_, err = client.createAd(0, "hello", "world")
+	assert.NoError(t, err)
+}
+
+func TestCreateUser_RateLimitSharedWithGateway(t *testing.T) {
+	client := getTestClient()
+
+	for i := 0; i < 10; i++ {
+		_, err := client.createUser(fmt.Sprint("user", i), fmt.Sprintf("user%d@mail.ru", i))
+		assert.NoError(t, err)
+	}
+	// /api/v2 тратит то же ведро, что и /api/v1
+	resp, err := client.gateway(http.MethodPost, "/users", map[string]any{"nickname": "user10", "email": "user10@mail.ru"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestCreateUser_RateLimitSharedWithGraphQL(t *testing.T) {
+	client := getTestClient()
+
+	for i := 0; i < 10; i++ {
+		_, err := client.createUser(fmt.Sprint("user", i), fmt.Sprintf("user%d@mail.ru", i))
+		assert.NoError(t, err)
+	}
+	// мутация createUser тратит то же ведро, что и POST /users
+	resp, err := client.graphql(`mutation { createUser(input: {nickname: "user10", email: "user10@mail.ru"}) { id } }`,
+		nil, nil)
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Errors, 1) {
+		assert.Equal(t, "RATE_LIMITED", resp.Errors[0].Extensions["code"])
+	}
+}
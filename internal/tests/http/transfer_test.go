@@ -0,0 +1,73 @@
+package http
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func Test_Ads_Import_CSV(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	file := fmt.Sprintf("title,text,user_id\nhello,world,%d\n,world,%d\nhello,world,abc\n", user.Data.ID, user.Data.ID)
+
+	response, err := client.importAds("csv", false, file, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, response.Data.Total)
+	assert.Equal(t, 1, response.Data.Valid)
+	assert.Equal(t, 2, response.Data.Failed)
+	assert.Equal(t, 3, response.Data.Rows[1].Line)
+
+	ad, err := client.getAdByID(*response.Data.Rows[0].AdID)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", ad.Data.Title)
+}
+
+func Test_Ads_Import_DryRun(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	file := fmt.Sprintf(`{"title":"hello","text":"world","user_id":%d}`, user.Data.ID)
+
+	response, err := client.importAds("jsonl", true, file, false)
+	assert.NoError(t, err)
+	assert.True(t, response.Data.DryRun)
+	assert.Equal(t, 1, response.Data.Valid)
+	assert.Nil(t, response.Data.Rows[0].AdID)
+
+	ads, err := client.listAdsFilters(queryParam{"published": "false"})
+	assert.NoError(t, err)
+	assert.Len(t, ads.Data, 0)
+}
+
+func Test_Ads_Import_BadHeader(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.importAds("csv", false, "title,text\nhello,world\n", false)
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func Test_Ads_Export(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
+	assert.NoError(t, err)
+	_, err = client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+	_, err = client.createAd(user.Data.ID, "second", "world")
+	assert.NoError(t, err)
+
+	exported, err := client.exportAds("jsonl", queryParam{"user_id": strconv.FormatInt(user.Data.ID, 10), "published": "false"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(exported, "\n"))
+
+	// выгрузку можно загрузить обратно
+	response, err := client.importAds("jsonl", true, exported, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, response.Data.Valid)
+}
@@ -57,7 +57,203 @@ func Test_User_Delete(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, deleteUser.UserId, user.Data.ID)
 
-	deleteUser, err = client.deleteUser(user.Data.ID)
+	_, err = client.deleteUser(user.Data.ID)
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func Test_User_Delete_CascadeAds(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.deleteUser(user.Data.ID)
+	assert.NoError(t, err)
+
+	_, err = client.getAdByID(ad.Data.ID)
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func Test_User_Delete_TransferAds(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	newOwner, err := client.createUser("owner", "owner@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.deleteUserWithAds(user.Data.ID, "transfer", newOwner.Data.ID)
+	assert.NoError(t, err)
+
+	transferred, err := client.getAdByID(ad.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, newOwner.Data.ID, transferred.Data.AuthorID)
+}
+
+func Test_User_Delete_TransferAds_BadOwner(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+
+	_, err = client.deleteUserWithAds(user.Data.ID, "transfer", user.Data.ID)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.deleteUserWithAds(user.Data.ID, "keep", 0)
+	assert.ErrorIs(t, err, ErrBadRequest)
+
+	_, err = client.getUserByID(user.Data.ID)
+	assert.NoError(t, err)
+}
+
+func Test_User_Create_BadEmail(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.registerUser("qwertys", "@mail.ru")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func Test_User_Verify(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.registerUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	assert.False(t, user.Data.Verified)
+
+	verified, err := client.verifyUser(client.tokens.token(user.Data.ID))
+	assert.NoError(t, err)
+	assert.True(t, verified.Data.Verified)
+
+	_, err = client.verifyUser(client.tokens.token(user.Data.ID))
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func Test_User_ResendVerification(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.registerUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+
+	resent, err := client.resendVerification(user.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Data.ID, resent.Data.ID)
+	verified, err := client.verifyUser(client.tokens.token(user.Data.ID))
+	assert.NoError(t, err)
+	assert.True(t, verified.Data.Verified)
+
+	_, err = client.resendVerification(user.Data.ID)
+	assert.ErrorIs(t, err, ErrUnprocessable)
+	_, err = client.resendVerification(-1)
+	assert.ErrorIs(t, err, ErrorNotFound)
+}
+
+func Test_User_Verify_BadToken(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.verifyUser("bad token")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func Test_User_Unverified_CantPublish(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.registerUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	_, err = client.changeAdStatus(user.Data.ID, ad.Data.ID, true)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func Test_User_Create_Conflict(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+
+	_, err = client.registerUser("QWERTYS", "other@mail.ru")
+	assert.ErrorIs(t, err, ErrConflict)
+
+	_, err = client.registerUser("other", "QW@mail.ru")
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func Test_User_Update_Conflict(t *testing.T) {
+	client := getTestClient()
+
+	_, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	user, err := client.createUser("other", "other@mail.ru")
+	assert.NoError(t, err)
+
+	_, err = client.updateUser(user.Data.ID, "Qwertys", "")
+	assert.ErrorIs(t, err, ErrConflict)
+}
+
+func Test_User_GetByNickname(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+
+	userByNickname, err := client.getUserByNickname("QwErTyS")
+	assert.NoError(t, err)
+	assert.Equal(t, user.Data.ID, userByNickname.Data.ID)
+
+	_, err = client.getUserByNickname("unknown")
+	assert.ErrorIs(t, err, ErrorNotFound)
+
+	_, err = client.getUserByNickname("")
+	assert.ErrorIs(t, err, ErrBadRequest)
+}
+
+func Test_User_Export(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	_, err = client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	export, err := client.exportUser(user.Data.ID, user.Data.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, user.Data.Email, export.Profile.Email)
+	assert.Len(t, export.Ads, 1)
+}
+
+func Test_User_Export_Forbidden(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	other, err := client.createUser("other", "other@mail.ru")
+	assert.NoError(t, err)
+
+	_, err = client.exportUser(other.Data.ID, user.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+	_, err = client.eraseUser(other.Data.ID, user.Data.ID)
+	assert.ErrorIs(t, err, ErrForbidden)
+}
+
+func Test_User_Erase(t *testing.T) {
+	client := getTestClient()
+
+	user, err := client.createUser("qwertys", "qw@mail.ru")
+	assert.NoError(t, err)
+	ad, err := client.createAd(user.Data.ID, "hello", "world")
+	assert.NoError(t, err)
+
+	erased, err := client.eraseUser(user.Data.ID, user.Data.ID)
+	assert.NoError(t, err)
+	assert.NotEqual(t, user.Data.Email, erased.Data.Email)
+	assert.NotEqual(t, user.Data.Nickname, erased.Data.Nickname)
+
+	_, err = client.getAdByID(ad.Data.ID)
 	assert.NoError(t, err)
-	assert.Equal(t, deleteUser.UserId, user.Data.ID)
 }
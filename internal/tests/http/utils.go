@@ -1,22 +1,31 @@
 package http
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"homework10/internal/adapters/repository/adrepo"
 	"homework10/internal/adapters/repository/userrepo"
 	"homework10/internal/app"
+	"homework10/internal/entities"
+	"homework10/internal/ports/graphql"
+	"homework10/internal/ports/grpc"
 	"homework10/internal/util"
 	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"homework10/internal/ports/httpgin"
+	"homework10/internal/ratelimit"
 )
 
 type adData struct {
@@ -41,11 +50,70 @@ type userData struct {
 	ID       int64  `json:"id"`
 	Nickname string `json:"nickname"`
 	Email    string `json:"email"`
+	Verified bool   `json:"verified"`
 }
 type userResponse struct {
 	Data userData `json:"data"`
 }
 
+type batchItem struct {
+	Status int     `json:"status"`
+	Ad     *adData `json:"ad"`
+	Error  *string `json:"error"`
+}
+
+type batchResponse struct {
+	Data []batchItem `json:"data"`
+}
+
+type importRow struct {
+	Line  int     `json:"line"`
+	AdID  *int64  `json:"ad_id"`
+	Error *string `json:"error"`
+}
+
+type importResponse struct {
+	Data struct {
+		DryRun bool        `json:"dry_run"`
+		Total  int         `json:"total"`
+		Valid  int         `json:"valid"`
+		Failed int         `json:"failed"`
+		Rows   []importRow `json:"rows"`
+	} `json:"data"`
+}
+
+type quotaResponse struct {
+	Data struct {
+		Plan struct {
+			Name         string `json:"name"`
+			MaxActiveAds int    `json:"max_active_ads"`
+			MaxDailyAds  int    `json:"max_daily_ads"`
+		} `json:"plan"`
+		ActiveAds int `json:"active_ads"`
+		DailyAds  int `json:"daily_ads"`
+	} `json:"data"`
+}
+
+type userExportResponse struct {
+	Profile userData          `json:"profile"`
+	Ads     []json.RawMessage `json:"ads"`
+}
+
+type auditEntry struct {
+	Actor     int64           `json:"actor"`
+	Action    string          `json:"action"`
+	Target    string          `json:"target"`
+	TargetID  int64           `json:"target_id"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	IP        string          `json:"ip"`
+	Transport string          `json:"transport"`
+}
+
+type auditResponse struct {
+	Data []auditEntry `json:"data"`
+}
+
 type userDeleteResponse struct {
 	UserId int64 `json:"user_id"`
 }
@@ -56,32 +124,71 @@ type adDeleteResponse struct {
 }
 
 var (
-	ErrBadRequest = fmt.Errorf("bad request")
-	ErrForbidden  = fmt.Errorf("forbidden")
-	ErrorNotFound = fmt.Errorf("not found")
+	ErrBadRequest      = fmt.Errorf("bad request")
+	ErrForbidden       = fmt.Errorf("forbidden")
+	ErrorNotFound      = fmt.Errorf("not found")
+	ErrConflict        = fmt.Errorf("conflict")
+	ErrUnprocessable   = fmt.Errorf("unprocessable entity")
+	ErrTooManyRequests = fmt.Errorf("too many requests")
 )
 
+// adminID администратор тестового сервера, пользователя с таким ID в тестах нет
+const adminID = 1000
+
 type testClient struct {
 	client  *http.Client
 	baseURL string
+	tokens  *tokenCatcher
+}
+
+// tokenCatcher перехватывает токены подтверждения email вместо отправки письма
+type tokenCatcher struct {
+	mutex  sync.Mutex
+	tokens map[int64]string
+}
+
+func (t *tokenCatcher) SendVerification(ctx context.Context, user entities.User, token string) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.tokens[user.ID] = token
+	return nil
+}
+
+func (t *tokenCatcher) token(userID int64) string {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.tokens[userID]
 }
 
 type queryParam map[string]string
 
-func getTestClient() *testClient {
+// getTestClient opts дополняют настройки App тестового сервера
+func getTestClient(opts ...app.Option) *testClient {
 	logger := log.New(io.Discard, "", 0)
 	gin.DefaultWriter = io.Discard
 	repo := adrepo.New()
 	uRep := userrepo.New()
 	formatter := util.NewDateTimeFormatter(time.RFC3339)
-	newApp := app.NewApp(repo, uRep, formatter)
-	server := httpgin.NewHTTPServer(":18080", newApp, logger, "*cert", "*key")
+	tokens := &tokenCatcher{tokens: make(map[int64]string)}
+	opts = append([]app.Option{app.WithVerificationSender(tokens), app.WithAdmins(adminID)}, opts...)
+	newApp := app.NewApp(repo, uRep, formatter, opts...)
+	// как в main, лимиты /api/v1, /api/v2 и /graphql считаются в одних ведрах
+	limiter := ratelimit.NewLimiter()
+	gateway, err := grpc.NewGateway(newApp, grpc.WithRateLimits(limiter, grpc.DefaultRateLimits))
+	if err != nil {
+		panic(err)
+	}
+	server := httpgin.NewHTTPServer(":18080", newApp, logger, "*cert", "*key",
+		httpgin.WithRateLimits(limiter, httpgin.DefaultRateLimits),
+		httpgin.WithGateway(gateway),
+		httpgin.WithGraphQL(graphql.NewHandler(newApp, graphql.WithRateLimits(limiter, graphql.DefaultRateLimits))))
 	httpServer := server.(*httpgin.HttpServer)
 	testServer := httptest.NewServer(httpServer.App.Handler)
 
 	return &testClient{
 		client:  testServer.Client(),
 		baseURL: testServer.URL,
+		tokens:  tokens,
 	}
 }
 
@@ -101,6 +208,15 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 		if resp.StatusCode == http.StatusNotFound {
 			return ErrorNotFound
 		}
+		if resp.StatusCode == http.StatusConflict {
+			return ErrConflict
+		}
+		if resp.StatusCode == http.StatusUnprocessableEntity {
+			return ErrUnprocessable
+		}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			return ErrTooManyRequests
+		}
 		return fmt.Errorf("unexpected status code: %s", resp.Status)
 	}
 
@@ -118,6 +234,11 @@ func (tc *testClient) getResponse(req *http.Request, out any) error {
 }
 
 func (tc *testClient) createAd(userID int64, title string, text string) (adResponse, error) {
+	return tc.createAdWithKey("", userID, title, text)
+}
+
+// createAdWithKey создает объявление с заголовком Idempotency-Key, если key не пустой
+func (tc *testClient) createAdWithKey(key string, userID int64, title string, text string) (adResponse, error) {
 	body := map[string]any{
 		"user_id": userID,
 		"title":   title,
@@ -135,6 +256,9 @@ func (tc *testClient) createAd(userID int64, title string, text string) (adRespo
 	}
 
 	req.Header.Add("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Add("Idempotency-Key", key)
+	}
 
 	var response adResponse
 	err = tc.getResponse(req, &response)
@@ -145,6 +269,21 @@ func (tc *testClient) createAd(userID int64, title string, text string) (adRespo
 	return response, nil
 }
 
+func (tc *testClient) userQuota(requesterID int64, userID int64) (quotaResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/quota?requester_id=%d", userID, requesterID), nil)
+	if err != nil {
+		return quotaResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response quotaResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return quotaResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) changeAdStatus(userID int64, adID int64, published bool) (adResponse, error) {
 	body := map[string]any{
 		"user_id":   userID,
@@ -254,7 +393,60 @@ func (tc *testClient) getAdByID(adID int64) (adResponse, error) {
 	return response, nil
 }
 
+// createUser регистрирует пользователя и сразу подтверждает его email
 func (tc *testClient) createUser(Nickname string, Email string) (userResponse, error) {
+	response, err := tc.registerUser(Nickname, Email)
+	if err != nil {
+		return userResponse{}, err
+	}
+	if _, err = tc.verifyUser(tc.tokens.token(response.Data.ID)); err != nil {
+		return userResponse{}, err
+	}
+	return response, nil
+}
+
+func (tc *testClient) verifyUser(token string) (userResponse, error) {
+	body := map[string]any{
+		"token": token,
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/users/verify", bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) resendVerification(userID int64) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/verification", userID), nil)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) registerUser(Nickname string, Email string) (userResponse, error) {
 	body := map[string]any{
 		"nickname": Nickname,
 		"email":    Email,
@@ -308,6 +500,34 @@ func (tc *testClient) updateUser(userID int64, Nickname string, Email string) (u
 	return response, nil
 }
 
+// mergePatch отправляет PATCH с телом JSON Merge Patch, nil в patch передается как null
+func (tc *testClient) mergePatch(path string, patch map[string]any, out any) error {
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPatch, tc.baseURL+"/api/v1"+path, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/merge-patch+json")
+	return tc.getResponse(req, out)
+}
+
+func (tc *testClient) patchAd(adID int64, patch map[string]any) (adResponse, error) {
+	var response adResponse
+	err := tc.mergePatch(fmt.Sprintf("/ads/%d", adID), patch, &response)
+	return response, err
+}
+
+func (tc *testClient) patchUser(userID int64, patch map[string]any) (userResponse, error) {
+	var response userResponse
+	err := tc.mergePatch(fmt.Sprintf("/users/%d", userID), patch, &response)
+	return response, err
+}
+
 func (tc *testClient) getUserByID(userID int64) (userResponse, error) {
 	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), nil)
 	if err != nil {
@@ -323,8 +543,29 @@ func (tc *testClient) getUserByID(userID int64) (userResponse, error) {
 	return response, nil
 }
 
+func (tc *testClient) getUserByNickname(nickname string) (userResponse, error) {
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/users?nickname="+url.QueryEscape(nickname), nil)
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
 func (tc *testClient) deleteUser(userID int64) (userDeleteResponse, error) {
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d", userID), nil)
+	return tc.deleteUserWithAds(userID, "cascade", 0)
+}
+
+// deleteUserWithAds policy: cascade или transfer, newOwnerID учитывается только при transfer
+func (tc *testClient) deleteUserWithAds(userID int64, policy string, newOwnerID int64) (userDeleteResponse, error) {
+	url := fmt.Sprintf(tc.baseURL+"/api/v1/users/%d?ads=%s&new_owner_id=%d", userID, policy, newOwnerID)
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
 	if err != nil {
 		return userDeleteResponse{}, fmt.Errorf("unable to create request: %w", err)
 	}
@@ -352,3 +593,278 @@ func (tc *testClient) deleteAd(queryParam queryParam, adID int64) (adDeleteRespo
 
 	return response, nil
 }
+
+func (tc *testClient) exportUser(requesterID int64, userID int64) (userExportResponse, error) {
+	url := fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/export?requester_id=%d", userID, requesterID)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return userExportResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	var response userExportResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userExportResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) eraseUser(requesterID int64, userID int64) (userResponse, error) {
+	data, err := json.Marshal(map[string]any{"requester_id": requesterID})
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(tc.baseURL+"/api/v1/users/%d/erase", userID), bytes.NewReader(data))
+	if err != nil {
+		return userResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response userResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return userResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) auditLog(requesterID int64, queryParam queryParam) (auditResponse, error) {
+	u := url.Values{}
+	u.Set("requester_id", fmt.Sprint(requesterID))
+	for key, value := range queryParam {
+		u.Set(key, value)
+	}
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/admin/audit?"+u.Encode(), nil)
+	if err != nil {
+		return auditResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	var response auditResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return auditResponse{}, err
+	}
+
+	return response, nil
+}
+
+// batchAds method: batchCreate, batchUpdateStatus или batchDelete
+func (tc *testClient) batchAds(method string, items []map[string]any, allOrNothing bool) (batchResponse, error) {
+	data, err := json.Marshal(map[string]any{"items": items, "all_or_nothing": allOrNothing})
+	if err != nil {
+		return batchResponse{}, fmt.Errorf("unable to marshal: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/ads:"+method, bytes.NewReader(data))
+	if err != nil {
+		return batchResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	var response batchResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return batchResponse{}, err
+	}
+
+	return response, nil
+}
+
+// importAds asFile отправляет файл полем file в multipart/form-data, иначе телом запроса
+func (tc *testClient) importAds(format string, dryRun bool, file string, asFile bool) (importResponse, error) {
+	url := fmt.Sprintf(tc.baseURL+"/api/v1/ads/import?format=%s&dry_run=%t", format, dryRun)
+	body := &bytes.Buffer{}
+	contentType := "text/plain"
+	if asFile {
+		form := multipart.NewWriter(body)
+		part, err := form.CreateFormFile("file", "ads."+format)
+		if err != nil {
+			return importResponse{}, err
+		}
+		_, _ = part.Write([]byte(file))
+		_ = form.Close()
+		contentType = form.FormDataContentType()
+	} else {
+		body.WriteString(file)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, body)
+	if err != nil {
+		return importResponse{}, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", contentType)
+
+	var response importResponse
+	err = tc.getResponse(req, &response)
+	if err != nil {
+		return importResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (tc *testClient) exportAds(format string, queryParam queryParam) (string, error) {
+	queryParam["format"] = format
+	req, err := http.NewRequest(http.MethodGet, tc.baseURL+"/api/v1/ads/export"+parseQueryParams(queryParam), nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to create request: %w", err)
+	}
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status code: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	return string(data), err
+}
+
+type problemField struct {
+	Field  string `json:"field"`
+	Code   string `json:"code"`
+	Detail string `json:"detail"`
+}
+
+type problemResponse struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail"`
+	Instance string         `json:"instance"`
+	Code     string         `json:"code"`
+	Errors   []problemField `json:"errors"`
+}
+
+// registerUserAccept регистрирует пользователя и возвращает ответ как есть, с заголовком Accept
+func (tc *testClient) registerUserAccept(accept string, nickname string, email string) (*http.Response, []byte, error) {
+	data, err := json.Marshal(map[string]any{"nickname": nickname, "email": email})
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to marshal: %w", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, tc.baseURL+"/api/v1/users", bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	if accept != "" {
+		req.Header.Add("Accept", accept)
+	}
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, body, err
+}
+
+// gateway запрос к /api/v2 с JSON телом, ответ как есть
+func (tc *testClient) gateway(method string, path string, body any, out any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, tc.baseURL+"/api/v2"+path, reader)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	req.Header.Add("Content-Type", "application/json")
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		err = json.NewDecoder(resp.Body).Decode(out)
+	}
+	return resp, err
+}
+
+// negotiate запрос к /api/v1 с телом в формате contentType и заголовком Accept, ответ как есть
+func (tc *testClient) negotiate(method string, path string, body []byte, contentType string, accept string) (*http.Response, []byte, error) {
+	req, err := http.NewRequest(method, tc.baseURL+"/api/v1"+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Add("Content-Type", contentType)
+	}
+	req.Header.Add("Accept", accept)
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unexpected error: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp, data, err
+}
+
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  string
+}
+
+// eventStream читает события из ответа /ads/events
+type eventStream struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func (tc *testClient) adEvents(ctx context.Context, queryParam queryParam, lastEventID string) (*eventStream, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tc.baseURL+"/api/v1/ads/events"+parseQueryParams(queryParam), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request: %w", err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := tc.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unexpected error: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusBadRequest {
+			return nil, ErrBadRequest
+		}
+		return nil, fmt.Errorf("unexpected status code: %s", resp.Status)
+	}
+	return &eventStream{body: resp.Body, reader: bufio.NewReader(resp.Body)}, nil
+}
+
+func (s *eventStream) next() (sseEvent, error) {
+	var event sseEvent
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			return event, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" {
+			return event, nil
+		}
+		field, value, _ := strings.Cut(line, ":")
+		switch field {
+		case "id":
+			event.ID = value
+		case "event":
+			event.Event = value
+		case "data":
+			event.Data = value
+		}
+	}
+}
+
+func (s *eventStream) Close() error {
+	return s.body.Close()
+}
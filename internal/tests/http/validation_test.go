@@ -10,7 +10,7 @@ import (
 func TestCreateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -23,7 +23,7 @@ func TestCreateAd_TooLongTitle(t *testing.T) {
 
 	title := strings.Repeat("a", 101)
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -34,7 +34,7 @@ func TestCreateAd_TooLongTitle(t *testing.T) {
 func TestCreateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -47,7 +47,7 @@ func TestCreateAd_TooLongText(t *testing.T) {
 
 	text := strings.Repeat("a", 501)
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -58,7 +58,7 @@ func TestCreateAd_TooLongText(t *testing.T) {
 func TestUpdateAd_EmptyTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -72,7 +72,7 @@ func TestUpdateAd_EmptyTitle(t *testing.T) {
 func TestUpdateAd_TooLongTitle(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -88,7 +88,7 @@ func TestUpdateAd_TooLongTitle(t *testing.T) {
 func TestUpdateAd_EmptyText(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
@@ -102,7 +102,7 @@ func TestUpdateAd_EmptyText(t *testing.T) {
 func TestUpdateAd_TooLongText(t *testing.T) {
 	client := getTestClient()
 
-	user, err := client.createUser("qwertys", "@mail.ru")
+	user, err := client.createUser("qwertys", "qwertys@mail.ru")
 	assert.NoError(t, err)
 	userID := user.Data.ID
 
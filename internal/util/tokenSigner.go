@@ -0,0 +1,56 @@
+package util
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"homework10/internal/apperr"
+	"strings"
+)
+
+var ErrBadToken = apperr.New(apperr.InvalidArgument, "bad_token", "bad token")
+
+type TokenSigner interface {
+	Sign(payload []byte) string
+	Verify(token string) ([]byte, error)
+}
+
+type hmacTokenSigner struct {
+	secret []byte
+}
+
+// NewHMACTokenSigner подписывает токены HMAC-SHA256. Формат токена: base64url(payload).base64url(signature)
+func NewHMACTokenSigner(secret []byte) TokenSigner {
+	return &hmacTokenSigner{secret: secret}
+}
+
+func (h *hmacTokenSigner) Sign(payload []byte) string {
+	encoding := base64.RawURLEncoding
+	return encoding.EncodeToString(payload) + "." + encoding.EncodeToString(h.mac(payload))
+}
+
+func (h *hmacTokenSigner) Verify(token string) ([]byte, error) {
+	encoding := base64.RawURLEncoding
+	strPayload, strSignature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrBadToken
+	}
+	payload, err := encoding.DecodeString(strPayload)
+	if err != nil {
+		return nil, ErrBadToken
+	}
+	signature, err := encoding.DecodeString(strSignature)
+	if err != nil {
+		return nil, ErrBadToken
+	}
+	if !hmac.Equal(signature, h.mac(payload)) {
+		return nil, ErrBadToken
+	}
+	return payload, nil
+}
+
+func (h *hmacTokenSigner) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
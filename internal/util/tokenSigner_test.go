@@ -0,0 +1,35 @@
+package util
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestHMACTokenSigner_Verify(t *testing.T) {
+	signer := NewHMACTokenSigner([]byte("secret"))
+	payload := []byte(`{"user_id":1}`)
+
+	token := signer.Sign(payload)
+	act, err := signer.Verify(token)
+	assert.NoError(t, err)
+	assert.Equal(t, payload, act)
+}
+
+func TestHMACTokenSigner_Verify_WrongSecret(t *testing.T) {
+	signer := NewHMACTokenSigner([]byte("secret"))
+	other := NewHMACTokenSigner([]byte("other"))
+
+	token := other.Sign([]byte(`{"user_id":1}`))
+	_, err := signer.Verify(token)
+	assert.ErrorIs(t, err, ErrBadToken)
+}
+
+func TestHMACTokenSigner_Verify_WrongFormat(t *testing.T) {
+	signer := NewHMACTokenSigner([]byte("secret"))
+
+	_, err := signer.Verify("not a token")
+	assert.ErrorIs(t, err, ErrBadToken)
+
+	_, err = signer.Verify("e30.!!!")
+	assert.ErrorIs(t, err, ErrBadToken)
+}
@@ -1,13 +1,13 @@
 package util
 
 import (
-	"errors"
+	"homework10/internal/apperr"
 	"sync/atomic"
 )
 
-var ErrGenID = errors.New("id overflow or negative")
-var ErrClosed = errors.New("repository closed")
-var ErrNotFound = errors.New("ad not found")
+var ErrGenID = apperr.New(apperr.Internal, "id_overflow", "id overflow or negative")
+var ErrClosed = apperr.New(apperr.Unavailable, "repository_closed", "repository closed")
+var ErrNotFound = apperr.New(apperr.NotFound, "ad_not_found", "ad not found")
 
 type UID struct {
 	Id int64
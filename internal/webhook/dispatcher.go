@@ -0,0 +1,268 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"homework10/internal/events"
+	"homework10/internal/outbox"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTimeout   = 10 * time.Second
+	defaultQueueSize = 1000
+)
+
+// DefaultRetry паузы 1s, 2s, 4s ... до 10 минут, после 10 попыток событие уходит в dead letters
+var DefaultRetry = events.RetryPolicy{Attempts: 10, Backoff: time.Second, MaxBackoff: 10 * time.Minute}
+
+// Payload тело доставки
+type Payload struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher рассылает записи outbox подписчикам. У каждой подписки своя очередь ограниченного размера
+// и одна горутина доставки, пока в очереди есть события: события приходят подписчику по порядку,
+// а ошибка одного получателя не задерживает других
+type Dispatcher struct {
+	store     Store
+	client    *http.Client
+	retry     events.RetryPolicy
+	queueSize int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	// mutex защищает queues и closed: в очередь пишет только Handle, и только пока диспетчер не закрыт
+	mutex  sync.Mutex
+	queues map[int64]*queue
+	closed bool
+}
+
+// queue события одной подписки, ожидающие доставки
+type queue struct {
+	subID int64
+	items chan queued
+}
+
+// testHookQueueRemoved вызывается, когда горутина доставки убрала пустую очередь и завершается
+var testHookQueueRemoved = func() {}
+
+type queued struct {
+	entry outbox.Entry
+	body  []byte
+}
+
+type DispatcherOption func(*Dispatcher)
+
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+func WithRetry(policy events.RetryPolicy) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.retry = policy
+	}
+}
+
+// WithQueueSize сколько событий может ждать доставки в очереди одной подписки, по умолчанию defaultQueueSize
+func WithQueueSize(size int) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.queueSize = size
+	}
+}
+
+func NewDispatcher(store Store, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{store: store, client: &http.Client{Timeout: defaultTimeout}, retry: DefaultRetry,
+		queueSize: defaultQueueSize, queues: make(map[int64]*queue)}
+	for _, opt := range opts {
+		opt(d)
+	}
+	d.queueSize = max(d.queueSize, 1)
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	return d
+}
+
+// Handle обработчик для outbox.Relay: ставит событие в очередь каждой подписки на него.
+// Если хоть одна очередь заполнена, событие не ставится никуда и возвращается ErrQueueFull,
+// запись остается в outbox, и релей повторит ее позже
+func (d *Dispatcher) Handle(ctx context.Context, entry outbox.Entry) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.closed {
+		return ErrDispatcher
+	}
+	subs := d.store.Matching(entry.Event)
+	if len(subs) == 0 {
+		return nil
+	}
+	body, err := json.Marshal(Payload{ID: entry.ID, Event: entry.Event, CreatedAt: entry.CreatedAt, Data: entry.Payload})
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if q, ok := d.queues[sub.ID]; ok && len(q.items) == cap(q.items) {
+			return ErrQueueFull
+		}
+	}
+	for _, sub := range subs {
+		d.queue(sub.ID).items <- queued{entry: entry, body: body}
+	}
+	return nil
+}
+
+// queue очередь подписки, при необходимости создает ее и запускает доставку. Вызывается под блокировкой
+func (d *Dispatcher) queue(subID int64) *queue {
+	if q, ok := d.queues[subID]; ok {
+		return q
+	}
+	q := &queue{subID: subID, items: make(chan queued, d.queueSize)}
+	d.queues[subID] = q
+	d.wg.Add(1)
+	go d.work(q)
+	return q
+}
+
+// Close прерывает ожидание повторов и ждет, пока каждое событие из очередей будет отправлено хотя бы раз.
+// События, которые не удалось доставить с первой попытки, попадают в dead letters
+func (d *Dispatcher) Close() {
+	d.mutex.Lock()
+	d.closed = true
+	for _, q := range d.queues {
+		close(q.items)
+	}
+	d.mutex.Unlock()
+
+	d.cancel()
+	d.wg.Wait()
+}
+
+// work доставляет события очереди по одному, пока очередь не опустеет или подписку не удалят
+func (d *Dispatcher) work(q *queue) {
+	defer d.wg.Done()
+
+	for {
+		item, ok := d.next(q)
+		if !ok {
+			return
+		}
+		if !d.deliver(q.subID, item) {
+			// подписку удалили, оставшиеся события ей больше не нужны
+			d.remove(q)
+			return
+		}
+	}
+}
+
+// next следующее событие очереди. Пустая очередь удаляется под блокировкой, чтобы Handle
+// не поставил в нее событие, которое уже некому доставить
+func (d *Dispatcher) next(q *queue) (queued, bool) {
+	d.mutex.Lock()
+	if len(q.items) == 0 {
+		d.removeLocked(q)
+		d.mutex.Unlock()
+		testHookQueueRemoved()
+		return queued{}, false
+	}
+	d.mutex.Unlock()
+	// читает очередь только эта горутина, поэтому событие уже есть
+	item, ok := <-q.items
+	return item, ok
+}
+
+func (d *Dispatcher) remove(q *queue) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.removeLocked(q)
+}
+
+// removeLocked убирает очередь из queues, если ее еще не заменили новой. Вызывается под блокировкой
+func (d *Dispatcher) removeLocked(q *queue) {
+	if d.queues[q.subID] == q {
+		delete(d.queues, q.subID)
+	}
+}
+
+// deliver доставляет событие с повторами, false - подписка удалена
+func (d *Dispatcher) deliver(subID int64, item queued) bool {
+	attempts := max(d.retry.Attempts, 1)
+	var lastErr string
+	for attempt := 1; attempt <= attempts; attempt++ {
+		// подписку могли удалить или изменить, пока событие ждало в очереди или между попытками
+		sub, err := d.store.Get(subID)
+		if err != nil {
+			return false
+		}
+		delivery := d.send(sub, item.entry, item.body, attempt)
+		d.store.LogDelivery(subID, delivery)
+		if delivery.Succeeded() {
+			return true
+		}
+		lastErr = delivery.Error
+		if lastErr == "" {
+			lastErr = fmt.Sprintf("unexpected status %d", delivery.StatusCode)
+		}
+		if attempt == attempts {
+			break
+		}
+		timer := time.NewTimer(d.retry.Delay(attempt))
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			d.deadLetter(subID, item.entry, attempt, lastErr+": dispatcher stopped")
+			return true
+		case <-timer.C:
+		}
+	}
+	d.deadLetter(subID, item.entry, attempts, lastErr)
+	return true
+}
+
+func (d *Dispatcher) send(sub Subscription, entry outbox.Entry, body []byte, attempt int) Delivery {
+	delivery := Delivery{EventID: entry.ID, Event: entry.Event, Attempt: attempt, At: time.Now().UTC()}
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderID, entry.ID)
+	req.Header.Set(HeaderEvent, entry.Event)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, now, body))
+
+	resp, err := d.client.Do(req)
+	delivery.DurationMs = time.Since(now).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	delivery.StatusCode = resp.StatusCode
+	return delivery
+}
+
+func (d *Dispatcher) deadLetter(subID int64, entry outbox.Entry, attempts int, lastErr string) {
+	d.store.AddDeadLetter(subID, DeadLetter{
+		EventID:   entry.ID,
+		Event:     entry.Event,
+		Payload:   entry.Payload,
+		Attempts:  attempts,
+		LastError: lastErr,
+		FailedAt:  time.Now().UTC(),
+	})
+}
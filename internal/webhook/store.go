@@ -0,0 +1,175 @@
+package webhook
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// keepDeliveries столько последних попыток доставки хранится в журнале каждой подписки
+const keepDeliveries = 100
+
+// Delivery попытка доставки события получателю
+type Delivery struct {
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+	At         time.Time `json:"at"`
+}
+
+// Succeeded получатель ответил 2xx
+func (d Delivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
+
+// DeadLetter событие, которое не удалось доставить за все попытки
+type DeadLetter struct {
+	EventID   string          `json:"event_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"last_error"`
+	FailedAt  time.Time       `json:"failed_at"`
+}
+
+type Store interface {
+	Add(sub Subscription) (Subscription, error)
+	Get(id int64) (Subscription, error)
+	// List подписки в порядке ID
+	List() []Subscription
+	// Delete удаляет подписку вместе с журналом и списком недоставленных событий
+	Delete(id int64) error
+	// Matching подписки на событие
+	Matching(event string) []Subscription
+	// LogDelivery пишет попытку в журнал, для удаленной подписки ничего не делает
+	LogDelivery(subID int64, delivery Delivery)
+	// Deliveries журнал подписки, новые попытки первыми
+	Deliveries(subID int64) ([]Delivery, error)
+	AddDeadLetter(subID int64, letter DeadLetter)
+	DeadLetters(subID int64) ([]DeadLetter, error)
+}
+
+type subscriptionState struct {
+	sub         Subscription
+	deliveries  []Delivery
+	deadLetters []DeadLetter
+}
+
+type memStore struct {
+	mutex  sync.RWMutex
+	subs   map[int64]*subscriptionState
+	nextID int64
+}
+
+func NewStore() Store {
+	return &memStore{subs: make(map[int64]*subscriptionState)}
+}
+
+func (s *memStore) Add(sub Subscription) (Subscription, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sub.ID = s.nextID
+	sub.CreatedAt = time.Now().UTC()
+	s.nextID++
+	s.subs[sub.ID] = &subscriptionState{sub: sub}
+	return sub, nil
+}
+
+func (s *memStore) Get(id int64) (Subscription, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state, ok := s.subs[id]
+	if !ok {
+		return Subscription{}, ErrNotFound
+	}
+	return state.sub, nil
+}
+
+func (s *memStore) List() []Subscription {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	subs := make([]Subscription, 0, len(s.subs))
+	for _, state := range s.subs {
+		subs = append(subs, state.sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs
+}
+
+func (s *memStore) Delete(id int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.subs[id]; !ok {
+		return ErrNotFound
+	}
+	delete(s.subs, id)
+	return nil
+}
+
+func (s *memStore) Matching(event string) []Subscription {
+	subs := s.List()
+	matched := subs[:0]
+	for _, sub := range subs {
+		if sub.Matches(event) {
+			matched = append(matched, sub)
+		}
+	}
+	return matched
+}
+
+func (s *memStore) LogDelivery(subID int64, delivery Delivery) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state, ok := s.subs[subID]
+	if !ok {
+		return
+	}
+	state.deliveries = append(state.deliveries, delivery)
+	if len(state.deliveries) > keepDeliveries {
+		state.deliveries = state.deliveries[len(state.deliveries)-keepDeliveries:]
+	}
+}
+
+func (s *memStore) Deliveries(subID int64) ([]Delivery, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state, ok := s.subs[subID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	deliveries := make([]Delivery, 0, len(state.deliveries))
+	for i := len(state.deliveries) - 1; i >= 0; i-- {
+		deliveries = append(deliveries, state.deliveries[i])
+	}
+	return deliveries, nil
+}
+
+func (s *memStore) AddDeadLetter(subID int64, letter DeadLetter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if state, ok := s.subs[subID]; ok {
+		state.deadLetters = append(state.deadLetters, letter)
+	}
+}
+
+func (s *memStore) DeadLetters(subID int64) ([]DeadLetter, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	state, ok := s.subs[subID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]DeadLetter(nil), state.deadLetters...), nil
+}
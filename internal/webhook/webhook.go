@@ -0,0 +1,128 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"homework10/internal/apperr"
+	"homework10/internal/events"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrNotFound   = apperr.New(apperr.NotFound, "webhook_not_found", "webhook subscription not found")
+	ErrBadURL     = apperr.Field("url", "bad_webhook_url", "bad webhook url")
+	ErrBadEvents  = apperr.Field("events", "bad_webhook_events", "bad webhook event types")
+	ErrBadSecret  = apperr.Field("secret", "bad_webhook_secret", "bad webhook secret")
+	ErrDispatcher = apperr.New(apperr.Unavailable, "webhook_dispatcher_closed", "webhook dispatcher is closed")
+	ErrQueueFull  = apperr.New(apperr.Unavailable, "webhook_queue_full", "webhook subscription queue is full")
+)
+
+// Заголовки доставки
+const (
+	HeaderID        = "X-Webhook-Id"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+const (
+	signaturePrefix = "sha256="
+	minSecretLength = 16
+)
+
+// Events события, на которые можно подписаться. События пользователей наружу не отдаются:
+// в них персональные данные
+var Events = []string{
+	events.NameAdCreated,
+	events.NameAdUpdated,
+	events.NameAdStatusChanged,
+	events.NameAdDeleted,
+}
+
+// Subscription подписка партнера. Secret не отдается в списках, только при создании
+type Subscription struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Matches подписан ли получатель на событие
+func (s Subscription) Matches(event string) bool {
+	for _, name := range s.Events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSubscription проверяет параметры подписки. Пустой secret заменяется случайным
+func NewSubscription(rawURL string, secret string, eventTypes []string) (Subscription, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return Subscription{}, ErrBadURL
+	}
+	if len(eventTypes) == 0 {
+		return Subscription{}, ErrBadEvents
+	}
+	unique := make([]string, 0, len(eventTypes))
+	seen := make(map[string]struct{}, len(eventTypes))
+	for _, name := range eventTypes {
+		if !isKnownEvent(name) {
+			return Subscription{}, ErrBadEvents
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		unique = append(unique, name)
+	}
+	if secret == "" {
+		secret = newSecret()
+	}
+	if len(secret) < minSecretLength {
+		return Subscription{}, ErrBadSecret
+	}
+	return Subscription{URL: parsed.String(), Secret: secret, Events: unique}, nil
+}
+
+// Sign подпись тела доставки: hex(HMAC-SHA256(secret, timestamp + "." + body)).
+// Получатель считает ее так же и сравнивает с заголовком X-Webhook-Signature
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify проверяет заголовки доставки, для получателей и тестов
+func Verify(secret string, timestamp string, signature string, body []byte) bool {
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	expected := Sign(secret, time.Unix(unix, 0), body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+func isKnownEvent(name string) bool {
+	for _, known := range Events {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+func newSecret() string {
+	secret := make([]byte, 24)
+	_, _ = rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
@@ -0,0 +1,309 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"homework10/internal/entities"
+	"homework10/internal/events"
+	"homework10/internal/outbox"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testSecret = "0123456789abcdef"
+
+var fastRetry = events.RetryPolicy{Attempts: 3, Backoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+// receiver httptest сервер партнера, отвечает статусами из statuses по очереди, затем 200
+type receiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newReceiver(statuses ...int) *receiver {
+	r := &receiver{statuses: statuses}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mutex.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := http.StatusOK
+		if len(r.statuses) > 0 {
+			status, r.statuses = r.statuses[0], r.statuses[1:]
+		}
+		r.mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	return r
+}
+
+func (r *receiver) received() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.requests)
+}
+
+// waitDeliveries ждет, пока в журнале подписки появится n попыток
+func waitDeliveries(t *testing.T, store Store, subID int64, n int) []Delivery {
+	var deliveries []Delivery
+	assert.Eventually(t, func() bool {
+		deliveries, _ = store.Deliveries(subID)
+		return len(deliveries) == n
+	}, time.Second, time.Millisecond)
+	return deliveries
+}
+
+func newEntry(t *testing.T, event events.Event) outbox.Entry {
+	store := outbox.NewStore()
+	store.Record([]events.Event{event})
+	due := store.Due(time.Now().UTC(), 1)
+	assert.Len(t, due, 1)
+	return due[0]
+}
+
+func subscribe(t *testing.T, store Store, url string, eventTypes ...string) Subscription {
+	sub, err := NewSubscription(url, testSecret, eventTypes)
+	assert.NoError(t, err)
+	sub, err = store.Add(sub)
+	assert.NoError(t, err)
+	return sub
+}
+
+func TestNewSubscription(t *testing.T) {
+	sub, err := NewSubscription("https://partner.example/hook", "", []string{events.NameAdCreated, events.NameAdCreated})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{events.NameAdCreated}, sub.Events)
+	assert.GreaterOrEqual(t, len(sub.Secret), minSecretLength)
+
+	_, err = NewSubscription("ftp://partner.example", testSecret, []string{events.NameAdCreated})
+	assert.ErrorIs(t, err, ErrBadURL)
+	_, err = NewSubscription("/hook", testSecret, []string{events.NameAdCreated})
+	assert.ErrorIs(t, err, ErrBadURL)
+	_, err = NewSubscription("https://partner.example", testSecret, nil)
+	assert.ErrorIs(t, err, ErrBadEvents)
+	_, err = NewSubscription("https://partner.example", testSecret, []string{events.NameUserCreated})
+	assert.ErrorIs(t, err, ErrBadEvents)
+	_, err = NewSubscription("https://partner.example", "short", []string{events.NameAdCreated})
+	assert.ErrorIs(t, err, ErrBadSecret)
+}
+
+func TestDispatcher_SignedDelivery(t *testing.T) {
+	recv := newReceiver()
+	defer recv.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdCreated)
+	other := newReceiver()
+	defer other.Close()
+	subscribe(t, store, other.URL, events.NameAdDeleted)
+
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry))
+	entry := newEntry(t, events.AdCreated{Ad: entities.Ad{ID: 7, Title: "hello"}})
+	assert.NoError(t, dispatcher.Handle(context.Background(), entry))
+	dispatcher.Close()
+
+	assert.Equal(t, 1, recv.received())
+	assert.Equal(t, 0, other.received())
+	req, body := recv.requests[0], recv.bodies[0]
+	assert.Equal(t, entry.ID, req.Header.Get(HeaderID))
+	assert.Equal(t, events.NameAdCreated, req.Header.Get(HeaderEvent))
+	assert.True(t, Verify(testSecret, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body))
+	assert.False(t, Verify("another secret!!", req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body))
+
+	var payload Payload
+	assert.NoError(t, json.Unmarshal(body, &payload))
+	assert.Equal(t, entry.ID, payload.ID)
+	assert.Equal(t, events.NameAdCreated, payload.Event)
+	assert.JSONEq(t, string(entry.Payload), string(payload.Data))
+
+	deliveries, err := store.Deliveries(sub.ID)
+	assert.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].Succeeded())
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	recv := newReceiver(http.StatusInternalServerError, http.StatusServiceUnavailable)
+	defer recv.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdDeleted)
+
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry))
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdDeleted{})))
+	deliveries := waitDeliveries(t, store, sub.ID, 3)
+	dispatcher.Close()
+
+	assert.Equal(t, 3, recv.received())
+	assert.Len(t, deliveries, 3)
+	assert.True(t, deliveries[0].Succeeded())
+	assert.Equal(t, 3, deliveries[0].Attempt)
+	assert.Equal(t, http.StatusServiceUnavailable, deliveries[1].StatusCode)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[2].StatusCode)
+	letters, err := store.DeadLetters(sub.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, letters)
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	recv := newReceiver(http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+	defer recv.Close()
+	healthy := newReceiver()
+	defer healthy.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdUpdated)
+	healthySub := subscribe(t, store, healthy.URL, events.NameAdUpdated)
+
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry))
+	entry := newEntry(t, events.AdUpdated{})
+	assert.NoError(t, dispatcher.Handle(context.Background(), entry))
+	waitDeliveries(t, store, sub.ID, 3)
+	dispatcher.Close()
+
+	letters, err := store.DeadLetters(sub.ID)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Equal(t, entry.ID, letters[0].EventID)
+	assert.Equal(t, 3, letters[0].Attempts)
+	assert.Contains(t, letters[0].LastError, "502")
+
+	healthyLetters, err := store.DeadLetters(healthySub.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, healthyLetters)
+	assert.Equal(t, 1, healthy.received())
+}
+
+func TestDispatcher_Closed(t *testing.T) {
+	recv := newReceiver(http.StatusInternalServerError)
+	defer recv.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdCreated)
+
+	dispatcher := NewDispatcher(store, WithRetry(events.RetryPolicy{Attempts: 5, Backoff: time.Hour}))
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	waitDeliveries(t, store, sub.ID, 1)
+	dispatcher.Close()
+
+	letters, err := store.DeadLetters(sub.ID)
+	assert.NoError(t, err)
+	assert.Len(t, letters, 1)
+	assert.Contains(t, letters[0].LastError, "dispatcher stopped")
+	assert.ErrorIs(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})), ErrDispatcher)
+}
+
+func TestDispatcher_Order(t *testing.T) {
+	// первое событие доставляется с третьей попытки, второе ждет его в очереди
+	recv := newReceiver(http.StatusInternalServerError, http.StatusInternalServerError)
+	defer recv.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdCreated, events.NameAdDeleted)
+
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry))
+	first, second := newEntry(t, events.AdCreated{}), newEntry(t, events.AdDeleted{})
+	assert.NoError(t, dispatcher.Handle(context.Background(), first))
+	assert.NoError(t, dispatcher.Handle(context.Background(), second))
+	waitDeliveries(t, store, sub.ID, 4)
+	dispatcher.Close()
+
+	for i, want := range []string{events.NameAdCreated, events.NameAdCreated, events.NameAdCreated, events.NameAdDeleted} {
+		assert.Equal(t, want, recv.requests[i].Header.Get(HeaderEvent))
+	}
+}
+
+func TestDispatcher_DeletedSubscription(t *testing.T) {
+	recv := newReceiver(http.StatusInternalServerError, http.StatusInternalServerError)
+	defer recv.Close()
+	store := NewStore()
+	sub := subscribe(t, store, recv.URL, events.NameAdCreated)
+
+	dispatcher := NewDispatcher(store, WithRetry(events.RetryPolicy{Attempts: 3, Backoff: 50 * time.Millisecond}))
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	waitDeliveries(t, store, sub.ID, 1)
+	assert.NoError(t, store.Delete(sub.ID))
+	// повторов и следующих событий удаленной подписке нет
+	assert.Eventually(t, func() bool {
+		dispatcher.mutex.Lock()
+		defer dispatcher.mutex.Unlock()
+		return len(dispatcher.queues) == 0
+	}, time.Second, time.Millisecond)
+	dispatcher.Close()
+	assert.Equal(t, 1, recv.received())
+}
+
+func TestDispatcher_HandleWhileWorkerExits(t *testing.T) {
+	recv := newReceiver()
+	defer recv.Close()
+	store := NewStore()
+	subscribe(t, store, recv.URL, events.NameAdCreated)
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry))
+
+	// событие приходит, пока горутина доставки опустевшей очереди завершается
+	var once sync.Once
+	testHookQueueRemoved = func() {
+		once.Do(func() {
+			assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+		})
+	}
+	defer func() { testHookQueueRemoved = func() {} }()
+
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	assert.Eventually(t, func() bool {
+		return recv.received() == 2
+	}, time.Second, time.Millisecond)
+	dispatcher.Close()
+	assert.Equal(t, 2, recv.received())
+}
+
+func TestDispatcher_QueueFull(t *testing.T) {
+	release := make(chan struct{})
+	blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-release
+	}))
+	defer blocked.Close()
+	recv := newReceiver()
+	defer recv.Close()
+	store := NewStore()
+	subscribe(t, store, blocked.URL, events.NameAdCreated)
+	other := subscribe(t, store, recv.URL, events.NameAdCreated)
+
+	dispatcher := NewDispatcher(store, WithRetry(fastRetry), WithQueueSize(1))
+	// первое событие отправляется, второе ждет в очереди, третьему места нет
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	assert.Eventually(t, func() bool {
+		dispatcher.mutex.Lock()
+		defer dispatcher.mutex.Unlock()
+		for _, q := range dispatcher.queues {
+			if len(q.items) > 0 {
+				return false
+			}
+		}
+		return true
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})))
+	assert.ErrorIs(t, dispatcher.Handle(context.Background(), newEntry(t, events.AdCreated{})), ErrQueueFull)
+
+	// событие не ставится ни в одну очередь, иначе после повтора релея другая подписка получила бы его дважды
+	close(release)
+	waitDeliveries(t, store, other.ID, 2)
+	dispatcher.Close()
+	assert.Equal(t, 2, recv.received())
+}
+
+func TestStore_Delete(t *testing.T) {
+	store := NewStore()
+	sub := subscribe(t, store, "http://partner.example", events.NameAdCreated)
+	assert.Len(t, store.List(), 1)
+
+	assert.NoError(t, store.Delete(sub.ID))
+	assert.ErrorIs(t, store.Delete(sub.ID), ErrNotFound)
+	_, err := store.Deliveries(sub.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.Empty(t, store.Matching(events.NameAdCreated))
+}